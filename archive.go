@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// exitArchiveFailed is the exit code used when the split succeeded but archiving the source failed.
+const exitArchiveFailed = 3
+
+// archiveLogName is the name of the log file appended to in the archive directory.
+const archiveLogName = "archive.log"
+
+// archiveSource moves the source file into the archive directory and records it in archive.log.
+// Moves across filesystems are done by copying, verifying the copy's hash and deleting the original.
+// The source is never deleted unless an identical copy exists in the archive.
+// Parameters:
+//   - sourcePath: path of the split input file
+//   - archiveDir: directory to move the input into
+//   - outputLocation: where the chapters of the input were written
+//
+// Returns:
+//   - error: if the source could not be archived
+func archiveSource(sourcePath, archiveDir, outputLocation string) error {
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return fmt.Errorf("create archive directory: %w", err)
+	}
+	absSource, err := filepath.Abs(sourcePath)
+	if err != nil {
+		return err
+	}
+	absOutput, err := filepath.Abs(outputLocation)
+	if err != nil {
+		return err
+	}
+
+	// Hash the source before it is moved
+	sum, err := fileSHA256(sourcePath)
+	if err != nil {
+		return fmt.Errorf("hash source: %w", err)
+	}
+
+	// Never overwrite a previously archived file
+	target := filepath.Join(archiveDir, filepath.Base(sourcePath))
+	if _, err := os.Stat(target); err == nil {
+		return fmt.Errorf("archive already contains '%s'", target)
+	}
+
+	// Try a plain rename first, then fall back to copy, verify and delete
+	if err := runFS.Rename(sourcePath, target); err != nil {
+		if err := copyVerified(sourcePath, target, sum); err != nil {
+			return err
+		}
+		if err := runFS.Remove(sourcePath); err != nil {
+			return fmt.Errorf("remove source after copying: %w", err)
+		}
+	}
+
+	// Record the archived file
+	logFile, err := os.OpenFile(filepath.Join(archiveDir, archiveLogName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", archiveLogName, err)
+	}
+	defer logFile.Close()
+	line := fmt.Sprintf("%s\t%s\t%s\t%s\n", runClock.Now().Format(time.RFC3339), sum, absSource, absOutput)
+	if _, err = logFile.WriteString(line); err != nil {
+		return fmt.Errorf("write %s: %w", archiveLogName, err)
+	}
+	printMsg("archived_source", target)
+	return nil
+}
+
+// copyVerified copies src to dst and checks that the copy has the expected SHA-256.
+// A copy that fails verification is removed.
+func copyVerified(src, dst, wantSum string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("create archive copy: %w", err)
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return fmt.Errorf("copy source to archive: %w", err)
+	}
+	if err = out.Close(); err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("copy source to archive: %w", err)
+	}
+
+	// Only trust the copy if it is byte-identical
+	gotSum, err := fileSHA256(dst)
+	if err != nil || gotSum != wantSum {
+		os.Remove(dst)
+		return fmt.Errorf("archive copy of '%s' failed verification", src)
+	}
+	return nil
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 of a file's content.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
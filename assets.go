@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Supported values of the --extract flag.
+const (
+	assetText   = "text"
+	assetImages = "images"
+)
+
+// assetKinds lists the --extract values in the order they are extracted.
+var assetKinds = []string{assetText, assetImages}
+
+// extractAssets holds the validated --extract values.
+var extractAssets []string
+
+// chapterAssets are the files extracted next to a chapter file, empty if not extracted.
+// text is the path of the text file, images the directory of the images.
+type chapterAssets struct {
+	text   string
+	images string
+}
+
+// parseAssetList validates the comma-separated values of --extract.
+func parseAssetList(values []string) ([]string, error) {
+	var kinds []string
+	for _, value := range values {
+		kind := strings.ToLower(strings.TrimSpace(value))
+		if !slices.Contains(assetKinds, kind) {
+			return nil, fmt.Errorf("invalid --extract value '%s': must be %s or %s", value, assetText, assetImages)
+		}
+		if !slices.Contains(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds, nil
+}
+
+// assetSource is the source the assets of all chapters of an export are taken from.
+type assetSource struct {
+	inputFile *os.File
+}
+
+// extractChapterAssets writes the --extract assets of a chapter next to its file: the text of its
+// pages as <stem>.txt, one page per form feed, and its images into <stem>_images/.
+// The assets are taken from the chapter's page range of the source. A failing asset is reported
+// with a warning and the split carries on.
+// Parameters:
+//   - src: the source, shared by all chapters of the export
+//   - cpt: the chapter
+//   - outputFilePath: path of the chapter file
+//
+// Returns:
+//   - chapterAssets: the assets written
+func extractChapterAssets(src *assetSource, cpt chapter, outputFilePath string) chapterAssets {
+	var assets chapterAssets
+	stem := strings.TrimSuffix(outputFilePath, filepath.Ext(outputFilePath))
+	for _, kind := range extractAssets {
+		var err error
+		switch kind {
+		case assetText:
+			path := stem + ".txt"
+			if err = src.writeText(cpt, path); err == nil {
+				assets.text = path
+			}
+		case assetImages:
+			dir := stem + "_images"
+			if err = src.writeImages(cpt, dir); err == nil {
+				assets.images = dir
+			}
+		}
+		if err != nil {
+			warnMsg("assets_failed", kind, cpt.title, err)
+		}
+	}
+	return assets
+}
+
+// writeText writes the text of the chapter's pages to path.
+func (s *assetSource) writeText(cpt chapter, path string) error {
+	var pages []string
+	err := inspectSource(s.inputFile, func(ctx *model.Context) error {
+		for _, span := range cpt.pageSpans() {
+			for page := span.start; page <= span.end; page++ {
+				pages = append(pages, pageText(ctx, int(page)))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	outputFile, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	if _, err = outputFile.WriteString(strings.Join(pages, "\n\f") + "\n"); err != nil {
+		outputFile.discard()
+		return err
+	}
+	return outputFile.commit()
+}
+
+// writeImages writes the images of the chapter's pages into dir, named by pdfcpu after the
+// source page and the image resource, e.g. page_012_Im0.png.
+func (s *assetSource) writeImages(cpt chapter, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	selection := strings.Split(chapterPageRange(cpt), ",")
+	return api.ExtractImages(s.inputFile, selection, pdfcpu.WriteImageToDisk(dir, "page"), sourceConfiguration())
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// pdfHeaderWindow is how far into an attachment the %PDF- header is searched,
+// matching the tolerance of common PDF readers.
+const pdfHeaderWindow = 1024
+
+// processAttachedPDFs splits every PDF file embedded in the source, as if it had been given
+// as an input, into a subdirectory of dir named after the attachment. Attachments without an
+// outline or sidecar are copied unchanged, and attachments that are not PDF files are skipped.
+// Parameters:
+//   - inputFile: pointer to the wrapper PDF file
+//   - dir: output directory of the wrapper
+//
+// Returns:
+//   - error: if the attachments cannot be read, or an attached PDF cannot be split or copied
+func processAttachedPDFs(inputFile *os.File, dir string) error {
+	attachments, err := api.ExtractAttachmentsRaw(inputFile, "", nil, sourceConfiguration())
+	if err != nil {
+		return fmt.Errorf("failed to read attachments: %w", err)
+	}
+	if len(attachments) == 0 {
+		printMsg("no_attachments")
+		return nil
+	}
+
+	// Process in name order so that colliding names are numbered the same way on every run
+	sort.SliceStable(attachments, func(i, j int) bool { return attachments[i].FileName < attachments[j].FileName })
+
+	tmpDir, err := os.MkdirTemp("", "pdf-split-attachments")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	used := make(map[string]bool)
+	for _, a := range attachments {
+		data, err := io.ReadAll(a)
+		if err != nil {
+			return fmt.Errorf("failed to read attachment '%s': %w", a.FileName, err)
+		}
+		if !bytes.Contains(data[:min(len(data), pdfHeaderWindow)], []byte("%PDF-")) {
+			printMsg("attachment_not_pdf", a.FileName)
+			continue
+		}
+
+		// Give attachments sharing a name distinct subdirectories
+		base := sanitizeFilename(strings.TrimSuffix(filepath.Base(a.FileName), filepath.Ext(a.FileName)))
+		if base == "" {
+			base = "attachment"
+		}
+		name := base
+		for i := 2; used[strings.ToLower(name)]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		used[strings.ToLower(name)] = true
+
+		// Split a temporary copy like a regular input
+		path := filepath.Join(tmpDir, name+".pdf")
+		if err = os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to extract attachment '%s': %w", a.FileName, err)
+		}
+		subdir := filepath.Join(dir, name)
+		printMsg("processing_attachment", a.FileName, inputFile.Name(), subdir)
+		if err = splitAttachment(path, subdir); err != nil {
+			return fmt.Errorf("failed to split attachment '%s': %w", a.FileName, err)
+		}
+	}
+	return nil
+}
+
+// splitAttachment splits an extracted attachment into dir, or copies it there if it has no chapters.
+func splitAttachment(path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open attachment %s: %w", path, err)
+	}
+	defer f.Close()
+	defer releaseSourceDocument(f)
+
+	if hasChapterSource(f) {
+		chapters, _, err := extractChapters(f, "")
+		if err != nil {
+			return err
+		}
+		return processChapters(f, chapters, dir)
+	}
+	if noOutput || dryRun != "" {
+		printMsg("attachment_would_copy", filepath.Join(dir, filepath.Base(path)))
+		return nil
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("fail to create output directory: %w", err)
+	}
+	target := filepath.Join(dir, filepath.Base(path))
+	if err = copyFile(path, target); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", target, err)
+	}
+	printMsg("attachment_copied", target)
+	return nil
+}
+
+// hasChapterSource reports whether chapters can be planned for a document,
+// either from a chapter sidecar or from a non-empty outline.
+func hasChapterSource(inputFile *os.File) bool {
+	if findSidecar(inputFile.Name(), sidecarSuffix) != "" {
+		return true
+	}
+	doc, err := sourceDocument(inputFile)
+	if err != nil {
+		return false
+	}
+	bookmarks, err := doc.Bookmarks()
+	return err == nil && len(bookmarks) > 0
+}
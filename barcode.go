@@ -0,0 +1,569 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	_ "image/png"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/souhup/pdf-spliter/ranges"
+	_ "golang.org/x/image/tiff"
+)
+
+// defaultBarcodeConfidence is the share of readable scan lines that must agree on the value of a
+// separator barcode.
+const defaultBarcodeConfidence = 0.5
+
+// defaultLeadingName is the title of the pages before the first separator page.
+const defaultLeadingName = "leading"
+
+// barcodeScanLines is the number of rows, and of columns, an image is read along.
+const barcodeScanLines = 32
+
+// barcodeMinContrast is the gray level difference below which a scan line is taken to be empty.
+const barcodeMinContrast = 48
+
+// Pattern matching tolerances, relative to the module width: the average deviation of a whole
+// symbol and the deviation of a single bar or space.
+const (
+	barcodeMaxVariance           = 0.25
+	barcodeMaxIndividualVariance = 0.7
+)
+
+// barcodePages is the parsed --barcode-pages selection, nil for all pages or with --pages-as
+// labels, which pageSelection resolves for every input.
+var barcodePages ranges.List
+
+// barcodeRead is the result of scanning a page for a separator barcode.
+// lines is the number of scan lines that held a complete barcode, votes the number of them that
+// decoded to value. For a QR code, see readQRCode.
+type barcodeRead struct {
+	value string
+	votes int
+	lines int
+	qr    bool
+}
+
+// confidence is the share of scan lines with a complete barcode that read value, or the share
+// of the error correction of a QR code left unused.
+func (r barcodeRead) confidence() float64 {
+	if r.lines == 0 {
+		return 0
+	}
+	return float64(r.votes) / float64(r.lines)
+}
+
+// barcodeChapters plans one document per separator page of a scanned batch: a page with an image
+// holding a Code 128 or Code 39 barcode or a QR code read with at least --barcode-confidence. Each document is
+// titled with the barcode value and runs from the page after its separator to the page before
+// the next one; the separator pages are not part of any document. Pages before the first
+// separator become an extra document titled --barcode-leading-name, numbered 0.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//
+// Returns:
+//   - []chapter: the documents in page order, nil if no separator page was found
+//   - error: if the pages cannot be read, a page label of --barcode-pages cannot be resolved or
+//     every separator is empty
+func barcodeChapters(inputFile *os.File) ([]chapter, error) {
+	pageCount, err := sourcePageCount(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	if truncateAtPage > 0 && truncateAtPage < pageCount {
+		pageCount = truncateAtPage
+	}
+	searched, err := pageSelection(inputFile, "barcode-pages", barcodePagesText, barcodePages)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the separator pages
+	type separator struct {
+		page int
+		read barcodeRead
+	}
+	var separators []separator
+	err = inspectSource(inputFile, func(ctx *model.Context) error {
+		for page := 1; page <= pageCount; page++ {
+			if searched != nil && !searched.Contains(page) {
+				continue
+			}
+			read := pageBarcode(ctx, page)
+			switch {
+			case read.lines == 0:
+				continue
+			case read.value == "" && read.qr:
+				if verbose {
+					printMsg("qr_unreadable", page)
+				}
+				continue
+			case read.value == "":
+				if verbose {
+					printMsg("barcode_unreadable", page, read.lines)
+				}
+				continue
+			case read.confidence() < barcodeConfidence:
+				if verbose {
+					printMsg("barcode_rejected", page, read.value, read.confidence(), barcodeConfidence)
+				}
+				continue
+			}
+			if verbose {
+				printMsg("barcode_found", page, read.value, read.confidence())
+			}
+			separators = append(separators, separator{page, read})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(separators) == 0 {
+		return nil, nil
+	}
+	if err = checkChapterLimit(len(separators) + 1); err != nil {
+		return nil, err
+	}
+
+	// Every document ends on the page before the next separator
+	var chapters []chapter
+	for i, sep := range separators {
+		end := pageCount
+		if i+1 < len(separators) {
+			end = separators[i+1].page - 1
+		}
+		if sep.page >= end {
+			warnMsg("barcode_empty", sep.read.value, sep.page)
+			continue
+		}
+		cpt := chapter{title: sep.read.value, order: uint32(len(chapters) + 1), startPage: uint32(sep.page + 1), endPage: uint32(end)}
+		cpt.explain("explain_barcode", sep.read.value, sep.page, sep.read.confidence())
+		chapters = append(chapters, cpt)
+	}
+
+	// Keep the pages before the first separator
+	if first := separators[0].page; first > 1 {
+		leading := chapter{title: barcodeLeadingName, frontMatter: true, startPage: 1, endPage: uint32(first - 1)}
+		leading.explain("explain_barcode_leading", first)
+		chapters = append([]chapter{leading}, chapters...)
+	}
+	if len(chapters) == 0 {
+		return nil, errors.New(msg("all_barcodes_empty", len(separators)))
+	}
+	return chapters, nil
+}
+
+// pageBarcode reads the images of a page and returns the barcode read with the most votes.
+// Images that pdfcpu cannot render, such as stencil masks, are skipped.
+func pageBarcode(ctx *model.Context, pageNr int) barcodeRead {
+	images, err := pdfcpu.ExtractPageImages(ctx, pageNr, false)
+	if err != nil {
+		return barcodeRead{}
+	}
+
+	// Read in object order so runs are reproducible
+	objNrs := make([]int, 0, len(images))
+	for objNr := range images {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	var best barcodeRead
+	for _, objNr := range objNrs {
+		if images[objNr].Reader == nil {
+			continue
+		}
+		img, _, err := image.Decode(images[objNr].Reader)
+		if err != nil {
+			continue
+		}
+		read := scanImage(img)
+		if read.votes > best.votes || read.votes == best.votes && read.lines > best.lines {
+			best = read
+		}
+	}
+	return best
+}
+
+// scanImage reads an image along evenly spaced rows and columns, both ways, so that barcodes
+// are found in any of the four orientations of a scan. The value read by most lines wins. An
+// image without a readable barcode is searched for a QR code.
+func scanImage(img image.Image) barcodeRead {
+	bounds := img.Bounds()
+	votes := make(map[string]int)
+	var lines int
+	scan := func(samples []uint8) {
+		value, complete := readScanLine(samples)
+		if !complete {
+			return
+		}
+		lines++
+		if value != "" {
+			votes[value]++
+		}
+	}
+	for i := 1; i <= barcodeScanLines; i++ {
+		y := bounds.Min.Y + bounds.Dy()*i/(barcodeScanLines+1)
+		row := make([]uint8, bounds.Dx())
+		for x := range row {
+			row[x] = color.GrayModel.Convert(img.At(bounds.Min.X+x, y)).(color.Gray).Y
+		}
+		scan(row)
+
+		x := bounds.Min.X + bounds.Dx()*i/(barcodeScanLines+1)
+		column := make([]uint8, bounds.Dy())
+		for y := range column {
+			column[y] = color.GrayModel.Convert(img.At(x, bounds.Min.Y+y)).(color.Gray).Y
+		}
+		scan(column)
+	}
+
+	read := barcodeRead{lines: lines}
+	for value, n := range votes {
+		if n > read.votes || n == read.votes && value < read.value {
+			read.value, read.votes = value, n
+		}
+	}
+	if read.votes == 0 {
+		if qr := readQRCode(img); qr.lines > 0 {
+			return qr
+		}
+	}
+	return read
+}
+
+// readScanLine decodes a barcode on a line of gray samples, read in both directions. A line that
+// cannot be decoded is read again with specks of single pixels removed, as left by noisy scans.
+// Returns:
+//   - string: the decoded value, empty if none could be decoded
+//   - bool: whether the line holds a complete barcode, with start and stop patterns,
+//     whether or not it could be decoded
+func readScanLine(samples []uint8) (string, bool) {
+	value, complete := readRuns(scanRuns(samples))
+	if value != "" {
+		return value, true
+	}
+	value, despeckled := readRuns(scanRuns(despeckle(samples)))
+	return value, complete || despeckled
+}
+
+// despeckle returns the samples with every sample replaced by the median of itself and its
+// two neighbors.
+func despeckle(samples []uint8) []uint8 {
+	filtered := append([]uint8(nil), samples...)
+	for i := 1; i+1 < len(samples); i++ {
+		a, b, c := samples[i-1], samples[i], samples[i+1]
+		filtered[i] = max(min(a, b), min(max(a, b), c))
+	}
+	return filtered
+}
+
+// readRuns decodes a barcode on the runs of a scan line, read in both directions.
+func readRuns(runs []int) (string, bool) {
+	if runs == nil {
+		return "", false
+	}
+	reversed := make([]int, 0, len(runs)+1)
+	for i := len(runs) - 1; i >= 0; i-- {
+		reversed = append(reversed, runs[i])
+	}
+	// The reversed runs must again start with a space
+	if len(runs)%2 == 0 {
+		reversed = append([]int{0}, reversed...)
+	}
+
+	var complete bool
+	for _, r := range [][]int{runs, reversed} {
+		for _, decode := range []func([]int) (string, bool){decodeCode128, decodeCode39} {
+			value, found := decode(r)
+			if value != "" {
+				return value, true
+			}
+			complete = complete || found
+		}
+	}
+	return "", complete
+}
+
+// scanRuns binarizes a line of samples at the middle of its gray levels and returns the widths
+// of its alternating light and dark runs, starting with a possibly empty light run.
+// A line without enough contrast has no runs.
+func scanRuns(samples []uint8) []int {
+	if len(samples) == 0 {
+		return nil
+	}
+	lo, hi := samples[0], samples[0]
+	for _, s := range samples {
+		lo, hi = min(lo, s), max(hi, s)
+	}
+	if int(hi)-int(lo) < barcodeMinContrast {
+		return nil
+	}
+	threshold := (int(lo) + int(hi)) / 2
+
+	runs := []int{0}
+	dark := false
+	for _, s := range samples {
+		if (int(s) < threshold) != dark {
+			dark = !dark
+			runs = append(runs, 0)
+		}
+		runs[len(runs)-1]++
+	}
+	return runs
+}
+
+// patternVariance compares the widths of a run of bars and spaces with a pattern of module
+// widths and returns their average deviation relative to the module width, or +Inf if a single
+// bar or space deviates too much.
+func patternVariance(counters []int, pattern []int) float64 {
+	var total, modules int
+	for i, c := range counters {
+		total += c
+		modules += pattern[i]
+	}
+	if total < modules {
+		return math.Inf(1)
+	}
+	unit := float64(total) / float64(modules)
+	var variance float64
+	for i, c := range counters {
+		v := math.Abs(float64(c) - float64(pattern[i])*unit)
+		if v > barcodeMaxIndividualVariance*unit {
+			return math.Inf(1)
+		}
+		variance += v
+	}
+	return variance / float64(total)
+}
+
+// runSum returns the total width of runs.
+func runSum(runs []int) int {
+	var total int
+	for _, r := range runs {
+		total += r
+	}
+	return total
+}
+
+// code128Patterns are the module widths of the Code 128 symbols 0 to 105, bar first.
+var code128Patterns = [][]int{
+	{2, 1, 2, 2, 2, 2}, {2, 2, 2, 1, 2, 2}, {2, 2, 2, 2, 2, 1}, {1, 2, 1, 2, 2, 3}, {1, 2, 1, 3, 2, 2},
+	{1, 3, 1, 2, 2, 2}, {1, 2, 2, 2, 1, 3}, {1, 2, 2, 3, 1, 2}, {1, 3, 2, 2, 1, 2}, {2, 2, 1, 2, 1, 3},
+	{2, 2, 1, 3, 1, 2}, {2, 3, 1, 2, 1, 2}, {1, 1, 2, 2, 3, 2}, {1, 2, 2, 1, 3, 2}, {1, 2, 2, 2, 3, 1},
+	{1, 1, 3, 2, 2, 2}, {1, 2, 3, 1, 2, 2}, {1, 2, 3, 2, 2, 1}, {2, 2, 3, 2, 1, 1}, {2, 2, 1, 1, 3, 2},
+	{2, 2, 1, 2, 3, 1}, {2, 1, 3, 2, 1, 2}, {2, 2, 3, 1, 1, 2}, {3, 1, 2, 1, 3, 1}, {3, 1, 1, 2, 2, 2},
+	{3, 2, 1, 1, 2, 2}, {3, 2, 1, 2, 2, 1}, {3, 1, 2, 2, 1, 2}, {3, 2, 2, 1, 1, 2}, {3, 2, 2, 2, 1, 1},
+	{2, 1, 2, 1, 2, 3}, {2, 1, 2, 3, 2, 1}, {2, 3, 2, 1, 2, 1}, {1, 1, 1, 3, 2, 3}, {1, 3, 1, 1, 2, 3},
+	{1, 3, 1, 3, 2, 1}, {1, 1, 2, 3, 1, 3}, {1, 3, 2, 1, 1, 3}, {1, 3, 2, 3, 1, 1}, {2, 1, 1, 3, 1, 3},
+	{2, 3, 1, 1, 1, 3}, {2, 3, 1, 3, 1, 1}, {1, 1, 2, 1, 3, 3}, {1, 1, 2, 3, 3, 1}, {1, 3, 2, 1, 3, 1},
+	{1, 1, 3, 1, 2, 3}, {1, 1, 3, 3, 2, 1}, {1, 3, 3, 1, 2, 1}, {3, 1, 3, 1, 2, 1}, {2, 1, 1, 3, 3, 1},
+	{2, 3, 1, 1, 3, 1}, {2, 1, 3, 1, 1, 3}, {2, 1, 3, 3, 1, 1}, {2, 1, 3, 1, 3, 1}, {3, 1, 1, 1, 2, 3},
+	{3, 1, 1, 3, 2, 1}, {3, 3, 1, 1, 2, 1}, {3, 1, 2, 1, 1, 3}, {3, 1, 2, 3, 1, 1}, {3, 3, 2, 1, 1, 1},
+	{3, 1, 4, 1, 1, 1}, {2, 2, 1, 4, 1, 1}, {4, 3, 1, 1, 1, 1}, {1, 1, 1, 2, 2, 4}, {1, 1, 1, 4, 2, 2},
+	{1, 2, 1, 1, 2, 4}, {1, 2, 1, 4, 2, 1}, {1, 4, 1, 1, 2, 2}, {1, 4, 1, 2, 2, 1}, {1, 1, 2, 2, 1, 4},
+	{1, 1, 2, 4, 1, 2}, {1, 2, 2, 1, 1, 4}, {1, 2, 2, 4, 1, 1}, {1, 4, 2, 1, 1, 2}, {1, 4, 2, 2, 1, 1},
+	{2, 4, 1, 2, 1, 1}, {2, 2, 1, 1, 1, 4}, {4, 1, 3, 1, 1, 1}, {2, 4, 1, 1, 1, 2}, {1, 3, 4, 1, 1, 1},
+	{1, 1, 1, 2, 4, 2}, {1, 2, 1, 1, 4, 2}, {1, 2, 1, 2, 4, 1}, {1, 1, 4, 2, 1, 2}, {1, 2, 4, 1, 1, 2},
+	{1, 2, 4, 2, 1, 1}, {4, 1, 1, 2, 1, 2}, {4, 2, 1, 1, 1, 2}, {4, 2, 1, 2, 1, 1}, {2, 1, 2, 1, 4, 1},
+	{2, 1, 4, 1, 2, 1}, {4, 1, 2, 1, 2, 1}, {1, 1, 1, 1, 4, 3}, {1, 1, 1, 3, 4, 1}, {1, 3, 1, 1, 4, 1},
+	{1, 1, 4, 1, 1, 3}, {1, 1, 4, 3, 1, 1}, {4, 1, 1, 1, 1, 3}, {4, 1, 1, 3, 1, 1}, {1, 1, 3, 1, 4, 1},
+	{1, 1, 4, 1, 3, 1}, {3, 1, 1, 1, 4, 1}, {4, 1, 1, 1, 3, 1}, {2, 1, 1, 4, 1, 2}, {2, 1, 1, 2, 1, 4},
+	{2, 1, 1, 2, 3, 2},
+}
+
+// code128Stop is the module widths of the Code 128 stop pattern, with its final bar.
+var code128Stop = []int{2, 3, 3, 1, 1, 1, 2}
+
+// Code 128 symbols with a special meaning.
+const (
+	code128ShiftOrFNC3 = 98
+	code128CodeC       = 99
+	code128CodeB       = 100
+	code128CodeA       = 101
+	code128FNC1        = 102
+	code128StartA      = 103
+	code128StartB      = 104
+	code128StartC      = 105
+)
+
+// matchCode128 returns the symbol among first to last whose pattern matches counters best,
+// or -1.
+func matchCode128(counters []int, first, last int) int {
+	best, bestVariance := -1, barcodeMaxVariance
+	for symbol := first; symbol <= last; symbol++ {
+		if v := patternVariance(counters, code128Patterns[symbol]); v < bestVariance {
+			best, bestVariance = symbol, v
+		}
+	}
+	return best
+}
+
+// isCode128Stop reports whether the runs from bar index i hold a stop pattern.
+func isCode128Stop(runs []int, i int) bool {
+	return i+len(code128Stop) <= len(runs) && patternVariance(runs[i:i+len(code128Stop)], code128Stop) < barcodeMaxVariance
+}
+
+// decodeCode128 decodes the first Code 128 barcode on a line of runs, whose odd indexes are bars.
+// Returns:
+//   - string: the value, empty if there is no barcode or it is damaged
+//   - bool: whether a start pattern and a later stop pattern were found
+func decodeCode128(runs []int) (string, bool) {
+	for i := 1; i+6 <= len(runs); i += 2 {
+		start := matchCode128(runs[i:i+6], code128StartA, code128StartC)
+		// A quiet zone of at least half the start pattern precedes it
+		if start < 0 || runs[i-1]*2 < runSum(runs[i:i+6]) {
+			continue
+		}
+		symbols := []int{start}
+		pos := i + 6
+		for ; pos+6 <= len(runs) && !isCode128Stop(runs, pos); pos += 6 {
+			symbol := matchCode128(runs[pos:pos+6], 0, code128StartC)
+			if symbol < 0 {
+				break
+			}
+			symbols = append(symbols, symbol)
+		}
+		if !isCode128Stop(runs, pos) {
+			// A damaged symbol still makes the line count if the barcode ends further on
+			for rest := pos + 2; rest < len(runs); rest += 2 {
+				if isCode128Stop(runs, rest) {
+					return "", true
+				}
+			}
+			continue
+		}
+		value, ok := code128Value(symbols)
+		if !ok {
+			return "", true
+		}
+		return value, true
+	}
+	return "", false
+}
+
+// code128Value verifies the check symbol of a Code 128 symbol sequence, starting with its start
+// symbol, and decodes the data symbols in code sets A, B and C. Function codes are dropped.
+func code128Value(symbols []int) (string, bool) {
+	if len(symbols) < 3 {
+		return "", false
+	}
+	data, check := symbols[1:len(symbols)-1], symbols[len(symbols)-1]
+	sum := symbols[0]
+	for i, symbol := range data {
+		sum += (i + 1) * symbol
+	}
+	if sum%103 != check {
+		return "", false
+	}
+
+	var sb strings.Builder
+	set, shift := symbols[0], false
+	for _, symbol := range data {
+		current := set
+		if shift {
+			current = code128StartA + code128StartB - set
+			shift = false
+		}
+		switch {
+		case symbol >= code128StartA:
+			return "", false
+		case current == code128StartC && symbol < 100:
+			sb.WriteByte(byte('0' + symbol/10))
+			sb.WriteByte(byte('0' + symbol%10))
+		case current == code128StartC:
+			switch symbol {
+			case code128CodeB:
+				set = code128StartB
+			case code128CodeA:
+				set = code128StartA
+			}
+		case symbol < 64:
+			sb.WriteByte(byte(' ' + symbol))
+		case symbol < 96 && current == code128StartB:
+			sb.WriteByte(byte(' ' + symbol))
+		case symbol < 96:
+			sb.WriteByte(byte(symbol - 64))
+		case symbol == code128ShiftOrFNC3:
+			shift = true
+		case symbol == code128CodeC:
+			set = code128StartC
+		case symbol == code128CodeB && current == code128StartA, symbol == code128CodeA && current == code128StartB:
+			set = code128StartA + code128StartB - current
+		}
+	}
+	return sb.String(), sb.Len() > 0
+}
+
+// code39Chars maps the wide elements of a Code 39 character, one bit per bar or space from the
+// most significant bit, to the character.
+var code39Chars = map[int]byte{
+	0x034: '0', 0x121: '1', 0x061: '2', 0x160: '3', 0x031: '4', 0x130: '5', 0x070: '6', 0x025: '7',
+	0x124: '8', 0x064: '9', 0x109: 'A', 0x049: 'B', 0x148: 'C', 0x019: 'D', 0x118: 'E', 0x058: 'F',
+	0x00D: 'G', 0x10C: 'H', 0x04C: 'I', 0x01C: 'J', 0x103: 'K', 0x043: 'L', 0x142: 'M', 0x013: 'N',
+	0x112: 'O', 0x052: 'P', 0x007: 'Q', 0x106: 'R', 0x046: 'S', 0x016: 'T', 0x181: 'U', 0x0C1: 'V',
+	0x1C0: 'W', 0x091: 'X', 0x190: 'Y', 0x0D0: 'Z', 0x085: '-', 0x184: '.', 0x0C4: ' ', 0x0A8: '$',
+	0x0A2: '/', 0x08A: '+', 0x02A: '%', 0x094: '*',
+}
+
+// code39Char classifies the nine bars and spaces of a Code 39 character into three wide and six
+// narrow ones and returns the character, or 0.
+func code39Char(counters []int) byte {
+	sorted := append([]int(nil), counters...)
+	sort.Ints(sorted)
+	narrow, wide := sorted[5], sorted[6]
+	// Wide elements are at least 1.25 times as wide as narrow ones
+	if wide*4 < narrow*5 {
+		return 0
+	}
+	threshold := float64(narrow+wide) / 2
+	var pattern int
+	for _, c := range counters {
+		pattern <<= 1
+		if float64(c) > threshold {
+			pattern |= 1
+		}
+	}
+	return code39Chars[pattern]
+}
+
+// decodeCode39 decodes the first Code 39 barcode on a line of runs, whose odd indexes are bars.
+// Characters are separated by a gap and the value is framed by asterisks.
+// Returns:
+//   - string: the value without the asterisks, empty if there is no barcode or it is damaged
+//   - bool: whether a start and a later stop character were found
+func decodeCode39(runs []int) (string, bool) {
+	for i := 1; i+9 <= len(runs); i += 2 {
+		// A quiet zone of at least half the start character precedes it
+		if code39Char(runs[i:i+9]) != '*' || runs[i-1]*2 < runSum(runs[i:i+9]) {
+			continue
+		}
+		var sb strings.Builder
+		pos := i + 10
+		for ; pos+9 <= len(runs); pos += 10 {
+			c := code39Char(runs[pos : pos+9])
+			if c == 0 || c == '*' {
+				break
+			}
+			sb.WriteByte(c)
+		}
+		if pos+9 > len(runs) || code39Char(runs[pos:pos+9]) != '*' {
+			// A damaged character still makes the line count if the barcode ends further on
+			for rest := pos + 2; rest+9 <= len(runs); rest += 2 {
+				if code39Char(runs[rest:rest+9]) == '*' {
+					return "", true
+				}
+			}
+			continue
+		}
+		return sb.String(), true
+	}
+	return "", false
+}
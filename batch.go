@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// exitBatchFailed is the exit code used when at least one input of a batch failed.
+const exitBatchFailed = 8
+
+// batchInput is one document of a batch and the subdirectory its chapters are written to.
+type batchInput struct {
+	path string
+	dir  string
+}
+
+// isBatch reports whether the -i values name more than one document:
+// -i was repeated or names a directory.
+func isBatch() bool {
+	if len(inputPaths) != 1 {
+		return len(inputPaths) > 1
+	}
+	info, err := os.Stat(inputPaths[0])
+	return err == nil && info.IsDir()
+}
+
+// batchInputs expands the -i values into the documents to split. Directories contribute their
+// *.pdf files in name order; every document gets a subdirectory of outputDir named after its
+// file name without extension, numbered like attachments if several documents share a name.
+// Returns:
+//   - []batchInput: the documents in the order given
+//   - error: if a directory cannot be read or contains no PDF file
+func batchInputs() ([]batchInput, error) {
+	var paths []string
+	for _, input := range inputPaths {
+		info, err := os.Stat(input)
+		if err != nil || !info.IsDir() {
+			// Missing files are reported by the run for that file
+			paths = append(paths, input)
+			continue
+		}
+		entries, err := os.ReadDir(input)
+		if err != nil {
+			return nil, fmt.Errorf("read input directory: %w", err)
+		}
+		var found []string
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".pdf") {
+				found = append(found, filepath.Join(input, entry.Name()))
+			}
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("input directory '%s' contains no PDF file", input)
+		}
+		sort.Strings(found)
+		paths = append(paths, found...)
+	}
+
+	used := make(map[string]bool)
+	inputs := make([]batchInput, 0, len(paths))
+	for _, path := range paths {
+		base := sanitizeFilename(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+		if base == "" {
+			base = "input"
+		}
+		name := base
+		for i := 2; used[strings.ToLower(name)]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		used[strings.ToLower(name)] = true
+		inputs = append(inputs, batchInput{path: path, dir: filepath.Join(outputDir, name)})
+	}
+	return inputs, nil
+}
+
+// splitBatch splits every document of a batch by running this program once per document,
+// so that a failing document cannot abort the others and each one starts with fresh state,
+// including chapter numbering. All flags except -i and -o are passed on unchanged,
+// and every run gets the batch's run ID. An absolute --manifest would be written by every run, so
+// the runs write their own manifests, which are merged into it once all documents are done; every
+// --also-link view gets a subdirectory per document like --output.
+// The failed documents are listed at the end, encrypted ones that no password opens as skipped,
+// and the run ends with exitBatchFailed. Documents
+// set apart by --review-threshold are listed as well; without failures the run ends with exitNeedsReview.
+// Parameters:
+//   - cmd: the root command, whose changed flags are passed on
+//
+// Returns:
+//   - error: if the batch could not be started, or the exit code of failed or set apart documents
+func splitBatch(cmd *cobra.Command) error {
+	inputs, err := batchInputs()
+	if err != nil {
+		return err
+	}
+	// Report a malformed password file once instead of once per document
+	if passwordFile != "" {
+		if _, err = readPasswordFile(passwordFile); err != nil {
+			return err
+		}
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate executable: %w", err)
+	}
+	// The runs write their manifests into a directory of their own for an absolute --manifest
+	var manifests string
+	if mergesManifests() {
+		if err = checkManifest(); err != nil {
+			return err
+		}
+		if manifests, err = os.MkdirTemp("", "pdf-split-manifests"); err != nil {
+			return fmt.Errorf("failed to create manifest directory: %w", err)
+		}
+		defer os.RemoveAll(manifests)
+	}
+	args := forwardedFlags(cmd.Flags(), manifests != "")
+
+	// All documents of a batch share the run ID
+	if !cmd.Flags().Changed("run-id") {
+		args = append(args, "--run-id="+runID)
+	}
+
+	// Split only a sample of the documents; their runs write into the sample directory as it is
+	if sampleEvery > 0 {
+		total := len(inputs)
+		inputs = sampleInputs(inputs)
+		printMsg("sampled_inputs", len(inputs), total)
+		args = append(args, "--sample-dir=")
+	}
+
+	var failed, review []string
+	for i, input := range inputs {
+		printMsg("batch_input", i+1, len(inputs), input.path, input.dir)
+		runArgs := append([]string{"-i", input.path, "-o", input.dir}, args...)
+		if manifests != "" {
+			runArgs = append(runArgs, "--manifest="+batchManifestPath(manifests, i))
+		}
+		for _, view := range linkViews {
+			runArgs = append(runArgs, "--also-link="+filepath.Join(view.dir, filepath.Base(input.dir))+":"+view.template)
+		}
+		run := exec.Command(executable, runArgs...)
+		run.Stdout = os.Stdout
+		run.Stderr = os.Stderr
+		if err := run.Run(); err != nil {
+			// Report the child's exit code, which tells failures apart as for a single input
+			code := -1
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			}
+			// A document set apart by --review-threshold was split, but is listed for review
+			if code == exitNeedsReview {
+				review = append(review, input.path)
+				continue
+			}
+			if code == exitPasswordRequired {
+				failed = append(failed, msg("batch_skipped_encrypted", input.path))
+				continue
+			}
+			failed = append(failed, msg("batch_failed_input", input.path, code))
+		}
+	}
+
+	if manifests != "" {
+		if err := writeBatchManifest(manifests, inputs); err != nil {
+			return err
+		}
+	}
+	if len(review) > 0 {
+		warnMsg("batch_needs_review", len(review), len(inputs), needsReviewDir)
+		for _, line := range review {
+			fmt.Fprintln(messageOutput, "  - "+line)
+		}
+	}
+	if len(failed) == 0 {
+		printMsg("batch_done", len(inputs))
+		if len(review) > 0 {
+			return exitWith(exitNeedsReview, nil)
+		}
+		return nil
+	}
+	errorMsg("batch_failed", len(failed), len(inputs))
+	for _, line := range failed {
+		fmt.Fprintln(messageOutput, "  - "+line)
+	}
+	return exitWith(exitBatchFailed, nil)
+}
+
+// forwardedFlags returns the flags set on the command line, except -i and -o, as arguments
+// for the run of a single batch document. Repeatable flags are passed once per value.
+// --also-link is passed per document by splitBatch, and --manifest is left out as well if the
+// batch merges the manifests of its runs.
+func forwardedFlags(flags *pflag.FlagSet, mergedManifest bool) []string {
+	var args []string
+	flags.Visit(func(f *pflag.Flag) {
+		if f.Name == "input" || f.Name == "output" || f.Name == "also-link" || (f.Name == "manifest" && mergedManifest) {
+			return
+		}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range slice.GetSlice() {
+				args = append(args, "--"+f.Name+"="+v)
+			}
+			return
+		}
+		args = append(args, "--"+f.Name+"="+f.Value.String())
+	})
+	return args
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// blankContentBytes is the size below which a page content stream is too short to draw
+// anything visible, e.g. "q Q" or a lone clipping path left by a scanner.
+const blankContentBytes = 32
+
+// stripBlankPages removes blank pages from the start and the end of every chapter, such as the
+// separator pages between the chapters of a printed book. Blank pages inside a chapter are kept.
+// A chapter that consists of blank pages only is skipped with a warning; the run fails if
+// no chapter is left.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//   - chapters: chapters with their page ranges
+//
+// Returns:
+//   - []chapter: the chapters with their shortened ranges, without the blank ones
+//   - error: if the pages cannot be read or every chapter is blank
+func stripBlankPages(inputFile *os.File, chapters []chapter) ([]chapter, error) {
+	doc, err := sourceDocument(inputFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Neighboring chapters look at the same pages, so every page is only inspected once
+	blank := make(map[uint32]bool)
+	isBlank := func(page uint32) bool {
+		b, ok := blank[page]
+		if !ok {
+			_ = doc.Inspect(func(ctx *model.Context) error {
+				b = isBlankPage(ctx, int(page))
+				return nil
+			})
+			blank[page] = b
+		}
+		return b
+	}
+
+	kept := chapters[:0]
+	var stripped int
+	for _, cpt := range chapters {
+		start, end := cpt.startPage, cpt.endPage
+		for start <= end && !cpt.startsMidPage && isBlank(start) {
+			start++
+		}
+		if start > end {
+			warnMsg("blank_chapter_skipped", cpt.title, cpt.startPage, cpt.endPage)
+			stripped += int(cpt.endPage-cpt.startPage) + 1
+			continue
+		}
+		for end > start && isBlank(end) {
+			end--
+		}
+		if start != cpt.startPage {
+			cpt.explain("explain_blank_start", start-cpt.startPage, start)
+		}
+		if end != cpt.endPage {
+			cpt.explain("explain_blank_end", cpt.endPage-end, end)
+		}
+		stripped += int(start-cpt.startPage) + int(cpt.endPage-end)
+		cpt.startPage, cpt.endPage = start, end
+		kept = append(kept, cpt)
+	}
+	if len(kept) == 0 {
+		return nil, errors.New(msg("all_chapters_blank"))
+	}
+	printMsg("stripped_blank_pages", stripped, len(kept))
+	return kept, nil
+}
+
+// isBlankPage reports whether a page shows nothing: its content stream is shorter than
+// blankContentBytes, or it draws no text, no image and no form XObject.
+// Annotations are not taken into account.
+func isBlankPage(ctx *model.Context, pageNr int) bool {
+	r, err := pdfcpu.ExtractPageContent(ctx, pageNr)
+	if err != nil {
+		return false
+	}
+	if r == nil {
+		return true
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return false
+	}
+	if len(bytes.TrimSpace(content)) < blankContentBytes {
+		return true
+	}
+	drawn := false
+	scanContent(content, func(op string, _ []string) error {
+		switch op {
+		case "Tj", "TJ", "'", "\"", "Do", "BI", "sh":
+			drawn = true
+		}
+		return nil
+	})
+	return !drawn
+}
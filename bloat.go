@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// defaultBloatFactor is the default multiple of the source's bytes per page above which
+// a chapter is reported as suspiciously large.
+const defaultBloatFactor = 3.0
+
+// bloatMinExcess is the number of bytes a chapter must exceed its share of the source by before
+// it is reported. Every file repeats the parts of a PDF file that do not belong to any page, the
+// header, catalog, document information and cross-reference table, which makes a chapter of a
+// few small pages look many times larger per page than the source without anything copied.
+const bloatMinExcess = 16 << 10
+
+// sourceBytesPerPage returns the average size of a source page in bytes.
+func sourceBytesPerPage(inputFile *os.File) (float64, error) {
+	info, err := inputFile.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read input file size: %w", err)
+	}
+	pageCount, err := sourcePageCount(inputFile)
+	if err != nil {
+		return 0, err
+	}
+	if pageCount == 0 {
+		return 0, nil
+	}
+	return float64(info.Size()) / float64(pageCount), nil
+}
+
+// checkBloat compares the bytes per page of a written chapter with the source average.
+// Chapters exceeding --bloat-factor times the source ratio usually carry a copy of fonts or
+// images shared across the whole document; they are reported with a warning, unless they exceed
+// their share of the source by less than bloatMinExcess bytes.
+// With --verbose the ratio of every chapter is printed.
+// Parameters:
+//   - path: path of the written chapter file
+//   - title: chapter title used in messages
+//   - pages: number of pages in the chapter file
+//   - sourceRatio: bytes per page of the source document
+//
+// Returns:
+//   - float64: the bytes per page of the chapter, 0 if it has no pages
+//   - float64: the multiple of the source ratio, 0 if it has no pages or the source none
+//   - error: if the size of the chapter file cannot be read
+func checkBloat(path, title string, pages int, sourceRatio float64) (float64, float64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read size of '%s': %w", path, err)
+	}
+	if pages == 0 {
+		return 0, 0, nil
+	}
+	ratio := float64(info.Size()) / float64(pages)
+	if sourceRatio <= 0 {
+		return ratio, 0, nil
+	}
+	multiple := ratio / sourceRatio
+	if verbose {
+		printMsg("chapter_ratio", title, formatBytes(ratio), multiple)
+	}
+	excess := float64(info.Size()) - sourceRatio*float64(pages)
+	if bloatFactor > 0 && multiple > bloatFactor && excess >= bloatMinExcess {
+		warnMsg("chapter_bloat", title, formatBytes(ratio), multiple, formatBytes(sourceRatio))
+	}
+	return ratio, multiple, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBloatSmallChapters splits the book fixture by its second outline level with the default
+// --bloat-factor and --strict: its one-page chapter is several times larger per page than the
+// source only by the parts every file repeats, which must not fail the run, and the manifest
+// must record the ratio.
+func TestBloatSmallChapters(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := runCommand(t, dir, "-i", source, "-o", "out", "-d", "2", "--strict",
+		"--manifest", "toc.json", "--sidecar-suffix=")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	records := readManifest(t, filepath.Join(dir, "out", "toc.json"))
+	if len(records) != 5 {
+		t.Fatalf("got %d records, want 5", len(records))
+	}
+	if ratio, _ := records[0]["bloat_ratio"].(float64); ratio <= defaultBloatFactor {
+		t.Errorf("%v: got bloat_ratio %v, want more than %g", records[0]["file"], records[0]["bloat_ratio"], defaultBloatFactor)
+	}
+	for _, record := range records {
+		if bytes, _ := record["bytes_per_page"].(float64); bytes <= 0 {
+			t.Errorf("%v: got bytes_per_page %v, want the size per page", record["file"], record["bytes_per_page"])
+		}
+	}
+}
+
+// TestCheckBloat checks a one-page file that exceeds the bloat factor by fewer bytes than
+// bloatMinExcess, which must not be reported, and one exceeding it by more, which must.
+func TestCheckBloat(t *testing.T) {
+	messageOutput = io.Discard
+	defer func() { messageOutput = clearingWriter{os.Stderr} }()
+	defer func() { warnings = []warning{} }()
+	saved := bloatFactor
+	bloatFactor = defaultBloatFactor
+	defer func() { bloatFactor = saved }()
+
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		size int
+		want int
+	}{
+		{4 << 10, 0},
+		{64 << 10, 1},
+	} {
+		path := filepath.Join(dir, "chapter.pdf")
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", tt.size)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		warnings = []warning{}
+		_, multiple, err := checkBloat(path, "Chapter", 1, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(warnings) != tt.want || multiple != float64(tt.size)/1000 {
+			t.Errorf("%d bytes: got %.2f× with warnings %v, want %d warnings", tt.size, multiple, warnings, tt.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// Source features that are not fully preserved in the outputs, as named by --fail-on-unsupported.
+const (
+	featureTagged     = "tagged"
+	featureSignatures = "signatures"
+	featureJavaScript = "javascript"
+	featureMultimedia = "multimedia"
+	featureXFA        = "xfa"
+)
+
+// unsupportedFeatures lists the detectable features in report order.
+var unsupportedFeatures = []string{featureTagged, featureSignatures, featureJavaScript, featureMultimedia, featureXFA}
+
+// sourceUnsupported holds the features of unsupportedFeatures found in the current source by
+// reportCapabilities, recorded in every record of its manifest.
+var sourceUnsupported []string
+
+// multimediaAnnotations are the annotation subtypes that embed audio or video.
+var multimediaAnnotations = []string{"Movie", "Sound", "Screen", "RichMedia", "3D"}
+
+// parseFeatureList validates the comma-separated values of --fail-on-unsupported.
+func parseFeatureList(values []string) ([]string, error) {
+	var features []string
+	for _, value := range values {
+		feature := strings.ToLower(strings.TrimSpace(value))
+		if !slices.Contains(unsupportedFeatures, feature) {
+			return nil, fmt.Errorf("invalid --fail-on-unsupported value '%s': must be one of %s", value, strings.Join(unsupportedFeatures, ", "))
+		}
+		features = append(features, feature)
+	}
+	return features, nil
+}
+
+// reportCapabilities scans the source for features that the outputs will not preserve,
+// prints one line per feature found, keeps them for the manifest and fails if one of them is
+// listed in failOn.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//   - failOn: features that make the run fail
+//
+// Returns:
+//   - error: if the source cannot be read, or it has a feature listed in failOn
+func reportCapabilities(inputFile *os.File, failOn []string) error {
+	var found []string
+	err := inspectSource(inputFile, func(ctx *model.Context) error {
+		found = sourceFeatures(ctx)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sourceUnsupported = found
+
+	// Print the report before failing so the whole picture is visible
+	for _, feature := range found {
+		printMsg("feature_" + feature)
+	}
+	for _, feature := range found {
+		if slices.Contains(failOn, feature) {
+			return fmt.Errorf("source contains unsupported feature '%s' (--fail-on-unsupported)", feature)
+		}
+	}
+	return nil
+}
+
+// sourceFeatures returns the features of unsupportedFeatures present in the document.
+func sourceFeatures(ctx *model.Context) []string {
+	root, err := ctx.Catalog()
+	if err != nil {
+		return nil
+	}
+	acroForm, _ := ctx.DereferenceDict(root["AcroForm"])
+
+	var found []string
+	if isTagged(ctx) {
+		found = append(found, featureTagged)
+	}
+	if hasSignatures(ctx, acroForm) {
+		found = append(found, featureSignatures)
+	}
+	if hasJavaScript(ctx, root) {
+		found = append(found, featureJavaScript)
+	}
+	if hasAnnotation(ctx, multimediaAnnotations) {
+		found = append(found, featureMultimedia)
+	}
+	if acroForm != nil {
+		if _, ok := acroForm.Find("XFA"); ok {
+			found = append(found, featureXFA)
+		}
+	}
+	return found
+}
+
+// hasSignatures reports whether the form declares signatures, either through the
+// SignaturesExist bit of SigFlags or through a signature field.
+func hasSignatures(ctx *model.Context, acroForm types.Dict) bool {
+	if acroForm == nil {
+		return false
+	}
+	if flags := acroForm.IntEntry("SigFlags"); flags != nil && *flags&1 != 0 {
+		return true
+	}
+	fields, err := ctx.DereferenceArray(acroForm["Fields"])
+	if err != nil {
+		return false
+	}
+	return hasSignatureField(ctx, fields, 0)
+}
+
+// hasSignatureField searches a field tree for a field of type Sig.
+func hasSignatureField(ctx *model.Context, fields types.Array, depth int) bool {
+	if depth > splitter.MaxOutlineDepth {
+		return false
+	}
+	for _, obj := range fields {
+		field, err := ctx.DereferenceDict(obj)
+		if err != nil || field == nil {
+			continue
+		}
+		if ft := field.NameEntry("FT"); ft != nil && *ft == "Sig" {
+			return true
+		}
+		if kids, err := ctx.DereferenceArray(field["Kids"]); err == nil && hasSignatureField(ctx, kids, depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasJavaScript reports whether the document has document-level scripts or an opening
+// or document action running JavaScript.
+func hasJavaScript(ctx *model.Context, root types.Dict) bool {
+	if names, err := ctx.DereferenceDict(root["Names"]); err == nil && names != nil {
+		if _, ok := names.Find("JavaScript"); ok {
+			return true
+		}
+	}
+	if action, err := ctx.DereferenceDict(root["OpenAction"]); err == nil && isJavaScriptAction(action) {
+		return true
+	}
+	if actions, err := ctx.DereferenceDict(root["AA"]); err == nil {
+		for _, obj := range actions {
+			if action, err := ctx.DereferenceDict(obj); err == nil && isJavaScriptAction(action) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isJavaScriptAction reports whether an action dictionary is a JavaScript action.
+func isJavaScriptAction(action types.Dict) bool {
+	if action == nil {
+		return false
+	}
+	s := action.NameEntry("S")
+	return s != nil && *s == "JavaScript"
+}
+
+// hasAnnotation reports whether any page has an annotation of one of the given subtypes.
+func hasAnnotation(ctx *model.Context, subtypes []string) bool {
+	if err := ctx.EnsurePageCount(); err != nil {
+		return false
+	}
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		page, _, _, err := ctx.PageDict(pageNr, false)
+		if err != nil || page == nil {
+			continue
+		}
+		annots, err := ctx.DereferenceArray(page["Annots"])
+		if err != nil {
+			continue
+		}
+		for _, obj := range annots {
+			annot, err := ctx.DereferenceDict(obj)
+			if err != nil || annot == nil {
+				continue
+			}
+			if subtype := annot.NameEntry("Subtype"); subtype != nil && slices.Contains(subtypes, *subtype) {
+				return true
+			}
+		}
+	}
+	return false
+}
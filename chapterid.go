@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/spf13/cobra"
+)
+
+// stampIDText is the --stamp-id value that prints the chapter ID on the first page.
+const stampIDText = "text"
+
+// chapterIDPrefix marks chapter IDs so that they can be found in the text of a page.
+const chapterIDPrefix = "CH-"
+
+// chapterIDPattern matches a chapter ID in extracted page text.
+var chapterIDPattern = regexp.MustCompile(chapterIDPrefix + `[0-9a-f]{12}`)
+
+// chapterIDStamp positions the ID in small type in the bottom right corner of the page.
+const chapterIDStamp = "font:Helvetica, points:7, pos:br, off:-12 10, scale:1 abs, rot:0, fillc:#404040"
+
+var (
+	identifyScan string
+	identifyID   string
+)
+
+var identifyCmd = &cobra.Command{
+	Use:     "identify",
+	Short:   "Find the chapter of a source that a printed and scanned chapter belongs to",
+	Args:    cobra.NoArgs,
+	RunE:    identifyChapter,
+	Example: `./pdf-split identify -i book.pdf --scan scan.pdf`,
+}
+
+// initIdentifyFlags registers the flags of the identify subcommand.
+// The chapter boundary flags are shared with the split command, because the ID covers the
+// page range: they must be given as they were for the split.
+func initIdentifyFlags() {
+	flags := identifyCmd.Flags()
+	flags.StringVarP(&inputFilePath, "input", "i", "", "source PDF file path")
+	flags.StringVar(&identifyScan, "scan", "", "PDF whose first page carries a stamped chapter ID, e.g. an OCRed scan")
+	flags.StringVar(&identifyID, "id", "", "chapter ID to look up, e.g. CH-3f9a2c1b7d04")
+	flags.StringVar(&midPageStart, "mid-page-start", "", "owner of a page shared by two chapters: previous, next or duplicate")
+	flags.BoolVar(&noOverlap, "no-overlap", true, "end each chapter on the page before the next one starts; =false repeats that page in both")
+	flags.IntVar(&lookback, "lookback", 0, "move each chapter start back by up to this many pages, e.g. to include part openers")
+	flags.BoolVar(&noAutoDescend, "no-auto-descend", false, "do not split below a single top-level bookmark")
+	flags.StringVar(&sidecarSuffix, "sidecar-suffix", defaultSidecarSuffix, "read chapters from the input path plus this suffix if that file exists (empty to disable)")
+	initPasswordFlags(flags)
+	flags.StringVar(&language, "lang", "", "language of messages, e.g. de or zh-CN (default from LANG)")
+	if err := identifyCmd.MarkFlagRequired("input"); err != nil {
+		log.Fatalf("failed to parse param: %v", err)
+	}
+	identifyCmd.MarkFlagsOneRequired("scan", "id")
+	identifyCmd.MarkFlagsMutuallyExclusive("scan", "id")
+}
+
+// sourceID returns a stable identifier of a document: the first part of its trailer ID,
+// which survives saving the document again, or else the SHA-256 of the file.
+func sourceID(inputFile *os.File) (string, error) {
+	var id []byte
+	err := inspectSource(inputFile, func(ctx *model.Context) error {
+		if len(ctx.ID) == 0 {
+			return nil
+		}
+		switch first := ctx.ID[0].(type) {
+		case types.HexLiteral:
+			id, _ = first.Bytes()
+		case types.StringLiteral:
+			id, _ = types.Unescape(first.Value())
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(id) > 0 {
+		return hex.EncodeToString(id), nil
+	}
+	sum, err := fileSHA256(inputFile.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to hash source: %w", err)
+	}
+	return sum, nil
+}
+
+// chapterID returns the stable ID of a chapter, derived from the source ID, the page range and the title.
+func chapterID(source string, cpt chapter) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d-%d\x00%s", source, cpt.startPage, cpt.endPage, cpt.title)))
+	return chapterIDPrefix + hex.EncodeToString(sum[:6])
+}
+
+// assignChapterIDs sets the ID of every chapter.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//   - chapters: list of chapter information, updated in place
+//
+// Returns:
+//   - error: if the source cannot be read
+func assignChapterIDs(inputFile *os.File, chapters []chapter) error {
+	source, err := sourceID(inputFile)
+	if err != nil {
+		return err
+	}
+	for i := range chapters {
+		chapters[i].id = chapterID(source, chapters[i])
+	}
+	return nil
+}
+
+// stampChapterID prints a chapter ID on the first page of a trimmed chapter.
+func stampChapterID(ctx *model.Context, id string) error {
+	wm, err := api.TextWatermark(id, chapterIDStamp, true, false, types.POINTS)
+	if err != nil {
+		return err
+	}
+	return api.WatermarkContext(ctx, types.IntSet{1: true}, wm)
+}
+
+// firstPageIDs returns the chapter IDs found in the text of a document's first page,
+// including text drawn by form XObjects such as stamps.
+func firstPageIDs(path string) ([]string, error) {
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", path, err)
+	}
+	if err = ctx.EnsurePageCount(); err != nil || ctx.PageCount == 0 {
+		return nil, fmt.Errorf("failed to read the pages of '%s': %w", path, err)
+	}
+	texts := []string{pageText(ctx, 1)}
+	pageDict, _, inherited, err := ctx.PageDict(1, false)
+	if err == nil && pageDict != nil {
+		resources := inherited.Resources
+		if d, err := ctx.DereferenceDict(pageDict["Resources"]); err == nil && d != nil {
+			resources = d
+		}
+		if xobjects, err := ctx.DereferenceDict(resources["XObject"]); err == nil {
+			for _, obj := range xobjects {
+				sd, _, err := ctx.DereferenceStreamDict(obj)
+				if err != nil || sd == nil || sd.Subtype() == nil || *sd.Subtype() != "Form" {
+					continue
+				}
+				if err = sd.Decode(); err == nil {
+					var lines []string
+					for _, run := range textRuns(sd.Content) {
+						lines = append(lines, run.text)
+					}
+					texts = append(texts, strings.Join(lines, "\n"))
+				}
+			}
+		}
+	}
+	return chapterIDPattern.FindAllString(strings.Join(texts, "\n"), -1), nil
+}
+
+// normalizeChapterID accepts an ID typed with or without its prefix and in any case.
+func normalizeChapterID(id string) string {
+	id = strings.TrimSpace(id)
+	if strings.HasPrefix(strings.ToUpper(id), chapterIDPrefix) {
+		id = id[len(chapterIDPrefix):]
+	}
+	return chapterIDPrefix + strings.ToLower(id)
+}
+
+// identifyChapter reports which chapter of the source a stamped chapter file or scan belongs to.
+// The chapters are planned exactly as for a split, so the ID ranges match.
+// Parameter cmd is the running command, _ is used to satisfy the cobra.Command RunE interface.
+func identifyChapter(cmd *cobra.Command, _ []string) error {
+	if err := setLanguage(language); err != nil {
+		return err
+	}
+	return failed(cmd, identify())
+}
+
+// identify finds the chapter of --id or of the ID stamped on the first page of --scan.
+func identify() error {
+	ids := []string{normalizeChapterID(identifyID)}
+	if identifyScan != "" {
+		var err error
+		if ids, err = firstPageIDs(identifyScan); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return fmt.Errorf("no chapter ID found on the first page of '%s'; pass it with --id", identifyScan)
+		}
+	}
+
+	inputFile, err := openInput(inputFilePath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+	chapters, _, err := extractChapters(inputFile, "")
+	if err != nil {
+		return err
+	}
+	if err = assignChapterIDs(inputFile, chapters); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		for _, cpt := range chapters {
+			if cpt.id == id {
+				printMsg("identified_chapter", id, cpt.order, inputFilePath, cpt.title, cpt.startPage, cpt.endPage)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no chapter of '%s' has the ID %s; give the boundary flags used for the split", inputFilePath, strings.Join(ids, ", "))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// checkChapters runs the validations of an export without writing any file, for --no-output.
+// It reports the files that would be written and warns about pages no chapter covers.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//   - chapters: list of chapter information in export order
+//   - dir: directory the chapters would be written to
+//
+// Returns:
+//   - error: if a check fails, e.g. with --fail-on-lossy-names, or the source cannot be read
+func checkChapters(inputFile *os.File, chapters []chapter, dir string) error {
+	if err := checkLossyNames(chapters); err != nil {
+		return err
+	}
+	// Warn about the structure of a tagged source being dropped
+	if _, err := sourceIsTagged(inputFile); err != nil {
+		return err
+	}
+
+	// List the planned files
+	for _, cpt := range chapters {
+		pageRange := chapterPageRange(cpt)
+		if singleOutput == "" {
+			printMsg("planned_chapter", filepath.Join(chapterDir(cpt, dir), cpt.file+".pdf"), pageRange)
+		} else {
+			printMsg("planned_combined_chapter", cpt.title, singleOutput, pageRange)
+		}
+	}
+
+	// Look for pages left out between chapters, or before the first one of the whole document
+	pageCount, err := sourcePageCount(inputFile)
+	if err != nil {
+		return err
+	}
+	covered := make([]bool, pageCount+1)
+	first := pageCount
+	for _, cpt := range chapters {
+		for _, span := range cpt.pageSpans() {
+			for p := span.start; p <= span.end && int(p) <= pageCount; p++ {
+				covered[p] = true
+			}
+		}
+		first = min(first, int(cpt.startPage))
+	}
+	if len(underTitles) == 0 {
+		first = 1
+	}
+	last := pageCount
+	if truncateAtPage > 0 {
+		last = min(last, truncateAtPage)
+	}
+	for p := first; p <= last; p++ {
+		if covered[p] {
+			continue
+		}
+		end := p
+		for end < last && !covered[end+1] {
+			end++
+		}
+		if len(underTitles) > 0 && end == last {
+			// Pages after the last subtree belong to other parts of the outline
+			break
+		}
+		warnMsg("uncovered_pages", p, end)
+		p = end
+	}
+	return nil
+}
+
+// printCheckResult prints the outcome of a --no-output run. Failures end the run earlier
+// with an error, so only passing and warning results remain.
+func printCheckResult() {
+	if len(warnings) > 0 {
+		printMsg("check_warn", inputFilePath, len(warnings))
+		return
+	}
+	printMsg("check_pass", inputFilePath)
+}
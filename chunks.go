@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// pageChunks plans sequential chunks of size pages each, for documents without an outline
+// or when --by-pages is given. The last chunk holds the remaining pages and may be shorter.
+// Chunks are titled after their page range, e.g. "pages_1-50".
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//   - size: number of pages per chunk
+//
+// Returns:
+//   - []chapter: one chapter per chunk
+//   - error: if the page count cannot be read or there would be more chunks than --max-chapters
+func pageChunks(inputFile *os.File, size int) ([]chapter, error) {
+	pageCount, err := sourcePageCount(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	if truncateAtPage > 0 && truncateAtPage < pageCount {
+		pageCount = truncateAtPage
+	}
+	if err = checkChapterLimit((pageCount + size - 1) / size); err != nil {
+		return nil, err
+	}
+
+	var chapters []chapter
+	for start := 1; start <= pageCount; start += size {
+		end := min(start+size-1, pageCount)
+		cpt := chapter{
+			title:     fmt.Sprintf("pages_%d-%d", start, end),
+			order:     uint32(len(chapters) + 1),
+			startPage: uint32(start),
+			endPage:   uint32(end),
+		}
+		cpt.explain("explain_chunk", size)
+		chapters = append(chapters, cpt)
+	}
+	return chapters, nil
+}
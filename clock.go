@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// clock tells the date to the features that record it: the --archive-layout partition, the
+// archive.log and the --provenance date. Durations are measured with time.Since on the monotonic
+// clock instead, which no clock adjustment changes.
+type clock interface {
+	Now() time.Time
+}
+
+// systemClock is the clock of the system.
+type systemClock struct{}
+
+// Now returns the current time.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// fileSystem holds the filesystem operations of --archive that can fail in ways a test cannot
+// bring about on its temporary directory, such as a rename across filesystems.
+type fileSystem interface {
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+}
+
+// osFileSystem is the filesystem of the operating system.
+type osFileSystem struct{}
+
+// Rename renames a file with os.Rename.
+func (osFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+// Remove removes a file with os.Remove.
+func (osFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+var (
+	// runClock is the clock of the run, replaced by tests to pin the date.
+	runClock clock = systemClock{}
+	// runFS is the filesystem --archive moves the source on, replaced by tests to make it fail.
+	runFS fileSystem = osFileSystem{}
+)
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fixedClock is a clock that always tells the same time.
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// crossDeviceFS is a filesystem whose renames fail as they do across filesystems.
+type crossDeviceFS struct {
+	osFileSystem
+}
+
+func (crossDeviceFS) Rename(oldpath, newpath string) error {
+	return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+}
+
+// useClock replaces the clock of the run for the rest of a test.
+func useClock(t *testing.T, c clock) {
+	saved := runClock
+	runClock = c
+	t.Cleanup(func() { runClock = saved })
+}
+
+// TestArchiveLayoutRunDate partitions by the run date on the last second of a year and the
+// first of the next, which must land in different partitions.
+func TestArchiveLayoutRunDate(t *testing.T) {
+	saved := archiveDate
+	archiveDate = archiveDateRun
+	t.Cleanup(func() { archiveDate = saved })
+	source, err := os.Open(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	for _, tt := range []struct {
+		now  time.Time
+		want string
+	}{
+		{time.Date(2025, 12, 31, 23, 59, 59, 0, time.Local), filepath.Join("out", "2025", "12", "book")},
+		{time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local), filepath.Join("out", "2026", "01", "book")},
+	} {
+		useClock(t, fixedClock(tt.now))
+		if got := archiveLayoutDir(source, "out"); got != tt.want {
+			t.Errorf("at %v: got %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
+
+// TestArchiveSourceCrossDevice archives a source whose rename fails as across filesystems: it
+// must be copied, verified and removed, and logged with the time of the clock.
+func TestArchiveSourceCrossDevice(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "scan.pdf")
+	if err := os.WriteFile(source, []byte("%PDF-1.7 scan"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	useClock(t, fixedClock(now))
+	saved := runFS
+	runFS = crossDeviceFS{}
+	t.Cleanup(func() { runFS = saved })
+
+	archive := filepath.Join(dir, "archive")
+	if err := archiveSource(source, archive, filepath.Join(dir, "out")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(source); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the source was not removed after copying: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(archive, "scan.pdf"))
+	if err != nil || string(data) != "%PDF-1.7 scan" {
+		t.Errorf("got archive copy %q, %v", data, err)
+	}
+	log, err := os.ReadFile(filepath.Join(archive, archiveLogName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(log), now.Format(time.RFC3339)+"\t") {
+		t.Errorf("got %s line %q, want it to start with %s", archiveLogName, log, now.Format(time.RFC3339))
+	}
+}
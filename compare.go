@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// exitStructureChanged is the exit code used when --fail-on-structure-change found chapters that
+// were added, removed or resized since the --compare-previous manifest.
+const exitStructureChanged = 19
+
+// defaultResizeThreshold is the share of its pages a chapter may gain or lose before it counts as resized.
+const defaultResizeThreshold = 0.25
+
+var (
+	comparePrevious       string
+	compareReport         string
+	resizeThreshold       float64
+	failOnStructureChange bool
+
+	// comparedChapters collects the planned chapters of the document for --compare-previous.
+	comparedChapters []chapter
+)
+
+// chapterChange is a chapter that differs from the previous run. Pages is unset for a removed
+// chapter and PreviousPages for an added one.
+type chapterChange struct {
+	Title         string `json:"title"`
+	PreviousPages int    `json:"previous_pages,omitempty"`
+	Pages         int    `json:"pages,omitempty"`
+}
+
+// structureComparison is the report of --compare-previous, written as JSON by --compare-report.
+type structureComparison struct {
+	Previous  string          `json:"previous"`
+	Added     []chapterChange `json:"added"`
+	Removed   []chapterChange `json:"removed"`
+	Resized   []chapterChange `json:"resized"`
+	Unchanged int             `json:"unchanged"`
+}
+
+// changed reports whether any chapter was added, removed or resized.
+func (c structureComparison) changed() bool {
+	return len(c.Added) > 0 || len(c.Removed) > 0 || len(c.Resized) > 0
+}
+
+// initCompareFlags registers the flags comparing the planned chapters with an earlier run.
+func initCompareFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&comparePrevious, "compare-previous", "", "compare the planned chapters with this JSON --manifest of an earlier run by title")
+	flags.StringVar(&compareReport, "compare-report", "", "write the --compare-previous report to this JSON file")
+	flags.Float64Var(&resizeThreshold, "resize-threshold", defaultResizeThreshold, "share of its pages a chapter may gain or lose before --compare-previous reports it as resized")
+	flags.BoolVar(&failOnStructureChange, "fail-on-structure-change", false, "fail with exit code 19 if --compare-previous found added, removed or resized chapters")
+}
+
+// checkCompareFlags validates the flags of --compare-previous.
+func checkCompareFlags() error {
+	if comparePrevious != "" && strings.ToLower(filepath.Ext(comparePrevious)) != manifestJSON {
+		return fmt.Errorf("invalid --compare-previous '%s': must be a %s manifest", comparePrevious, manifestJSON)
+	}
+	if resizeThreshold < 0 {
+		return fmt.Errorf("invalid --resize-threshold value %g: must not be negative", resizeThreshold)
+	}
+	return nil
+}
+
+// recordComparedChapters keeps the planned chapters of a document for --compare-previous.
+func recordComparedChapters(chapters []chapter) {
+	if comparePrevious != "" {
+		comparedChapters = append(comparedChapters, chapters...)
+	}
+}
+
+// compareWithPrevious compares the planned chapters of the document with the --compare-previous
+// manifest, prints the differences and writes --compare-report.
+// Returns:
+//   - error: if a file cannot be read or written, or an *exitError with exitStructureChanged for
+//     --fail-on-structure-change and a changed structure
+func compareWithPrevious() error {
+	data, err := os.ReadFile(comparePrevious)
+	var previous []manifestEntry
+	if err == nil {
+		err = json.Unmarshal(data, &previous)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read --compare-previous manifest '%s': %w", comparePrevious, err)
+	}
+	comparison := compareChapters(previous, comparedChapters, resizeThreshold)
+	comparison.Previous = comparePrevious
+	for _, c := range comparison.Added {
+		printMsg("compare_added", c.Title, c.Pages)
+	}
+	for _, c := range comparison.Removed {
+		printMsg("compare_removed", c.Title, c.PreviousPages)
+	}
+	for _, c := range comparison.Resized {
+		printMsg("compare_resized", c.Title, c.PreviousPages, c.Pages)
+	}
+	printMsg("compare_summary", comparePrevious, len(comparison.Added), len(comparison.Removed), len(comparison.Resized), comparison.Unchanged)
+
+	if compareReport != "" {
+		data, err := json.MarshalIndent(comparison, "", "  ")
+		if err == nil {
+			err = os.WriteFile(compareReport, append(data, '\n'), 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write --compare-report '%s': %w", compareReport, err)
+		}
+	}
+	if failOnStructureChange && comparison.changed() {
+		return exitWith(exitStructureChanged, fmt.Errorf("the chapter structure changed since %s", comparePrevious))
+	}
+	return nil
+}
+
+// compareChapters matches planned chapters to the entries of an earlier manifest by title, in
+// order for titles that appear several times.
+// Parameters:
+//   - previous: the entries of the earlier manifest
+//   - chapters: the planned chapters
+//   - threshold: share of its pages a matched chapter may gain or lose before it is resized
+//
+// Returns:
+//   - structureComparison: the added, removed and resized chapters and the number of unchanged ones
+func compareChapters(previous []manifestEntry, chapters []chapter, threshold float64) structureComparison {
+	comparison := structureComparison{Added: []chapterChange{}, Removed: []chapterChange{}, Resized: []chapterChange{}}
+	byTitle := make(map[string][]int)
+	for i, e := range previous {
+		byTitle[e.Title] = append(byTitle[e.Title], i)
+	}
+	matched := make([]bool, len(previous))
+	for _, cpt := range chapters {
+		pages := plannedPages(cpt)
+		candidates := byTitle[cpt.title]
+		if len(candidates) == 0 {
+			comparison.Added = append(comparison.Added, chapterChange{Title: cpt.title, Pages: pages})
+			continue
+		}
+		e := previous[candidates[0]]
+		matched[candidates[0]] = true
+		byTitle[cpt.title] = candidates[1:]
+		if e.Pages > 0 && math.Abs(float64(pages-e.Pages))/float64(e.Pages) > threshold {
+			comparison.Resized = append(comparison.Resized, chapterChange{Title: cpt.title, PreviousPages: e.Pages, Pages: pages})
+			continue
+		}
+		comparison.Unchanged++
+	}
+	for i, e := range previous {
+		if !matched[i] {
+			comparison.Removed = append(comparison.Removed, chapterChange{Title: e.Title, PreviousPages: e.Pages})
+		}
+	}
+	return comparison
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// exitNeedsReview is the exit code used when --review-threshold routed the document into
+// needsReviewDir, and by a batch in which documents need review but none failed.
+const exitNeedsReview = 16
+
+// needsReviewDir is the subdirectory of the output directory that --review-threshold writes
+// documents with a doubtful chapter detection into.
+const needsReviewDir = "_needs_review"
+
+// Sources of chapter boundaries, from the most to the least reliable.
+const (
+	detectionOutline  = "outline"
+	detectionSidecar  = "sidecar"
+	detectionTOC      = "toc-from-pdf"
+	detectionBarcode  = "barcode"
+	detectionHeadings = "headings"
+	detectionChunks   = "fixed-size"
+)
+
+// detectionWeights is the highest confidence each source of boundaries can reach.
+var detectionWeights = map[string]float64{
+	detectionOutline:  1,
+	detectionSidecar:  1,
+	detectionTOC:      0.9,
+	detectionBarcode:  0.85,
+	detectionHeadings: 0.6,
+	detectionChunks:   0.3,
+}
+
+// Penalties of the confidence score for the share of chapters showing a problem.
+const (
+	estimatedPenalty = 0.5
+	collisionPenalty = 0.3
+	sharedPenalty    = 0.3
+	titlePenalty     = 0.4
+)
+
+// longTitleLength is the number of characters above which a title is more likely body text
+// picked up as a heading than a chapter title.
+const longTitleLength = 150
+
+// placeholderTitle matches titles that name no chapter: empty ones, bare numbers and
+// punctuation, and the defaults of authoring tools such as "Untitled" or "Bookmark 3".
+var placeholderTitle = regexp.MustCompile(`(?i)^(untitled|new bookmark|bookmark|outline|page)?[\s\d\p{P}]*$`)
+
+// documentConfidence is the confidence score of the chapters detected in the input document,
+// recorded in the manifest and the --dry-run plan.
+var documentConfidence float64
+
+// needsReview is set when --review-threshold routed the document into needsReviewDir.
+var needsReview bool
+
+// markDetection records the source of the chapters' boundaries for the confidence score.
+func markDetection(chapters []chapter, source string) []chapter {
+	for i := range chapters {
+		chapters[i].detectedBy = source
+	}
+	return chapters
+}
+
+// confidence scores how reliable the detected chapters of a document are, from 0 to 1: the
+// weight of the source of their boundaries, lowered by the share of chapters with an estimated
+// start page, with bookmarks folded in because they start on the same page, that share a page
+// with the next chapter, and with a placeholder or overlong title.
+// Parameters:
+//   - chapters: the detected chapters of all subtrees of the document, before any grouping
+//
+// Returns:
+//   - float64: the score, rounded to two decimals
+//   - []string: the signals that lowered it, as localized messages
+func confidence(chapters []chapter) (float64, []string) {
+	if len(chapters) == 0 {
+		return 1, nil
+	}
+	var reasons []string
+	weight := 1.0
+	for _, cpt := range chapters {
+		if w, ok := detectionWeights[cpt.detectedBy]; ok && w < weight {
+			weight = w
+			reasons = []string{msg("confidence_source", cpt.detectedBy)}
+		}
+	}
+
+	// Count the chapters of every signal
+	var estimated, collisions, shared, placeholders, long int
+	byPage := append([]chapter(nil), chapters...)
+	sort.SliceStable(byPage, func(a, b int) bool { return byPage[a].startPage < byPage[b].startPage })
+	for i, cpt := range byPage {
+		if cpt.estimated {
+			estimated++
+		}
+		if cpt.collisions > 0 {
+			collisions++
+		}
+		if i+1 < len(byPage) && cpt.endPage >= byPage[i+1].startPage {
+			shared++
+		}
+		switch {
+		case cpt.frontMatter:
+		case placeholderTitle.MatchString(strings.TrimSpace(cpt.title)):
+			placeholders++
+		case utf8.RuneCountInString(cpt.title) > longTitleLength:
+			long++
+		}
+	}
+
+	n := float64(len(chapters))
+	penalty := estimatedPenalty*float64(estimated)/n + collisionPenalty*float64(collisions)/n +
+		sharedPenalty*float64(shared)/n + titlePenalty*float64(placeholders+long)/n
+	for _, signal := range []struct {
+		key   string
+		count int
+	}{
+		{"confidence_estimated", estimated},
+		{"confidence_collisions", collisions},
+		{"confidence_shared", shared},
+		{"confidence_placeholders", placeholders},
+		{"confidence_long_titles", long},
+	} {
+		if signal.count > 0 {
+			reasons = append(reasons, msg(signal.key, signal.count, len(chapters)))
+		}
+	}
+	score := math.Round(max(0, weight*(1-penalty))*100) / 100
+	return score, reasons
+}
+
+// scoreDocument scores the detected chapters of the input document and, below
+// --review-threshold, returns the directory to write it into instead of baseDir.
+// Parameters:
+//   - chapters: the detected chapters of all subtrees of the document
+//   - baseDir: the output directory of the document
+//
+// Returns:
+//   - string: baseDir, or its needsReviewDir subdirectory
+func scoreDocument(chapters []chapter, baseDir string) string {
+	score, reasons := confidence(chapters)
+	documentConfidence = score
+	plan.Confidence = score
+	details := msg("confidence_clean")
+	if len(reasons) > 0 {
+		details = strings.Join(reasons, "; ")
+	}
+	if reviewThreshold > 0 && score < reviewThreshold {
+		needsReview = true
+		warnMsg("needs_review", score, reviewThreshold, details, needsReviewDir)
+		return filepath.Join(baseDir, needsReviewDir)
+	}
+	if verbose || reviewThreshold > 0 {
+		printMsg("confidence", score, details)
+	}
+	return baseDir
+}
+
+// exitOnNeedsReview ends a run whose document was routed into needsReviewDir with
+// exitNeedsReview, after everything else was done, so that a batch can list it.
+func exitOnNeedsReview() error {
+	if needsReview {
+		return exitWith(exitNeedsReview, nil)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// continuationStamp centers the text of a --continuation-page on the page.
+const continuationStamp = "font:Helvetica, points:14, pos:c, scale:1 abs, rot:0, fillc:#000000"
+
+// continuationText is the text of a --continuation-page. It is part of the document, so it is
+// not translated like the messages.
+const continuationText = "Continued in: %s (%s)"
+
+// continuationPages returns the number of pages --continuation-page adds to a chapter.
+func continuationPages(cpt chapter) int {
+	if cpt.continuation != "" {
+		return 1
+	}
+	return 0
+}
+
+// addedPages returns the number of pages added to a chapter beyond its planned range.
+func addedPages(cpt chapter) int {
+	return paddedPages(cpt) + continuationPages(cpt)
+}
+
+// setContinuations points every chapter of an export but the last to the output that follows it,
+// by its final file name. Names must be resolved for all chapters before any of them is written.
+// Parameters:
+//   - chapters: chapters in export order
+//   - paths: output file of every chapter
+func setContinuations(chapters []chapter, paths []string) {
+	if !continuationPage {
+		return
+	}
+	for i := 0; i < len(chapters)-1; i++ {
+		chapters[i].continuation = fmt.Sprintf(continuationText, chapters[i+1].title, filepath.Base(paths[i+1]))
+	}
+}
+
+// appendContinuationPage appends a page with text after the last page of a document.
+// The page is sized like the last page, as the blank page of --pad-to-even.
+func appendContinuationPage(ctx *model.Context, text string) error {
+	if err := appendBlankPage(ctx); err != nil {
+		return err
+	}
+	wm, err := api.TextWatermark(text, continuationStamp, true, false, types.POINTS)
+	if err != nil {
+		return err
+	}
+	return api.WatermarkContext(ctx, types.IntSet{ctx.PageCount: true}, wm)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// exitDeliveryFailed is the exit code used when --dest-cmd failed for some chapters.
+const exitDeliveryFailed = 15
+
+var (
+	// outputDestination receives the chapter files for --dest-cmd; nil writes them to the output directory.
+	outputDestination splitter.Destination
+	// deliveredFiles lists the chapter files handed to the destination, for its Finalize.
+	deliveredFiles splitter.Manifest
+	// failedDeliveries lists the chapters the destination refused, for the summary.
+	failedDeliveries []string
+)
+
+// setupDestination prepares the destination of --dest-cmd. The command's output is shown with
+// the messages of the run.
+func setupDestination() error {
+	if destCmd == "" {
+		return nil
+	}
+	args, err := splitter.ParseCommand(destCmd)
+	if err != nil {
+		return fmt.Errorf("invalid --dest-cmd: %w", err)
+	}
+	outputDestination = splitter.CommandDestination{Args: args, Stdout: messageOutput, Stderr: os.Stderr}
+	return nil
+}
+
+// stageOutputs returns the files the chapters are written to. Without a destination these are
+// their paths in the output directory. With one, they are written to a staging directory
+// first, checked there like any output and then delivered under their path relative to the
+// output directory; the returned function removes the staging directory.
+// Parameters:
+//   - paths: paths of the chapter files in the output directory
+//
+// Returns:
+//   - []string: the file every chapter is written to
+//   - func(): cleans up the staged files
+//   - error: if the staging directory cannot be created
+func stageOutputs(paths []string) ([]string, func(), error) {
+	if outputDestination == nil {
+		return paths, func() {}, nil
+	}
+	staging, err := os.MkdirTemp("", "pdf-split-dest-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	files := make([]string, len(paths))
+	for i, path := range paths {
+		files[i] = filepath.Join(staging, filepath.FromSlash(manifestFilePath(path)))
+		if err := os.MkdirAll(filepath.Dir(files[i]), 0755); err != nil {
+			return nil, nil, fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+	}
+	return files, func() { os.RemoveAll(staging) }, nil
+}
+
+// deliverChapter hands a written and checked chapter file to the destination, under its path
+// relative to the output directory. A refused chapter is reported and listed at the end, and
+// the export carries on with the next one.
+// Parameters:
+//   - cpt: the chapter
+//   - file: the staged chapter file
+//   - path: the path of the chapter in the output directory
+//
+// Returns:
+//   - bool: whether the chapter was delivered
+func deliverChapter(cpt chapter, file, path string) bool {
+	name := manifestFilePath(path)
+	f, err := os.Open(file)
+	if err == nil {
+		err = splitter.Deliver(outputDestination, name, f)
+		f.Close()
+	}
+	if err != nil {
+		failedDeliveries = append(failedDeliveries, msg("delivery_entry", cpt.title, name, err))
+		errorMsg("delivery_failed", cpt.title, name, err)
+		return false
+	}
+	if verbose {
+		printMsg("delivered_chapter", cpt.title, name)
+	}
+	deliveredFiles.Files = append(deliveredFiles.Files, splitter.ManifestFile{
+		Name:    name,
+		Chapter: splitter.Chapter{Title: cpt.title, Order: int(cpt.order), StartPage: int(cpt.startPage), EndPage: int(cpt.endPage)},
+	})
+	return true
+}
+
+// exitOnFailedDeliveries ends the run with exitDeliveryFailed if the destination refused any
+// chapter, after listing them.
+func exitOnFailedDeliveries() error {
+	if len(failedDeliveries) == 0 {
+		return nil
+	}
+	errorMsg("deliveries_failed", len(failedDeliveries))
+	for _, line := range failedDeliveries {
+		fmt.Fprintln(messageOutput, "  - "+line)
+	}
+	return exitWith(exitDeliveryFailed, nil)
+}
+
+// finalizeDestination finalizes the destination once every chapter was delivered.
+func finalizeDestination() error {
+	if outputDestination == nil {
+		return nil
+	}
+	if err := outputDestination.Finalize(deliveredFiles); err != nil {
+		errorMsg("destination_finalize_failed", err)
+		return exitWith(exitDeliveryFailed, nil)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// receiveChapter is the --dest-cmd of the tests, standing in for the upload tool of a document
+// management system. TestMain runs it when the test binary is started with splitter.NameEnv
+// set, as a CommandDestination does: it stores stdin in the directory args[0] under that name,
+// and fails for the names in the rest of args.
+// Parameters:
+//   - name: the name of the delivered file
+//   - args: the directory to store it in, followed by the names to refuse
+//
+// Returns:
+//   - int: the exit code of the command
+func receiveChapter(name string, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "no directory to receive into")
+		return 2
+	}
+	if slices.Contains(args[1:], name) {
+		fmt.Fprintf(os.Stderr, "refused '%s'\n", name)
+		return 3
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(args[0], filepath.FromSlash(name)), data, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// TestDestCmd splits the parts of the book fixture with --dest-cmd: once delivering both, and
+// once with the first refused, which must fail that chapter alone with exitDeliveryFailed.
+// Neither split may leave a chapter in its output directory.
+func TestDestCmd(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		refuse []string
+		want   map[string]int
+	}{
+		{"delivered", nil, map[string]int{"01.pdf": 8, "02.pdf": 8}},
+		{"refused", []string{"01.pdf"}, map[string]int{"02.pdf": 8}},
+	}
+	for _, tt := range tests {
+		received, out := filepath.Join(dir, tt.name, "received"), filepath.Join(dir, tt.name, "out")
+		if err := os.MkdirAll(received, 0755); err != nil {
+			t.Fatal(err)
+		}
+		command := strings.Join(append([]string{os.Args[0], received}, tt.refuse...), " ")
+		output, err := runCommand(t, dir, "-i", source, "-o", out, "--dest-cmd", command,
+			"--name-template", "{order}", "--sidecar-suffix=", "--bloat-factor=0")
+		var exitErr *exec.ExitError
+		switch {
+		case tt.refuse == nil && err != nil:
+			t.Fatalf("%s: %v\n%s", tt.name, err, output)
+		case tt.refuse != nil && (!errors.As(err, &exitErr) || exitErr.ExitCode() != exitDeliveryFailed):
+			t.Fatalf("%s: got %v, want exit code %d\n%s", tt.name, err, exitDeliveryFailed, output)
+		case tt.refuse != nil && !strings.Contains(output, "failed to deliver chapter 'Part One' as '01.pdf'"):
+			t.Errorf("%s: the refused chapter is not reported\n%s", tt.name, output)
+		}
+		if got := outputPageCounts(t, received); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: received %v, want %v", tt.name, got, tt.want)
+		}
+		if entries, _ := os.ReadDir(out); len(entries) > 0 {
+			t.Errorf("%s: '%s' was written to the output directory", tt.name, entries[0].Name())
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// defaultHeadingPattern matches the chapter headings --detect-headings looks for by default.
+const defaultHeadingPattern = `^(Chapter|CHAPTER)\s+\d+`
+
+// headingLines is the number of text lines at the top of a page that are searched for a heading.
+const headingLines = 3
+
+// frontMatterTitle is the title of the pages before the first detected heading.
+const frontMatterTitle = "front_matter"
+
+// headingPattern is the compiled --heading-pattern.
+var headingPattern = regexp.MustCompile(defaultHeadingPattern)
+
+// headingChapters plans one chapter per page that starts with a line matching --heading-pattern,
+// titled with that line, for documents whose outline is missing or unusable. Each chapter ends
+// on the page before the next heading. Pages before the first heading become an extra
+// front_matter chapter, numbered 0 so that the chapters keep their numbers.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//
+// Returns:
+//   - []chapter: the detected chapters in page order, nil if no page matched
+//   - error: if the pages cannot be read or more headings than --max-chapters match
+func headingChapters(inputFile *os.File) ([]chapter, error) {
+	pageCount, err := sourcePageCount(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	if truncateAtPage > 0 && truncateAtPage < pageCount {
+		pageCount = truncateAtPage
+	}
+
+	// Start a chapter on every page with a heading at its top
+	var chapters []chapter
+	err = inspectSource(inputFile, func(ctx *model.Context) error {
+		for page := 1; page <= pageCount; page++ {
+			title := pageHeading(ctx, page)
+			if title == "" {
+				continue
+			}
+			cpt := chapter{title: title, order: uint32(len(chapters) + 1), startPage: uint32(page)}
+			cpt.explain("explain_detected_heading", title, page)
+			chapters = append(chapters, cpt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(chapters) == 0 {
+		return nil, nil
+	}
+	if err = checkChapterLimit(len(chapters) + 1); err != nil {
+		return nil, err
+	}
+
+	// Headings are at the top of their page, so every chapter ends on the page before the next one
+	for i := 0; i < len(chapters)-1; i++ {
+		chapters[i].endPage = chapters[i+1].startPage - 1
+		chapters[i].explain("explain_end_before_heading", chapters[i].endPage, chapters[i+1].title)
+	}
+	chapters[len(chapters)-1].endPage = uint32(pageCount)
+	chapters[len(chapters)-1].explain("explain_end", pageCount, msg("end_document"))
+
+	// Keep the pages before the first heading
+	if chapters[0].startPage > 1 {
+		front := chapter{title: frontMatterTitle, frontMatter: true, startPage: 1, endPage: chapters[0].startPage - 1}
+		front.explain("explain_front_matter", chapters[0].title)
+		chapters = append([]chapter{front}, chapters...)
+	}
+	return chapters, nil
+}
+
+// pageHeading returns the first of the top headingLines readable text lines of a page that
+// matches --heading-pattern, or an empty string. Lines are taken in content stream order,
+// which follows the layout from the top for most producers.
+func pageHeading(ctx *model.Context, pageNr int) string {
+	r, err := pdfcpu.ExtractPageContent(ctx, pageNr)
+	if err != nil || r == nil {
+		return ""
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return ""
+	}
+	var lines int
+	for _, run := range textRuns(content) {
+		if !isReadable(run.text) {
+			continue
+		}
+		if line := strings.TrimSpace(run.text); headingPattern.MatchString(line) {
+			return line
+		}
+		if lines++; lines == headingLines {
+			break
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Parameters of the duplicated-document heuristic.
+const (
+	duplicationSamples   = 8
+	duplicationMinShare  = 0.75
+	duplicationMinLength = 4
+)
+
+// detectDuplication checks whether the document seems to contain itself twice back-to-back,
+// as happens when a re-scan is appended to the original. It compares the text of sampled pages k
+// with pages k + N/2 and reports the result together with the length of the last chapter.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//   - chapters: planned chapters
+//
+// Returns:
+//   - int: the page at which the first copy ends, or 0 if no duplication was detected
+//   - error: if the pages cannot be read
+func detectDuplication(inputFile *os.File, chapters []chapter) (int, error) {
+	pageCount, err := sourcePageCount(inputFile)
+	if err != nil {
+		return 0, err
+	}
+	half := pageCount / 2
+	if half < 1 {
+		return 0, nil
+	}
+
+	// Compare evenly spaced pages of the first half with their counterparts in the second half
+	var compared, equal int
+	step := max(1, half/duplicationSamples)
+	err = inspectSource(inputFile, func(ctx *model.Context) error {
+		for k := 1; k <= half; k += step {
+			text := pageText(ctx, k)
+			if len(text) < duplicationMinLength {
+				continue
+			}
+			compared++
+			if text == pageText(ctx, k+half) {
+				equal++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	last := chapters[len(chapters)-1]
+	lastLength := last.endPage - last.startPage + 1
+	duplicated := compared > 0 && float64(equal)/float64(compared) >= duplicationMinShare
+	printMsg("duplication_check", equal, compared, half, last.title, lastLength, pageCount)
+	if !duplicated {
+		return 0, nil
+	}
+	if truncateAtPage > 0 && truncateAtPage <= half {
+		// The user already capped the document within the first copy
+		return half, nil
+	}
+	warnMsg("duplication_warning", half, half+1, 2*half, half)
+	return half, nil
+}
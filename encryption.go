@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/spf13/pflag"
+)
+
+// passwordEnv is the environment variable read for the user password if --user-password is not given,
+// which keeps the password out of the shell history.
+const passwordEnv = "PDF_SPLIT_PASSWORD"
+
+// exitPasswordRequired is the exit code used when an input is encrypted and no password was given.
+const exitPasswordRequired = 17
+
+// protection is the encryption of a source document, re-applied to its chapters by --keep-encryption.
+type protection struct {
+	userPW      string
+	ownerPW     string
+	aes         bool
+	keyLength   int
+	permissions model.PermissionFlags
+}
+
+// initPasswordFlags registers the password flags on a command that reads a source document.
+func initPasswordFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&userPassword, "user-password", "", "password to open an encrypted input (default from "+passwordEnv+")")
+	flags.StringVar(&ownerPassword, "owner-password", "", "owner password of an encrypted input, for documents whose permissions do not allow extracting pages")
+}
+
+// sourceConfiguration returns the pdfcpu configuration for reading the source document,
+// with the passwords given on the command line.
+func sourceConfiguration() *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW = userPassword
+	if conf.UserPW == "" {
+		conf.UserPW = os.Getenv(passwordEnv)
+	}
+	conf.OwnerPW = ownerPassword
+	return conf
+}
+
+// checkPassword opens the source with the given passwords and fails with a readable message
+// if it is encrypted and they do not open it, instead of failing later inside pdfcpu.
+// The check is made for trimming, so permissions that forbid extracting pages are reported too.
+func checkPassword(inputFile *os.File) error {
+	conf := sourceConfiguration()
+	conf.Cmd = model.TRIM
+	_, err := api.ReadContext(inputFile, conf)
+	switch {
+	case err == nil:
+	case errors.Is(err, pdfcpu.ErrWrongPassword) && conf.UserPW == "" && conf.OwnerPW == "":
+		return exitWith(exitPasswordRequired, errors.New(msg("password_required", filepath.Base(inputFile.Name()), passwordEnv)))
+	case errors.Is(err, pdfcpu.ErrWrongPassword):
+		return errors.New(msg("incorrect_password", filepath.Base(inputFile.Name())))
+	case strings.Contains(err.Error(), "permission bits"):
+		return errors.New(msg("password_restricted", filepath.Base(inputFile.Name())))
+	}
+	return nil
+}
+
+// readPasswordFile reads a --password-file: a CSV file whose records hold a file name or glob
+// pattern, matched against the file name of an input, and the password of the matching inputs.
+// Lines starting with # are comments. Errors name the line only, never its content.
+// Parameters:
+//   - path: path of the CSV file
+//
+// Returns:
+//   - [][2]string: the patterns and their passwords, in file order
+//   - error: if the file cannot be read or a record is malformed
+func readPasswordFile(path string) ([][2]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open password file: %w", err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	var entries [][2]string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return nil, fmt.Errorf("password file '%s': line %d is not valid CSV", path, line)
+		}
+		if len(record) != 2 || record[0] == "" {
+			return nil, fmt.Errorf("password file '%s': line %d must hold a file name and a password", path, line)
+		}
+		if _, err := filepath.Match(record[0], ""); err != nil {
+			return nil, fmt.Errorf("password file '%s': line %d has an invalid pattern", path, line)
+		}
+		entries = append(entries, [2]string{record[0], record[1]})
+	}
+}
+
+// usePasswordFile sets the user password of an input from the first --password-file record
+// that matches its file name, which takes precedence over --user-password and passwordEnv.
+// Inputs without a matching record keep those.
+// Parameters:
+//   - input: path of the input as given with -i
+//
+// Returns:
+//   - error: if the password file cannot be read
+func usePasswordFile(input string) error {
+	if passwordFile == "" {
+		return nil
+	}
+	entries, err := readPasswordFile(passwordFile)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(input)
+	for _, entry := range entries {
+		if matched, _ := filepath.Match(entry[0], name); matched {
+			userPassword = entry[1]
+			return nil
+		}
+	}
+	return nil
+}
+
+// keptProtection returns the protection to re-apply to the chapters, or nil if the source is not
+// encrypted or --keep-encryption is not set, in which case the chapters are written unencrypted.
+func keptProtection(inputFile *os.File) (*protection, error) {
+	if !keepEncryption {
+		return nil, nil
+	}
+	p, err := sourceProtection(inputFile)
+	if p == nil || err != nil {
+		return nil, err
+	}
+
+	// Both passwords must be known, or the chapters would be protected more weakly than the source
+	if p.ownerPW == "" {
+		return nil, errors.New(msg("keep_encryption_owner", filepath.Base(inputFile.Name())))
+	}
+	if p.userPW == "" {
+		if _, err := api.ReadContext(inputFile, model.NewDefaultConfiguration()); err != nil {
+			return nil, errors.New(msg("keep_encryption_user", filepath.Base(inputFile.Name())))
+		}
+	}
+	return p, nil
+}
+
+// sourceProtection returns the encryption of the source document, or nil if it is not encrypted.
+func sourceProtection(inputFile *os.File) (*protection, error) {
+	conf := sourceConfiguration()
+	ctx, err := api.ReadContext(inputFile, conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF catalog: %w", err)
+	}
+	if ctx.E == nil {
+		return nil, nil
+	}
+
+	// Revisions 5 and 6 always use AES-256; otherwise the crypt filter tells AES from RC4
+	p := &protection{userPW: conf.UserPW, ownerPW: conf.OwnerPW, keyLength: ctx.E.L, permissions: model.PermissionFlags(ctx.E.P)}
+	switch {
+	case ctx.E.R >= 5:
+		p.aes, p.keyLength = true, 256
+	case ctx.E.V == 4:
+		p.aes = cryptFilterMethod(ctx) != "V2"
+	}
+	if p.keyLength < 40 {
+		p.keyLength = 40
+	}
+	return p, nil
+}
+
+// cryptFilterMethod returns the method of the standard crypt filter of an encrypted document,
+// e.g. AESV2 or V2 for RC4, or an empty string if it is not declared.
+func cryptFilterMethod(ctx *model.Context) string {
+	if ctx.Encrypt == nil {
+		return ""
+	}
+	d, err := ctx.DereferenceDict(*ctx.Encrypt)
+	if err != nil || d == nil {
+		return ""
+	}
+	filters := d.DictEntry("CF")
+	if filters == nil {
+		return ""
+	}
+	std := filters.DictEntry("StdCF")
+	if std == nil {
+		return ""
+	}
+	if method := std.NameEntry("CFM"); method != nil {
+		return *method
+	}
+	return ""
+}
+
+// encryptOnWrite makes the next write of a chapter encrypt it with the source's protection.
+func encryptOnWrite(ctx *model.Context, p *protection) {
+	ctx.Cmd = model.ENCRYPT
+	ctx.UserPW = p.userPW
+	ctx.OwnerPW = p.ownerPW
+	ctx.EncryptUsingAES = p.aes
+	ctx.EncryptKeyLength = p.keyLength
+	ctx.Permissions = p.permissions
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// TestPasswordFile splits a batch of two encrypted documents and a plain one with a password file
+// that only matches the first: it must be split, the second listed as skipped without stopping
+// the batch, and no password printed.
+func TestPasswordFile(t *testing.T) {
+	dir := t.TempDir()
+	inputs := filepath.Join(dir, "inputs")
+	if err := os.Mkdir(inputs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join("testdata", "book.pdf")
+	for name, password := range map[string]string{"annual-2023.pdf": "alpha", "memo.pdf": "beta"} {
+		conf := model.NewAESConfiguration(password, password+"-owner", 256)
+		conf.Permissions = model.PermissionsAll
+		if err := api.EncryptFile(source, filepath.Join(inputs, name), conf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(inputs, "plain.pdf"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	passwords := filepath.Join(dir, "passwords.csv")
+	if err = os.WriteFile(passwords, []byte("# file,password\nannual-*.pdf,alpha\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := runCommand(t, dir, "-i", inputs, "-o", "out", "--password-file", passwords,
+		"--sidecar-suffix=", "--bloat-factor=0")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitBatchFailed {
+		t.Fatalf("got %v, want exit code %d\n%s", err, exitBatchFailed, output)
+	}
+	if want := filepath.Join(inputs, "memo.pdf") + " (skipped: encrypted, no password)"; !strings.Contains(output, want) {
+		t.Errorf("output does not list %q\n%s", want, output)
+	}
+	if strings.Contains(output, "alpha") {
+		t.Errorf("output shows a password\n%s", output)
+	}
+	for _, document := range []string{"annual-2023", "plain"} {
+		if _, err := os.Stat(filepath.Join(dir, "out", document, "02_Part Two.pdf")); err != nil {
+			t.Errorf("document %s: %v", document, err)
+		}
+	}
+}
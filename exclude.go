@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/souhup/pdf-spliter/ranges"
+)
+
+// excludedPages holds the pages of --exclude-pages, nil if none are excluded or they are
+// page labels, which pageSelection resolves for every input.
+var excludedPages ranges.List
+
+// applyExcludedPages removes the pages of --exclude-pages from the planned chapters. A chapter
+// that loses pages from its middle keeps the pages around them as one output; a chapter left
+// without pages is dropped, and the others keep their numbers.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//   - chapters: the planned chapters, numbered
+//
+// Returns:
+//   - []chapter: the chapters without the excluded pages
+//   - error: if the page count of the source cannot be read, a page label cannot be resolved or
+//     every page is excluded
+func applyExcludedPages(inputFile *os.File, chapters []chapter) ([]chapter, error) {
+	selection, err := pageSelection(inputFile, "exclude-pages", excludePages, excludedPages)
+	if err != nil {
+		return nil, err
+	}
+	if selection == nil {
+		return chapters, nil
+	}
+	pageCount, err := sourcePageCount(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	var excluded []pageRange
+	for _, page := range selection.Numbers(pageCount) {
+		excluded = append(excluded, pageRange{uint32(page), uint32(page)})
+	}
+	excluded = unionRanges(excluded)
+	if len(excluded) == 0 {
+		return chapters, nil
+	}
+
+	kept := chapters[:0]
+	for _, cpt := range chapters {
+		removed := intersectRanges(cpt.pageSpans(), excluded)
+		if len(removed) == 0 {
+			kept = append(kept, cpt)
+			continue
+		}
+		spans := subtractRanges(cpt.pageSpans(), excluded)
+		if len(spans) == 0 {
+			printMsg("excluded_chapter", cpt.order, cpt.title)
+			continue
+		}
+		if spans[0].start != cpt.startPage {
+			cpt.startsMidPage = false
+		}
+		cpt.startPage, cpt.endPage, cpt.ranges = spans[0].start, spans[len(spans)-1].end, nil
+		if len(spans) > 1 {
+			cpt.ranges = spans
+		}
+		cpt.explain("explain_excluded_pages", formatPageRuns(removed))
+		kept = append(kept, cpt)
+	}
+	if len(kept) == 0 {
+		return nil, errors.New(msg("all_pages_excluded"))
+	}
+	return kept, nil
+}
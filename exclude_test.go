@@ -0,0 +1,117 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// TestExcludePages splits the parts of the book fixture without some of their pages: a part
+// that loses pages from its middle keeps the rest in one file, a part that loses all of them
+// is not written and the other keeps its number.
+func TestExcludePages(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		exclude string
+		want    map[string]int
+	}{
+		{"1-2, 12-13, 16-", map[string]int{"01_Part One.pdf": 6, "02_Part Two.pdf": 5}},
+		{"odd", map[string]int{"01_Part One.pdf": 4, "02_Part Two.pdf": 4}},
+		{"1-8", map[string]int{"02_Part Two.pdf": 8}},
+	}
+	for i, tt := range tests {
+		out := filepath.Join(dir, strings.Repeat("x", i+1))
+		if output, err := runCommand(t, dir, "-i", source, "-o", out, "--exclude-pages", tt.exclude,
+			"--sidecar-suffix=", "--bloat-factor=0"); err != nil {
+			t.Fatalf("--exclude-pages %s: %v\n%s", tt.exclude, err, output)
+		}
+		if got := outputPageCounts(t, out); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("--exclude-pages %s: got %v, want %v", tt.exclude, got, tt.want)
+		}
+	}
+
+	output, err := runCommand(t, dir, "-i", source, "-o", "rejected", "--exclude-pages", "1-8, 9-x")
+	if err == nil || !strings.Contains(output, "invalid --exclude-pages '1-8, 9-x': 'x' at column 8: not a number") {
+		t.Errorf("malformed selection: got %v\n%s", err, output)
+	}
+	if output, err = runCommand(t, dir, "-i", source, "-o", "empty", "--exclude-pages", "1-"); err == nil {
+		t.Errorf("every page excluded: got success\n%s", output)
+	}
+}
+
+// TestExcludePageLabels excludes pages of a labeled copy of the book fixture by their labels:
+// i-iv, 1-8, an appendix i-ii and A-1-A-2. Prefixed labels with a dash and ranges of them must
+// resolve, while a label of two ranges, labels mixed with physical pages and an input without
+// labels are rejected.
+func TestExcludePageLabels(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	labeled := writeLabeledCopy(t, source, filepath.Join(dir, "labeled.pdf"))
+	tests := []struct {
+		exclude string
+		want    map[string]int
+	}{
+		{"iii-iv, 8, A-2", map[string]int{"01_Part One.pdf": 6, "02_Part Two.pdf": 6}},
+		{"iv,A-1-A-2", map[string]int{"01_Part One.pdf": 7, "02_Part Two.pdf": 6}},
+		{"iii-4", map[string]int{"01_Part One.pdf": 2, "02_Part Two.pdf": 8}},
+	}
+	for i, tt := range tests {
+		out := filepath.Join(dir, strings.Repeat("x", i+1))
+		if output, err := runCommand(t, dir, "-i", labeled, "-o", out, "--exclude-pages", tt.exclude,
+			"--pages-as", "labels", "--sidecar-suffix=", "--bloat-factor=0"); err != nil {
+			t.Fatalf("--exclude-pages %s: %v\n%s", tt.exclude, err, output)
+		}
+		if got := outputPageCounts(t, out); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("--exclude-pages %s: got %v, want %v", tt.exclude, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		input, exclude, want string
+	}{
+		{labeled, "i", "the label 'i' is ambiguous: it is on physical pages 1, 13"},
+		{labeled, "iv, 16", "mixes page labels and physical page numbers: '16' is not a page label"},
+		{labeled, "v", "no page is labeled 'v'"},
+		{source, "iv", "the input has no page labels"},
+	} {
+		output, err := runCommand(t, dir, "-i", tt.input, "-o", "rejected", "--exclude-pages", tt.exclude, "--pages-as", "labels")
+		if err == nil || !strings.Contains(output, tt.want) {
+			t.Errorf("--exclude-pages %s: got %v, want an error containing %q\n%s", tt.exclude, err, tt.want, output)
+		}
+	}
+}
+
+// writeLabeledCopy writes a copy of the book fixture with page labels: front matter i-iv, body
+// 1-8, an appendix numbered i-ii again and A-1-A-2.
+func writeLabeledCopy(t *testing.T, source, path string) string {
+	t.Helper()
+	ctx, err := api.ReadContextFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ctx.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root["PageLabels"] = types.Dict{"Nums": types.Array{
+		types.Integer(0), types.Dict{"S": types.Name("r")},
+		types.Integer(4), types.Dict{"S": types.Name("D")},
+		types.Integer(12), types.Dict{"S": types.Name("r")},
+		types.Integer(14), types.Dict{"S": types.Name("D"), "P": types.StringLiteral("A-")},
+	}}
+	if err := api.WriteContextFile(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+)
+
+// pendingFile is an output file written under a temporary name next to its target and renamed
+// into place only once it is complete, so an interrupted run never leaves a partial file that
+// --skip-existing would take for a finished one.
+type pendingFile struct {
+	*os.File
+	target string
+}
+
+// createOutput creates a pendingFile for target in the target's directory.
+func createOutput(target string) (*pendingFile, error) {
+	base := outputFS.Truncate(filepath.Base(target), outputFS.MaxLength-partialNameReserve)
+	f, err := os.CreateTemp(filepath.Dir(target), "."+base+".*.partial")
+	if err != nil {
+		return nil, err
+	}
+	return &pendingFile{File: f, target: target}, nil
+}
+
+// commit closes the file and moves it to its target, replacing an existing file.
+// Filesystems without permissions such as FAT may refuse them, which is reported once at the end.
+func (p *pendingFile) commit() error {
+	if err := p.Close(); err != nil {
+		p.discard()
+		return err
+	}
+	if err := os.Chmod(p.Name(), 0644); err != nil {
+		if !outputFS.noPermissions {
+			p.discard()
+			return err
+		}
+		permissionsIgnored.Store(true)
+	}
+	if err := os.Rename(p.Name(), p.target); err != nil {
+		p.discard()
+		return err
+	}
+	return nil
+}
+
+// discard closes and removes the temporary file, leaving the target untouched.
+func (p *pendingFile) discard() {
+	p.Close()
+	os.Remove(p.Name())
+}
+
+// initExistingFlags registers the flags deciding what happens to output files that already exist.
+func initExistingFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&overwrite, "overwrite", false, "replace output files that already exist")
+	flags.BoolVar(&skipExisting, "skip-existing", false, "keep non-empty output files that already exist and skip their chapters, e.g. to resume a split")
+}
+
+// checkExistingOutputs decides what to do with output files that already exist, before
+// anything is written. With --skip-existing, non-empty files are kept and their chapters
+// skipped; with --overwrite they are replaced; otherwise the run fails listing them.
+// Parameters:
+//   - paths: target files of the export
+//
+// Returns:
+//   - []bool: for every path, whether its export is skipped
+//   - error: listing the existing files if they are neither kept nor replaced
+func checkExistingOutputs(paths []string) ([]bool, error) {
+	skip := make([]bool, len(paths))
+	var existing []string
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		switch {
+		case skipExisting && info.Size() > 0:
+			skip[i] = true
+		case !overwrite && !skipExisting:
+			existing = append(existing, path)
+		}
+	}
+	if len(existing) > 0 {
+		return nil, errors.New(msg("outputs_exist", len(existing), existing[0]))
+	}
+	return skip, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// exitError ends the run with an exit code of its own. err is printed like a fatal error;
+// it is nil if the reason was already reported.
+type exitError struct {
+	code int
+	err  error
+}
+
+// Error returns the message of the underlying error.
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return "exit status " + strconv.Itoa(e.code)
+	}
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// exitWith returns an error that ends the run with code.
+// Parameters:
+//   - code: the exit code
+//   - err: the reason printed before exiting, or nil if it was already reported
+//
+// Returns:
+//   - error: an *exitError
+func exitWith(code int, err error) error {
+	return &exitError{code: code, err: err}
+}
+
+// failed marks the error of a command that has started working, so that it is reported like a
+// fatal error instead of a usage error. Errors with an exit code of their own keep it; all others
+// end the run with exit code 1.
+// Parameters:
+//   - cmd: the running command
+//   - err: the error of the command, or nil
+//
+// Returns:
+//   - error: nil if err is nil, or else an *exitError
+func failed(cmd *cobra.Command, err error) error {
+	if err == nil {
+		return nil
+	}
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	var exit *exitError
+	if errors.As(err, &exit) {
+		return err
+	}
+	return exitWith(1, err)
+}
+
+// exit ends the process for the error returned by the command line: an *exitError is printed and
+// ends the run with its code, any other error is a usage error already printed by cobra.
+func exit(err error) {
+	var e *exitError
+	if !errors.As(err, &e) {
+		log.Fatalf("failed to execute: %v", err)
+	}
+	if e.err != nil {
+		log.Print(e.err)
+	}
+	os.Exit(e.code)
+}
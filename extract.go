@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/spf13/cobra"
+)
+
+// maxTitleSuggestions bounds the number of similar titles offered for an unknown title.
+const maxTitleSuggestions = 3
+
+var (
+	extractFrom   string
+	extractTo     string
+	extractOutput string
+	rawSelection  string
+)
+
+var extractCmd = &cobra.Command{
+	Use:     "extract",
+	Short:   "Export the pages from one chapter through another as a single PDF",
+	Args:    cobra.NoArgs,
+	RunE:    extractSpan,
+	Example: `./pdf-split extract -i book.pdf --from "Chapter 3" --to "Chapter 5"`,
+}
+
+// initExtractFlags registers the flags of the extract subcommand.
+// The chapter boundary flags are shared with the split command, so a span covers
+// exactly the pages the split would write for its chapters.
+func initExtractFlags() {
+	flags := extractCmd.Flags()
+	flags.StringVarP(&inputFilePath, "input", "i", "", "input PDF file path")
+	flags.StringVarP(&extractOutput, "output", "o", "", "output PDF file path (default named after the chapters)")
+	flags.StringVar(&extractFrom, "from", "", "title of the first chapter of the span")
+	flags.StringVar(&extractTo, "to", "", "title of the last chapter of the span (default the --from chapter)")
+	flags.StringVar(&rawSelection, "raw-selection", "", "pdfcpu page selection to export instead of a chapter span, e.g. 'even,!5'")
+	flags.StringVar(&midPageStart, "mid-page-start", "", "owner of a page shared by two chapters: previous, next or duplicate")
+	flags.BoolVar(&noOverlap, "no-overlap", true, "end each chapter on the page before the next one starts; =false repeats that page in both")
+	flags.IntVar(&lookback, "lookback", 0, "move each chapter start back by up to this many pages, e.g. to include part openers")
+	flags.BoolVar(&noAutoDescend, "no-auto-descend", false, "do not split below a single top-level bookmark")
+	flags.StringVar(&sidecarSuffix, "sidecar-suffix", defaultSidecarSuffix, "read chapters from the input path plus this suffix if that file exists (empty to disable)")
+	initPasswordFlags(flags)
+	initExistingFlags(flags)
+	initTargetFSFlag(flags)
+	flags.BoolVar(&validateOutputs, "validate-outputs", false, "check the written file with pdfcpu's strict validation")
+	flags.BoolVar(&keepEncryption, "keep-encryption", false, "encrypt the output of an encrypted input with its passwords and permissions")
+	flags.BoolVar(&noRetitle, "no-retitle", false, "keep the source's Title in the output instead of adding the span's title")
+	flags.StringVar(&language, "lang", "", "language of messages, e.g. de or zh-CN (default from LANG)")
+	if err := extractCmd.MarkFlagRequired("input"); err != nil {
+		log.Fatalf("failed to parse param: %v", err)
+	}
+	extractCmd.MarkFlagsOneRequired("from", "raw-selection")
+	extractCmd.MarkFlagsMutuallyExclusive("from", "raw-selection")
+	extractCmd.MarkFlagsMutuallyExclusive("to", "raw-selection")
+}
+
+// extractSpan exports the pages from the --from chapter through the end of the --to chapter
+// into one file. The chapters are planned exactly as for a split, so boundary flags apply.
+// Parameter cmd is the running command, _ is used to satisfy the cobra.Command RunE interface.
+func extractSpan(cmd *cobra.Command, _ []string) error {
+	// Validate flag values before doing any work
+	if err := setLanguage(language); err != nil {
+		return err
+	}
+	switch midPageStart {
+	case "", midPageStartPrevious, midPageStartNext, midPageStartDuplicate:
+	default:
+		return fmt.Errorf("invalid --mid-page-start value '%s': must be %s, %s or %s",
+			midPageStart, midPageStartPrevious, midPageStartNext, midPageStartDuplicate)
+	}
+	if lookback < 0 {
+		return fmt.Errorf("invalid --lookback value %d: must not be negative", lookback)
+	}
+	if err := resolveTargetFS(filepath.Dir(extractOutput)); err != nil {
+		return err
+	}
+
+	inputFile, err := openInput(inputFilePath)
+	if err != nil {
+		return failed(cmd, err)
+	}
+	defer inputFile.Close()
+
+	// Raw selections bypass the chapter plan
+	if rawSelection != "" {
+		return extractSelection(cmd, inputFile)
+	}
+
+	// Resolve both titles against the planned chapters
+	chapters, _, err := extractChapters(inputFile, "")
+	if err != nil {
+		return failed(cmd, err)
+	}
+	first, err := findChapter(chapters, extractFrom)
+	if err != nil {
+		return err
+	}
+	last := first
+	if extractTo != "" {
+		if last, err = findChapter(chapters, extractTo); err != nil {
+			return err
+		}
+	}
+	if last.startPage < first.startPage {
+		return fmt.Errorf("chapter '%s' starts before chapter '%s'", last.title, first.title)
+	}
+
+	// Name the file after the span unless an output path was given
+	span := chapter{title: first.title, order: 1, startPage: first.startPage, endPage: max(first.endPage, last.endPage)}
+	if last.title != first.title {
+		span.title = first.title + " - " + last.title
+	}
+	outputFilePath := extractOutput
+	if outputFilePath == "" {
+		outputFilePath = sanitizeFilename(span.title) + ".pdf"
+	}
+	pageRange := fmt.Sprintf("%d-%d", span.startPage, span.endPage)
+	written, err := extractPages(inputFile, outputFilePath, span.title, span.title, pageRange)
+	if err != nil || !written {
+		return failed(cmd, err)
+	}
+	if _, _, err = verifyPageCount(outputFilePath, span.title, plannedPages(span)); err != nil {
+		return failed(cmd, err)
+	}
+	printMsg("extracted_span", span.title, pageRange, outputFilePath)
+	if validateOutputs {
+		validateOutput(outputFilePath, span.title)
+		return failed(cmd, exitOnInvalidOutputs())
+	}
+	return nil
+}
+
+// extractPages trims pages of the source into one file with the same fixes as a chapter file,
+// creating its directory, unless the file exists and is kept.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//   - outputFilePath: path of the output file
+//   - name: name of the pages in messages
+//   - title: title added to the document information, empty to keep the source's
+//   - pageRange: pdfcpu page selection of the pages
+//
+// Returns:
+//   - bool: whether the file was written, false if an existing file was kept
+//   - error: if the source cannot be read or the file cannot be written
+func extractPages(inputFile *os.File, outputFilePath, name, title, pageRange string) (bool, error) {
+	if dir := filepath.Dir(outputFilePath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return false, fmt.Errorf("fail to create output directory: %w", err)
+		}
+	}
+	skip, err := checkExistingOutputs([]string{outputFilePath})
+	if err != nil {
+		return false, err
+	}
+	if skip[0] {
+		printMsg("skipped_existing", name, outputFilePath)
+		return false, nil
+	}
+
+	var fixes chapterFixes
+	if fixes.layers, err = sourceHasLayers(inputFile); err != nil {
+		return false, err
+	}
+	if fixes.untag, err = sourceIsTagged(inputFile); err != nil {
+		return false, err
+	}
+	if fixes.threads, err = sourceHasThreads(inputFile); err != nil {
+		return false, err
+	}
+	if fixes.protect, err = keptProtection(inputFile); err != nil {
+		return false, err
+	}
+	fixes.info = readDocumentInfo(inputFile).properties(title)
+
+	outputFile, err := createOutput(outputFilePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to create output file '%s': %w", outputFilePath, err)
+	}
+	if _, err = trimChapter(inputFile, nil, outputFile, pageRange, fixes); err != nil {
+		outputFile.discard()
+		return false, fmt.Errorf("failed to extract '%s': %w", name, err)
+	}
+	if err = outputFile.commit(); err != nil {
+		return false, fmt.Errorf("failed to write output file '%s': %w", outputFilePath, err)
+	}
+	reportIgnoredPermissions()
+	return true, nil
+}
+
+// extractSelection exports the pages of --raw-selection, which is passed to pdfcpu verbatim after
+// its syntax and page numbers were checked. The chapter plan cannot describe such a selection,
+// so the page count of the output is not verified and a notice says so.
+// Parameters:
+//   - cmd: the running command
+//   - inputFile: pointer to the source PDF file
+//
+// Returns:
+//   - error: if the selection is malformed or selects no page, or the pages cannot be exported
+func extractSelection(cmd *cobra.Command, inputFile *os.File) error {
+	selection, err := api.ParsePageSelection(rawSelection)
+	if err != nil {
+		return fmt.Errorf("invalid --raw-selection '%s': %s", rawSelection, strings.TrimSpace(err.Error()))
+	}
+	pageCount, err := sourcePageCount(inputFile)
+	if err != nil {
+		return failed(cmd, err)
+	}
+	pages, err := api.PagesForPageSelection(pageCount, selection, false, false)
+	if err != nil {
+		return fmt.Errorf("invalid --raw-selection '%s': %v", rawSelection, err)
+	}
+	var selected int
+	for _, ok := range pages {
+		if ok {
+			selected++
+		}
+	}
+	if selected == 0 {
+		return fmt.Errorf("--raw-selection '%s' selects none of the %d pages", rawSelection, pageCount)
+	}
+
+	// Name the file after the source and the selection unless an output path was given
+	outputFilePath := extractOutput
+	if outputFilePath == "" {
+		stem := strings.TrimSuffix(filepath.Base(inputFilePath), filepath.Ext(inputFilePath))
+		outputFilePath = sanitizeFilename(stem+" "+rawSelection) + ".pdf"
+	}
+	written, err := extractPages(inputFile, outputFilePath, rawSelection, "", strings.Join(selection, ","))
+	if err != nil || !written {
+		return failed(cmd, err)
+	}
+	printMsg("extracted_selection", rawSelection, selected, outputFilePath)
+	printMsg("raw_selection_unverified")
+	if validateOutputs {
+		validateOutput(outputFilePath, rawSelection)
+		return failed(cmd, exitOnInvalidOutputs())
+	}
+	return nil
+}
+
+// findChapter returns the planned chapter with the given title.
+// Parameters:
+//   - chapters: planned chapters of the document
+//   - title: exact chapter title
+//
+// Returns:
+//   - chapter: the chapter with that title
+//   - error: if no chapter or several chapters have that title, naming the candidates
+func findChapter(chapters []chapter, title string) (chapter, error) {
+	var matches []chapter
+	for _, cpt := range chapters {
+		if cpt.title == title {
+			matches = append(matches, cpt)
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		suggestions := similarTitles(chapters, title)
+		if len(suggestions) == 0 {
+			return chapter{}, fmt.Errorf("no chapter titled '%s'", title)
+		}
+		return chapter{}, fmt.Errorf("no chapter titled '%s', did you mean %s?", title, strings.Join(suggestions, ", "))
+	}
+	var pages []string
+	for _, m := range matches {
+		pages = append(pages, fmt.Sprintf("page %d", m.startPage))
+	}
+	return chapter{}, fmt.Errorf("%d chapters are titled '%s' (starting at %s), use a sidecar or --under to disambiguate",
+		len(matches), title, strings.Join(pages, ", "))
+}
+
+// similarTitles returns up to maxTitleSuggestions quoted chapter titles resembling title:
+// titles containing it case-insensitively first, then those within a small edit distance.
+func similarTitles(chapters []chapter, title string) []string {
+	type candidate struct {
+		title    string
+		distance int
+	}
+	want := []rune(strings.ToLower(title))
+	var candidates []candidate
+	for _, cpt := range chapters {
+		lower := strings.ToLower(cpt.title)
+		distance := editDistance(want, []rune(lower))
+		if strings.Contains(lower, string(want)) {
+			distance = 0
+		} else if distance > max(len(want)/3, 2) {
+			continue
+		}
+		candidates = append(candidates, candidate{title: cpt.title, distance: distance})
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int { return a.distance - b.distance })
+
+	var result []string
+	for _, c := range candidates[:min(len(candidates), maxTitleSuggestions)] {
+		result = append(result, fmt.Sprintf("'%s'", c.title))
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/souhup/pdf-spliter/ranges"
+)
+
+// chapterFilter selects the chapters to export by --match and --chapters.
+// A chapter is exported only if it passes both; a nil pattern or empty range list passes everything.
+type chapterFilter struct {
+	pattern *regexp.Regexp
+	orders  ranges.List
+}
+
+// exportFilter is the parsed --match and --chapters selection used by processChapters.
+var exportFilter chapterFilter
+
+// parseChapterFilter parses the --match regular expression and the --chapters selection.
+// Parameters:
+//   - pattern: regular expression for chapter titles, or empty
+//   - selection: chapter numbers and ranges such as "3,5,7-9" or "16-", or empty
+//
+// Returns:
+//   - chapterFilter: the parsed filter
+//   - error: if the expression or the selection is malformed
+func parseChapterFilter(pattern, selection string) (chapterFilter, error) {
+	var filter chapterFilter
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --match pattern '%s': %v", pattern, err)
+		}
+		filter.pattern = re
+	}
+	if selection == "" {
+		return filter, nil
+	}
+
+	orders, err := ranges.Parse(selection)
+	if err != nil {
+		return filter, fmt.Errorf("invalid --chapters '%s': %v; use numbers and ranges such as 3,5,7-9 or 16-", selection, err)
+	}
+	filter.orders = orders
+	return filter, nil
+}
+
+// active reports whether the filter excludes anything.
+func (f chapterFilter) active() bool {
+	return f.pattern != nil || len(f.orders) > 0
+}
+
+// selects reports whether a chapter passes the filter.
+// The pattern is matched against the chapter title and its original bookmark title.
+func (f chapterFilter) selects(cpt chapter) bool {
+	if f.pattern != nil && !f.pattern.MatchString(cpt.title) &&
+		(cpt.bookmarkTitle == "" || !f.pattern.MatchString(cpt.bookmarkTitle)) {
+		return false
+	}
+	return len(f.orders) == 0 || f.orders.Contains(int(cpt.order))
+}
+
+// filterChapters keeps the chapters selected by the filter. Their numbers are left unchanged,
+// so a chapter keeps the filename prefix it would have in a full split.
+// Parameters:
+//   - chapters: numbered chapters in export order
+//   - filter: the --match and --chapters selection
+//
+// Returns:
+//   - []chapter: the selected chapters
+//   - error: if no chapter is selected, listing the available chapters
+func filterChapters(chapters []chapter, filter chapterFilter) ([]chapter, error) {
+	var selected []chapter
+	for _, cpt := range chapters {
+		if filter.selects(cpt) {
+			selected = append(selected, cpt)
+		}
+	}
+	if len(selected) > 0 {
+		return selected, nil
+	}
+
+	// List what could have been selected
+	available := make([]string, len(chapters))
+	for i, cpt := range chapters {
+		available[i] = fmt.Sprintf("  %02d %s", cpt.order, cpt.title)
+	}
+	return nil, fmt.Errorf("no chapter matches --match/--chapters; available chapters:\n%s", strings.Join(available, "\n"))
+}
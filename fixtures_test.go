@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+var update = flag.Bool("update", false, "regenerate the PDF fixtures in testdata")
+
+// fixtures are the documents in testdata shared by the tests of this program and of the
+// splitter package, generated with -update. edit, if set, changes the generated document in
+// ways pdfcpu does not write itself, e.g. to corrupt its outline.
+var fixtures = []struct {
+	name      string
+	pageCount int
+	outline   []pdfcpu.Bookmark
+	edit      func(ctx *model.Context) error
+}{
+	// The selftest document: two parts of two chapters with two sections each
+	{"book.pdf", selftestPages, selftestOutline, nil},
+	// Two parts whose first chapter has the first part as its child, a cycle in the outline
+	{"cyclic.pdf", 4, []pdfcpu.Bookmark{
+		{Title: "Part One", PageFrom: 1, Kids: []pdfcpu.Bookmark{{Title: "Chapter 1", PageFrom: 2}}},
+		{Title: "Part Two", PageFrom: 3},
+	}, linkOutlineCycle},
+	// Three chapters of two pages: the first uses the layer Walls, the second the hidden layer
+	// Wiring, the third none
+	{"layers.pdf", 6, []pdfcpu.Bookmark{
+		{Title: "Ground Floor", PageFrom: 1},
+		{Title: "First Floor", PageFrom: 3},
+		{Title: "Notes", PageFrom: 5},
+	}, addLayers},
+}
+
+func TestUpdateFixtures(t *testing.T) {
+	if !*update {
+		t.Skip("run with -update to regenerate the fixtures")
+	}
+	for _, f := range fixtures {
+		path := filepath.Join("testdata", f.name)
+		if err := writeSampleDocument(path, f.pageCount, f.outline); err != nil {
+			t.Fatalf("%s: %v", f.name, err)
+		}
+		if f.edit == nil {
+			continue
+		}
+		ctx, err := api.ReadContextFile(path)
+		if err == nil {
+			err = f.edit(ctx)
+		}
+		if err == nil {
+			err = api.WriteContextFile(ctx, path)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", f.name, err)
+		}
+	}
+}
+
+// linkOutlineCycle makes the first outline item the child of its own first child.
+func linkOutlineCycle(ctx *model.Context) error {
+	root, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	outlines, err := ctx.DereferenceDict(root["Outlines"])
+	if err != nil || outlines == nil {
+		return errors.New("no outline")
+	}
+	first := outlines.IndirectRefEntry("First")
+	part, err := ctx.DereferenceDict(*first)
+	if err != nil || part == nil {
+		return errors.New("no first outline item")
+	}
+	chapter, err := ctx.DereferenceDict(*part.IndirectRefEntry("First"))
+	if err != nil || chapter == nil {
+		return errors.New("first outline item has no child")
+	}
+	chapter["First"], chapter["Last"], chapter["Count"] = *first, *first, types.Integer(1)
+	return nil
+}
+
+// addLayers defines the optional content groups Walls, shown, and Wiring, hidden, and draws a
+// line in Walls on page 2 and in Wiring on page 3.
+func addLayers(ctx *model.Context) error {
+	root, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	var groups types.Array
+	for _, layer := range []struct {
+		name string
+		page int
+	}{{"Walls", 2}, {"Wiring", 3}} {
+		ref, err := ctx.IndRefForNewObject(types.Dict{"Type": types.Name("OCG"), "Name": types.StringLiteral(layer.name)})
+		if err != nil {
+			return err
+		}
+		groups = append(groups, *ref)
+
+		// Give the page resources of its own that name the group
+		page, _, inherited, err := ctx.PageDict(layer.page, false)
+		if err != nil {
+			return err
+		}
+		resources := types.Dict{}
+		if inherited != nil && inherited.Resources != nil {
+			resources = inherited.Resources.Clone().(types.Dict)
+		}
+		if own, err := ctx.DereferenceDict(page["Resources"]); err == nil && own != nil {
+			resources = own.Clone().(types.Dict)
+		}
+		resources["Properties"] = types.Dict{"OC1": *ref}
+		page["Resources"] = resources
+
+		// Mark content with the group, or pdfcpu drops the unused resource when trimming
+		sd, err := ctx.NewStreamDictForBuf([]byte("/OC /OC1 BDC 0 0 m 100 100 l S EMC"))
+		if err == nil {
+			err = sd.Encode()
+		}
+		if err != nil {
+			return err
+		}
+		content, err := ctx.IndRefForNewObject(*sd)
+		if err != nil {
+			return err
+		}
+		page["Contents"] = types.Array{page["Contents"], *content}
+	}
+	root["OCProperties"] = types.Dict{
+		"OCGs": groups,
+		"D": types.Dict{
+			"Order": types.Array{groups[0], groups[1]},
+			"ON":    types.Array{groups[0]},
+			"OFF":   types.Array{groups[1]},
+		},
+	}
+	return nil
+}
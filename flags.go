@@ -0,0 +1,791 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// flagInteraction describes how two or more flags affect each other.
+// Rules with a violated check are enforced when a split or list starts; the others only document
+// an interaction. The same table is printed by explain-flags, so the documentation
+// cannot drift from the validation.
+type flagInteraction struct {
+	flags    []string
+	note     string
+	violated func() bool
+}
+
+// flagInteractions is the single source of truth for flag exclusions and interactions.
+var flagInteractions = []flagInteraction{
+	{
+		flags:    []string{"single-output", "under"},
+		note:     "--single-output cannot be combined with more than one --under",
+		violated: func() bool { return singleOutput != "" && len(underTitles) > 1 },
+	},
+	{
+		flags:    []string{"overwrite", "skip-existing"},
+		note:     "--overwrite cannot be combined with --skip-existing",
+		violated: func() bool { return overwrite && skipExisting },
+	},
+	{
+		flags:    []string{"strict-pages", "no-verify"},
+		note:     "--strict-pages cannot be combined with --no-verify",
+		violated: func() bool { return strictPages && noVerify },
+	},
+	{
+		flags:    []string{"no-output", "archive-source"},
+		note:     "--no-output cannot be combined with --archive-source",
+		violated: func() bool { return noOutput && archiveDir != "" },
+	},
+	{
+		flags:    []string{"input", "single-output"},
+		note:     "--single-output cannot be combined with several inputs, which would all write the same file",
+		violated: func() bool { return singleOutput != "" && isBatch() },
+	},
+	{
+		flags:    []string{"archive-layout", "single-output"},
+		note:     "--archive-layout cannot be combined with --single-output, which names its output file itself",
+		violated: func() bool { return archiveLayout && singleOutput != "" },
+	},
+	{
+		flags: []string{"archive-date", "archive-layout"},
+		note:  "--archive-date only has an effect with --archive-layout",
+	},
+	{
+		flags: []string{"archive-layout", "archive-source"},
+		note:  "with --archive-layout the archive log records the partition directory as the output location",
+	},
+	{
+		flags:    []string{"process-attachments", "single-output"},
+		note:     "--process-attachments cannot be combined with --single-output, which has room for one document only",
+		violated: func() bool { return processAttached && singleOutput != "" },
+	},
+	{
+		flags: []string{"process-attachments", "under"},
+		note:  "--under selects subtrees of the input only; attachments are always split as a whole",
+	},
+	{
+		flags:    []string{"by-pages", "pages-per-file"},
+		note:     "--by-pages requires --pages-per-file",
+		violated: func() bool { return byPages && pagesPerFile == 0 },
+	},
+	{
+		flags:    []string{"by-pages", "under"},
+		note:     "--by-pages cannot be combined with --under, which selects bookmarks",
+		violated: func() bool { return byPages && len(underTitles) > 0 },
+	},
+	{
+		flags: []string{"pages-per-file", "sidecar-suffix"},
+		note:  "page chunks are only used for documents with neither an outline nor a chapter sidecar, unless --by-pages is given",
+	},
+	{
+		flags:    []string{"dry-run", "no-output"},
+		note:     "--dry-run cannot be combined with --no-output; use --no-output for the full checks, --dry-run for the plan",
+		violated: func() bool { return dryRun != "" && noOutput },
+	},
+	{
+		flags:    []string{"dry-run", "archive-source"},
+		note:     "--dry-run cannot be combined with --archive-source",
+		violated: func() bool { return dryRun != "" && archiveDir != "" },
+	},
+	{
+		flags: []string{"under", "sidecar-suffix"},
+		note:  "a chapter sidecar is ignored when --under selects a subtree of the outline",
+	},
+	{
+		flags: []string{"sidecar-suffix", "mid-page-start"},
+		note:  "chapters read from a sidecar have no destinations, so --mid-page-start has no effect",
+	},
+	{
+		flags: []string{"depth", "no-auto-descend"},
+		note:  "the outline is only descended automatically with --depth 1; deeper levels count from the top of the outline",
+	},
+	{
+		flags: []string{"depth", "under"},
+		note:  "with --under, --depth counts levels below the selected bookmark",
+	},
+	{
+		flags: []string{"under", "no-auto-descend"},
+		note:  "the outline is only descended automatically when no --under is given",
+	},
+	{
+		flags: []string{"no-overlap", "mid-page-start"},
+		note:  "--mid-page-start decides every boundary from the bookmark positions, so --no-overlap has no effect",
+	},
+	{
+		flags: []string{"mid-page-start", "lookback"},
+		note:  "--lookback is applied after the --mid-page-start decision and may move the start further back",
+	},
+	{
+		flags: []string{"truncate-at-page", "detect-duplication"},
+		note:  "--detect-duplication suggests a --truncate-at-page value and stays quiet if one is already given",
+	},
+	{
+		flags: []string{"title-from", "order-by"},
+		note:  "--order-by title sorts by the final titles, after --title-from was applied",
+	},
+	{
+		flags: []string{"pad-to-even", "strict-pages"},
+		note:  "pages added by --pad-to-even are expected by the page count check",
+	},
+	{
+		flags:    []string{"continuation-page", "single-output"},
+		note:     "--continuation-page cannot be combined with --single-output, which writes no separate outputs to point to",
+		violated: func() bool { return continuationPage && singleOutput != "" },
+	},
+	{
+		flags: []string{"strip-blank-pages", "pad-to-even"},
+		note:  "--pad-to-even counts the pages left after --strip-blank-pages and may add a blank page again",
+	},
+	{
+		flags: []string{"strip-blank-pages", "mid-page-start"},
+		note:  "a chapter starting in the middle of a page keeps its first page, which shows the end of the previous chapter",
+	},
+	{
+		flags: []string{"continuation-page", "pad-to-even"},
+		note:  "the continuation page counts towards the even page count, so --pad-to-even adds its blank page after it",
+	},
+	{
+		flags:    []string{"signature-size", "pad-to-even"},
+		note:     "--signature-size already pads every output to a multiple of its size and cannot be combined with --pad-to-even",
+		violated: func() bool { return signatureSize > 0 && padToEven },
+	},
+	{
+		flags:    []string{"signature-size", "pages-per-file"},
+		note:     "--pages-per-file must be a multiple of --signature-size, so only the last output is padded",
+		violated: func() bool { return signatureSize > 0 && pagesPerFile%signatureSize != 0 },
+	},
+	{
+		flags:    []string{"signature-size", "target-pages"},
+		note:     "--target-pages must be a multiple of --signature-size, so a padded output stays within the target",
+		violated: func() bool { return signatureSize > 0 && targetPages%signatureSize != 0 },
+	},
+	{
+		flags: []string{"continuation-page", "signature-size"},
+		note:  "the continuation page counts towards the signature, so --signature-size pads after it",
+	},
+	{
+		flags: []string{"continuation-page", "chapters"},
+		note:  "a continuation page names the next chapter that is exported, skipping those left out by --chapters and --match",
+	},
+	{
+		flags: []string{"chapter-timeout", "bandwidth"},
+		note:  "with --chapter-timeout a chapter is exported into memory first and throttled only while it is written",
+	},
+	{
+		flags: []string{"target-pages", "order-by"},
+		note:  "--target-pages combines neighbors in page order before --order-by numbers the outputs",
+	},
+	{
+		flags: []string{"chapters", "target-pages"},
+		note:  "--chapters selects by the numbers of the outputs after --target-pages and --min-pages have combined chapters",
+	},
+	{
+		flags:    []string{"sample-seed", "sample"},
+		note:     "--sample-seed draws the chapters and inputs of --sample and requires it",
+		violated: func() bool { return sampleRandom && sampleEvery == 0 },
+	},
+	{
+		flags: []string{"sample", "chapters"},
+		note:  "--sample picks from the chapters left by --match and --chapters",
+	},
+	{
+		flags: []string{"low-memory", "workers"},
+		note:  "with --low-memory every worker parses the source on its own, so memory use grows with --workers",
+	},
+	{
+		flags: []string{"workers", "bandwidth"},
+		note:  "--bandwidth limits the combined write rate of all --workers",
+	},
+	{
+		flags: []string{"manifest", "dry-run"},
+		note:  "with --dry-run the manifest is printed to stdout in place of the plan instead of being written",
+	},
+	{
+		flags: []string{"manifest", "skip-existing"},
+		note:  "--skip-existing keeps an existing manifest as it is, like the chapter files",
+	},
+	{
+		flags:    []string{"output", "manifest"},
+		note:     "-o - writes a single chapter to stdout and cannot be combined with --manifest",
+		violated: func() bool { return writesStdout() && manifestFile != "" },
+	},
+	{
+		flags:    []string{"output", "dry-run"},
+		note:     "-o - cannot be combined with --dry-run, which prints the plan to stdout",
+		violated: func() bool { return writesStdout() && dryRun != "" },
+	},
+	{
+		flags:    []string{"output", "single-output"},
+		note:     "-o - cannot be combined with --single-output",
+		violated: func() bool { return writesStdout() && singleOutput != "" },
+	},
+	{
+		flags:    []string{"output", "also-link"},
+		note:     "-o - cannot be combined with --also-link, as no chapter file is kept",
+		violated: func() bool { return writesStdout() && len(alsoLink) > 0 },
+	},
+	{
+		flags:    []string{"output", "under"},
+		note:     "-o - cannot be combined with more than one --under",
+		violated: func() bool { return writesStdout() && len(underTitles) > 1 },
+	},
+	{
+		flags:    []string{"output", "process-attachments"},
+		note:     "-o - cannot be combined with --process-attachments, which writes further outputs",
+		violated: func() bool { return writesStdout() && processAttached },
+	},
+	{
+		flags:    []string{"output", "extract"},
+		note:     "-o - cannot be combined with --extract, which writes further files next to the chapter",
+		violated: func() bool { return writesStdout() && len(extractValues) > 0 },
+	},
+	{
+		flags:    []string{"input", "archive-source"},
+		note:     "-i - cannot be combined with --archive-source, as stdin cannot be moved",
+		violated: func() bool { return readsStdin() && archiveDir != "" },
+	},
+	{
+		flags: []string{"input", "sidecar-suffix"},
+		note:  "a source read from stdin with -i - has no chapter sidecar",
+	},
+	{
+		flags:    []string{"quiet", "verbose"},
+		note:     "--quiet cannot be combined with --verbose",
+		violated: func() bool { return quiet && verbose },
+	},
+	{
+		flags:    []string{"quiet", "explain"},
+		note:     "--quiet cannot be combined with --explain, whose output it would suppress",
+		violated: func() bool { return quiet && explainPlan },
+	},
+	{
+		flags: []string{"quiet", "strict"},
+		note:  "--quiet still prints warnings, and the list of warnings a --strict run fails on",
+	},
+	{
+		flags:    []string{"detect-headings", "by-pages"},
+		note:     "--detect-headings cannot be combined with --by-pages",
+		violated: func() bool { return detectHeadings && byPages },
+	},
+	{
+		flags:    []string{"detect-headings", "under"},
+		note:     "--detect-headings cannot be combined with --under, which selects bookmarks",
+		violated: func() bool { return detectHeadings && len(underTitles) > 0 },
+	},
+	{
+		flags: []string{"detect-headings", "sidecar-suffix"},
+		note:  "detected headings replace the outline and a chapter sidecar; both are only used if no page matches --heading-pattern",
+	},
+	{
+		flags:    []string{"split-on-barcode", "detect-headings"},
+		note:     "--split-on-barcode cannot be combined with --detect-headings",
+		violated: func() bool { return splitOnBarcode && detectHeadings },
+	},
+	{
+		flags:    []string{"split-on-barcode", "by-pages"},
+		note:     "--split-on-barcode cannot be combined with --by-pages",
+		violated: func() bool { return splitOnBarcode && byPages },
+	},
+	{
+		flags:    []string{"split-on-barcode", "under"},
+		note:     "--split-on-barcode cannot be combined with --under, which selects bookmarks",
+		violated: func() bool { return splitOnBarcode && len(underTitles) > 0 },
+	},
+	{
+		flags: []string{"split-on-barcode", "name-template"},
+		note:  "with --split-on-barcode, documents are named {title}, the barcode value, unless --name-template is given",
+	},
+	{
+		flags: []string{"split-on-barcode", "verbose"},
+		note:  "with --verbose every page with a barcode or QR code is reported, with the confidence it was read with",
+	},
+	{
+		flags: []string{"barcode-pages", "split-on-barcode"},
+		note:  "--barcode-pages, --barcode-confidence and --barcode-leading-name only have an effect with --split-on-barcode",
+	},
+	{
+		flags: []string{"heading-pattern", "detect-headings"},
+		note:  "--heading-pattern only has an effect with --detect-headings",
+	},
+	{
+		flags: []string{"logical-offset", "page-offset"},
+		note:  "--logical-offset only changes how pages are shown, --page-offset moves the chapters of --toc-from-pdf; printed numbers refer to the input",
+	},
+	{
+		flags:    []string{"logical-offset", "name-template"},
+		note:     "the --name-template placeholders {logical_start} and {logical_end} require --logical-offset",
+		violated: func() bool { return logicalOffsetText == "" && nameTemplateParsed.UsesLogicalPages() },
+	},
+	{
+		flags:    []string{"compare-previous", "input"},
+		note:     "--compare-previous compares the chapters of one document and cannot be combined with several inputs",
+		violated: func() bool { return comparePrevious != "" && isBatch() },
+	},
+	{
+		flags:    []string{"fail-on-structure-change", "compare-previous"},
+		note:     "--fail-on-structure-change, --compare-report and --resize-threshold require --compare-previous",
+		violated: func() bool { return (failOnStructureChange || compareReport != "") && comparePrevious == "" },
+	},
+	{
+		flags: []string{"pages-as", "exclude-pages"},
+		note:  "--pages-as labels reads --exclude-pages and --barcode-pages as page labels of every input; --logical-offset, --truncate-at-page and the other page flags still take physical pages",
+	},
+	{
+		flags: []string{"logical-offset", "truncate-at-page"},
+		note:  "--truncate-at-page and the other page flags take physical pages, not printed ones",
+	},
+	{
+		flags:    []string{"toc-from-pdf", "input"},
+		note:     "--toc-from-pdf cannot be combined with several inputs or a directory, which are different documents",
+		violated: func() bool { return tocFromPDF != "" && isBatch() },
+	},
+	{
+		flags:    []string{"page-offset", "toc-from-pdf"},
+		note:     "--page-offset moves the chapters of --toc-from-pdf and requires it",
+		violated: func() bool { return pageOffsetSet && tocFromPDF == "" },
+	},
+	{
+		flags: []string{"toc-from-pdf", "sidecar-suffix"},
+		note:  "with --toc-from-pdf the chapter sidecar is looked up next to that document, and --detect-headings reads its pages",
+	},
+	{
+		flags: []string{"toc-from-pdf", "truncate-at-page"},
+		note:  "--truncate-at-page counts the pages of the --toc-from-pdf document, before --page-offset is added",
+	},
+	{
+		flags: []string{"toc-from-pdf", "title-from"},
+		note:  "--title-from reads the headings from the pages of the input, not of the --toc-from-pdf document",
+	},
+	{
+		flags:    []string{"plan", "min-pages"},
+		note:     "--plan cannot be combined with --min-pages, which also groups chapters",
+		violated: func() bool { return planFile != "" && minPages > 0 },
+	},
+	{
+		flags:    []string{"plan", "target-pages"},
+		note:     "--plan cannot be combined with --target-pages, which also groups chapters",
+		violated: func() bool { return planFile != "" && targetPages > 0 },
+	},
+	{
+		flags: []string{"plan", "order-by"},
+		note:  "the chapter numbers of the --plan file are those of --order-by; the outputs are numbered in the order of the file",
+	},
+	{
+		flags: []string{"plan", "chapters"},
+		note:  "--chapters, --match and --sample select among the outputs of the --plan file, by their numbers in the file",
+	},
+	{
+		flags: []string{"plan", "name-template"},
+		note:  "with --plan, outputs are named {title}, their name in the plan, unless --name-template is given",
+	},
+	{
+		flags: []string{"strict-plan", "plan"},
+		note:  "--strict-plan only has an effect with --plan",
+	},
+	{
+		flags:    []string{"profile", "dry-run", "no-output"},
+		note:     "--profile cannot be combined with --dry-run or --no-output",
+		violated: func() bool { return len(profileValues) > 0 && (dryRun != "" || noOutput) },
+	},
+	{
+		flags:    []string{"profile", "single-output", "dest-cmd"},
+		note:     "--profile cannot be combined with --single-output, -o - or --dest-cmd, which take the files of one split",
+		violated: func() bool { return len(profileValues) > 0 && (singleOutput != "" || writesStdout() || destCmd != "") },
+	},
+	{
+		flags:    []string{"profile", "manifest"},
+		note:     "with --profile, a relative --manifest is written into the directory of every profile; an absolute one cannot be used",
+		violated: func() bool { return len(profileValues) > 0 && filepath.IsAbs(manifestFile) },
+	},
+	{
+		flags: []string{"profile", "depth", "name-template"},
+		note:  "--depth and --name-template are the defaults of the profiles that do not set level or template",
+	},
+	{
+		flags: []string{"fail-fast", "profile"},
+		note:  "--fail-fast only has an effect with --profile",
+	},
+	{
+		flags: []string{"allow-absolute-output-dirs", "plan"},
+		note:  "--allow-absolute-output-dirs only has an effect with --plan",
+	},
+	{
+		flags:    []string{"allow-absolute-output-dirs", "dest-cmd"},
+		note:     "--allow-absolute-output-dirs cannot be combined with --dest-cmd, which receives paths relative to --output",
+		violated: func() bool { return allowAbsoluteDirs && destCmd != "" },
+	},
+	{
+		flags:    []string{"allow-resplit", "resplit"},
+		note:     "--allow-resplit cannot be combined with --resplit",
+		violated: func() bool { return allowResplit && resplit },
+	},
+	{
+		flags: []string{"resplit", "archive-source"},
+		note:  "with --resplit, --archive-source moves the source that is split instead of the chapter given as input",
+	},
+	{
+		flags: []string{"resplit", "input"},
+		note:  "--resplit replaces every chapter among the inputs by its source, which is split once per chapter given",
+	},
+	{
+		flags:    []string{"infer-missing-destinations", "mid-page-start"},
+		note:     "--infer-missing-destinations cannot be combined with --mid-page-start, which needs the exact destination of every chapter",
+		violated: func() bool { return inferMissing && midPageStart != "" },
+	},
+	{
+		flags: []string{"infer-missing-destinations", "dry-run"},
+		note:  "--dry-run marks estimated start pages with ~ for review before splitting with --yes",
+	},
+	{
+		flags: []string{"yes", "infer-missing-destinations"},
+		note:  "--yes only has an effect when --infer-missing-destinations estimated start pages",
+	},
+	{
+		flags: []string{"min-pages", "target-pages"},
+		note:  "--min-pages merges short chapters first; --target-pages then packs the merged chapters",
+	},
+	{
+		flags: []string{"keep-bookmarks", "pack-bookmarks"},
+		note:  "with both, the sub-bookmarks of every packed chapter are nested below its --pack-bookmarks entry",
+	},
+	{
+		flags: []string{"pack-bookmarks", "single-output"},
+		note:  "with --single-output the combined file has one bookmark per output, so --pack-bookmarks has no effect",
+	},
+	{
+		flags:    []string{"extract", "single-output"},
+		note:     "--extract cannot be combined with --single-output, which writes no chapter files to put the assets next to",
+		violated: func() bool { return len(extractValues) > 0 && singleOutput != "" },
+	},
+	{
+		flags: []string{"extract", "skip-existing"},
+		note:  "chapters kept by --skip-existing are not extracted again",
+	},
+	{
+		flags: []string{"extract", "continuation-page"},
+		note:  "--extract reads the pages of the source, so the text and images of added pages are not extracted",
+	},
+	{
+		flags: []string{"stamp-header-size", "stamp-header"},
+		note:  "--stamp-header-size only has an effect with --stamp-header",
+	},
+	{
+		flags: []string{"stamp-header", "continuation-page"},
+		note:  "pages added after trimming, by --continuation-page, --pad-to-even or --signature-size, get no --stamp-header line",
+	},
+	{
+		flags: []string{"no-retitle", "single-output"},
+		note:  "the --single-output file always keeps the source's Title",
+	},
+	{
+		flags: []string{"orphan-pages", "chapters"},
+		note:  "--orphan-pages looks for pages no chapter covers before --chapters, --match and --sample select some; the collected pages are numbered after the last chapter",
+	},
+	{
+		flags: []string{"orphan-pages", "strip-blank-pages"},
+		note:  "blank pages dropped by --strip-blank-pages are orphan pages, which collect and attach-previous export again",
+	},
+	{
+		flags: []string{"orphan-pages", "under"},
+		note:  "the pages outside the bookmark of --under are orphan pages",
+	},
+	{
+		flags: []string{"orphan-pages", "split-on-barcode"},
+		note:  "the separator pages of --split-on-barcode are orphan pages",
+	},
+	{
+		flags:    []string{"dest-cmd", "single-output"},
+		note:     "--dest-cmd cannot be combined with --single-output or -o -, which write one file themselves",
+		violated: func() bool { return destCmd != "" && (singleOutput != "" || writesStdout()) },
+	},
+	{
+		flags:    []string{"dest-cmd", "skip-existing"},
+		note:     "--dest-cmd cannot be combined with --skip-existing, which looks for the files in the output directory",
+		violated: func() bool { return destCmd != "" && skipExisting },
+	},
+	{
+		flags:    []string{"dest-cmd", "also-link", "extract"},
+		note:     "--dest-cmd cannot be combined with --also-link or --extract, which need the files in the output directory",
+		violated: func() bool { return destCmd != "" && (len(alsoLink) > 0 || len(extractAssets) > 0) },
+	},
+	{
+		flags: []string{"dest-cmd", "input"},
+		note:  "in batch mode every document delivers names relative to its own output directory; {source} in --name-template tells them apart",
+	},
+	{
+		flags: []string{"review-threshold", "strict"},
+		note:  "with --strict, warning W029 of a document below --review-threshold fails the run with exit code 5 after it was written into _needs_review",
+	},
+	{
+		flags: []string{"review-threshold", "input"},
+		note:  "in batch mode every document below --review-threshold is written into _needs_review inside its own output directory",
+	},
+	{
+		flags: []string{"name-template", "chapters"},
+		note:  "chapters given the same name by --name-template are numbered _2, _3 before --chapters, --match and --sample select some",
+	},
+	{
+		flags: []string{"max-name-length", "target-fs"},
+		note:  "--max-name-length counts bytes and applies on top of the length limit of --target-fs",
+	},
+	{
+		flags: []string{"name-template", "single-output"},
+		note:  "--name-template names chapter files only; the --single-output file keeps its path",
+	},
+	{
+		flags: []string{"also-link", "single-output"},
+		note:  "--also-link publishes chapter files only; the --single-output file is not linked",
+	},
+	{
+		flags: []string{"image-quality", "subset-resources"},
+		note:  "images are recompressed before --subset-resources measures its savings",
+	},
+	{
+		flags: []string{"subset-resources", "bloat-factor"},
+		note:  "--bloat-factor compares the chapter files after --subset-resources removed unused resources",
+	},
+	{
+		flags: []string{"subset-resources", "single-output"},
+		note:  "--subset-resources applies to chapter files; the --single-output file keeps all resources",
+	},
+	{
+		flags: []string{"strict", "fail-on-lossy-names"},
+		note:  "--fail-on-lossy-names fails before writing, --strict fails at the end of the run on any warning",
+	},
+	{
+		flags: []string{"fail-on-unsupported", "allow-untagged-output"},
+		note:  "--fail-on-unsupported tagged fails on tagged sources even with --allow-untagged-output",
+	},
+	{
+		flags: []string{"strict", "allow-untagged-output"},
+		note:  "--allow-untagged-output silences the tagged PDF warning, so it does not fail a --strict run",
+	},
+}
+
+// flagExamples holds a one-line example invocation per flag.
+var flagExamples = map[string]string{
+	"input":                      "pdf-split -i book.pdf",
+	"output":                     "pdf-split -i book.pdf -o chapters",
+	"title-from":                 "pdf-split -i vendor.pdf --title-from structure --dry-run",
+	"mid-page-start":             "pdf-split -i book.pdf --mid-page-start previous",
+	"single-output":              "pdf-split -i book.pdf --single-output combined.pdf",
+	"under":                      "pdf-split -i standards.pdf --under \"ISO 12345\"",
+	"fail-on-lossy-names":        "pdf-split -i book.pdf --fail-on-lossy-names",
+	"bandwidth":                  "pdf-split -i book.pdf -o /mnt/share --bandwidth 10MB/s",
+	"archive-source":             "pdf-split -i inbox/book.pdf --archive-source done",
+	"detect-duplication":         "pdf-split -i scan.pdf --detect-duplication",
+	"truncate-at-page":           "pdf-split -i scan.pdf --truncate-at-page 240",
+	"explain":                    "pdf-split -i book.pdf --explain",
+	"no-auto-descend":            "pdf-split -i book.pdf --no-auto-descend",
+	"strict-pages":               "pdf-split -i book.pdf --strict-pages",
+	"no-verify":                  "pdf-split -i huge.pdf --no-verify",
+	"strip-blank-pages":          "pdf-split -i book.pdf --strip-blank-pages",
+	"pad-to-even":                "pdf-split -i book.pdf --pad-to-even",
+	"signature-size":             "pdf-split -i book.pdf --signature-size 16",
+	"continuation-page":          "pdf-split -i handbook.pdf --continuation-page",
+	"allow-untagged-output":      "pdf-split -i tagged.pdf --allow-untagged-output",
+	"sidecar-suffix":             "pdf-split -i book.pdf --sidecar-suffix .toc.txt",
+	"bloat-factor":               "pdf-split -i book.pdf --bloat-factor 5",
+	"no-output":                  "pdf-split -i delivery.pdf --no-output --strict",
+	"lookback":                   "pdf-split -i book.pdf --lookback 1",
+	"max-outline-entries":        "pdf-split -i upload.pdf --max-outline-entries 10000",
+	"max-chapters":               "pdf-split -i upload.pdf --max-chapters 1000",
+	"max-title-length":           "pdf-split -i upload.pdf --max-title-length 512",
+	"strict":                     "pdf-split -i book.pdf --strict",
+	"match":                      "pdf-split -i book.pdf --match '(?i)network'",
+	"chapters":                   "pdf-split -i book.pdf --chapters 3,5,7-9,16-",
+	"exclude-pages":              "pdf-split -i scan.pdf --exclude-pages 1-2,200-",
+	"pages-as":                   "pdf-split -i book.pdf --exclude-pages iv,ix --pages-as labels",
+	"compare-previous":           "pdf-split -i handbook.pdf --compare-previous last/manifest.json --fail-on-structure-change",
+	"compare-report":             "pdf-split -i handbook.pdf --compare-previous last/manifest.json --compare-report changes.json",
+	"resize-threshold":           "pdf-split -i handbook.pdf --compare-previous last/manifest.json --resize-threshold 0.5",
+	"fail-on-structure-change":   "pdf-split -i handbook.pdf --compare-previous last/manifest.json --fail-on-structure-change",
+	"user-password":              "PDF_SPLIT_PASSWORD=secret pdf-split -i locked.pdf",
+	"owner-password":             "pdf-split -i locked.pdf --owner-password \"$OWNER_PW\"",
+	"password-file":              "pdf-split -i inbox --password-file passwords.csv",
+	"keep-encryption":            "pdf-split -i locked.pdf --owner-password \"$OWNER_PW\" --user-password \"$USER_PW\" --keep-encryption",
+	"validate-outputs":           "pdf-split -i book.pdf --validate-outputs",
+	"run-id":                     "pdf-split -i book.pdf --dry-run=json --run-id \"$PIPELINE_RUN\"",
+	"sample":                     "pdf-split -i scans/ --sample 5 --sample-seed 42",
+	"sample-seed":                "pdf-split -i scans/ --sample 5 --sample-seed 42",
+	"sample-dir":                 "pdf-split -i scans/ --sample 5 --sample-dir review",
+	"min-pages":                  "pdf-split -i novel.pdf --min-pages 3",
+	"stamp-id":                   "pdf-split -i book.pdf --stamp-id text",
+	"stamp-header":               "pdf-split -i book.pdf --stamp-header bottom",
+	"stamp-header-size":          "pdf-split -i book.pdf --stamp-header top --stamp-header-size 6",
+	"orphan-pages":               "pdf-split -i book.pdf --plan volumes.yaml --orphan-pages collect",
+	"dest-cmd":                   "pdf-split -i book.pdf --dest-cmd 'dms-upload --folder books {name}'",
+	"review-threshold":           "pdf-split -i scans/ --detect-headings --review-threshold 0.7",
+	"no-retitle":                 "pdf-split -i book.pdf --no-retitle",
+	"keep-bookmarks":             "pdf-split -i book.pdf --keep-bookmarks",
+	"overwrite":                  "pdf-split -i book.pdf --overwrite",
+	"skip-existing":              "pdf-split -i book.pdf --skip-existing",
+	"target-fs":                  "pdf-split -i book.pdf -o /media/usb/book --target-fs fat",
+	"max-name-length":            "pdf-split -i book.pdf --max-name-length 120",
+	"manifest":                   "pdf-split -i book.pdf --manifest manifest.json",
+	"detect-headings":            "pdf-split -i scan.pdf --detect-headings",
+	"plan":                       "pdf-split -i book.pdf --plan volumes.yaml",
+	"strict-plan":                "pdf-split -i book.pdf --plan volumes.yaml --strict-plan",
+	"profile":                    "pdf-split -i book.pdf --profile print:level=1,output=parts --profile web:level=2,template={order}_{title}",
+	"fail-fast":                  "pdf-split -i book.pdf --profile print:level=1 --profile web:level=2 --fail-fast",
+	"allow-absolute-output-dirs": "pdf-split -i book.pdf --plan departments.yaml --allow-absolute-output-dirs",
+	"split-on-barcode":           "pdf-split -i batch.pdf --split-on-barcode",
+	"barcode-pages":              "pdf-split -i batch.pdf --split-on-barcode --barcode-pages odd",
+	"barcode-confidence":         "pdf-split -i batch.pdf --split-on-barcode --barcode-confidence 0.3 -v",
+	"barcode-leading-name":       "pdf-split -i batch.pdf --split-on-barcode --barcode-leading-name unsorted",
+	"heading-pattern":            "pdf-split -i thesis.pdf --detect-headings --heading-pattern '^Kapitel \\d+'",
+	"logical-offset":             "pdf-split -i book.pdf --logical-offset auto --dry-run",
+	"toc-from-pdf":               "pdf-split -i scan.pdf --toc-from-pdf text-edition.pdf",
+	"page-offset":                "pdf-split -i scan.pdf --toc-from-pdf text-edition.pdf --page-offset 2",
+	"infer-missing-destinations": "pdf-split -i export.pdf --infer-missing-destinations --dry-run",
+	"yes":                        "pdf-split -i export.pdf --infer-missing-destinations --yes",
+	"allow-resplit":              "pdf-split -i 03_Networking.pdf --allow-resplit",
+	"resplit":                    "pdf-split -i 03_Networking.pdf --resplit",
+	"workers":                    "pdf-split -i standard.pdf --workers 4",
+	"low-memory":                 "pdf-split -i scans.pdf --low-memory --workers 1",
+	"read-retries":               "pdf-split -i /mnt/nfs/book.pdf --read-retries 5 -v",
+	"no-overlap":                 "pdf-split -i slides.pdf --no-overlap=false",
+	"name-template":              "pdf-split -i book.pdf --name-template '{source} - {order:1}. {title}'",
+	"dry-run":                    "pdf-split -i manual.pdf --dry-run=json | jq '.files[].target'",
+	"pages-per-file":             "pdf-split -i scan.pdf --pages-per-file 50",
+	"by-pages":                   "pdf-split -i book.pdf --by-pages --pages-per-file 50",
+	"depth":                      "pdf-split -i textbook.pdf -d 2",
+	"image-quality":              "pdf-split -i book.pdf -o web --image-quality web",
+	"subset-resources":           "pdf-split -i book.pdf --subset-resources",
+	"process-attachments":        "pdf-split -i proceedings.pdf --process-attachments",
+	"also-link":                  "pdf-split -i book.pdf -o by-order --also-link by-title:{title}",
+	"extract":                    "pdf-split -i book.pdf --extract text,images",
+	"target-pages":               "pdf-split -i journal.pdf --target-pages 30",
+	"pack-joiner":                "pdf-split -i journal.pdf --target-pages 30 --pack-joiner \" & \"",
+	"pack-bookmarks":             "pdf-split -i journal.pdf --target-pages 30 --pack-bookmarks",
+	"fail-on-unsupported":        "pdf-split -i form.pdf --fail-on-unsupported xfa,signatures",
+	"archive-layout":             "pdf-split -i report.pdf -o archive --archive-layout",
+	"archive-date":               "pdf-split -i report.pdf -o archive --archive-layout --archive-date run",
+	"chapter-timeout":            "pdf-split -i damaged.pdf --chapter-timeout 2m",
+	"order-by":                   "pdf-split -i articles.pdf --order-by title",
+	"lang":                       "pdf-split -i book.pdf --lang de",
+	"verbose":                    "pdf-split -i book.pdf -v",
+	"quiet":                      "pdf-split -i book.pdf -q",
+}
+
+// checkFlagInteractions returns an error for the first violated flag exclusion.
+func checkFlagInteractions() error {
+	for _, rule := range flagInteractions {
+		if rule.violated != nil && rule.violated() {
+			return fmt.Errorf("%s", rule.note)
+		}
+	}
+	return nil
+}
+
+// checkPlanFlags validates the values of the flags split and list share to plan the chapters.
+func checkPlanFlags() error {
+	switch orderBy {
+	case orderByPage, orderByOutline, orderByTitle:
+	default:
+		return fmt.Errorf("invalid --order-by value '%s': must be %s, %s or %s", orderBy, orderByPage, orderByOutline, orderByTitle)
+	}
+	switch midPageStart {
+	case "", midPageStartPrevious, midPageStartNext, midPageStartDuplicate:
+	default:
+		return fmt.Errorf("invalid --mid-page-start value '%s': must be %s, %s or %s",
+			midPageStart, midPageStartPrevious, midPageStartNext, midPageStartDuplicate)
+	}
+	if splitDepth < 1 {
+		return fmt.Errorf("invalid --depth value %d: must be at least 1", splitDepth)
+	}
+	if lookback < 0 {
+		return fmt.Errorf("invalid --lookback value %d: must not be negative", lookback)
+	}
+	return nil
+}
+
+// flagReference is the documentation of one flag printed by explain-flags.
+type flagReference struct {
+	Name         string   `json:"name"`
+	Shorthand    string   `json:"shorthand,omitempty"`
+	Type         string   `json:"type"`
+	Default      string   `json:"default"`
+	Usage        string   `json:"usage"`
+	Example      string   `json:"example,omitempty"`
+	Exclusions   []string `json:"exclusions,omitempty"`
+	Interactions []string `json:"interactions,omitempty"`
+}
+
+// explainFlagsFormat is the output format of explain-flags: text or json.
+var explainFlagsFormat string
+
+var explainFlagsCmd = &cobra.Command{
+	Use:   "explain-flags",
+	Short: "Describe every flag with its default, interactions and an example",
+	Args:  cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		refs := flagReferences(rootCmd.Flags())
+		switch explainFlagsFormat {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(refs)
+		case "text":
+			printFlagReferences(refs)
+			return nil
+		}
+		return fmt.Errorf("invalid --format value '%s': must be text or json", explainFlagsFormat)
+	},
+}
+
+// flagReferences builds the reference of all flags of a flag set, in alphabetical order.
+func flagReferences(flags *pflag.FlagSet) []flagReference {
+	var refs []flagReference
+	flags.VisitAll(func(f *pflag.Flag) {
+		ref := flagReference{
+			Name:      f.Name,
+			Shorthand: f.Shorthand,
+			Type:      f.Value.Type(),
+			Default:   f.DefValue,
+			Usage:     f.Usage,
+			Example:   flagExamples[f.Name],
+		}
+		for _, rule := range flagInteractions {
+			if !slices.Contains(rule.flags, f.Name) {
+				continue
+			}
+			if rule.violated != nil {
+				ref.Exclusions = append(ref.Exclusions, rule.note)
+			} else {
+				ref.Interactions = append(ref.Interactions, rule.note)
+			}
+		}
+		refs = append(refs, ref)
+	})
+	return refs
+}
+
+// printFlagReferences prints the flag reference as plain text.
+func printFlagReferences(refs []flagReference) {
+	for _, ref := range refs {
+		name := "--" + ref.Name
+		if ref.Shorthand != "" {
+			name = "-" + ref.Shorthand + ", " + name
+		}
+		fmt.Printf("%s (%s, default %q)\n", name, ref.Type, ref.Default)
+		fmt.Printf("    %s\n", ref.Usage)
+		for _, note := range ref.Exclusions {
+			fmt.Printf("    excludes: %s\n", note)
+		}
+		for _, note := range ref.Interactions {
+			fmt.Printf("    interacts: %s\n", note)
+		}
+		if ref.Example != "" {
+			fmt.Printf("    example: %s\n", ref.Example)
+		}
+		fmt.Println()
+	}
+}
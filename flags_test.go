@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFlagInteractionsEnforced runs conflicting flags, which must fail with the note of their
+// flagInteractions entry before anything is written.
+func TestFlagInteractionsEnforced(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args []string
+		rule []string
+	}{
+		{[]string{"--by-pages", "--pages-per-file", "10", "--signature-size", "16"}, []string{"signature-size", "pages-per-file"}},
+		{[]string{"--target-pages", "20", "--signature-size", "16"}, []string{"signature-size", "target-pages"}},
+		{[]string{"--signature-size", "16", "--pad-to-even"}, []string{"signature-size", "pad-to-even"}},
+		{[]string{"--sample-seed", "42"}, []string{"sample-seed", "sample"}},
+		{[]string{"--page-offset", "2"}, []string{"page-offset", "toc-from-pdf"}},
+		{[]string{"--name-template", "{logical_start}_{title}"}, []string{"logical-offset", "name-template"}},
+		{[]string{"list", "--name-template", "{logical_end}"}, []string{"logical-offset", "name-template"}},
+	}
+	for _, tt := range tests {
+		note := interactionNote(t, tt.rule)
+		args := append(tt.args, "-i", source)
+		if tt.args[0] != "list" {
+			args = append(args, "-o", "out")
+		}
+		output, err := runCommand(t, dir, args...)
+		if err == nil || !strings.Contains(output, note) {
+			t.Errorf("%v: got %v, want %q\n%s", tt.args, err, note, output)
+		}
+	}
+}
+
+// interactionNote returns the note of the enforced flagInteractions entry for flags.
+func interactionNote(t *testing.T, flags []string) string {
+	t.Helper()
+	for _, rule := range flagInteractions {
+		if rule.violated != nil && strings.Join(rule.flags, ",") == strings.Join(flags, ",") {
+			return rule.note
+		}
+	}
+	t.Fatalf("no enforced flag interaction for %v", flags)
+	return ""
+}
@@ -3,19 +3,19 @@ module github.com/souhup/pdf-spliter
 go 1.23.3
 
 require (
+	github.com/mattn/go-runewidth v0.0.16
 	github.com/pdfcpu/pdfcpu v0.9.1
 	github.com/spf13/cobra v1.8.1
+	github.com/spf13/pflag v1.0.5
+	golang.org/x/image v0.21.0
+	golang.org/x/text v0.19.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
 	github.com/hhrutter/lzw v1.0.0 // indirect
 	github.com/hhrutter/tiff v1.0.1 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/image v0.21.0 // indirect
-	golang.org/x/text v0.19.0 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// textRun is a piece of text drawn by a single text-showing operator,
+// together with the font size that was active when it was drawn.
+type textRun struct {
+	text     string
+	fontSize float64
+}
+
+// firstHeading returns the first prominent text line of a page.
+// A line is considered prominent if it is drawn with the largest font size found on the page.
+// Parameters:
+//   - ctx: pdfcpu context of the source document
+//   - pageNr: 1-based page number
+//
+// Returns:
+//   - string: the heading text, or an empty string if nothing usable was found
+func firstHeading(ctx *model.Context, pageNr int) string {
+	r, err := pdfcpu.ExtractPageContent(ctx, pageNr)
+	if err != nil || r == nil {
+		return ""
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return ""
+	}
+
+	// Find the largest font size used on the page
+	runs := textRuns(content)
+	var maxSize float64
+	for _, run := range runs {
+		if run.fontSize > maxSize {
+			maxSize = run.fontSize
+		}
+	}
+
+	// Return the first readable line drawn with that size
+	for _, run := range runs {
+		if run.fontSize == maxSize && isReadable(run.text) {
+			return run.text
+		}
+	}
+	return ""
+}
+
+// textRuns scans a page content stream and collects the text drawn between each BT/ET pair.
+// Consecutive strings drawn with the same font size inside one text object are joined into one run.
+func textRuns(content []byte) []textRun {
+	runs, _ := scanText(content)
+	return runs
+}
+
+// markedText scans a page content stream and returns the text drawn inside each marked-content
+// sequence with an MCID, which is how the structure tree of a tagged PDF refers to page content.
+func markedText(content []byte) map[int]string {
+	_, marked := scanText(content)
+	return marked
+}
+
+// scanText collects the text runs of a page content stream together with the text of every
+// marked-content sequence, keyed by MCID.
+// This is a deliberately small tokenizer: it understands strings, arrays, numbers and operators,
+// which is enough to recover plain text from simple fonts.
+func scanText(content []byte) ([]textRun, map[int]string) {
+	var (
+		runs     []textRun
+		operands []string
+		fontSize float64
+		current  strings.Builder
+		inText   bool
+		mcids    []int
+	)
+	marked := make(map[int]string)
+
+	// flush closes the current run, if any
+	flush := func() {
+		if text := strings.TrimSpace(current.String()); text != "" {
+			runs = append(runs, textRun{text: text, fontSize: fontSize})
+		}
+		current.Reset()
+	}
+
+	for pos := 0; pos < len(content); {
+		c := content[pos]
+		switch {
+		case isPDFWhitespace(c):
+			pos++
+		case c == '%':
+			// Skip comments up to the end of line
+			for pos < len(content) && content[pos] != '\n' && content[pos] != '\r' {
+				pos++
+			}
+		case c == '(':
+			s, next := readLiteralString(content, pos)
+			operands = append(operands, s)
+			pos = next
+		case (c == '<' || c == '>') && pos+1 < len(content) && content[pos+1] == c:
+			// Skip dictionary brackets, e.g. of marked-content properties
+			pos += 2
+		case c == '<':
+			s, next := readHexString(content, pos)
+			operands = append(operands, s)
+			pos = next
+		case c == '[':
+			// Join all strings of a TJ array, ignoring the kerning numbers in between
+			var sb strings.Builder
+			pos++
+			for pos < len(content) && content[pos] != ']' {
+				switch content[pos] {
+				case '(':
+					s, next := readLiteralString(content, pos)
+					sb.WriteString(s)
+					pos = next
+				case '<':
+					s, next := readHexString(content, pos)
+					sb.WriteString(s)
+					pos = next
+				default:
+					pos++
+				}
+			}
+			operands = append(operands, sb.String())
+			pos++
+		default:
+			start := pos
+			if c == '/' {
+				// Names start with a slash which is itself a delimiter
+				pos++
+			}
+			for pos < len(content) && !isPDFWhitespace(content[pos]) && !isPDFDelimiter(content[pos]) {
+				pos++
+			}
+			if pos == start {
+				// Unhandled delimiter such as dictionary brackets
+				pos++
+				continue
+			}
+			token := string(content[start:pos])
+			if token[0] == '/' || isNumber(token) {
+				operands = append(operands, token)
+				continue
+			}
+
+			// Operator: interpret the ones relevant for text extraction
+			switch token {
+			case "BT":
+				inText = true
+			case "ET":
+				flush()
+				inText = false
+			case "Tf":
+				if len(operands) > 0 {
+					if size, err := strconv.ParseFloat(operands[len(operands)-1], 64); err == nil {
+						if size != fontSize {
+							flush()
+						}
+						fontSize = size
+					}
+				}
+			case "Tj", "TJ", "'", "\"":
+				if inText && len(operands) > 0 {
+					current.WriteString(operands[len(operands)-1])
+					if len(mcids) > 0 && mcids[len(mcids)-1] >= 0 {
+						marked[mcids[len(mcids)-1]] += operands[len(operands)-1]
+					}
+				}
+			case "BMC", "BDC":
+				// Nested sequences without an inline MCID belong to the enclosing one
+				mcid := -1
+				if len(mcids) > 0 {
+					mcid = mcids[len(mcids)-1]
+				}
+				for i := 0; i+1 < len(operands); i++ {
+					if operands[i] == "/MCID" {
+						if n, err := strconv.Atoi(operands[i+1]); err == nil {
+							mcid = n
+						}
+					}
+				}
+				mcids = append(mcids, mcid)
+			case "EMC":
+				if len(mcids) > 0 {
+					mcids = mcids[:len(mcids)-1]
+				}
+			case "ID":
+				// Skip inline image data which may contain arbitrary bytes
+				if end := bytes.Index(content[pos:], []byte("EI")); end >= 0 {
+					pos += end + 2
+				} else {
+					pos = len(content)
+				}
+			}
+			operands = operands[:0]
+		}
+	}
+	flush()
+	return runs, marked
+}
+
+// readLiteralString decodes a literal string starting at the opening parenthesis.
+// It returns the decoded string and the position after the closing parenthesis.
+func readLiteralString(content []byte, pos int) (string, int) {
+	var sb strings.Builder
+	depth := 0
+	for pos < len(content) {
+		c := content[pos]
+		switch {
+		case c == '\\' && pos+1 < len(content):
+			pos++
+			switch e := content[pos]; e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'b', 'f':
+				// Backspace and form feed carry no text
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				// Octal escape with up to three digits
+				end := pos
+				for end < len(content) && end-pos < 3 && content[end] >= '0' && content[end] <= '7' {
+					end++
+				}
+				v, _ := strconv.ParseUint(string(content[pos:end]), 8, 8)
+				sb.WriteRune(rune(v))
+				pos = end - 1
+			case '\r', '\n':
+				// Line continuation
+			default:
+				sb.WriteRune(rune(e))
+			}
+		case c == '(':
+			if depth > 0 {
+				sb.WriteByte(c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return sb.String(), pos + 1
+			}
+			sb.WriteByte(c)
+		default:
+			// Treat bytes as Latin-1, which matches PDFDocEncoding for printable ASCII
+			sb.WriteRune(rune(c))
+		}
+		pos++
+	}
+	return sb.String(), pos
+}
+
+// readHexString decodes a hexadecimal string starting at the opening angle bracket.
+// It returns the decoded string and the position after the closing angle bracket.
+func readHexString(content []byte, pos int) (string, int) {
+	end := bytes.IndexByte(content[pos:], '>')
+	if end < 0 {
+		return "", len(content)
+	}
+	var digits []byte
+	for _, c := range content[pos+1 : pos+end] {
+		if !isPDFWhitespace(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	var sb strings.Builder
+	for i := 0; i < len(digits); i += 2 {
+		v, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			return "", pos + end + 1
+		}
+		sb.WriteRune(rune(v))
+	}
+	return sb.String(), pos + end + 1
+}
+
+// isReadable reports whether text looks like human-readable text rather than
+// glyph IDs of an embedded font, which cannot be decoded without its encoding.
+func isReadable(text string) bool {
+	if strings.TrimSpace(text) == "" {
+		return false
+	}
+	for _, r := range text {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPDFWhitespace reports whether c is a whitespace character as defined by the PDF specification.
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+// isPDFDelimiter reports whether c is a delimiter character as defined by the PDF specification.
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// isNumber reports whether token is a numeric operand.
+func isNumber(token string) bool {
+	_, err := strconv.ParseFloat(token, 64)
+	return err == nil
+}
+
+// pageText returns all readable text of a page, one text object per line.
+func pageText(ctx *model.Context, pageNr int) string {
+	r, err := pdfcpu.ExtractPageContent(ctx, pageNr)
+	if err != nil || r == nil {
+		return ""
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return ""
+	}
+	var lines []string
+	for _, run := range textRuns(content) {
+		if isReadable(run.text) {
+			lines = append(lines, run.text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
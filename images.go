@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/souhup/pdf-spliter/splitter"
+	"golang.org/x/image/draw"
+)
+
+// Supported values of the --image-quality flag.
+const (
+	imageQualityKeep  = "keep"
+	imageQualityWeb   = "web"
+	imageQualityPrint = "print"
+)
+
+// imageSettings caps the resolution of images in the outputs.
+// Images above maxDPI at their largest placement are downsampled and stored as JPEG with quality.
+// The zero value keeps all images unchanged.
+type imageSettings struct {
+	maxDPI  float64
+	quality int
+}
+
+// imageQualities maps the --image-quality presets to their settings.
+var imageQualities = map[string]imageSettings{
+	imageQualityKeep:  {},
+	imageQualityWeb:   {maxDPI: 150, quality: 75},
+	imageQualityPrint: {maxDPI: 300, quality: 90},
+}
+
+// matrix is a PDF transformation matrix [a b c d e f].
+type matrix [6]float64
+
+// identity is the matrix that leaves coordinates unchanged.
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// mul returns the concatenation m × n, i.e. m applied first.
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2], m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2], m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4], m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// placement is the largest size, in points, at which an image is drawn.
+type placement struct {
+	width  float64
+	height float64
+}
+
+// recompressImages downsamples every image drawn above the configured resolution and stores it
+// as JPEG, unless that would make the image larger. The resolution of an image is taken at its
+// largest placement on any page, including placements inside form XObjects.
+// Parameters:
+//   - ctx: pdfcpu context of the trimmed chapter
+//   - settings: resolution cap and JPEG quality
+//
+// Returns:
+//   - int: number of images recompressed
+//   - error: if an image above the cap uses a format that cannot be recompressed
+func recompressImages(ctx *model.Context, settings imageSettings) (int, error) {
+	// Find the largest placement of every image
+	placements := make(map[int]placement)
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		_, _, inherited, err := ctx.PageDict(pageNr, false)
+		if err != nil {
+			return 0, err
+		}
+		r, err := pdfcpu.ExtractPageContent(ctx, pageNr)
+		if err != nil || r == nil {
+			continue
+		}
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return 0, err
+		}
+		if err = collectPlacements(ctx, content, inherited.Resources, identity, placements, 0); err != nil {
+			return 0, err
+		}
+	}
+
+	// Recompress in object order so runs are reproducible
+	objNrs := make([]int, 0, len(placements))
+	for objNr := range placements {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	var count int
+	for _, objNr := range objNrs {
+		entry, found := ctx.FindTableEntryLight(objNr)
+		if !found {
+			continue
+		}
+		sd, ok := entry.Object.(types.StreamDict)
+		if !ok {
+			continue
+		}
+		width, height := sd.IntEntry("Width"), sd.IntEntry("Height")
+		if width == nil || height == nil || *width <= 0 || *height <= 0 {
+			continue
+		}
+		pl := placements[objNr]
+		dpi := max(float64(*width)/(pl.width/72), float64(*height)/(pl.height/72))
+		if dpi <= settings.maxDPI {
+			continue
+		}
+
+		scaled, err := downsampleImage(ctx, sd, settings.maxDPI/dpi, settings.quality)
+		if err != nil {
+			return count, fmt.Errorf("image object %d at %.0f dpi: %w", objNr, dpi, err)
+		}
+
+		// Small images can grow as JPEG; they are kept as they are
+		if len(scaled.Raw) >= len(sd.Raw) {
+			continue
+		}
+		entry.Object = *scaled
+		count++
+	}
+	return count, nil
+}
+
+// collectPlacements scans a content stream for images drawn with Do and records their largest size.
+// Form XObjects are followed with their matrix and resources, up to splitter.MaxOutlineDepth levels.
+func collectPlacements(ctx *model.Context, content []byte, resources types.Dict, ctm matrix, placements map[int]placement, depth int) error {
+	if depth > splitter.MaxOutlineDepth {
+		return nil
+	}
+	xObjects, _ := ctx.DereferenceDict(resources["XObject"])
+	var stack []matrix
+
+	return scanContent(content, func(op string, operands []string) error {
+		switch op {
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if n := len(stack); n > 0 {
+				ctm, stack = stack[n-1], stack[:n-1]
+			}
+		case "cm":
+			if m, ok := operandMatrix(operands); ok {
+				ctm = m.mul(ctm)
+			}
+		case "Do":
+			if len(operands) == 0 || xObjects == nil {
+				return nil
+			}
+			ref, ok := xObjects[operands[len(operands)-1][1:]].(types.IndirectRef)
+			if !ok {
+				return nil
+			}
+			sd, _, err := ctx.DereferenceStreamDict(ref)
+			if err != nil || sd == nil {
+				return err
+			}
+			switch subtype := sd.NameEntry("Subtype"); {
+			case subtype != nil && *subtype == "Image":
+				pl := placements[ref.ObjectNumber.Value()]
+				pl.width = max(pl.width, math.Hypot(ctm[0], ctm[1]))
+				pl.height = max(pl.height, math.Hypot(ctm[2], ctm[3]))
+				placements[ref.ObjectNumber.Value()] = pl
+			case subtype != nil && *subtype == "Form":
+				formCTM := ctm
+				if m, ok := arrayMatrix(ctx, sd.ArrayEntry("Matrix")); ok {
+					formCTM = m.mul(ctm)
+				}
+				formResources := resources
+				if d, err := ctx.DereferenceDict(sd.Dict["Resources"]); err == nil && d != nil {
+					formResources = d
+				}
+				if err = sd.Decode(); err != nil {
+					return err
+				}
+				return collectPlacements(ctx, sd.Content, formResources, formCTM, placements, depth+1)
+			}
+		}
+		return nil
+	})
+}
+
+// operandMatrix parses the six numeric operands of a cm operator.
+func operandMatrix(operands []string) (matrix, bool) {
+	if len(operands) < 6 {
+		return matrix{}, false
+	}
+	var m matrix
+	for i, s := range operands[len(operands)-6:] {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return matrix{}, false
+		}
+		m[i] = v
+	}
+	return m, true
+}
+
+// arrayMatrix converts the Matrix entry of a form XObject.
+func arrayMatrix(ctx *model.Context, a types.Array) (matrix, bool) {
+	if len(a) != 6 {
+		return matrix{}, false
+	}
+	var m matrix
+	for i, obj := range a {
+		v, err := ctx.DereferenceNumber(obj)
+		if err != nil {
+			return matrix{}, false
+		}
+		m[i] = v
+	}
+	return m, true
+}
+
+// scanContent tokenizes a content stream and calls fn for every operator with its operands.
+// Strings and arrays are passed as single placeholder operands, names keep their slash,
+// and inline image data is skipped.
+func scanContent(content []byte, fn func(op string, operands []string) error) error {
+	var operands []string
+	for pos := 0; pos < len(content); {
+		c := content[pos]
+		switch {
+		case isPDFWhitespace(c):
+			pos++
+		case c == '%':
+			for pos < len(content) && content[pos] != '\n' && content[pos] != '\r' {
+				pos++
+			}
+		case c == '(':
+			_, pos = readLiteralString(content, pos)
+			operands = append(operands, "()")
+		case c == '<' && pos+1 < len(content) && content[pos+1] != '<':
+			_, pos = readHexString(content, pos)
+			operands = append(operands, "<>")
+		case c == '[':
+			// Skip the array, including any strings inside it
+			for pos++; pos < len(content) && content[pos] != ']'; {
+				switch content[pos] {
+				case '(':
+					_, pos = readLiteralString(content, pos)
+				default:
+					pos++
+				}
+			}
+			pos++
+			operands = append(operands, "[]")
+		default:
+			start := pos
+			if c == '/' {
+				pos++
+			}
+			for pos < len(content) && !isPDFWhitespace(content[pos]) && !isPDFDelimiter(content[pos]) {
+				pos++
+			}
+			if pos == start {
+				// Dictionary brackets and other lone delimiters
+				pos++
+				continue
+			}
+			token := string(content[start:pos])
+			if token[0] == '/' || isNumber(token) {
+				operands = append(operands, token)
+				continue
+			}
+			if token == "ID" {
+				if end := bytes.Index(content[pos:], []byte("EI")); end >= 0 {
+					pos += end + 2
+				} else {
+					pos = len(content)
+				}
+			}
+			if err := fn(token, operands); err != nil {
+				return err
+			}
+			operands = operands[:0]
+		}
+	}
+	return nil
+}
+
+// downsampleImage scales an image by factor and encodes it as JPEG.
+// Parameters:
+//   - ctx: pdfcpu context owning the image
+//   - sd: image XObject
+//   - factor: scale factor below 1
+//   - quality: JPEG quality from 1 to 100
+//
+// Returns:
+//   - *types.StreamDict: the replacement image XObject
+//   - error: if the image format cannot be recompressed
+func downsampleImage(ctx *model.Context, sd types.StreamDict, factor float64, quality int) (*types.StreamDict, error) {
+	src, colorSpace, err := decodeImage(ctx, sd)
+	if err != nil {
+		return nil, err
+	}
+	bounds := src.Bounds()
+	width := max(1, int(math.Round(float64(bounds.Dx())*factor)))
+	height := max(1, int(math.Round(float64(bounds.Dy())*factor)))
+
+	var dst draw.Image
+	cs := model.DeviceRGBCS
+	if _, gray := src.(*image.Gray); gray {
+		dst, cs = image.NewGray(image.Rect(0, 0, width, height)), model.DeviceGrayCS
+	} else {
+		dst = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	scaled, err := model.CreateDCTImageObject(ctx.XRefTable, buf.Bytes(), width, height, 8, cs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep an ICC profile with the same number of components
+	scaled.Dict["ColorSpace"] = colorSpace
+	if interpolate, ok := sd.Find("Interpolate"); ok {
+		scaled.Dict["Interpolate"] = interpolate
+	}
+	return scaled, nil
+}
+
+// decodeImage decodes an image XObject in the formats that can be recompressed: 8-bit gray or
+// RGB samples, stored as JPEG or with lossless filters, without masks or decode arrays.
+// Returns:
+//   - image.Image: the decoded image, *image.Gray for single-component images
+//   - types.Object: the image's color space entry
+//   - error: naming the feature that prevents recompression
+func decodeImage(ctx *model.Context, sd types.StreamDict) (image.Image, types.Object, error) {
+	if mask := sd.BooleanEntry("ImageMask"); mask != nil && *mask {
+		return nil, nil, fmt.Errorf("cannot recompress stencil masks")
+	}
+	for _, key := range []string{"SMask", "Mask", "Decode"} {
+		if _, ok := sd.Find(key); ok {
+			return nil, nil, fmt.Errorf("cannot recompress images with %s", key)
+		}
+	}
+	colorSpace := sd.Dict["ColorSpace"]
+	components, err := colorComponents(ctx, colorSpace)
+	if err != nil {
+		return nil, nil, err
+	}
+	width, height := *sd.IntEntry("Width"), *sd.IntEntry("Height")
+
+	// JPEG images are decoded by the standard library
+	if len(sd.FilterPipeline) == 1 && sd.FilterPipeline[0].Name == "DCTDecode" {
+		img, err := jpeg.Decode(bytes.NewReader(sd.Raw))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot decode JPEG: %v", err)
+		}
+		if _, gray := img.(*image.Gray); gray != (components == 1) {
+			return nil, nil, fmt.Errorf("cannot recompress JPEG whose components do not match its color space")
+		}
+		return img, colorSpace, nil
+	}
+
+	// Other images must be raw 8-bit samples behind lossless filters
+	for _, f := range sd.FilterPipeline {
+		if f.Name != "FlateDecode" && f.Name != "LZWDecode" && f.Name != "RunLengthDecode" {
+			return nil, nil, fmt.Errorf("cannot recompress images with filter %s", f.Name)
+		}
+	}
+	if bpc := sd.IntEntry("BitsPerComponent"); bpc == nil || *bpc != 8 {
+		return nil, nil, fmt.Errorf("cannot recompress images with other than 8 bits per component")
+	}
+	if err = sd.Decode(); err != nil {
+		return nil, nil, err
+	}
+	if len(sd.Content) < width*height*components {
+		return nil, nil, fmt.Errorf("image data is shorter than its size")
+	}
+	rect := image.Rect(0, 0, width, height)
+	if components == 1 {
+		return &image.Gray{Pix: sd.Content[:width*height], Stride: width, Rect: rect}, colorSpace, nil
+	}
+	img := image.NewRGBA(rect)
+	for i := 0; i < width*height; i++ {
+		copy(img.Pix[i*4:], sd.Content[i*3:i*3+3])
+		img.Pix[i*4+3] = 0xff
+	}
+	return img, colorSpace, nil
+}
+
+// colorComponents returns the number of components of a gray or RGB color space,
+// including ICC based ones, and fails for all others.
+func colorComponents(ctx *model.Context, colorSpace types.Object) (int, error) {
+	obj, err := ctx.Dereference(colorSpace)
+	if err != nil {
+		return 0, err
+	}
+	switch cs := obj.(type) {
+	case types.Name:
+		switch cs {
+		case model.DeviceGrayCS:
+			return 1, nil
+		case model.DeviceRGBCS:
+			return 3, nil
+		}
+	case types.Array:
+		if len(cs) == 2 {
+			if name, ok := cs[0].(types.Name); ok && name == model.ICCBasedCS {
+				profile, _, err := ctx.DereferenceStreamDict(cs[1])
+				if err == nil && profile != nil {
+					if n := profile.IntEntry("N"); n != nil && (*n == 1 || *n == 3) {
+						return *n, nil
+					}
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("cannot recompress images in color space %v", obj)
+}
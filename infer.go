@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// lenientBookmarks reads the outline like api.Bookmarks, but leaves PageFrom at 0 for items whose
+// destination cannot be resolved, e.g. names missing from the Dests name tree, instead of failing
+// the whole outline. Items are skipped by the same rules as pdfcpu.
+// Parameters:
+//   - ctx: pdfcpu context of the validated source document
+//
+// Returns:
+//   - []pdfcpu.Bookmark: the bookmark tree
+//   - error: if the outline structure cannot be read
+func lenientBookmarks(ctx *model.Context) ([]pdfcpu.Bookmark, error) {
+	if err := ctx.LocateNameTree("Dests", false); err != nil {
+		return nil, err
+	}
+	if ctx.Outlines == nil {
+		return nil, nil
+	}
+	return lenientOutlineItems(ctx, ctx.Outlines.IndirectRefEntry("First"), nil)
+}
+
+// lenientOutlineItems reads an outline item, its siblings and their kids for lenientBookmarks.
+func lenientOutlineItems(ctx *model.Context, first *types.IndirectRef, parent *pdfcpu.Bookmark) ([]pdfcpu.Bookmark, error) {
+	var (
+		bms []pdfcpu.Bookmark
+		d   types.Dict
+		err error
+	)
+	for ir := first; ir != nil; ir = d.IndirectRefEntry("Next") {
+		if d, err = ctx.DereferenceDict(*ir); err != nil {
+			return nil, err
+		}
+		_, hasDest := d["Dest"]
+		_, hasAction := d["A"]
+		if !hasDest && !hasAction {
+			continue
+		}
+
+		// An unresolvable destination keeps page 0; an action other than GoTo skips the item
+		bm := pdfcpu.Bookmark{Title: splitter.OutlineItemTitle(ctx, d), Parent: parent}
+		arr, err := destinationArray(ctx, d)
+		switch {
+		case err != nil:
+		case len(arr) == 0 && !hasDest:
+			continue
+		case len(arr) > 0:
+			if pageRef, ok := arr[0].(types.IndirectRef); ok {
+				if page, err := ctx.PageNumber(pageRef.ObjectNumber.Value()); err == nil {
+					bm.PageFrom = page
+				}
+			}
+		}
+		if kids := d.IndirectRefEntry("First"); kids != nil {
+			if bm.Kids, err = lenientOutlineItems(ctx, kids, &bm); err != nil {
+				return nil, err
+			}
+		}
+		bms = append(bms, bm)
+	}
+	return bms, nil
+}
+
+// inferStartPages estimates the start page of every bookmark whose destination could not be
+// resolved, by interpolating between the nearest resolved bookmarks before and after it in
+// outline order, in proportion to its position between them. A single missing bookmark lands
+// midway. Before the first resolved bookmark the document starts at page 1; after the last one
+// it ends at pageCount.
+// Parameters:
+//   - bookmarks: bookmarks at the split level, in outline order; estimated pages are filled in
+//   - pageCount: number of pages of the document
+//
+// Returns:
+//   - []bool: for every bookmark, whether its start page was estimated
+func inferStartPages(bookmarks []pdfcpu.Bookmark, pageCount int) []bool {
+	estimated := make([]bool, len(bookmarks))
+	for i := 0; i < len(bookmarks); {
+		if bookmarks[i].PageFrom != 0 {
+			i++
+			continue
+		}
+
+		// Find the run of unresolved bookmarks and the resolved pages around it
+		end := i
+		for end < len(bookmarks) && bookmarks[end].PageFrom == 0 {
+			end++
+		}
+		from, to := 1, pageCount+1
+		if i > 0 {
+			from = bookmarks[i-1].PageFrom
+		}
+		if end < len(bookmarks) {
+			to = bookmarks[end].PageFrom
+		}
+		steps := end - i + 1
+		for j := i; j < end; j++ {
+			page := from + (to-from)*(j-i+1)/steps
+			bookmarks[j].PageFrom = min(max(page, 1), pageCount)
+			estimated[j] = true
+		}
+		i = end
+	}
+	return estimated
+}
+
+// hasEstimated reports whether any chapter starts at an estimated page.
+func hasEstimated(chapters []chapter) bool {
+	for _, cpt := range chapters {
+		if cpt.estimated {
+			return true
+		}
+	}
+	return false
+}
+
+// estimatedStart formats the start page of a planned file, marked with ~ if it was estimated.
+func estimatedStart(f plannedFile) string {
+	if f.Estimated {
+		return "~" + strconv.Itoa(int(f.StartPage))
+	}
+	return strconv.Itoa(int(f.StartPage))
+}
+
+// refuseEstimated ends the run with exitPlanProblems before anything is written if chapter
+// boundaries were estimated and --yes did not accept them, listing the estimated chapters.
+// Accepted estimates are reported with a warning per chapter, so they can still be reviewed.
+func refuseEstimated(chapters []chapter) error {
+	if !hasEstimated(chapters) {
+		return nil
+	}
+	if assumeYes {
+		for i := range chapters {
+			if chapters[i].estimated {
+				chapters[i].warn("estimated_boundary", chapters[i].title, chapters[i].startPage)
+			}
+		}
+		return nil
+	}
+	var count int
+	for _, cpt := range chapters {
+		if cpt.estimated {
+			count++
+		}
+	}
+	errorMsg("estimated_refused", count)
+	for _, cpt := range chapters {
+		if cpt.estimated {
+			fmt.Fprintln(messageOutput, "  - "+msg("estimated_entry", cpt.title, cpt.startPage, cpt.endPage))
+		}
+	}
+	return exitWith(exitPlanProblems, nil)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/spf13/cobra"
+)
+
+var infoFormat string
+
+var infoCmd = &cobra.Command{
+	Use:     "info",
+	Short:   "Print the page count and outline size of a document, whether it is tagged and the features its chapters do not preserve",
+	Args:    cobra.NoArgs,
+	RunE:    printInfo,
+	Example: `./pdf-split info -i book.pdf --format json`,
+}
+
+// documentReport is the document printed by info --format json.
+// Unsupported are the features of unsupportedFeatures found in the document.
+type documentReport struct {
+	Source      string   `json:"source"`
+	Pages       int      `json:"pages"`
+	Bookmarks   int      `json:"bookmarks"`
+	Tagged      bool     `json:"tagged"`
+	Unsupported []string `json:"unsupported_features"`
+}
+
+// initInfoFlags registers the flags of the info subcommand.
+func initInfoFlags() {
+	flags := infoCmd.Flags()
+	flags.StringVarP(&inputFilePath, "input", "i", "", "source PDF file path")
+	flags.StringVar(&infoFormat, "format", listText, "output format: text or json")
+	initPasswordFlags(flags)
+	flags.StringVar(&language, "lang", "", "language of messages, e.g. de or zh-CN (default from LANG)")
+	if err := infoCmd.MarkFlagRequired("input"); err != nil {
+		log.Fatalf("failed to parse param: %v", err)
+	}
+}
+
+// printInfo prints the documentReport of --input in the --format of the info subcommand.
+// Parameter cmd is the running command, _ is used to satisfy the cobra.Command RunE interface.
+func printInfo(cmd *cobra.Command, _ []string) error {
+	if err := setLanguage(language); err != nil {
+		return err
+	}
+	switch infoFormat {
+	case listText, listJSON:
+	default:
+		return fmt.Errorf("invalid --format value '%s': must be %s or %s", infoFormat, listText, listJSON)
+	}
+	report, err := inspectDocument(inputFilePath)
+	if err != nil {
+		return failed(cmd, err)
+	}
+
+	if infoFormat == listJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return failed(cmd, fmt.Errorf("failed to write document info: %w", err))
+		}
+		return nil
+	}
+	fmt.Println(msg("info_summary", report.Source, report.Pages, report.Bookmarks))
+	if report.Tagged {
+		fmt.Println(msg("info_tagged"))
+	} else {
+		fmt.Println(msg("info_untagged"))
+	}
+	for _, feature := range report.Unsupported {
+		fmt.Println(msg("feature_" + feature))
+	}
+	return nil
+}
+
+// inspectDocument reads the documentReport of a PDF file.
+// Parameters:
+//   - path: the PDF file
+//
+// Returns:
+//   - documentReport: what info prints about the file
+//   - error: if the file cannot be opened or read
+func inspectDocument(path string) (documentReport, error) {
+	report := documentReport{Source: path, Unsupported: []string{}}
+	inputFile, err := openInput(path)
+	if err != nil {
+		return report, err
+	}
+	defer inputFile.Close()
+	doc, err := sourceDocument(inputFile)
+	if err != nil {
+		return report, err
+	}
+	bookmarks, err := doc.Bookmarks()
+	if err != nil {
+		return report, err
+	}
+	report.Pages, report.Bookmarks = doc.PageCount(), countBookmarks(bookmarks)
+	err = doc.Inspect(func(ctx *model.Context) error {
+		report.Tagged = isTagged(ctx)
+		report.Unsupported = append(report.Unsupported, sourceFeatures(ctx)...)
+		return nil
+	})
+	return report, err
+}
+
+// countBookmarks returns the number of bookmarks in an outline tree, at all levels.
+func countBookmarks(bookmarks []pdfcpu.Bookmark) int {
+	n := len(bookmarks)
+	for _, bm := range bookmarks {
+		n += countBookmarks(bm.Kids)
+	}
+	return n
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// TestInfo reports the book fixture and a copy of it marked as tagged: the page count and
+// bookmarks must be those of the fixture, and only the copy must be reported as tagged, with
+// the tagged structure as a feature its chapters do not preserve.
+func TestInfo(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := runCommand(t, dir, "info", "-i", source, "--format", "json")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	var report documentReport
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	want := documentReport{Source: source, Pages: selftestPages, Bookmarks: countBookmarks(selftestOutline), Unsupported: []string{}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got %+v, want %+v", report, want)
+	}
+
+	tagged := writeTaggedCopy(t, source, filepath.Join(dir, "tagged.pdf"))
+	output, err = runCommand(t, dir, "info", "-i", tagged)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	for _, line := range []string{"tagged PDF: yes", "note: contains tagged structure"} {
+		if !strings.Contains(output, line) {
+			t.Errorf("the report of the tagged copy has no line %q\n%s", line, output)
+		}
+	}
+}
+
+// writeTaggedCopy writes a copy of a PDF that declares itself as marked, a tagged PDF to isTagged.
+func writeTaggedCopy(t *testing.T, source, path string) string {
+	t.Helper()
+	ctx, err := api.ReadContextFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ctx.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root["MarkInfo"] = types.Dict{"Marked": types.Boolean(true)}
+	if err := api.WriteContextFile(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
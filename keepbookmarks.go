@@ -0,0 +1,38 @@
+package main
+
+import "github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+
+// chapterBookmarks returns the sub-bookmarks of a chapter for its own outline, with pages
+// counted from the chapter's first page. Packed chapters combine the sub-bookmarks of all parts.
+// Parameters:
+//   - cpt: the chapter
+//
+// Returns:
+//   - []pdfcpu.Bookmark: the remapped bookmark tree, empty if the chapter has no sub-bookmarks
+func chapterBookmarks(cpt chapter) []pdfcpu.Bookmark {
+	parts := cpt.parts
+	if len(parts) == 0 {
+		parts = []chapter{cpt}
+	}
+	var bookmarks []pdfcpu.Bookmark
+	for _, part := range parts {
+		bookmarks = append(bookmarks, remapBookmarks(part.kids, part.startPage, part.endPage, int(cpt.startPage)-1)...)
+	}
+	return bookmarks
+}
+
+// remapBookmarks keeps the bookmarks pointing into the pages from start to end, shifted back by
+// offset pages, and preserves their nesting. A bookmark pointing outside the range is dropped
+// rather than left with a broken destination; its kids inside the range take its place.
+func remapBookmarks(bookmarks []pdfcpu.Bookmark, start, end uint32, offset int) []pdfcpu.Bookmark {
+	var kept []pdfcpu.Bookmark
+	for _, bm := range bookmarks {
+		kids := remapBookmarks(bm.Kids, start, end, offset)
+		if bm.PageFrom < int(start) || bm.PageFrom > int(end) {
+			kept = append(kept, kids...)
+			continue
+		}
+		kept = append(kept, pdfcpu.Bookmark{Title: bm.Title, PageFrom: bm.PageFrom - offset, Kids: kids})
+	}
+	return kept
+}
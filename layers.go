@@ -0,0 +1,329 @@
+package main
+
+import (
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// hasOptionalContent reports whether a document defines optional content groups (layers).
+func hasOptionalContent(ctx *model.Context) bool {
+	root, err := ctx.Catalog()
+	if err != nil {
+		return false
+	}
+	_, found := root.Find("OCProperties")
+	return found
+}
+
+// copyOptionalContent gives a trimmed chapter the OCProperties of its source, which pdfcpu drops
+// when trimming while the pages keep their copies of the groups. The groups in the properties are
+// replaced by these copies, and groups that no page of the chapter refers to are left out; what
+// remains of them, like empty Order entries, is removed by pruneOptionalContent.
+// Parameters:
+//   - src: pdfcpu context of the source, which is not changed
+//   - pageNrs: the source pages of the chapter, in the order of its pages
+//   - ctx: pdfcpu context of the trimmed chapter
+//
+// Returns:
+//   - error: if the pages of the source or the chapter cannot be read
+func copyOptionalContent(src *model.Context, pageNrs []int, ctx *model.Context) error {
+	srcRoot, err := src.Catalog()
+	if err != nil {
+		return err
+	}
+	ocProperties, err := src.DereferenceDict(srcRoot["OCProperties"])
+	if err != nil || ocProperties == nil {
+		return err
+	}
+
+	// Find the copy of each group by walking the pages of the source and the chapter side by side.
+	// Trimming gives each page the resources it inherits, so these are compared on their own.
+	copies := map[int]types.IndirectRef{}
+	visited := map[int]bool{}
+	for i, pageNr := range pageNrs {
+		srcPage, _, inherited, err := src.PageDict(pageNr, false)
+		if err != nil {
+			return err
+		}
+		page, _, _, err := ctx.PageDict(i+1, false)
+		if err != nil {
+			return err
+		}
+		if inherited != nil {
+			matchOCGs(src, ctx, inherited.Resources, page["Resources"], copies, visited, 0)
+		}
+		for key, value := range srcPage {
+			if key != "Resources" {
+				matchOCGs(src, ctx, value, page[key], copies, visited, 0)
+			}
+		}
+	}
+	if len(copies) == 0 {
+		return nil
+	}
+	root, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	if chapterProperties := copyOCObject(src, ocProperties, copies, 0); chapterProperties != nil {
+		root["OCProperties"] = chapterProperties
+	}
+	return nil
+}
+
+// matchOCGs walks an object of the source and its copy in a chapter side by side and records the
+// copy of every optional content group reached, by the object number of the group in the source.
+// Parent links are not followed, like in collectOCGs. visited holds the object numbers of the
+// chapter already walked.
+func matchOCGs(src, ctx *model.Context, srcObj, obj types.Object, copies map[int]types.IndirectRef, visited map[int]bool, depth int) {
+	if depth > splitter.MaxOutlineDepth {
+		return
+	}
+	if ref, ok := srcObj.(types.IndirectRef); ok {
+		target, err := src.Dereference(ref)
+		if err != nil {
+			return
+		}
+		if d, ok := target.(types.Dict); ok && d.Type() != nil && *d.Type() == "OCG" {
+			if copyRef, ok := obj.(types.IndirectRef); ok {
+				copies[ref.ObjectNumber.Value()] = copyRef
+			}
+			return
+		}
+		srcObj = target
+	}
+
+	// Trimming copies an object of the source once per chapter but may inline it, so only the
+	// objects of the chapter are marked as visited
+	if ref, ok := obj.(types.IndirectRef); ok {
+		if visited[ref.ObjectNumber.Value()] {
+			return
+		}
+		visited[ref.ObjectNumber.Value()] = true
+		target, err := ctx.Dereference(ref)
+		if err != nil {
+			return
+		}
+		obj = target
+	}
+	switch o := srcObj.(type) {
+	case types.Dict:
+		if d, ok := obj.(types.Dict); ok {
+			for key, value := range o {
+				if key != "Parent" && key != "P" {
+					matchOCGs(src, ctx, value, d[key], copies, visited, depth+1)
+				}
+			}
+		}
+	case types.StreamDict:
+		if sd, ok := obj.(types.StreamDict); ok {
+			matchOCGs(src, ctx, o.Dict, sd.Dict, copies, visited, depth+1)
+		}
+	case types.Array:
+		if a, ok := obj.(types.Array); ok && len(a) == len(o) {
+			for i, value := range o {
+				matchOCGs(src, ctx, value, a[i], copies, visited, depth+1)
+			}
+		}
+	}
+}
+
+// copyOCObject returns a direct copy of an object of the source's OCProperties for a chapter.
+// Groups are replaced by their copies in copies, other indirect objects by copies of their
+// values. Groups without a copy are left out of arrays and dictionaries; nil is returned for
+// them and for objects that cannot be read.
+func copyOCObject(src *model.Context, obj types.Object, copies map[int]types.IndirectRef, depth int) types.Object {
+	if depth > splitter.MaxOutlineDepth {
+		return nil
+	}
+	if ref, ok := obj.(types.IndirectRef); ok {
+		if copyRef, ok := copies[ref.ObjectNumber.Value()]; ok {
+			return copyRef
+		}
+		target, err := src.Dereference(ref)
+		if err != nil || target == nil {
+			return nil
+		}
+		if d, ok := target.(types.Dict); ok && d.Type() != nil && *d.Type() == "OCG" {
+			return nil
+		}
+		obj = target
+	}
+	switch o := obj.(type) {
+	case types.Dict:
+		d := types.Dict{}
+		for key, value := range o {
+			if kept := copyOCObject(src, value, copies, depth+1); kept != nil {
+				d[key] = kept
+			}
+		}
+		return d
+	case types.Array:
+		a := types.Array{}
+		for _, value := range o {
+			if kept := copyOCObject(src, value, copies, depth+1); kept != nil {
+				a = append(a, kept)
+			}
+		}
+		return a
+	case types.StreamDict:
+		return nil
+	}
+	if obj == nil {
+		return nil
+	}
+	return obj.Clone()
+}
+
+// pruneOptionalContent rewrites the OCProperties of a trimmed chapter so that it lists exactly
+// the optional content groups referenced by the remaining pages. Names, default visibility
+// and ordering of the kept groups are preserved. A chapter using no groups gets no OCProperties.
+// Parameters:
+//   - ctx: pdfcpu context of the trimmed chapter
+//
+// Returns:
+//   - error: if the document structure cannot be read
+func pruneOptionalContent(ctx *model.Context) error {
+	root, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	ocProperties, err := ctx.DereferenceDict(root["OCProperties"])
+	if err != nil || ocProperties == nil {
+		return err
+	}
+
+	// Collect all groups reachable from the pages, their resources, forms and annotations
+	used := map[int]bool{}
+	visited := map[int]bool{}
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		pageDict, _, inherited, err := ctx.PageDict(pageNr, false)
+		if err != nil {
+			return err
+		}
+		collectOCGs(ctx, pageDict, used, visited)
+		if inherited != nil {
+			collectOCGs(ctx, inherited.Resources, used, visited)
+		}
+	}
+
+	// Drop the dictionary entirely if no page uses any group
+	if len(used) == 0 {
+		root.Delete("OCProperties")
+		return nil
+	}
+
+	ocProperties["OCGs"] = filterOCGArray(ctx, ocProperties["OCGs"], used)
+	if d, err := ctx.DereferenceDict(ocProperties["D"]); err == nil && d != nil {
+		filterOCConfig(ctx, d, used)
+	}
+	if configs, err := ctx.DereferenceArray(ocProperties["Configs"]); err == nil {
+		for _, c := range configs {
+			if d, err := ctx.DereferenceDict(c); err == nil && d != nil {
+				filterOCConfig(ctx, d, used)
+			}
+		}
+	}
+	return nil
+}
+
+// filterOCConfig removes unused groups from an optional content configuration dictionary.
+func filterOCConfig(ctx *model.Context, config types.Dict, used map[int]bool) {
+	for _, key := range []string{"ON", "OFF", "Locked", "Order", "RBGroups"} {
+		if _, found := config[key]; found {
+			config[key] = filterOCGArray(ctx, config[key], used)
+		}
+	}
+	if usage, err := ctx.DereferenceArray(config["AS"]); err == nil {
+		for _, u := range usage {
+			if d, err := ctx.DereferenceDict(u); err == nil && d != nil {
+				d["OCGs"] = filterOCGArray(ctx, d["OCGs"], used)
+			}
+		}
+	}
+}
+
+// filterOCGArray returns a copy of an array of group references keeping only used groups.
+// Nested arrays, as found in Order and RBGroups, are filtered recursively and dropped when
+// they no longer contain any group; their text labels are kept otherwise.
+func filterOCGArray(ctx *model.Context, obj types.Object, used map[int]bool) types.Array {
+	arr, err := ctx.DereferenceArray(obj)
+	if err != nil {
+		return types.Array{}
+	}
+	result := types.Array{}
+	for _, o := range arr {
+		switch o := o.(type) {
+		case types.IndirectRef:
+			if used[o.ObjectNumber.Value()] {
+				result = append(result, o)
+				continue
+			}
+			// An indirect array is a nested group of the Order tree
+			if nested, err := ctx.DereferenceArray(o); err == nil && nested != nil {
+				if kept := filterOCGArray(ctx, nested, used); containsRef(kept) {
+					result = append(result, kept)
+				}
+			}
+		case types.Array:
+			if kept := filterOCGArray(ctx, o, used); containsRef(kept) {
+				result = append(result, kept)
+			}
+		default:
+			result = append(result, o)
+		}
+	}
+	return result
+}
+
+// containsRef reports whether an array, or any array nested in it, contains an indirect reference.
+func containsRef(arr types.Array) bool {
+	for _, o := range arr {
+		switch o := o.(type) {
+		case types.IndirectRef:
+			return true
+		case types.Array:
+			if containsRef(o) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// collectOCGs walks all objects reachable from obj and records the object numbers of
+// optional content group dictionaries. Parent links are not followed, so the walk
+// stays within the page it started from.
+func collectOCGs(ctx *model.Context, obj types.Object, used, visited map[int]bool) {
+	switch o := obj.(type) {
+	case types.IndirectRef:
+		nr := o.ObjectNumber.Value()
+		if visited[nr] {
+			return
+		}
+		visited[nr] = true
+		target, err := ctx.Dereference(o)
+		if err != nil {
+			return
+		}
+		if d, ok := target.(types.Dict); ok && d.Type() != nil && *d.Type() == "OCG" {
+			used[nr] = true
+			return
+		}
+		collectOCGs(ctx, target, used, visited)
+	case types.Dict:
+		for key, value := range o {
+			if key == "Parent" || key == "P" {
+				continue
+			}
+			collectOCGs(ctx, value, used, visited)
+		}
+	case types.StreamDict:
+		collectOCGs(ctx, o.Dict, used, visited)
+	case types.Array:
+		for _, value := range o {
+			collectOCGs(ctx, value, used, visited)
+		}
+	}
+}
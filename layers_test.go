@@ -0,0 +1,109 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// TestLayers splits the layers fixture, whose first two chapters use one layer each, reading the
+// source once and with --low-memory: every output must list exactly the layers of its pages with
+// their default visibility, and the chapter using none must have no optional content properties.
+func TestLayers(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "layers.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file    string
+		ocgs    []string
+		on, off []string
+	}{
+		{"01_Ground Floor.pdf", []string{"Walls"}, []string{"Walls"}, []string{}},
+		{"02_First Floor.pdf", []string{"Wiring"}, []string{}, []string{"Wiring"}},
+		{"03_Notes.pdf", nil, nil, nil},
+	}
+	for _, out := range []string{"once", "low-memory"} {
+		args := []string{"-i", source, "-o", out, "--sidecar-suffix=", "--bloat-factor=0"}
+		if out == "low-memory" {
+			args = append(args, "--low-memory")
+		}
+		if output, err := runCommand(t, dir, args...); err != nil {
+			t.Fatalf("%s: %v\n%s", out, err, output)
+		}
+		for _, tt := range tests {
+			path := filepath.Join(dir, out, tt.file)
+			ctx, err := api.ReadContextFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			root, err := ctx.Catalog()
+			if err != nil {
+				t.Fatal(err)
+			}
+			ocProperties, err := ctx.DereferenceDict(root["OCProperties"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.ocgs == nil {
+				if ocProperties != nil {
+					t.Errorf("%s uses no layers but has OCProperties %v", path, ocProperties)
+				}
+				continue
+			}
+			if ocProperties == nil {
+				t.Errorf("%s has no OCProperties, want layers %q", path, tt.ocgs)
+				continue
+			}
+			config, err := ctx.DereferenceDict(ocProperties["D"])
+			if err != nil || config == nil {
+				t.Fatalf("%s has no default configuration: %v", path, err)
+			}
+			for _, got := range []struct {
+				key   string
+				names []string
+				want  []string
+			}{
+				{"OCGs", layerNames(t, ctx, ocProperties["OCGs"]), tt.ocgs},
+				{"Order", layerNames(t, ctx, config["Order"]), tt.ocgs},
+				{"ON", layerNames(t, ctx, config["ON"]), tt.on},
+				{"OFF", layerNames(t, ctx, config["OFF"]), tt.off},
+			} {
+				if !reflect.DeepEqual(got.names, got.want) {
+					t.Errorf("%s: %s lists %q, want %q", path, got.key, got.names, got.want)
+				}
+			}
+		}
+	}
+}
+
+// layerNames returns the names of the optional content groups in an array of group references.
+func layerNames(t *testing.T, ctx *model.Context, obj types.Object) []string {
+	t.Helper()
+	groups, err := ctx.DereferenceArray(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, group := range groups {
+		d, err := ctx.DereferenceDict(group)
+		if err != nil || d == nil {
+			t.Fatalf("%v is not a group: %v", group, err)
+		}
+		name, err := ctx.Dereference(d["Name"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, err := model.Text(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, text)
+	}
+	return names
+}
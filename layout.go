@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Supported values of the --archive-date flag.
+const (
+	archiveDateCreation = "creation"
+	archiveDateRun      = "run"
+)
+
+// archiveLayoutDir returns the date-partitioned output directory <dir>/YYYY/MM/<source>
+// used by --archive-layout. The date is the source's CreationDate or the run date, depending
+// on --archive-date; a missing or unparsable CreationDate falls back to the run date with a warning.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//   - dir: base output directory
+//
+// Returns:
+//   - string: the partition directory for the source's chapters
+func archiveLayoutDir(inputFile *os.File, dir string) string {
+	date := runClock.Now()
+	if archiveDate == archiveDateCreation {
+		raw := sourceCreationDate(inputFile)
+		if created, ok := types.DateTime(raw, true); ok {
+			date = created
+		} else if raw == "" {
+			warnMsg("creation_date_missing", date.Format("2006-01"))
+		} else {
+			warnMsg("creation_date_invalid", raw, date.Format("2006-01"))
+		}
+	}
+
+	source := strings.TrimSuffix(filepath.Base(inputFile.Name()), filepath.Ext(inputFile.Name()))
+	return filepath.Join(dir, date.Format("2006"), date.Format("01"), sanitizeFilename(source))
+}
+
+// sourceCreationDate returns the raw CreationDate of the source's document information
+// dictionary, or an empty string if it has none.
+func sourceCreationDate(inputFile *os.File) string {
+	var date string
+	_ = inspectSource(inputFile, func(ctx *model.Context) error {
+		if ctx.Info == nil {
+			return nil
+		}
+		info, err := ctx.DereferenceDict(*ctx.Info)
+		if err != nil || info == nil {
+			return nil
+		}
+		obj, err := ctx.Dereference(info["CreationDate"])
+		if err != nil || obj == nil {
+			return nil
+		}
+		if date, err = model.Text(obj); err != nil {
+			date = ""
+		}
+		return nil
+	})
+	return strings.TrimSpace(date)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// exitLimitsExceeded is the exit code used when a document exceeds the extraction limits.
+const exitLimitsExceeded = 4
+
+// limitFlags names the flag that sets a limit, by the unit of the limit.
+var limitFlags = map[string]string{
+	"entries":  "--max-outline-entries",
+	"bytes":    "--max-title-length",
+	"chapters": "--max-chapters",
+}
+
+// sourceLimits returns the extraction limits set by --max-outline-entries, --max-title-length
+// and --max-chapters.
+func sourceLimits() splitter.Limits {
+	return splitter.Limits{OutlineEntries: maxOutlineEntries, TitleLength: maxTitleLength, Chapters: maxChapters}
+}
+
+// limitExceeded gives an error caused by a limit the exit code exitLimitsExceeded and names the
+// flag that sets the limit; other errors are returned unchanged.
+func limitExceeded(err error) error {
+	var limit *splitter.LimitError
+	if !errors.As(err, &limit) {
+		return err
+	}
+	return exitWith(exitLimitsExceeded, fmt.Errorf("%s exceeds limits (%s %s, see %s)",
+		limit.What, splitter.FormatCount(limit.Limit), limit.Unit, limitFlags[limit.Unit]))
+}
+
+// checkChapterLimit enforces the limit on the number of planned chapters.
+func checkChapterLimit(count int) error {
+	if maxChapters > 0 && count > maxChapters {
+		return limitExceeded(&splitter.LimitError{What: "chapter plan", Limit: maxChapters, Unit: "chapters"})
+	}
+	return nil
+}
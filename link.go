@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultLinkTemplate names the linked files when --also-link gives no template.
+const defaultLinkTemplate = "{title}"
+
+// linkView is an additional directory in which every chapter file is published under another name.
+type linkView struct {
+	dir      string
+	template string
+}
+
+// parseLinkViews parses the --also-link values of the form dir or dir:template.
+// The template may use {order}, {title}, {start} and {end}.
+func parseLinkViews(values []string) ([]linkView, error) {
+	var views []linkView
+	for _, value := range values {
+		view := linkView{dir: value, template: defaultLinkTemplate}
+		if i := strings.LastIndex(value, ":"); i > 0 && !strings.ContainsAny(value[i+1:], `/\`) {
+			view.dir, view.template = value[:i], value[i+1:]
+		}
+		if view.dir == "" || view.template == "" {
+			return nil, fmt.Errorf("invalid --also-link value '%s': must be dir or dir:template", value)
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// linkName expands a link template for a chapter.
+func linkName(template string, cpt chapter) string {
+	name := strings.NewReplacer(
+		"{order}", fmt.Sprintf("%02d", cpt.order),
+		"{title}", cpt.title,
+		"{start}", fmt.Sprint(cpt.startPage),
+		"{end}", fmt.Sprint(cpt.endPage),
+	).Replace(template)
+	return sanitizeFilename(name) + ".pdf"
+}
+
+// linkChapter publishes a written chapter file in every --also-link view.
+// Hard links are used where possible, then symbolic links, then copies; falling back and
+// failing are reported as warnings, so a view problem never fails the split.
+// Parameters:
+//   - outputFilePath: path of the written chapter file
+//   - cpt: the chapter, used to name the links
+//
+// Returns:
+//   - []string: the absolute paths the chapter was published at, by link or copy
+func linkChapter(outputFilePath string, cpt chapter) []string {
+	var links []string
+	for _, view := range linkViews {
+		target := filepath.Join(view.dir, linkName(view.template, cpt))
+		if err := os.MkdirAll(view.dir, 0755); err != nil {
+			warnMsg("link_failed", cpt.title, target, err)
+			continue
+		}
+
+		// Replace links left by a previous run
+		os.Remove(target)
+		if err := os.Link(outputFilePath, target); err == nil {
+			links = append(links, absolutePath(target))
+			continue
+		}
+		absPath, err := filepath.Abs(outputFilePath)
+		if err == nil {
+			if err = os.Symlink(absPath, target); err == nil {
+				warnMsg("link_symlink", cpt.title, target)
+				links = append(links, absolutePath(target))
+				continue
+			}
+		}
+		if err = copyFile(outputFilePath, target); err != nil {
+			warnMsg("link_failed", cpt.title, target, err)
+			continue
+		}
+		warnMsg("link_copy", cpt.title, target)
+		links = append(links, absolutePath(target))
+	}
+	return links
+}
+
+// copyFile copies src to dst, removing dst if the copy is incomplete.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err = out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/spf13/cobra"
+)
+
+// List output formats.
+const (
+	listText = "text"
+	listJSON = "json"
+)
+
+var (
+	listFormat string
+	listUnder  string
+)
+
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "Print the bookmark tree with the page ranges and files a split would produce",
+	Args:    cobra.NoArgs,
+	RunE:    listBookmarks,
+	Example: `./pdf-split list -i book.pdf -d 2`,
+}
+
+// outlineRef identifies the bookmark a chapter was made from by its title and start page,
+// 0 if its destination could not be resolved.
+type outlineRef struct {
+	title string
+	page  int
+}
+
+// listedBookmark is a node of the bookmark tree printed by the list subcommand.
+// Page is 0 if the destination could not be resolved. Chapter is set for the bookmarks
+// that start a chapter of the split.
+type listedBookmark struct {
+	Title   string           `json:"title"`
+	Page    int              `json:"page"`
+	Chapter *listedChapter   `json:"chapter,omitempty"`
+	Kids    []listedBookmark `json:"kids,omitempty"`
+}
+
+// listedChapter is the chapter a bookmark starts, with the file it would be written to.
+type listedChapter struct {
+	Order     uint32 `json:"order"`
+	StartPage uint32 `json:"start_page"`
+	EndPage   uint32 `json:"end_page"`
+	File      string `json:"file"`
+	Estimated bool   `json:"estimated,omitempty"`
+}
+
+// bookmarkList is the document printed by list --format json.
+type bookmarkList struct {
+	Source    string           `json:"source"`
+	Sidecar   string           `json:"sidecar,omitempty"`
+	Bookmarks []listedBookmark `json:"bookmarks"`
+}
+
+// initListFlags registers the flags of the list subcommand.
+// The outline and boundary flags are shared with the split command, so the listed ranges
+// and file names are those a split with the same flags would write.
+func initListFlags() {
+	flags := listCmd.Flags()
+	flags.StringVarP(&inputFilePath, "input", "i", "", "input PDF file path")
+	flags.StringVar(&listFormat, "format", listText, "output format: text or json")
+	flags.IntVarP(&splitDepth, "depth", "d", 1, "outline level to split at, 1 for top-level bookmarks")
+	flags.StringVar(&listUnder, "under", "", "split only the sub-bookmarks of the bookmark with this title or regular expression")
+	flags.StringVar(&midPageStart, "mid-page-start", "", "owner of a page shared by two chapters: previous, next or duplicate")
+	flags.BoolVar(&noOverlap, "no-overlap", true, "end each chapter on the page before the next one starts; =false repeats that page in both")
+	flags.IntVar(&lookback, "lookback", 0, "move each chapter start back by up to this many pages, e.g. to include part openers")
+	flags.BoolVar(&noAutoDescend, "no-auto-descend", false, "do not split below a single top-level bookmark")
+	flags.IntVar(&truncateAtPage, "truncate-at-page", 0, "ignore all pages after this one")
+	flags.BoolVar(&inferMissing, "infer-missing-destinations", false, "estimate the start page of bookmarks whose destination cannot be resolved from the bookmarks around them")
+	flags.StringVar(&sidecarSuffix, "sidecar-suffix", defaultSidecarSuffix, "read chapters from the input path plus this suffix if that file exists (empty to disable)")
+	flags.StringVar(&nameTemplate, "name-template", defaultNameTemplate, "chapter filename without .pdf, with {order}, {order:N}, {title}, {start}, {end} and {source}")
+	flags.StringVar(&orderBy, "order-by", orderByPage, "export order and numbering of chapters: page, outline or title")
+	initPasswordFlags(flags)
+	flags.StringVar(&language, "lang", "", "language of messages, e.g. de or zh-CN (default from LANG)")
+	if err := listCmd.MarkFlagRequired("input"); err != nil {
+		log.Fatalf("failed to parse param: %v", err)
+	}
+}
+
+// listBookmarks prints the complete bookmark tree of the input. Bookmarks that start a chapter
+// show the chapter's page range and file name, taken from the same chapter plan as a split.
+// Parameter cmd is the running command, _ is used to satisfy the cobra.Command RunE interface.
+func listBookmarks(cmd *cobra.Command, _ []string) error {
+	// Validate flag values before doing any work
+	if err := setLanguage(language); err != nil {
+		return err
+	}
+	switch listFormat {
+	case listText, listJSON:
+	default:
+		return fmt.Errorf("invalid --format value '%s': must be %s or %s", listFormat, listText, listJSON)
+	}
+	if err := checkPlanFlags(); err != nil {
+		return err
+	}
+	var err error
+	if nameTemplateParsed, err = parseNameTemplate(nameTemplate); err != nil {
+		return err
+	}
+	if err = checkFlagInteractions(); err != nil {
+		return err
+	}
+	return failed(cmd, printBookmarkList())
+}
+
+// printBookmarkList prints the bookmark tree of the input with the chapters planned from it,
+// in the --format of the list.
+func printBookmarkList() error {
+	inputFile, err := openInput(inputFilePath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	// Plan the chapters from the same tree that is listed
+	bookmarks, sidecar, err := readOutline(inputFile, listUnder)
+	if err != nil {
+		return err
+	}
+	if len(bookmarks) == 0 {
+		return fmt.Errorf("'%s' has no bookmarks and no chapter sidecar", inputFilePath)
+	}
+	chapters, _, err := outlineChapters(inputFile, bookmarks, sidecar, listUnder)
+	if err != nil {
+		return err
+	}
+	chapters = orderChapters(chapters, orderBy)
+	if err := assignFileNames(chapters, inputFile.Name()); err != nil {
+		return err
+	}
+
+	// Attach every chapter to the bookmark it was made from
+	starts := make(map[outlineRef][]*listedChapter)
+	for _, cpt := range chapters {
+		starts[cpt.source] = append(starts[cpt.source], &listedChapter{
+			Order:     cpt.order,
+			StartPage: cpt.startPage,
+			EndPage:   cpt.endPage,
+			File:      cpt.file + ".pdf",
+			Estimated: cpt.estimated,
+		})
+	}
+	list := bookmarkList{Source: inputFilePath, Sidecar: sidecar, Bookmarks: listedTree(bookmarks, starts)}
+
+	if listFormat == listJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(list); err != nil {
+			return fmt.Errorf("failed to write bookmark list: %w", err)
+		}
+	} else {
+		printListedTree(list.Bookmarks, 0)
+	}
+	return nil
+}
+
+// listedTree converts a bookmark tree to its listed form and attaches the chapters starting at
+// each bookmark, in outline order. A parent split into its kids by --depth starts the chapter
+// of its own pages before the first kid, titled with introSuffix.
+// Parameters:
+//   - bookmarks: bookmarks of the current level
+//   - starts: chapters by the bookmark they were made from; attached chapters are removed
+//
+// Returns:
+//   - []listedBookmark: the listed bookmarks
+func listedTree(bookmarks []pdfcpu.Bookmark, starts map[outlineRef][]*listedChapter) []listedBookmark {
+	listed := make([]listedBookmark, 0, len(bookmarks))
+	for _, bm := range bookmarks {
+		node := listedBookmark{Title: bm.Title, Page: bm.PageFrom}
+		for _, ref := range []outlineRef{{bm.Title, bm.PageFrom}, {bm.Title + introSuffix, bm.PageFrom}} {
+			if queue := starts[ref]; len(queue) > 0 {
+				node.Chapter, starts[ref] = queue[0], queue[1:]
+				break
+			}
+		}
+		node.Kids = listedTree(bm.Kids, starts)
+		listed = append(listed, node)
+	}
+	return listed
+}
+
+// printListedTree prints listed bookmarks indented by their level, with the chapter each one starts.
+func printListedTree(bookmarks []listedBookmark, level int) {
+	indent := strings.Repeat("  ", level)
+	for _, bm := range bookmarks {
+		page := "?"
+		if bm.Page > 0 {
+			page = strconv.Itoa(bm.Page)
+		}
+		line := indent + msg("list_bookmark", bm.Title, page)
+		if c := bm.Chapter; c != nil {
+			start := strconv.Itoa(int(c.StartPage))
+			if c.Estimated {
+				start = "~" + start
+			}
+			line += "  " + msg("list_chapter", start, c.EndPage, c.File)
+		}
+		fmt.Println(line)
+		printListedTree(bm.Kids, level+1)
+	}
+}
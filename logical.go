@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// logicalOffsetAuto is the --logical-offset value that takes the offset from the page labels.
+const logicalOffsetAuto = "auto"
+
+var (
+	// logicalNumbering is set when pages are also shown with their printed numbers.
+	logicalNumbering bool
+	// logicalOffset is the number of physical pages before printed page 1.
+	logicalOffset int
+)
+
+// parseLogicalOffset validates --logical-offset: empty, auto, or the number of pages before
+// printed page 1. The offset of auto is read from every input by resolveLogicalOffset.
+func parseLogicalOffset(value string) error {
+	if value == "" || value == logicalOffsetAuto {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid --logical-offset value '%s': must be a number of pages or %s", value, logicalOffsetAuto)
+	}
+	logicalNumbering, logicalOffset = true, n
+	return nil
+}
+
+// resolveLogicalOffset takes the offset of --logical-offset auto from the page labels of the
+// input: printed page 1 is where the first decimal label range would count 1. Without such a
+// range a warning is printed and only physical page numbers are shown.
+func resolveLogicalOffset(inputFile *os.File) error {
+	if logicalOffsetText != logicalOffsetAuto {
+		return nil
+	}
+	var offset int
+	var ok bool
+	err := inspectSource(inputFile, func(ctx *model.Context) error {
+		offset, ok = decimalLabelOffset(ctx)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		logicalNumbering = false
+		warnMsg("no_page_labels")
+		return nil
+	}
+	logicalNumbering, logicalOffset = true, offset
+	printMsg("logical_offset", offset+1, offset)
+	return nil
+}
+
+// decimalLabelOffset returns the physical pages before printed page 1, from the first range of
+// the page label number tree with decimal numbering.
+// Parameters:
+//   - ctx: pdfcpu context of the source document
+//
+// Returns:
+//   - int: offset such that physical page p is printed page p - offset
+//   - bool: false if the document has no decimal page labels
+func decimalLabelOffset(ctx *model.Context) (int, bool) {
+	root, err := ctx.Catalog()
+	if err != nil {
+		return 0, false
+	}
+	tree, err := ctx.DereferenceDict(root["PageLabels"])
+	if err != nil || tree == nil {
+		return 0, false
+	}
+	var (
+		offset int
+		found  bool
+	)
+	splitter.WalkNumberTree(ctx, tree, 0, func(index int, value types.Object) bool {
+		label, err := ctx.DereferenceDict(value)
+		if err != nil || label == nil || label.NameEntry("S") == nil || *label.NameEntry("S") != "D" {
+			return true
+		}
+		start := 1
+		if st := label.IntEntry("St"); st != nil {
+			start = *st
+		}
+		offset, found = index+1-start, true
+		return false
+	})
+	return offset, found
+}
+
+// logicalPage returns the printed number of a physical page. Pages before printed page 1 are
+// shown as lowercase roman numerals counted from the first page, as front matter is numbered.
+func logicalPage(page uint32) string {
+	if n := int(page) - logicalOffset; n >= 1 {
+		return strconv.Itoa(n)
+	}
+	if page < 1 {
+		return "n/a"
+	}
+	return romanNumeral(int(page))
+}
+
+// logicalStart returns the printed number of a chapter's first page, or "" without --logical-offset.
+func logicalStart(cpt chapter) string {
+	if !logicalNumbering {
+		return ""
+	}
+	return logicalPage(cpt.startPage)
+}
+
+// logicalEnd returns the printed number of a chapter's last page, or "" without --logical-offset.
+func logicalEnd(cpt chapter) string {
+	if !logicalNumbering {
+		return ""
+	}
+	return logicalPage(cpt.endPage)
+}
+
+// pageRangeText returns the physical page range of a chapter for messages, followed by the
+// printed range with --logical-offset.
+func pageRangeText(cpt chapter) string {
+	physical := chapterPageRange(cpt)
+	if !logicalNumbering {
+		return physical
+	}
+	return msg("logical_range", physical, logicalStart(cpt)+"-"+logicalEnd(cpt))
+}
+
+// romanNumeral returns n in lowercase roman numerals, for n from 1 to 3999.
+func romanNumeral(n int) string {
+	if n < 1 || n > 3999 {
+		return strconv.Itoa(n)
+	}
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+	var sb strings.Builder
+	for i, v := range values {
+		for ; n >= v; n -= v {
+			sb.WriteString(symbols[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// benchmarkPages and benchmarkChapterPages are the size of the document BenchmarkSplit generates.
+const (
+	benchmarkPages        = 600
+	benchmarkChapterPages = 10
+)
+
+// outputPageCounts returns the page count of every file a split wrote to dir, by file name.
+func outputPageCounts(t *testing.T, dir string) map[string]int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		pages, err := api.PageCountFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("%s: %v", entry.Name(), err)
+		}
+		counts[entry.Name()] = pages
+	}
+	return counts
+}
+
+// TestLowMemoryPageCounts splits the sections of the book fixture from the source read once and
+// with --low-memory, which must write the same files with the same page counts.
+func TestLowMemoryPageCounts(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]map[string]int)
+	for _, mode := range []string{"read-once", "low-memory"} {
+		args := []string{"-i", source, "-o", mode, "--depth=3", "--sidecar-suffix=", "--bloat-factor=0"}
+		if mode == "low-memory" {
+			args = append(args, "--low-memory")
+		}
+		if output, err := runCommand(t, dir, args...); err != nil {
+			t.Fatalf("%s: %v\n%s", mode, err, output)
+		}
+		counts[mode] = outputPageCounts(t, filepath.Join(dir, mode))
+	}
+	if len(counts["read-once"]) != 9 {
+		t.Fatalf("got %d files, want 9", len(counts["read-once"]))
+	}
+	if !reflect.DeepEqual(counts["low-memory"], counts["read-once"]) {
+		t.Errorf("--low-memory wrote %v, want %v", counts["low-memory"], counts["read-once"])
+	}
+}
+
+// BenchmarkSplit times the split of a generated document with a chapter every
+// benchmarkChapterPages pages, from the source read once and with --low-memory.
+func BenchmarkSplit(b *testing.B) {
+	var outline []pdfcpu.Bookmark
+	for start := 1; start <= benchmarkPages; start += benchmarkChapterPages {
+		outline = append(outline, pdfcpu.Bookmark{Title: fmt.Sprintf("Chapter %d", len(outline)+1), PageFrom: start})
+	}
+	dir := b.TempDir()
+	source := filepath.Join(dir, "benchmark.pdf")
+	if err := writeSampleDocument(source, benchmarkPages, outline); err != nil {
+		b.Fatal(err)
+	}
+	for _, mode := range []struct {
+		name string
+		args []string
+	}{
+		{name: "read-once"},
+		{name: "low-memory", args: []string{"--low-memory"}},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				args := append([]string{"-i", source, "-o", fmt.Sprintf("%s-%d", mode.name, i),
+					"--sidecar-suffix=", "--bloat-factor=0", "--no-verify"}, mode.args...)
+				if output, err := runCommand(b, dir, args...); err != nil {
+					b.Fatalf("%v\n%s", err, output)
+				}
+			}
+		})
+	}
+}
@@ -1,18 +1,32 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/souhup/pdf-spliter/ranges"
+	"github.com/souhup/pdf-spliter/splitter"
 	"github.com/spf13/cobra"
 )
 
 func main() {
+	// Fatal errors must not run into a progress line
+	log.SetOutput(clearingWriter{os.Stderr})
 	initFlags()
 }
 
@@ -26,98 +40,1284 @@ var rootCmd = &cobra.Command{
 
 var (
 	inputFilePath string
+	inputPaths    []string
 	outputDir     string
+	titleFrom     string
+	midPageStart  string
+	noOverlap     bool
+	verbose       bool
+	quiet         bool
+	singleOutput  string
+	underTitles   []string
+	splitDepth    int
+	pagesPerFile  int
+	byPages       bool
+
+	failOnLossyNames   bool
+	explainPlan        bool
+	noAutoDescend      bool
+	strictPages        bool
+	noVerify           bool
+	language           string
+	orderBy            string
+	stripBlank         bool
+	padToEven          bool
+	signatureSize      int
+	continuationPage   bool
+	inferMissing       bool
+	tocFromPDF         string
+	pageOffset         int
+	logicalOffsetText  string
+	assumeYes          bool
+	allowUntagged      bool
+	sidecarSuffix      string
+	bloatFactor        float64
+	noOutput           bool
+	dryRun             string
+	nameTemplate       string
+	readRetries        int
+	keepBookmarks      bool
+	stampID            string
+	stampHeader        string
+	stampHeaderSize    int
+	noRetitle          bool
+	orphanPages        string
+	destCmd            string
+	reviewThreshold    float64
+	minPages           int
+	matchPattern       string
+	chapterSelection   string
+	excludePages       string
+	sampleEvery        int
+	sampleSeed         int64
+	sampleRandom       bool
+	sampleDir          string
+	runID              string
+	userPassword       string
+	ownerPassword      string
+	passwordFile       string
+	keepEncryption     bool
+	validateOutputs    bool
+	workers            int
+	lowMemory          bool
+	overwrite          bool
+	targetFS           string
+	manifestFile       string
+	skipExisting       bool
+	lookback           int
+	strict             bool
+	imageQuality       string
+	subsetResource     bool
+	targetPages        int
+	packJoiner         string
+	packBookmarks      bool
+	failUnsupported    []string
+	extractValues      []string
+	chapterTimeout     time.Duration
+	bandwidth          string
+	archiveDir         string
+	alsoLink           []string
+	archiveLayout      bool
+	archiveDate        string
+	detectDuplicate    bool
+	processAttached    bool
+	truncateAtPage     int
+	detectHeadings     bool
+	headingPatternText string
+	allowResplit       bool
+	resplit            bool
+	splitOnBarcode     bool
+	barcodePagesText   string
+	barcodeConfidence  float64
+	barcodeLeadingName string
+	planFile           string
+	strictPlan         bool
+	allowAbsoluteDirs  bool
+	profileValues      []string
+	profiles           []splitProfile
+	failFast           bool
+
+	// Extraction limits guarding against pathological documents
+	maxOutlineEntries int
+	maxChapters       int
+	maxTitleLength    int
+
+	// writeLimiter throttles all output writes if --bandwidth is set
+	writeLimiter *rateLimiter
+
+	// linkViews are the additional directories given by --also-link
+	linkViews []linkView
+)
+
+// Supported values of the --title-from flag.
+const (
+	titleFromBookmark     = "bookmark"
+	titleFromFirstHeading = "first-heading"
+	titleFromStructure    = "structure"
+)
+
+// Supported values of the --mid-page-start flag.
+const (
+	midPageStartPrevious  = "previous"
+	midPageStartNext      = "next"
+	midPageStartDuplicate = "duplicate"
 )
 
 // initFlags initializes command line flags and validates required parameters.
 // The program will terminate if required parameters are missing or parsing fails.
 func initFlags() {
-	rootCmd.Flags().StringVarP(&inputFilePath, "input", "i", "", "input file path")
+	rootCmd.Flags().StringArrayVarP(&inputPaths, "input", "i", nil, "input file path, or a directory of PDF files (repeatable)")
 	rootCmd.Flags().StringVarP(&outputDir, "output", "o", "output", "output directory path")
+	rootCmd.Flags().StringVar(&destCmd, "dest-cmd", "", "deliver every chapter file to this command on its stdin instead of the output directory, e.g. 'mytool put {name}'")
+	rootCmd.Flags().IntVar(&pagesPerFile, "pages-per-file", 0, "split documents without bookmarks into chunks of this many pages")
+	rootCmd.Flags().BoolVar(&byPages, "by-pages", false, "split into --pages-per-file chunks even if the document has bookmarks")
+	rootCmd.Flags().IntVarP(&splitDepth, "depth", "d", 1, "outline level to split at, 1 for top-level bookmarks")
+	rootCmd.Flags().StringArrayVar(&profileValues, "profile", nil, "split once more per profile into a subdirectory, from the source read once, as name:level=N,template=T,output=DIR (repeatable)")
+	rootCmd.Flags().BoolVar(&failFast, "fail-fast", false, "stop at the first failing --profile instead of splitting the others")
+	rootCmd.Flags().StringVar(&titleFrom, "title-from", titleFromBookmark, "source of chapter titles: bookmark, first-heading or structure")
+	rootCmd.Flags().StringVar(&midPageStart, "mid-page-start", "", "owner of a page shared by two chapters when the next one starts mid-page: previous, next or duplicate")
+	rootCmd.Flags().BoolVar(&noOverlap, "no-overlap", true, "end each chapter on the page before the next one starts; =false repeats that page in both")
+	rootCmd.Flags().StringVar(&singleOutput, "single-output", "", "write all chapters into this single PDF with one bookmark per chapter")
+	rootCmd.Flags().StringArrayVar(&underTitles, "under", nil, "split only the sub-bookmarks of the bookmark with this title or regular expression (repeatable)")
+	rootCmd.Flags().BoolVar(&failOnLossyNames, "fail-on-lossy-names", false, "fail instead of warn when sanitizing changes a title significantly")
+	rootCmd.Flags().StringVar(&bandwidth, "bandwidth", "", "limit the output write rate, e.g. 10MB/s")
+	rootCmd.Flags().StringVar(&archiveDir, "archive-source", "", "move the input into this directory after a successful split")
+	rootCmd.Flags().StringArrayVar(&alsoLink, "also-link", nil, "also publish every chapter file in dir, named by an optional template, as dir:template (repeatable)")
+	rootCmd.Flags().BoolVar(&archiveLayout, "archive-layout", false, "write chapters into <output>/YYYY/MM/<source>/")
+	rootCmd.Flags().StringVar(&archiveDate, "archive-date", archiveDateCreation, "date used by --archive-layout: creation or run")
+	rootCmd.Flags().BoolVar(&processAttached, "process-attachments", false, "also split every PDF file attached to the input, into subdirectories")
+	rootCmd.Flags().BoolVar(&detectDuplicate, "detect-duplication", false, "warn if the input seems to contain the document twice")
+	rootCmd.Flags().IntVar(&truncateAtPage, "truncate-at-page", 0, "ignore all pages after this one")
+	rootCmd.Flags().StringVar(&tocFromPDF, "toc-from-pdf", "", "read the chapters from the outline of this PDF, an edition of the input with the same pagination")
+	rootCmd.Flags().IntVar(&pageOffset, "page-offset", 0, "pages to add to the --toc-from-pdf page numbers for the input, e.g. 2 for two extra cover pages")
+	rootCmd.Flags().StringVar(&logicalOffsetText, "logical-offset", "", "also show printed page numbers: physical pages before printed page 1, or auto from the page labels")
+	rootCmd.Flags().BoolVar(&inferMissing, "infer-missing-destinations", false, "estimate the start page of bookmarks whose destination cannot be resolved from the bookmarks around them")
+	rootCmd.Flags().BoolVar(&assumeYes, "yes", false, "split with estimated chapter boundaries without reviewing them first")
+	rootCmd.Flags().BoolVar(&detectHeadings, "detect-headings", false, "start chapters at pages whose top line matches --heading-pattern, for documents without a usable outline")
+	rootCmd.Flags().StringVar(&headingPatternText, "heading-pattern", defaultHeadingPattern, "regular expression of the chapter headings found by --detect-headings")
+	rootCmd.Flags().BoolVar(&splitOnBarcode, "split-on-barcode", false, "start a document after every page with a Code 128 or Code 39 barcode or a QR code, named after its value, and drop that page")
+	rootCmd.Flags().StringVar(&barcodePagesText, "barcode-pages", "", "pages searched for separator barcodes, e.g. odd or 1-200, instead of all pages")
+	rootCmd.Flags().Float64Var(&barcodeConfidence, "barcode-confidence", defaultBarcodeConfidence, "share of scan lines that must agree on a barcode's value, or of a QR code's error correction left unused, from 0 to 1")
+	rootCmd.Flags().StringVar(&barcodeLeadingName, "barcode-leading-name", defaultLeadingName, "title of the pages before the first barcode separator page")
+	rootCmd.Flags().StringVar(&planFile, "plan", "", "YAML file grouping chapters or pages into named outputs, one file per output")
+	rootCmd.Flags().BoolVar(&strictPlan, "strict-plan", false, "fail instead of warning about unknown chapters, overlaps and gaps in the --plan file")
+	rootCmd.Flags().BoolVar(&allowAbsoluteDirs, "allow-absolute-output-dirs", false, "allow the outputs of the --plan file to name absolute output_dir directories outside --output")
+	rootCmd.Flags().BoolVar(&allowResplit, "allow-resplit", false, "split an input that is a chapter written by an earlier run")
+	rootCmd.Flags().BoolVar(&resplit, "resplit", false, "if the input is a chapter written by an earlier run, split the source it was taken from instead")
+	rootCmd.Flags().BoolVar(&stampProvenance, "provenance", false, "record the file name and SHA-256 of the source and the date in every chapter, so that a later run recognizes it")
+	rootCmd.Flags().BoolVar(&explainPlan, "explain", false, "print how each chapter's page range was derived")
+	rootCmd.Flags().BoolVar(&noAutoDescend, "no-auto-descend", false, "do not split below a single top-level bookmark")
+	rootCmd.Flags().BoolVar(&strictPages, "strict-pages", false, "fail if a written chapter's page count differs from the plan")
+	rootCmd.Flags().BoolVar(&noVerify, "no-verify", false, "skip reading back written files and checking their page counts")
+	rootCmd.Flags().BoolVar(&noVerify, "no-verify-pages", false, "skip reading back written files and checking their page counts")
+	rootCmd.Flags().MarkDeprecated("no-verify-pages", "use --no-verify instead")
+	rootCmd.Flags().BoolVar(&stripBlank, "strip-blank-pages", false, "drop blank pages at the start and end of every chapter")
+	rootCmd.Flags().BoolVar(&padToEven, "pad-to-even", false, "append a blank page to chapters with an odd page count")
+	rootCmd.Flags().IntVar(&signatureSize, "signature-size", 0, "pad every output with blank pages to a multiple of this many pages, e.g. 16 for bookbinding")
+	rootCmd.Flags().BoolVar(&continuationPage, "continuation-page", false, "append a page naming the next chapter and its file to every output but the last")
+	rootCmd.Flags().StringVar(&imageQuality, "image-quality", imageQualityKeep, "cap image resolution in the outputs: keep, web (150 dpi) or print (300 dpi)")
+	rootCmd.Flags().BoolVar(&subsetResource, "subset-resources", false, "drop fonts and images not used by a chapter's pages")
+	rootCmd.Flags().BoolVar(&allowUntagged, "allow-untagged-output", false, "do not warn that chapters of a tagged PDF are written untagged")
+	rootCmd.Flags().StringVar(&sidecarSuffix, "sidecar-suffix", defaultSidecarSuffix, "read chapters from the input path plus this suffix if that file exists (empty to disable)")
+	rootCmd.Flags().Float64Var(&bloatFactor, "bloat-factor", defaultBloatFactor, "warn about chapters with more than this multiple of the source's bytes per page (0 to disable)")
+	rootCmd.Flags().BoolVar(&noOutput, "no-output", false, "run all planning and validation steps without writing any file")
+	rootCmd.Flags().StringVar(&dryRun, "dry-run", "", "print the planned files as a table, or as JSON with --dry-run=json, without writing anything")
+	rootCmd.Flags().Lookup("dry-run").NoOptDefVal = dryRunTable
+	initExistingFlags(rootCmd.Flags())
+	initTargetFSFlag(rootCmd.Flags())
+	rootCmd.Flags().StringVar(&manifestFile, "manifest", "", "write a table of contents of the outputs to this file in the output directory, as .json or .csv")
+	initCompareFlags(rootCmd.Flags())
+	rootCmd.Flags().IntVar(&workers, "workers", runtime.NumCPU(), "number of chapters exported at the same time")
+	rootCmd.Flags().BoolVar(&lowMemory, "low-memory", false, "read the source again for every chapter instead of holding it in memory")
+	rootCmd.Flags().IntVar(&readRetries, "read-retries", 3, "retry a chapter this many times, with backoff, if the source fails with a transient read error")
+	rootCmd.Flags().StringVar(&nameTemplate, "name-template", defaultNameTemplate, "chapter filename without .pdf, with {order}, {order:N}, {title}, {start}, {end} and {source}")
+	rootCmd.Flags().IntVar(&lookback, "lookback", 0, "move each chapter start back by up to this many pages, e.g. to include part openers")
+	rootCmd.Flags().IntVar(&maxOutlineEntries, "max-outline-entries", splitter.DefaultLimits.OutlineEntries, "fail on documents with more outline entries (0 for no limit)")
+	rootCmd.Flags().IntVar(&maxChapters, "max-chapters", splitter.DefaultLimits.Chapters, "fail if more chapters would be planned (0 for no limit)")
+	rootCmd.Flags().IntVar(&maxTitleLength, "max-title-length", splitter.DefaultLimits.TitleLength, "fail on bookmark titles longer than this many bytes (0 for no limit)")
+	rootCmd.Flags().BoolVar(&strict, "strict", false, "fail the run if any warning was printed")
+	rootCmd.Flags().DurationVar(&chapterTimeout, "chapter-timeout", 0, "skip a chapter whose export takes longer than this, e.g. 2m (0 for no limit)")
+	rootCmd.Flags().StringSliceVar(&failUnsupported, "fail-on-unsupported", nil, "fail if the source uses one of these features: tagged, signatures, javascript, multimedia, xfa")
+	rootCmd.Flags().StringSliceVar(&extractValues, "extract", nil, "also write each chapter's text as .txt and its images into a _images folder next to it: text, images")
+	rootCmd.Flags().IntVar(&targetPages, "target-pages", 0, "combine consecutive chapters into outputs of up to this many pages")
+	rootCmd.Flags().StringVar(&packJoiner, "pack-joiner", defaultPackJoiner, "separator of chapter titles in the name of a combined output")
+	rootCmd.Flags().StringVar(&matchPattern, "match", "", "only export chapters whose title matches this regular expression")
+	rootCmd.Flags().StringVar(&chapterSelection, "chapters", "", "only export these chapters by number, e.g. 3,5,7-9 or 16-")
+	rootCmd.Flags().StringVar(&excludePages, "exclude-pages", "", "leave these pages of the source out of every chapter, e.g. 1-2,odd or 200-")
+	rootCmd.Flags().StringVar(&pagesAs, "pages-as", pagesAsPhysical, "what --exclude-pages and --barcode-pages name: physical pages, or page labels such as iv or A-3")
+	initPasswordFlags(rootCmd.Flags())
+	rootCmd.Flags().StringVar(&passwordFile, "password-file", "", "CSV file of file names or glob patterns and the passwords of the inputs they match, taking precedence over --user-password")
+	rootCmd.Flags().BoolVar(&validateOutputs, "validate-outputs", false, "check every written file with pdfcpu's strict validation and fail at the end if any is invalid")
+	rootCmd.Flags().BoolVar(&keepEncryption, "keep-encryption", false, "encrypt the outputs of an encrypted input with its passwords and permissions")
+	rootCmd.Flags().StringVar(&runID, "run-id", "", "identifier of this run to correlate it with a pipeline, included in --dry-run=json and --manifest (default a random UUID)")
+	rootCmd.Flags().IntVar(&sampleEvery, "sample", 0, "only split every nth chapter of every nth input, into the --sample-dir subdirectory")
+	rootCmd.Flags().Int64Var(&sampleSeed, "sample-seed", 0, "draw the --sample at random with this seed instead of taking every nth item")
+	rootCmd.Flags().StringVar(&sampleDir, "sample-dir", defaultSampleDir, "subdirectory of the output directory for --sample outputs (empty to write into the output directory)")
+	rootCmd.Flags().IntVar(&minPages, "min-pages", 0, "merge chapters shorter than this many pages into the following one (0 to disable)")
+	rootCmd.Flags().StringVar(&stampID, "stamp-id", "", "print each chapter's stable ID on its first page: text")
+	rootCmd.Flags().StringVar(&stampHeader, "stamp-header", "", "print the chapter title and source page number on every page: top or bottom")
+	rootCmd.Flags().IntVar(&stampHeaderSize, "stamp-header-size", 8, "font size of --stamp-header in points")
+	rootCmd.Flags().BoolVar(&noRetitle, "no-retitle", false, "keep the source's Title in the outputs instead of adding the chapter title")
+	rootCmd.Flags().Float64Var(&reviewThreshold, "review-threshold", 0, "write documents whose chapter detection scores below this confidence, e.g. 0.7, into _needs_review")
+	rootCmd.Flags().StringVar(&orphanPages, "orphan-pages", orphanIgnore, "pages no chapter covers: ignore, collect into a last file or attach-previous")
+	rootCmd.Flags().BoolVar(&keepBookmarks, "keep-bookmarks", false, "copy the sub-bookmarks of each chapter into its file, with pages remapped")
+	rootCmd.Flags().BoolVar(&packBookmarks, "pack-bookmarks", false, "bookmark the start of every chapter inside a combined output")
+	rootCmd.Flags().StringVar(&orderBy, "order-by", orderByPage, "export order and numbering of chapters: page, outline or title")
+	rootCmd.Flags().StringVar(&language, "lang", "", "language of messages, e.g. de or zh-CN (default from LANG)")
+	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "print detailed information about the split, with the time taken by every chapter")
+	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "print only warnings and errors")
 	if err := rootCmd.MarkFlagRequired("input"); err != nil {
 		log.Fatalf("failed to parse param: %v", err)
 	}
+	rootCmd.Version = versionString()
+	rootCmd.AddCommand(versionCmd)
+	explainFlagsCmd.Flags().StringVar(&explainFlagsFormat, "format", "text", "output format: text or json")
+	rootCmd.AddCommand(explainFlagsCmd)
+	warningsCmd.Flags().StringVar(&warningsFormat, "format", "text", "output format: text or json")
+	rootCmd.AddCommand(warningsCmd)
+	initExtractFlags()
+	rootCmd.AddCommand(extractCmd)
+	initIdentifyFlags()
+	rootCmd.AddCommand(identifyCmd)
+	initListFlags()
+	rootCmd.AddCommand(listCmd)
+	initSelftestFlags()
+	rootCmd.AddCommand(selftestCmd)
+	initReviewFlags()
+	rootCmd.AddCommand(reviewCmd)
+	initSnapshotFlags()
+	rootCmd.AddCommand(snapshotCmd)
+	initInfoFlags()
+	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(schemaCmd)
+	initValidatePlanFlags()
+	rootCmd.AddCommand(validatePlanCmd)
 	if err := rootCmd.Execute(); err != nil {
-		log.Fatalf("failed to execute: %v", err)
+		exit(err)
 	}
 }
 
 // splitPDF coordinates the PDF splitting process by reading bookmarks
 // and creating separate files for each chapter.
-// Parameters _ and _ are used to satisfy the cobra.Command RunE interface.
-func splitPDF(_ *cobra.Command, _ []string) error {
+// Several inputs are split one after another by splitBatch.
+// Parameter cmd gives access to the flags set, _ is used to satisfy the cobra.Command RunE interface.
+func splitPDF(cmd *cobra.Command, _ []string) error {
+	// Validate flag values before doing any work
+	if err := setLanguage(language); err != nil {
+		return err
+	}
+	switch titleFrom {
+	case titleFromBookmark, titleFromFirstHeading, titleFromStructure:
+	default:
+		return fmt.Errorf("invalid --title-from value '%s': must be %s, %s or %s", titleFrom, titleFromBookmark, titleFromFirstHeading, titleFromStructure)
+	}
+	if err := checkPlanFlags(); err != nil {
+		return err
+	}
+	if err := checkCompareFlags(); err != nil {
+		return err
+	}
+	if stampID != "" && stampID != stampIDText {
+		return fmt.Errorf("invalid --stamp-id value '%s': must be %s", stampID, stampIDText)
+	}
+	switch stampHeader {
+	case "", stampHeaderTop, stampHeaderBottom:
+	default:
+		return fmt.Errorf("invalid --stamp-header value '%s': must be %s or %s", stampHeader, stampHeaderTop, stampHeaderBottom)
+	}
+	switch orphanPages {
+	case orphanIgnore, orphanCollect, orphanAttachPrevious:
+	default:
+		return fmt.Errorf("invalid --orphan-pages value '%s': must be %s, %s or %s", orphanPages, orphanIgnore, orphanCollect, orphanAttachPrevious)
+	}
+	if stampHeaderSize <= 0 {
+		return fmt.Errorf("invalid --stamp-header-size value %d: must be positive", stampHeaderSize)
+	}
+	switch dryRun {
+	case "", dryRunTable, dryRunJSON:
+	default:
+		return fmt.Errorf("invalid --dry-run value '%s': must be %s or %s", dryRun, dryRunTable, dryRunJSON)
+	}
+	if manifestFile != "" {
+		if err := checkManifestFormat(); err != nil {
+			return err
+		}
+	}
+	if _, ok := imageQualities[imageQuality]; !ok {
+		return fmt.Errorf("invalid --image-quality value '%s': must be %s, %s or %s", imageQuality, imageQualityKeep, imageQualityWeb, imageQualityPrint)
+	}
+	switch archiveDate {
+	case archiveDateCreation, archiveDateRun:
+	default:
+		return fmt.Errorf("invalid --archive-date value '%s': must be %s or %s", archiveDate, archiveDateCreation, archiveDateRun)
+	}
+	failOnFeatures, err := parseFeatureList(failUnsupported)
+	if err != nil {
+		return err
+	}
+	if extractAssets, err = parseAssetList(extractValues); err != nil {
+		return err
+	}
+	if planFile != "" && !cmd.Flags().Changed("name-template") {
+		nameTemplate = titleNameTemplate
+	}
+	barcodeNames = splitOnBarcode && !cmd.Flags().Changed("name-template")
+	if nameTemplateParsed, err = parseNameTemplate(nameTemplate); err != nil {
+		return err
+	}
+	if err = parseLogicalOffset(logicalOffsetText); err != nil {
+		return err
+	}
+	if !writesStdout() {
+		if outputDir, err = normalizeOutputDir(outputDir); err != nil {
+			return err
+		}
+	}
+	if readsStdin() && len(inputPaths) > 1 {
+		return fmt.Errorf("-i %s cannot be combined with other inputs", stdioPath)
+	}
+	if writesStdout() && isTerminal(os.Stdout) {
+		return fmt.Errorf("-o %s writes a PDF file to stdout, which is a terminal; redirect it to a file or pipe", stdioPath)
+	}
+	if pagesPerFile < 0 {
+		return fmt.Errorf("invalid --pages-per-file value %d: must not be negative", pagesPerFile)
+	}
+	if signatureSize < 0 {
+		return fmt.Errorf("invalid --signature-size value %d: must not be negative", signatureSize)
+	}
+	if sampleEvery < 0 {
+		return fmt.Errorf("invalid --sample value %d: must not be negative", sampleEvery)
+	}
+	sampleRandom = cmd.Flags().Changed("sample-seed")
+	pageOffsetSet = cmd.Flags().Changed("page-offset")
+	runOptionsFingerprint = optionsFingerprint(cmd.Flags())
+	if err := checkFlagInteractions(); err != nil {
+		return err
+	}
+	if profiles, err = parseProfiles(profileValues); err != nil {
+		return err
+	}
+	if headingPattern, err = regexp.Compile(headingPatternText); err != nil {
+		return fmt.Errorf("invalid --heading-pattern: %w", err)
+	}
+	if pagesAs != pagesAsPhysical && pagesAs != pagesAsLabels {
+		return fmt.Errorf("invalid --pages-as value '%s': must be %s or %s", pagesAs, pagesAsPhysical, pagesAsLabels)
+	}
+	if barcodePagesText != "" && pagesAs == pagesAsPhysical {
+		if barcodePages, err = ranges.Parse(barcodePagesText); err != nil {
+			return fmt.Errorf("invalid --barcode-pages '%s': %v", barcodePagesText, err)
+		}
+	}
+	if barcodeConfidence <= 0 || barcodeConfidence > 1 {
+		return fmt.Errorf("invalid --barcode-confidence value %g: must be above 0 and at most 1", barcodeConfidence)
+	}
+	if strings.TrimSpace(barcodeLeadingName) == "" {
+		return fmt.Errorf("--barcode-leading-name must not be empty")
+	}
+	if minPages < 0 {
+		return fmt.Errorf("invalid --min-pages value %d: must not be negative", minPages)
+	}
+	if targetPages < 0 {
+		return fmt.Errorf("invalid --target-pages value %d: must not be negative", targetPages)
+	}
+	if workers < 1 {
+		return fmt.Errorf("invalid --workers value %d: must be at least 1", workers)
+	}
+	if readRetries < 0 {
+		return fmt.Errorf("invalid --read-retries value %d: must not be negative", readRetries)
+	}
+	if chapterTimeout < 0 {
+		return fmt.Errorf("--chapter-timeout must not be negative")
+	}
+	if reviewThreshold < 0 || reviewThreshold > 1 {
+		return fmt.Errorf("invalid --review-threshold value %g: must be between 0 and 1", reviewThreshold)
+	}
+	if bloatFactor < 0 {
+		return fmt.Errorf("--bloat-factor must not be negative")
+	}
+	if truncateAtPage < 0 {
+		return fmt.Errorf("invalid --truncate-at-page value %d: must be positive", truncateAtPage)
+	}
+	if bandwidth != "" {
+		bytesPerSecond, err := parseBandwidth(bandwidth)
+		if err != nil {
+			return err
+		}
+		writeLimiter = newRateLimiter(bytesPerSecond)
+	}
+	if linkViews, err = parseLinkViews(alsoLink); err != nil {
+		return err
+	}
+	if exportFilter, err = parseChapterFilter(matchPattern, chapterSelection); err != nil {
+		return err
+	}
+	if excludePages != "" && pagesAs == pagesAsPhysical {
+		if excludedPages, err = ranges.Parse(excludePages); err != nil {
+			return fmt.Errorf("invalid --exclude-pages '%s': %v", excludePages, err)
+		}
+	}
+	if err = setupDestination(); err != nil {
+		return err
+	}
+
+	// Identify the run, so a pipeline can join its outputs to its own records
+	if runID == "" {
+		runID = newRunID()
+	}
+	if verbose {
+		printMsg("run_id", runID)
+	}
+
+	// Keep sampled outputs apart from the real ones
+	if sampleEvery > 0 && sampleDir != "" {
+		outputDir = filepath.Join(outputDir, sampleDir)
+	}
+	if singleOutput == "" && !writesStdout() {
+		printMsg("output_directory", outputDir)
+	}
+
+	// Name the outputs by the rules of the filesystem they are written to; a chapter for stdout is written to a temporary directory first
+	fsDir := outputDir
+	if writesStdout() {
+		fsDir = os.TempDir()
+	}
+	if err := resolveTargetFS(fsDir); err != nil {
+		return err
+	}
+	if verbose {
+		printMsg("target_fs", outputFS.name)
+		printConfiguration()
+	}
+
+	// Split every document of a batch in a run of its own
+	if isBatch() {
+		return failed(cmd, splitBatch(cmd))
+	}
+	return failed(cmd, splitInput(failOnFeatures))
+}
+
+// splitInput splits the single input of a run once the flags are validated.
+// Parameters:
+//   - failOnFeatures: source features that make the run fail, from --fail-on-unsupported
+//
+// Returns:
+//   - error: the first failure, or an *exitError for a run that ends with an exit code of its own
+func splitInput(failOnFeatures []string) error {
+	inputFilePath = inputPaths[0]
+	if err := usePasswordFile(inputFilePath); err != nil {
+		return err
+	}
+	if readsStdin() {
+		path, cleanup, err := spoolStdin()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		inputFilePath = path
+	}
+
+	// Keep or refuse an existing manifest before anything is written; profiles have their own
+	if manifestFile != "" && dryRun == "" && !noOutput && len(profiles) == 0 {
+		if err := checkManifest(); err != nil {
+			return err
+		}
+	}
+
 	// Open the source PDF file for reading
-	inputFile, err := os.Open(inputFilePath)
+	inputFile, err := openInput(inputFilePath)
 	if err != nil {
-		log.Fatalf("open input inputFile %s: %v", inputFilePath, err)
+		return err
 	}
 	defer inputFile.Close()
+	defer releaseSourceDocument(inputFile)
 
-	// Extract chapter information from PDF bookmarks
-	chapters := extractChapters(inputFile)
+	// Refuse a chapter of an earlier run, or split its source instead with --resplit
+	source, err := checkResplit(inputFile)
+	if err != nil {
+		return err
+	}
+	if source != "" {
+		inputFile.Close()
+		inputFilePath = source
+		if inputFile, err = openInput(inputFilePath); err != nil {
+			return err
+		}
+		defer inputFile.Close()
+		defer releaseSourceDocument(inputFile)
+	}
+	if err = resolveLogicalOffset(inputFile); err != nil {
+		return err
+	}
 
-	// Create separate PDF files for each chapter
-	exportChapters(inputFile, chapters)
+	// Report source features that the outputs will not preserve
+	if err = reportCapabilities(inputFile, failOnFeatures); err != nil {
+		return err
+	}
+
+	// File the chapters into a date partition if requested
+	baseDir := outputDir
+	if archiveLayout {
+		baseDir = archiveLayoutDir(inputFile, outputDir)
+	}
+
+	// Split the document once, or once per --profile from the source read once
+	if len(profiles) > 0 {
+		err = splitProfiles(inputFile, baseDir)
+	} else {
+		err = splitDocument(inputFile, baseDir)
+	}
+	if err != nil || dryRun != "" || noOutput {
+		return err
+	}
+
+	// Archive the source only after everything was written successfully
+	if archiveDir != "" {
+		inputFile.Close()
+		outputLocation := baseDir
+		if singleOutput != "" {
+			outputLocation = singleOutput
+		}
+		if err := archiveSource(inputFilePath, archiveDir, outputLocation); err != nil {
+			return exitWith(exitArchiveFailed, fmt.Errorf("failed to archive source: %w", err))
+		}
+	}
+	return exitOnNeedsReview()
+}
+
+// splitDocument detects the chapters of an opened source and exports them, or plans them for
+// --dry-run and --no-output, then writes the manifest.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//   - baseDir: directory the chapters are written to
+//
+// Returns:
+//   - error: the first failure, or an *exitError for a run that ends with an exit code of its own
+func splitDocument(inputFile *os.File, baseDir string) error {
+	comparedChapters = nil
+
+	// Detect the chapters of the whole document unless subtrees were selected; a wrapper of attachments may have no chapters
+	type subtreeChapters struct {
+		chapters    []chapter
+		parentTitle string
+	}
+	var subtrees []subtreeChapters
+	if len(underTitles) == 0 {
+		if processAttached && !hasChapterSource(inputFile) {
+			printMsg("wrapper_not_split")
+		} else {
+			chapters, _, err := sourceChapters(inputFile, "")
+			if err != nil {
+				return err
+			}
+			subtrees = append(subtrees, subtreeChapters{chapters: chapters})
+		}
+	}
+	for _, under := range underTitles {
+		chapters, parentTitle, err := sourceChapters(inputFile, under)
+		if err != nil {
+			return err
+		}
+		subtrees = append(subtrees, subtreeChapters{chapters, parentTitle})
+	}
+
+	// Score the detection, and set a doubtful document apart for --review-threshold
+	docDir := baseDir
+	if len(subtrees) > 0 {
+		var detected []chapter
+		for _, subtree := range subtrees {
+			detected = append(detected, subtree.chapters...)
+		}
+		docDir = scoreDocument(detected, baseDir)
+	}
+
+	// Split each selected subtree, into its own subdirectory if there are several
+	for _, subtree := range subtrees {
+		dir := docDir
+		if len(underTitles) > 1 {
+			dir = filepath.Join(docDir, sanitizeFilename(subtree.parentTitle))
+		}
+		if err := processChapters(inputFile, subtree.chapters, dir); err != nil {
+			return err
+		}
+	}
+
+	// Split the attached documents as additional inputs
+	if processAttached {
+		if err := processAttachedPDFs(inputFile, baseDir); err != nil {
+			return err
+		}
+	}
+
+	// Compare the planned chapters with those of an earlier run
+	if comparePrevious != "" {
+		if err := compareWithPrevious(); err != nil {
+			return err
+		}
+	}
+
+	// Report the outcome of the checks when nothing was written
+	if noOutput {
+		printCheckResult()
+	}
+	if dryRun != "" {
+		if manifestFile != "" {
+			return printManifest()
+		}
+		return printPlan(dryRun)
+	}
+
+	// Fail for skipped chapters and, in strict mode, for warnings, before the source is archived
+	reportIgnoredPermissions()
+	for _, check := range []func() error{exitOnTimeouts, exitOnUnreadable, exitOnInvalidOutputs} {
+		if err := check(); err != nil {
+			// The manifest tells which chapters were skipped or invalid and why
+			if manifestFile != "" && !noOutput {
+				if err := writeManifest(); err != nil {
+					return err
+				}
+			}
+			return err
+		}
+	}
+	for _, check := range []func() error{exitOnFailedDeliveries, failOnWarnings} {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	if noOutput {
+		return nil
+	}
+	if err := finalizeDestination(); err != nil {
+		return err
+	}
+	if manifestFile != "" {
+		return writeManifest()
+	}
 	return nil
 }
 
+// openInput opens a source PDF file and checks that it is a PDF file the passwords open.
+func openInput(path string) (*os.File, error) {
+	inputFile, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open input inputFile %s: %w", path, err)
+	}
+	if err = checkInputFormat(inputFile); err == nil {
+		err = checkPassword(inputFile)
+	}
+	if err != nil {
+		inputFile.Close()
+		return nil, err
+	}
+	return inputFile, nil
+}
+
+// processChapters exports a list of chapters, either as separate files or combined into one.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//   - chapters: list of chapter information
+//   - dir: output directory for separate chapter files
+//
+// Returns:
+//   - error: if the chapters cannot be planned or exported
+func processChapters(inputFile *os.File, chapters []chapter, dir string) error {
+	// Look for a second copy of the document appended to the first
+	if detectDuplicate {
+		if _, err := detectDuplication(inputFile, chapters); err != nil {
+			return err
+		}
+	}
+
+	// Drop blank separator pages at the chapter boundaries before the start pages are read for titles
+	var err error
+	if stripBlank {
+		if chapters, err = stripBlankPages(inputFile, chapters); err != nil {
+			return err
+		}
+	}
+
+	// Replace bookmark titles with the headings found on the start pages if requested
+	switch titleFrom {
+	case titleFromFirstHeading:
+		err = applyHeadingTitles(inputFile, chapters)
+	case titleFromStructure:
+		err = applyStructureTitles(inputFile, chapters)
+	}
+	if err != nil {
+		return err
+	}
+	if titleFrom != titleFromBookmark {
+		printRecoveredTitles(chapters)
+	}
+
+	// Number the chapters in the requested export order
+	if minPages > 0 {
+		chapters = mergeSmallChapters(chapters, minPages)
+	}
+	if targetPages > 0 {
+		chapters = packChapters(chapters, targetPages, packJoiner)
+	}
+	chapters = orderChapters(chapters, orderBy)
+
+	// Group the numbered chapters into the outputs of a --plan file
+	if planFile != "" {
+		var unreferenced []chapter
+		if chapters, unreferenced, err = applySplitPlan(inputFile, chapters); err != nil {
+			return err
+		}
+		defer printUnreferencedChapters(unreferenced)
+	}
+
+	// Ignore, collect or attach the pages that none of the planned chapters covers
+	if chapters, err = applyOrphanPages(inputFile, chapters); err != nil {
+		return err
+	}
+
+	// Leave the pages of --exclude-pages out of the chapters
+	if chapters, err = applyExcludedPages(inputFile, chapters); err != nil {
+		return err
+	}
+
+	// Name the files of all chapters, so that a selection does not change them
+	if err := assignFileNames(chapters, inputFile.Name()); err != nil && singleOutput == "" {
+		return err
+	}
+
+	// Keep only the chapters selected by --match and --chapters, with their numbers
+	if exportFilter.active() {
+		total := len(chapters)
+		selected, err := filterChapters(chapters, exportFilter)
+		if err != nil {
+			return err
+		}
+		chapters = selected
+		printMsg("filtered_chapters", len(chapters), total)
+	}
+
+	// Keep only a sample of the chapters for --sample, with their numbers
+	if sampleEvery > 0 {
+		total := len(chapters)
+		chapters = sampleChapters(chapters)
+		printMsg("sampled_chapters", len(chapters), total)
+	}
+
+	// Derive the stable chapter IDs for stamping and the plan
+	if stampID != "" || dryRun != "" || manifestFile != "" {
+		if err = assignChapterIDs(inputFile, chapters); err != nil {
+			return err
+		}
+	}
+
+	// Show how every chapter came about
+	if explainPlan {
+		printExplanation(chapters)
+	}
+
+	// Estimated boundaries are only split once accepted; the plan of a --dry-run shows them for review
+	if dryRun == "" && !noOutput {
+		if err = refuseEstimated(chapters); err != nil {
+			return err
+		}
+	}
+
+	// Keep the planned chapters for the comparison with an earlier run
+	recordComparedChapters(chapters)
+
+	// Writing to stdout takes exactly one chapter
+	if writesStdout() && len(chapters) != 1 {
+		return errors.New(msg("stdout_needs_one", len(chapters)))
+	}
+
+	switch {
+	case dryRun != "":
+		// Only collect the plan for --dry-run, which reports colliding names as problems
+		planChapters(inputFile, chapters, dir)
+		return nil
+	case noOutput:
+		// Only validate the plan if nothing should be written
+		return checkChapters(inputFile, chapters, dir)
+	case singleOutput != "":
+		// Combine all chapters into one file if requested
+		return exportCombined(inputFile, chapters, singleOutput)
+	case writesStdout():
+		// Write the only chapter to stdout if requested
+		return exportToStdout(inputFile, chapters[0])
+	}
+
+	// Create separate PDF files for each chapter
+	return exportChapters(inputFile, chapters, dir)
+}
+
 // chapter represents a section in the PDF document.
 // It contains the chapter title, order number, start page, and end page.
+// bookmarkTitle keeps the original bookmark title when title was taken from another source.
+// startsMidPage is set when the bookmark destination points below the top of the start page.
+// order is the position in export order, pageOrder the position in the document's page order.
+// trace lists the rules that produced the chapter's range, in application order.
+// parts holds the chapters combined into this one by --target-pages, and merged the titles of
+// those merged into it by --min-pages.
+// kids holds the sub-bookmarks of the chapter's bookmark, for --keep-bookmarks.
+// id is the stable chapter ID, set for --stamp-id and --dry-run.
+// continuation is the text of the --continuation-page naming the next output, empty for the last one.
+// frontMatter marks the pages before the first heading found by --detect-headings, numbered 0.
+// estimated is set when the start page was estimated by --infer-missing-destinations.
+// source is the bookmark the chapter was made from, as read from the outline.
+// file is the name of the chapter's file without .pdf, unique within its export.
+// ranges holds the page ranges of a --plan output made of several places, which startPage and
+// endPage span; it is empty for a chapter of consecutive pages.
+// detectedBy is the source of the chapter's boundaries, and collisions the number of bookmarks
+// folded into it because they start on the same page; both feed the confidence score.
+// warnings holds the codes of the warnings printed about the chapter while it was planned.
 type chapter struct {
-	title     string
-	order     uint32
-	startPage uint32
-	endPage   uint32
+	title         string
+	bookmarkTitle string
+	order         uint32
+	pageOrder     uint32
+	startPage     uint32
+	endPage       uint32
+	startsMidPage bool
+	trace         []string
+	parts         []chapter
+	merged        []string
+	kids          []pdfcpu.Bookmark
+	id            string
+	frontMatter   bool
+	continuation  string
+	estimated     bool
+	source        outlineRef
+	file          string
+	outputDir     string
+	ranges        []pageRange
+	detectedBy    string
+	collisions    int
+	warnings      []string
+}
+
+// explain records a step of the chapter's derivation, shown by --explain.
+// The step is stored as a localized message built from key and args.
+func (c *chapter) explain(key string, args ...any) {
+	c.trace = append(c.trace, msg(key, args...))
+}
+
+// warn prints a warning about the chapter with warnMsg and records its code for the manifest.
+func (c *chapter) warn(key string, args ...any) {
+	warnMsg(key, args...)
+	c.warnings = append(c.warnings, warningCodes[key])
 }
 
 // extractChapters reads the PDF bookmarks and converts them into chapter information.
 // It filters out nested sub-chapters and keeps only top-level chapters.
+// If under is set, only the kids of the bookmark it names are used and the last chapter
+// ends where that bookmark's span ends rather than at the end of the document.
 // Parameters:
 //   - inputFile: pointer to the opened PDF file
+//   - under: title or regular expression of the parent bookmark, or empty for the whole outline
 //
 // Returns:
 //   - []chapter: slice containing all chapter information
-func extractChapters(inputFile *os.File) []chapter {
-	// Create default configuration for PDF processing
-	conf := model.NewDefaultConfiguration()
+//   - string: title of the parent bookmark, empty if under is not set
+//   - error: if the chapters cannot be detected
+func extractChapters(inputFile *os.File, under string) ([]chapter, string, error) {
+	// Start documents after barcode separator pages on request; without any, the outline is used as usual
+	if under == "" && splitOnBarcode {
+		chapters, err := barcodeChapters(inputFile)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(chapters) > 0 {
+			printMsg("using_barcodes", len(chapters))
+			return markDetection(chapters, detectionBarcode), "", nil
+		}
+		warnMsg("no_barcodes")
+	}
+
+	// Start chapters at detected headings on request; without any match the outline is used as usual
+	if under == "" && detectHeadings {
+		chapters, err := headingChapters(inputFile)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(chapters) > 0 {
+			printMsg("using_headings", len(chapters), headingPattern)
+			return markDetection(chapters, detectionHeadings), "", nil
+		}
+		warnMsg("no_headings", headingPattern)
+	}
 
-	// Extract bookmarks from the PDF file
-	bookmarks, err := api.Bookmarks(inputFile, conf)
+	// Fall back to fixed-size chunks without an outline, or split by pages on request
+	if under == "" && pagesPerFile > 0 && (byPages || !hasChapterSource(inputFile)) {
+		chapters, err := pageChunks(inputFile, pagesPerFile)
+		if err != nil {
+			return nil, "", err
+		}
+		return markDetection(chapters, detectionChunks), "", nil
+	}
+
+	bookmarks, sidecar, err := readOutline(inputFile, under)
 	if err != nil {
-		log.Fatalf("failed to read PDF bookmarks: %v", err)
+		return nil, "", err
 	}
+	return outlineChapters(inputFile, bookmarks, sidecar, under)
+}
 
-	// Convert bookmarks to chapter information, skipping nested chapters
-	var chapters []chapter
-	for i, bm := range bookmarks {
-		// Skip if this bookmark is within the page range of the previous chapter
-		if len(chapters) > 0 && uint32(bm.PageFrom) < chapters[len(chapters)-1].endPage {
-			continue
+// readOutline reads the bookmark tree that chapters are made from: the chapter sidecar next to
+// the input if there is one and no subtree was selected, or else the outline of the document.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//   - under: title or regular expression of the parent bookmark, or empty for the whole outline
+//
+// Returns:
+//   - []pdfcpu.Bookmark: the complete bookmark tree
+//   - string: path of the chapter sidecar, empty if the outline was read
+//   - error: if the document, the sidecar or the outline cannot be read
+func readOutline(inputFile *os.File, under string) ([]pdfcpu.Bookmark, string, error) {
+	doc, err := sourceDocument(inputFile)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// A chapter sidecar next to the input replaces the outline unless a subtree was selected
+	var sidecar string
+	if under == "" {
+		sidecar = findSidecar(inputFile.Name(), sidecarSuffix)
+	}
+
+	// Extract bookmarks from the sidecar or the PDF file
+	var bookmarks []pdfcpu.Bookmark
+	if sidecar != "" {
+		entries, err := readSidecar(sidecar, doc.PageCount())
+		if err != nil {
+			return nil, "", limitExceeded(fmt.Errorf("failed to read chapter sidecar: %w", err))
+		}
+		bookmarks = sidecarBookmarks(entries)
+		printMsg("using_sidecar", sidecar)
+	} else if bookmarks, err = doc.Bookmarks(); err != nil {
+		// pdfcpu fails the whole outline on a single missing destination
+		if !inferMissing {
+			return nil, "", fmt.Errorf("failed to read PDF bookmarks: %w", err)
+		}
+		warnMsg("outline_unresolved", err)
+		err = doc.Inspect(func(ctx *model.Context) error {
+			bookmarks, err = lenientBookmarks(ctx)
+			return err
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read PDF bookmarks: %w", err)
 		}
-		chapters = append(chapters, chapter{
-			title:     bm.Title,
-			order:     uint32(i + 1),
-			startPage: uint32(bm.PageFrom),
+	}
+	return bookmarks, sidecar, nil
+}
+
+// outlineChapters converts a bookmark tree read by readOutline into chapters, at the outline
+// level selected by --under, --depth and the single-root rule, with the boundary flags applied.
+// The chapters are planned by splitter.PlanChapters; the list subcommand relates them to the
+// tree by their source bookmarks, so both always show the same split.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//   - bookmarks: the complete bookmark tree, which is not modified
+//   - sidecar: path of the chapter sidecar the tree was read from, or empty
+//   - under: title or regular expression of the parent bookmark, or empty for the whole outline
+//
+// Returns:
+//   - []chapter: slice containing all chapter information
+//   - string: title of the parent bookmark, empty if under is not set
+//   - error: if the outline does not give any chapter to split
+func outlineChapters(inputFile *os.File, bookmarks []pdfcpu.Bookmark, sidecar, under string) ([]chapter, string, error) {
+	// Resolve destination coordinates when chapters may start mid-page
+	var dests []destinationNode
+	if midPageStart != "" && sidecar == "" {
+		err := inspectSource(inputFile, func(ctx *model.Context) error {
+			if err := ctx.LocateNameTree("Dests", false); err != nil {
+				return fmt.Errorf("failed to read named destinations: %w", err)
+			}
+			var err error
+			if dests, err = outlineDestinations(ctx); err != nil {
+				return fmt.Errorf("failed to read bookmark destinations: %w", err)
+			}
+			return nil
 		})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	// Restrict the outline to the selected subtree
+	var parentTitle string
+	var lastPage int
+	if under != "" {
+		sub, err := findSubtree(bookmarks, dests, under)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to locate --under bookmark: %w", err)
+		}
+		if len(sub.bookmark.Kids) == 0 {
+			return nil, "", fmt.Errorf("bookmark '%s' has no sub-bookmarks to split", sub.bookmark.Title)
+		}
+		parentTitle = sub.bookmark.Title
+		bookmarks, dests, lastPage = sub.bookmark.Kids, sub.dests, sub.endPage
+
+		// The bookmark after the subtree owns the page it starts on
+		if noOverlap && midPageStart == "" && lastPage > bookmarks[len(bookmarks)-1].PageFrom {
+			lastPage--
+		}
+	}
+
+	// A single root bookmark spanning everything is usually the document title
+	var descendedFrom string
+	if under == "" && !noAutoDescend && splitDepth == 1 && len(bookmarks) == 1 {
+		var descended bool
+		root := bookmarks[0].Title
+		if bookmarks, dests, descended = descendSingleRoot(bookmarks, dests); descended {
+			descendedFrom = root
+			printMsg("auto_descend", root)
+		}
+	}
+
+	// Split at deeper outline levels if requested
+	if splitDepth > 1 {
+		bookmarks, dests = flattenToDepth(bookmarks, dests, splitDepth)
+	}
+
+	// Estimate the start pages of bookmarks whose destination could not be resolved
+	var pageCount int
+	if inferMissing || lastPage == 0 {
+		var err error
+		if pageCount, err = sourcePageCount(inputFile); err != nil {
+			return nil, "", err
+		}
+	}
+	var estimated []bool
+	if inferMissing {
+		// The tree itself keeps the unresolved pages
+		bookmarks = slices.Clone(bookmarks)
+		estimated = inferStartPages(bookmarks, pageCount)
+	}
+
+	// End the last chapter with the subtree or the document, or at the truncation page
+	endReason := msg("end_document")
+	if lastPage == 0 {
+		lastPage = pageCount
+	} else {
+		endReason = msg("end_subtree")
+	}
+	if truncateAtPage > 0 && truncateAtPage < lastPage {
+		lastPage = truncateAtPage
+		endReason = msg("end_truncated")
+	}
+
+	// Plan the chapters in page order, merging bookmarks at the top of the page the chapter
+	// before starts on; the boundary flags decide about the pages where chapters meet below
+	reordered := warnOutlineOrder(bookmarks)
+	midPage := func(i int) bool {
+		return len(dests) == len(bookmarks) && dests[i].page == bookmarks[i].PageFrom && !dests[i].nearTop()
+	}
+	planned, err := splitter.PlanChapters(bookmarks, splitter.PlanOptions{
+		LastPage: lastPage,
+		Overlap:  midPageStart != "" || !noOverlap,
+		Separate: midPage,
+		Limits:   sourceLimits(),
+	})
+	switch {
+	case errors.Is(err, splitter.ErrNoChapters):
+		return nil, "", fmt.Errorf("no chapters found in input file")
+	case errors.Is(err, splitter.ErrPastLastPage) && truncateAtPage > 0:
+		return nil, "", fmt.Errorf("--truncate-at-page %d is before the first chapter", truncateAtPage)
+	case err != nil:
+		return nil, "", limitExceeded(err)
+	}
+
+	// Convert the planned chapters, explaining how every one came about
+	chapters := make([]chapter, len(planned))
+	used := make([]bool, len(bookmarks))
+	var merged bool
+	for n, p := range planned {
+		i := p.Bookmark
+		bm := bookmarks[i]
+		cpt := chapter{
+			title:      bm.Title,
+			order:      uint32(p.Order),
+			startPage:  uint32(p.StartPage),
+			endPage:    uint32(p.EndPage),
+			kids:       bm.Kids,
+			source:     outlineRef{title: bm.Title, page: bm.PageFrom},
+			detectedBy: detectionOutline,
+		}
+		if len(estimated) > 0 && estimated[i] {
+			cpt.source.page = 0
+		}
+		if sidecar != "" {
+			cpt.detectedBy = detectionSidecar
+			cpt.explain("explain_from_sidecar", sidecar, i+1, bm.PageFrom)
+		} else {
+			cpt.explain("explain_from_bookmark", bm.Title, i+1, bm.PageFrom)
+		}
+		if parentTitle != "" {
+			cpt.explain("explain_under", parentTitle)
+		}
+		if descendedFrom != "" {
+			cpt.explain("explain_descended", descendedFrom)
+		}
+		if splitDepth > 1 {
+			cpt.explain("explain_depth", splitDepth)
+		}
+		if len(estimated) > 0 && estimated[i] {
+			cpt.estimated = true
+			cpt.explain("explain_estimated", bm.PageFrom)
+		}
+		cpt.startsMidPage = midPage(i)
+		used[i] = true
+
+		// A bookmark at the top of the page the chapter starts on adds nothing but a title
+		for _, j := range p.Merged {
+			cpt.warn("outline_merged", cpt.title, bookmarks[j].Title, bookmarks[j].PageFrom)
+			cpt.explain("explain_merged_same_page", bookmarks[j].Title, bookmarks[j].PageFrom)
+			cpt.kids = append(cpt.kids, bookmarks[j].Kids...)
+			cpt.collisions++
+			used[j] = true
+			merged = true
+		}
+		chapters[n] = cpt
 	}
 
-	// Ensure at least one chapter was found
-	if len(chapters) == 0 {
-		log.Fatalf("no chapters found in input file")
+	// --strict stops at an outline that had to be repaired, before anything is written
+	if reordered || merged {
+		if err := failOnWarnings(); err != nil {
+			return nil, "", err
+		}
 	}
 
-	// Set end pages for each chapter based on the next chapter's start page
+	// Explain the end pages: where the next chapter starts, or the end of the document
+	last := &chapters[len(chapters)-1]
 	for i := 0; i < len(chapters)-1; i++ {
-		chapters[i].endPage = chapters[i+1].startPage
+		chapters[i].explain("explain_end_next", chapters[i+1].startPage, chapters[i+1].title)
+	}
+	byPage, _ := splitter.PageOrder(bookmarks)
+	for _, i := range byPage {
+		if !used[i] {
+			last.explain("explain_dropped", bookmarks[i].Title)
+		}
 	}
+	last.explain("explain_end", lastPage, endReason)
 
-	// Set the end page of the last chapter to the total page count
-	pageCount, err := api.PageCount(inputFile, conf)
-	if err != nil {
-		log.Fatalf("failed to read page count: %+v", err)
+	// Decide per boundary which chapter owns the page where the next chapter starts
+	for i := 0; i < len(chapters)-1; i++ {
+		prev, next := &chapters[i], &chapters[i+1]
+		switch {
+		case midPageStart != "":
+			resolveBoundary(prev, next)
+		case prev.endPage < next.startPage:
+			prev.explain("explain_end_no_overlap", prev.endPage, next.startPage, next.title)
+		}
+	}
+
+	// Pull intro pages in front of a heading into the chapter they introduce
+	if lookback > 0 {
+		for i := 0; i < len(chapters)-1; i++ {
+			applyLookback(&chapters[i], &chapters[i+1])
+		}
+	}
+
+	// Never hand a broken range to pdfcpu
+	if err := checkRanges(chapters); err != nil {
+		return nil, "", err
+	}
+	return chapters, parentTitle, nil
+}
+
+// applyLookback moves the start of next back by up to --lookback pages, taking the pages
+// from prev. The start never moves onto or before prev's heading page, and the moved pages
+// are removed from prev rather than duplicated.
+// Parameters:
+//   - prev: chapter before the boundary
+//   - next: chapter whose start is moved
+func applyLookback(prev, next *chapter) {
+	if next.startPage <= prev.startPage+1 {
+		return
+	}
+	moved := min(uint32(lookback), next.startPage-prev.startPage-1)
+	next.startPage -= moved
+	prev.endPage = next.startPage - 1
+	next.explain("explain_lookback", moved, next.startPage)
+	prev.explain("explain_lookback_prev", prev.endPage, next.title)
+	if verbose {
+		printMsg("lookback", next.title, moved, next.startPage)
+	}
+}
+
+// resolveBoundary applies the --mid-page-start policy to the boundary between two consecutive chapters.
+// A next chapter starting at the top of its page owns that page completely. If it starts mid-page,
+// the page goes to the previous chapter, the next chapter, or both, according to the policy.
+// Ranges are never reduced below one page; in that case the page is kept in both chapters.
+// Parameters:
+//   - prev: chapter before the boundary, whose endPage is initially next's startPage
+//   - next: chapter after the boundary
+func resolveBoundary(prev, next *chapter) {
+	shared := next.startPage
+	decision := midPageStartDuplicate
+	switch {
+	case prev.startPage == next.startPage:
+		// Both chapters start on the same page, there is nothing to move
+	case !next.startsMidPage || midPageStart == midPageStartNext:
+		prev.endPage = shared - 1
+		decision = midPageStartNext
+		prev.explain("explain_end_moved", prev.endPage, shared, next.title)
+	case midPageStart == midPageStartPrevious && next.startPage < next.endPage:
+		next.startPage = shared + 1
+		decision = midPageStartPrevious
+		next.explain("explain_start_moved", next.startPage, shared, prev.title)
+	default:
+		next.explain("explain_shared", shared, prev.title)
+	}
+
+	if verbose {
+		position := msg("position_top")
+		if next.startsMidPage {
+			position = msg("position_mid")
+		}
+		printMsg("boundary", prev.title, next.title, position, shared, decision)
+	}
+}
+
+// printExplanation prints the derivation of every chapter's page range.
+func printExplanation(chapters []chapter) {
+	for _, cpt := range chapters {
+		printMsg("explain_chapter", cpt.order, cpt.title, cpt.startPage, cpt.endPage)
+		for _, step := range cpt.trace {
+			printMsg("explain_step", step)
+		}
+	}
+}
+
+// applyHeadingTitles replaces each chapter title with the first prominent text line of its start page.
+// Chapters whose start page yields no readable heading keep their bookmark title.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//   - chapters: list of chapter information, updated in place
+//
+// Returns:
+//   - error: if the pages cannot be read
+func applyHeadingTitles(inputFile *os.File, chapters []chapter) error {
+	return inspectSource(inputFile, func(ctx *model.Context) error {
+		for i := range chapters {
+			chapters[i].bookmarkTitle = chapters[i].title
+			if heading := firstHeading(ctx, int(chapters[i].startPage)); heading != "" && heading != chapters[i].title {
+				chapters[i].title = heading
+				chapters[i].explain("explain_heading", chapters[i].startPage)
+			}
+		}
+		return nil
+	})
+}
+
+// printRecoveredTitles lists the bookmark title and the recovered title of every chapter side by side,
+// so that the substitution can be reviewed, e.g. with --dry-run, before splitting.
+func printRecoveredTitles(chapters []chapter) {
+	for _, cpt := range chapters {
+		if cpt.bookmarkTitle != "" && cpt.bookmarkTitle != cpt.title {
+			printMsg("title_recovered", cpt.bookmarkTitle, cpt.title)
+		} else {
+			printMsg("title_kept", cpt.title)
+		}
 	}
-	chapters[len(chapters)-1].endPage = uint32(pageCount)
-	return chapters
 }
 
 // exportChapters creates separate PDF files for each chapter.
@@ -125,49 +1325,456 @@ func extractChapters(inputFile *os.File) []chapter {
 // Parameters:
 //   - inputFile: pointer to the source PDF file
 //   - chapters: list of chapter information
-func exportChapters(inputFile *os.File, chapters []chapter) {
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		log.Fatalf("fail to create output directory: %v", err)
+//   - dir: output directory
+//
+// Returns:
+//   - error: if a chapter cannot be exported, or a check ends the run
+func exportChapters(inputFile *os.File, chapters []chapter, dir string) error {
+	// Report titles that lose much of their content in the filename
+	if err := checkLossyNames(chapters); err != nil {
+		return err
 	}
 
-	// Process each chapter and create separate PDF files
-	for _, cpt := range chapters {
-		// Format the page range string for PDF splitting
-		pageRange := fmt.Sprintf("%d-%d", cpt.startPage, cpt.endPage)
+	// Create output directory if it doesn't exist; --dest-cmd gets the files instead
+	if outputDestination == nil {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("fail to create output directory: %w", err)
+		}
+	}
+
+	// Layers and article threads need to be rebuilt per chapter, and the structure of tagged sources is dropped
+	layered, err := sourceHasLayers(inputFile)
+	if err != nil {
+		return err
+	}
+	tagged, err := sourceIsTagged(inputFile)
+	if err != nil {
+		return err
+	}
+	threaded, err := sourceHasThreads(inputFile)
+	if err != nil {
+		return err
+	}
+	protected, err := keptProtection(inputFile)
+	if err != nil {
+		return err
+	}
+
+	// Mark every chapter with its source with --provenance, so a later run recognizes it
+	origin, err := runProvenance(inputFile)
+	if err != nil {
+		return err
+	}
+	info := readDocumentInfo(inputFile)
+
+	// Compare the size of every chapter with the source average
+	sourceRatio, err := sourceBytesPerPage(inputFile)
+	if err != nil {
+		return err
+	}
+
+	// Extract the assets of --extract from the source, which is read once for the text of all chapters
+	assetSrc := &assetSource{inputFile: inputFile}
 
-		// Generate output filename with chapter order and sanitized title
-		outputFilePath := filepath.Join(outputDir, fmt.Sprintf("%02d_%s.pdf", cpt.order, sanitizeFilename(cpt.title)))
+	// Take all chapters from the source read once, unless --low-memory
+	doc := loadSourceDocument(inputFile)
 
-		// Create the output file
-		outputFile, err := os.Create(outputFilePath)
+	// Track written bytes to report the write throughput
+	var stats writeStats
+	start := time.Now()
+
+	// Resolve every file name first; continuation pages name the file of the next chapter
+	paths := make([]string, len(chapters))
+	for i, cpt := range chapters {
+		paths[i] = filepath.Join(chapterDir(cpt, dir), cpt.file+".pdf")
+	}
+	setContinuations(chapters, paths)
+
+	// Create the output_dir directories of the --plan outputs
+	if outputDestination == nil {
+		for _, path := range paths {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("fail to create output directory: %w", err)
+			}
+		}
+	}
+
+	// Write the files for --dest-cmd to a staging directory, delivering each once it is checked
+	files, cleanup, err := stageOutputs(paths)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// Prepare the fixes of every chapter
+	fixes := make([]chapterFixes, len(chapters))
+	for i, cpt := range chapters {
+		fixes[i] = chapterFixes{layers: layered, untag: tagged, continuation: cpt.continuation, pad: paddedPages(cpt), threads: threaded, subset: subsetResource, images: imageQualities[imageQuality], protect: protected, provenance: origin, info: info.properties(cpt.title)}
+		if stampID != "" {
+			fixes[i].stamp = cpt.id
+		}
+		if stampHeader != "" {
+			fixes[i].header = &pageHeader{title: cpt.title, position: stampHeader, size: stampHeaderSize}
+		}
+		switch {
+		case packBookmarks && len(cpt.parts) > 1:
+			fixes[i].bookmarks = partBookmarks(cpt)
+		case keepBookmarks:
+			fixes[i].bookmarks = chapterBookmarks(cpt)
+		}
+	}
+
+	// Keep or refuse existing files before anything is written
+	skip := make([]bool, len(paths))
+	if outputDestination == nil {
+		if skip, err = checkExistingOutputs(paths); err != nil {
+			return err
+		}
+	}
+
+	// Trim the chapters on --workers goroutines; a failure cancels the chapters not yet started.
+	// Every worker times its own chapters, which are read only after their result arrived.
+	durations := make([]time.Duration, len(chapters))
+	reports := make([]fixReport, len(chapters))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exports := splitter.RunExports(ctx, len(chapters), workers, isChapterFailure, func(i int) error {
+		if skip[i] {
+			return nil
+		}
+		begin := time.Now()
+		defer func() { durations[i] = time.Since(begin) }()
+		pageRange := chapterPageRange(chapters[i])
+		var err error
+		reports[i], err = writeChapterFile(inputFile.Name(), doc, files[i], chapters[i].title, pageRange, fixes[i], &stats)
+		return err
+	})
+	// A failure returns before all chapters were waited for; no chapter may be written after
+	// that, and the staging directory is removed only once the workers are done
+	defer exports.Close()
+
+	// Check and report every chapter in order as soon as it is written
+	var written, writtenPages, padding int
+	for i, cpt := range chapters {
+		showProgress(i, len(chapters), cpt)
+		pageRange := chapterPageRange(cpt)
+		outputFilePath := files[i]
+		padded := fixes[i].pad
+		firstWarning := len(warnings)
+		err := exports.Wait(i)
+		report := reports[i]
+		if skip[i] {
+			printMsg("skipped_existing", cpt.title, outputFilePath)
+			addSkippedToManifest(cpt, paths[i], statusKept, nil)
+			continue
+		}
+		if errors.Is(err, context.Canceled) {
+			// A later chapter failed and stopped this one
+			if j, failure := exports.FirstFailure(i + 1); j >= 0 {
+				return fmt.Errorf("failed to split chapter '%s' (pages %d-%d): %w", chapters[j].title, chapters[j].startPage, chapters[j].endPage, failure)
+			}
+		}
+		if errors.Is(err, errChapterTimeout) || errors.Is(err, errSourceUnreadable) {
+			// Carry on with the next chapter
+			if errors.Is(err, errChapterTimeout) {
+				timedOutChapters = append(timedOutChapters, msg("timeout_entry", cpt.title, pageRange, chapterTimeout))
+				errorMsg("chapter_timeout", cpt.title, pageRange, chapterTimeout)
+				addSkippedToManifest(cpt, paths[i], statusTimeout, fmt.Errorf("%w after %s", err, chapterTimeout))
+			} else {
+				unreadableChapters = append(unreadableChapters, msg("unreadable_entry", cpt.title, pageRange, err))
+				errorMsg("chapter_unreadable", cpt.title, pageRange, err)
+				addSkippedToManifest(cpt, paths[i], statusUnreadable, err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to split chapter '%s' (pages %s): %w", cpt.title, pageRange, err)
+		}
+
+		// Check that the written file can be read and contains the planned pages
+		pagesWritten, verified, err := verifyPageCount(outputFilePath, cpt.title, plannedPages(cpt)+addedPages(cpt))
+		if err != nil {
+			return err
+		}
+		if outputDestination != nil && !deliverChapter(cpt, outputFilePath, paths[i]) {
+			continue
+		}
+		written++
+		writtenPages += plannedPages(cpt) + addedPages(cpt)
+		if verbose {
+			if cpt.bookmarkTitle != "" && cpt.bookmarkTitle != cpt.title {
+				printMsg("exported_chapter_bookmark", cpt.title, cpt.bookmarkTitle, pageRange)
+			} else {
+				printMsg("exported_chapter", cpt.title, pageRange)
+			}
+			printChapterDuration(cpt.title, durations[i])
+		}
+		var validation []string
+		if validateOutputs {
+			validation = validateOutput(outputFilePath, cpt.title)
+			verified = len(validation) == 0 && verified
+		}
+		switch {
+		case len(cpt.parts) > 1:
+			printMsg("packed_chapter", len(cpt.parts), partTitles(cpt))
+		case len(cpt.merged) > 1:
+			printMsg("merged_chapter", len(cpt.merged), quoteTitles(cpt.merged))
+		}
+		switch {
+		case padded > 0 && signatureSize > 0:
+			printMsg("signature_padded_chapter", padded, cpt.title, signatureSize)
+		case padded > 0:
+			printMsg("padded_chapter", cpt.title)
+		}
+		padding += padded
+		if stampID != "" {
+			printMsg("stamped_id", cpt.id, cpt.title)
+		}
+		if threaded && verbose {
+			printMsg("chapter_threads", cpt.title, report.threads.preserved, report.threads.truncated)
+		}
+		if report.images > 0 {
+			printMsg("images_recompressed", cpt.title, report.images)
+		}
+		if subsetResource {
+			switch {
+			case report.subset.skipped != nil:
+				warnMsg("subset_skipped", cpt.title, report.subset.skipped)
+			case report.subset.savedBytes > 0:
+				printMsg("subset_saved", cpt.title, formatBytes(float64(report.subset.savedBytes)))
+			case verbose:
+				printMsg("subset_none", cpt.title)
+			}
+		}
+		bytesPerPage, bloatRatio, err := checkBloat(outputFilePath, cpt.title, plannedPages(cpt)+addedPages(cpt), sourceRatio)
+		if err != nil {
+			return err
+		}
+		links := linkChapter(outputFilePath, cpt)
+		addToManifest(cpt, paths[i], verified)
+		if len(extractAssets) > 0 {
+			addAssetsToManifest(extractChapterAssets(assetSrc, cpt, outputFilePath))
+		}
+		if entry := lastManifestEntry(); entry != nil {
+			entry.DurationMS = durations[i].Milliseconds()
+			entry.PagesWritten = pagesWritten
+			entry.BytesPerPage = int64(math.Round(bytesPerPage))
+			entry.BloatRatio = math.Round(bloatRatio*100) / 100
+			entry.Links = links
+			entry.Validation = validation
+			entry.Warnings = append(entry.Warnings, warningCodesSince(firstWarning)...)
+		}
+	}
+	endProgress()
+	printMsg("exported_chapters", written, len(chapters), dir)
+	if padding > 0 {
+		printMsg("padded_total", padding)
+	}
+	if err = printPageSummary(inputFile, chapters, writtenPages); err != nil {
+		return err
+	}
+
+	if bandwidth != "" || verbose {
+		printThroughput(&stats, time.Since(start))
+	}
+	return nil
+}
+
+// exportCombined trims every chapter and merges them in order into a single PDF file.
+// The outline of the result is regenerated with one top-level bookmark per chapter,
+// pointing at the chapter's first page within the combined file.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//   - chapters: list of chapter information
+//   - outputFilePath: path of the combined PDF file
+//
+// Returns:
+//   - error: if a chapter cannot be exported or the combined file cannot be written
+func exportCombined(inputFile *os.File, chapters []chapter, outputFilePath string) error {
+	// Keep or refuse an existing file before any work is done.
+	// Every chapter of the manifest points at the combined file.
+	skip, err := checkExistingOutputs([]string{outputFilePath})
+	if err != nil {
+		return err
+	}
+	if skip[0] {
+		printMsg("skipped_existing", "combined", outputFilePath)
+		for _, cpt := range chapters {
+			addSkippedToManifest(cpt, outputFilePath, statusKept, nil)
+		}
+		return nil
+	}
+
+	// Tagged sources lose their structure in the combined file as well
+	tagged, err := sourceIsTagged(inputFile)
+	if err != nil {
+		return err
+	}
+	threaded, err := sourceHasThreads(inputFile)
+	if err != nil {
+		return err
+	}
+	doc := loadSourceDocument(inputFile)
+
+	// Trim each chapter into memory and remember where it starts in the combined file
+	var (
+		parts     []io.ReadSeeker
+		bookmarks []pdfcpu.Bookmark
+		nextPage  = 1
+	)
+	for i, cpt := range chapters {
+		showProgress(i, len(chapters), cpt)
+		begin := time.Now()
+		pageRange := chapterPageRange(cpt)
+		// Pad chapters so that each one starts on a right-hand page or a new signature
+		var buf bytes.Buffer
+		fixes := chapterFixes{pad: paddedPages(cpt), threads: threaded, images: imageQualities[imageQuality]}
+		if stampID != "" {
+			fixes.stamp = cpt.id
+		}
+		if stampHeader != "" {
+			fixes.header = &pageHeader{title: cpt.title, position: stampHeader, size: stampHeaderSize}
+		}
+		err := withReadRetries(inputFile, cpt.title, func(source *os.File) error {
+			buf.Reset()
+			_, err := trimChapter(source, doc, &buf, pageRange, fixes)
+			return err
+		})
+		if errors.Is(err, errSourceUnreadable) {
+			// The combined file cannot be completed without the chapter
+			unreadableChapters = append(unreadableChapters, msg("unreadable_entry", cpt.title, pageRange, err))
+			return exitOnUnreadable()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to split chapter '%s' (pages %s): %w", cpt.title, pageRange, err)
+		}
+		part := bytes.NewReader(buf.Bytes())
+
+		// Use the real page count of the trimmed part to keep destinations correct
+		pageCount, err := api.PageCount(part, model.NewDefaultConfiguration())
 		if err != nil {
-			log.Fatalf("failed to create output file '%s': %v", outputFilePath, err)
+			return fmt.Errorf("failed to read page count of chapter '%s' (pages %s): %w", cpt.title, pageRange, err)
 		}
+		bm := pdfcpu.Bookmark{Title: cpt.title, PageFrom: nextPage}
+		if keepBookmarks {
+			bm.Kids = remapBookmarks(chapterBookmarks(cpt), 1, uint32(pageCount), -(nextPage - 1))
+		}
+		bookmarks = append(bookmarks, bm)
+		parts = append(parts, part)
+		nextPage += pageCount
+		if verbose {
+			printMsg("added_chapter", cpt.title, pageRange)
+			printChapterDuration(cpt.title, time.Since(begin))
+		}
+	}
+	endProgress()
 
-		// Extract the chapter pages to a new PDF file
-		if err = api.Trim(inputFile, outputFile, []string{pageRange}, model.NewDefaultConfiguration()); err != nil {
-			log.Fatalf("failed to split chapter '%s': %v", cpt.title, err)
+	// Merge all parts into one document
+	var merged bytes.Buffer
+	if err := api.MergeRaw(parts, &merged, false, model.NewDefaultConfiguration()); err != nil {
+		return fmt.Errorf("failed to merge chapters: %w", err)
+	}
+
+	// Replace the merged outline with one bookmark per chapter
+	ctx, err := api.ReadAndValidate(bytes.NewReader(merged.Bytes()), model.NewDefaultConfiguration())
+	if err != nil {
+		return fmt.Errorf("failed to read merged chapters: %w", err)
+	}
+	if err = writeOutline(ctx, bookmarks); err != nil {
+		return fmt.Errorf("failed to add bookmarks to '%s': %w", outputFilePath, err)
+	}
+	if err = pruneOptionalContent(ctx); err != nil {
+		return fmt.Errorf("failed to rebuild layers of '%s': %w", outputFilePath, err)
+	}
+	if tagged {
+		if err = stripStructure(ctx); err != nil {
+			return fmt.Errorf("failed to remove structure of '%s': %w", outputFilePath, err)
 		}
-		fmt.Printf("exported chapter: '%s' (pages: %s)\n", cpt.title, pageRange)
 	}
+	// The combined file keeps the title of the source
+	if err = setDocumentInfo(ctx, readDocumentInfo(inputFile).properties("")); err != nil {
+		return fmt.Errorf("failed to copy the document information into '%s': %w", outputFilePath, err)
+	}
+	protected, err := keptProtection(inputFile)
+	if err != nil {
+		return err
+	}
+	if protected != nil {
+		encryptOnWrite(ctx, protected)
+	}
+
+	// Create the output file and its directory
+	if dir := filepath.Dir(outputFilePath); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("fail to create output directory: %w", err)
+		}
+	}
+	outputFile, err := createOutput(outputFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file '%s': %w", outputFilePath, err)
+	}
+	var stats writeStats
+	start := time.Now()
+	if err = api.WriteContext(ctx, outputWriter(outputFile, &stats)); err != nil {
+		outputFile.discard()
+		return fmt.Errorf("failed to write '%s': %w", outputFilePath, err)
+	}
+	if err = outputFile.commit(); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", outputFilePath, err)
+	}
+
+	// Check that the combined file contains all planned pages
+	var want, padding int
+	for _, cpt := range chapters {
+		want += plannedPages(cpt) + paddedPages(cpt)
+		padding += paddedPages(cpt)
+	}
+	_, verified, err := verifyPageCount(outputFilePath, "combined", want)
+	if err != nil {
+		return err
+	}
+	printMsg("exported_combined", len(chapters), outputFilePath)
+	if padding > 0 {
+		printMsg("padded_total", padding)
+	}
+	var validation []string
+	if validateOutputs {
+		validation = validateOutput(outputFilePath, "combined")
+		verified = len(validation) == 0 && verified
+	}
+	for _, cpt := range chapters {
+		addToManifest(cpt, outputFilePath, verified)
+		if entry := lastManifestEntry(); entry != nil {
+			entry.Validation = validation
+		}
+	}
+	if err = printPageSummary(inputFile, chapters, want); err != nil {
+		return err
+	}
+	if bandwidth != "" || verbose {
+		printThroughput(&stats, time.Since(start))
+	}
+	return nil
+}
+
+// sourceHasLayers reports whether the source document defines optional content groups (layers).
+func sourceHasLayers(inputFile *os.File) (bool, error) {
+	var layered bool
+	err := inspectSource(inputFile, func(ctx *model.Context) error {
+		layered = hasOptionalContent(ctx)
+		return nil
+	})
+	return layered, err
 }
 
 // sanitizeFilename cleans illegal characters from filename by replacing them with underscores.
 // Common illegal characters include: /, \, :, *, ?, ", <, >, |
+// The rules of the output filesystem from --target-fs are applied on top, including its length limit.
 // Parameters:
 //   - filename: original filename
 //
 // Returns:
 //   - string: sanitized legal filename
 func sanitizeFilename(filename string) string {
-	// Define characters that are not allowed in filenames
-	illegal := []string{"/", "\\", ":", "*", "?", "\"", "<", ">", "|"}
-	result := filename
-
-	// Replace each illegal character with an underscore
-	for _, char := range illegal {
-		result = strings.ReplaceAll(result, char, "_")
-	}
-	return result
+	return outputFS.Clean(splitter.SanitizeFilename(filename))
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// runMainEnv makes the test binary run the command line tool instead of the tests, so that
+// runCommand splits documents as a user would, in a process of its own with fresh flags.
+const runMainEnv = "PDF_SPLIT_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if name := os.Getenv(splitter.NameEnv); name != "" {
+		os.Exit(receiveChapter(name, os.Args[1:]))
+	}
+	if os.Getenv(runMainEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCommand runs the command line tool with args in dir and returns its combined output.
+// Parameters:
+//   - tb: the running test or benchmark
+//   - dir: working directory of the run
+//   - args: command line arguments, without the program name
+//
+// Returns:
+//   - string: stdout and stderr of the run
+//   - error: an *exec.ExitError if the run failed
+func runCommand(tb testing.TB, dir string, args ...string) (string, error) {
+	tb.Helper()
+	run := exec.Command(os.Args[0], args...)
+	run.Dir = dir
+	run.Env = append(os.Environ(), runMainEnv+"=1", "LANG=C")
+	var output bytes.Buffer
+	run.Stdout, run.Stderr = &output, &output
+	err := run.Run()
+	return output.String(), err
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Supported --manifest formats, chosen by the file extension.
+const (
+	manifestJSON = ".json"
+	manifestCSV  = ".csv"
+)
+
+// The statuses of the chapters in the manifest.
+const (
+	statusWritten    = "written"
+	statusKept       = "kept"
+	statusPlanned    = "planned"
+	statusTimeout    = "timeout"
+	statusUnreadable = "unreadable"
+)
+
+// manifestEntry is one chapter of the --manifest table of contents.
+// File is the path of the output relative to the output directory, and Path its absolute path.
+// Estimated is set when the start page was estimated by --infer-missing-destinations.
+// StartPage and EndPage are physical pages; LogicalStart and LogicalEnd are the printed page
+// numbers of --logical-offset.
+// TOCSource and TOCSourceID name the --toc-from-pdf document the chapter was planned on.
+// TextFile and ImagesDir are the assets written by --extract, relative like File.
+// PaddedPages is the number of blank pages added after Pages by --pad-to-even or --signature-size.
+// PagesWritten is the page count the file was read back with, including the padding; it is unset
+// with --no-verify, for a file that could not be read back and for a combined file.
+// BytesPerPage is the size of the file per page, and BloatRatio its multiple of the source's
+// bytes per page that --bloat-factor is compared with; both are unset for a combined file.
+// Unsupported are the features of the source that the file does not fully preserve, see
+// --fail-on-unsupported.
+// Warnings are the codes of the warnings printed about the chapter, while it was planned and
+// while it was written, in order; see the warnings subcommand.
+// Confidence is the confidence score of the chapter detection of the document, see --review-threshold.
+// Version is the versionString of the pdf-split build that wrote the file, and RunID the --run-id
+// of the run that wrote it.
+// Verified is set when the file was read back with the planned page count and, with
+// --validate-outputs, passed the validation; it is never set with --no-verify.
+// Status is how the chapter ended: written, kept by --skip-existing, planned by --dry-run, or
+// skipped after a --chapter-timeout or an unreadable source, with Error saying why; a skipped
+// chapter has no file at File.
+// Validation holds the messages of a file that failed --validate-outputs.
+type manifestEntry struct {
+	ID           string   `json:"id"`
+	Order        uint32   `json:"order"`
+	PageOrder    uint32   `json:"page_order"`
+	Title        string   `json:"title"`
+	StartPage    uint32   `json:"start_page"`
+	EndPage      uint32   `json:"end_page"`
+	Pages        int      `json:"pages"`
+	PaddedPages  int      `json:"padded_pages,omitempty"`
+	PagesWritten int      `json:"pages_written,omitempty"`
+	BytesPerPage int64    `json:"bytes_per_page,omitempty"`
+	BloatRatio   float64  `json:"bloat_ratio,omitempty"`
+	File         string   `json:"file"`
+	Path         string   `json:"path"`
+	Links        []string `json:"links,omitempty"`
+	Estimated    bool     `json:"estimated,omitempty"`
+	LogicalStart string   `json:"logical_start_page,omitempty"`
+	LogicalEnd   string   `json:"logical_end_page,omitempty"`
+	TOCSource    string   `json:"toc_source,omitempty"`
+	TOCSourceID  string   `json:"toc_source_id,omitempty"`
+	TextFile     string   `json:"text_file,omitempty"`
+	ImagesDir    string   `json:"images_dir,omitempty"`
+	Unsupported  []string `json:"unsupported_features,omitempty"`
+	Warnings     []string `json:"warnings,omitempty"`
+	Confidence   float64  `json:"confidence"`
+	Verified     bool     `json:"verified"`
+	Status       string   `json:"status"`
+	Error        string   `json:"error,omitempty"`
+	Validation   []string `json:"validation,omitempty"`
+	DurationMS   int64    `json:"duration_ms"`
+	Version      string   `json:"version"`
+	RunID        string   `json:"run_id"`
+}
+
+var (
+	// manifestEntries collects the written chapters of all processed documents and subtrees in export order.
+	manifestEntries = []manifestEntry{}
+	// manifestSkipped is set by checkManifest if --skip-existing keeps an existing manifest.
+	manifestSkipped bool
+)
+
+// manifestPath returns the path --manifest is written to: relative paths are taken within the output directory.
+func manifestPath() string {
+	if filepath.IsAbs(manifestFile) {
+		return manifestFile
+	}
+	return filepath.Join(outputDir, manifestFile)
+}
+
+// checkManifestFormat validates the extension of --manifest.
+func checkManifestFormat() error {
+	switch strings.ToLower(filepath.Ext(manifestFile)) {
+	case manifestJSON, manifestCSV:
+		return nil
+	}
+	return fmt.Errorf("invalid --manifest '%s': the file name must end in %s or %s", manifestFile, manifestJSON, manifestCSV)
+}
+
+// addToManifest records a chapter, the file it was written to and whether that file was verified.
+func addToManifest(cpt chapter, path string, verified bool) {
+	if manifestFile == "" {
+		return
+	}
+	manifestEntries = append(manifestEntries, manifestEntry{
+		ID:           cpt.id,
+		Order:        cpt.order,
+		PageOrder:    cpt.pageOrder,
+		Title:        cpt.title,
+		StartPage:    cpt.startPage,
+		EndPage:      cpt.endPage,
+		Pages:        plannedPages(cpt),
+		PaddedPages:  paddedPages(cpt),
+		File:         manifestFilePath(path),
+		Path:         absolutePath(path),
+		Estimated:    cpt.estimated,
+		LogicalStart: logicalStart(cpt),
+		LogicalEnd:   logicalEnd(cpt),
+		TOCSource:    tocSource(),
+		TOCSourceID:  tocSourceID,
+		Unsupported:  sourceUnsupported,
+		Warnings:     slices.Clone(cpt.warnings),
+		Confidence:   documentConfidence,
+		Verified:     verified,
+		Status:       statusWritten,
+		Version:      versionString(),
+		RunID:        runID,
+	})
+}
+
+// addSkippedToManifest records a chapter that was not written, with the reason of its status.
+func addSkippedToManifest(cpt chapter, path, status string, reason error) {
+	addToManifest(cpt, path, false)
+	if entry := lastManifestEntry(); entry != nil {
+		entry.Status = status
+		if reason != nil {
+			entry.Error = reason.Error()
+		}
+	}
+}
+
+// lastManifestEntry returns the entry of the chapter added last, to record what is known only
+// after the chapter was added, or nil without --manifest.
+func lastManifestEntry() *manifestEntry {
+	if manifestFile == "" || len(manifestEntries) == 0 {
+		return nil
+	}
+	return &manifestEntries[len(manifestEntries)-1]
+}
+
+// addAssetsToManifest records the --extract assets of the chapter added last.
+func addAssetsToManifest(assets chapterAssets) {
+	entry := lastManifestEntry()
+	if entry == nil {
+		return
+	}
+	if assets.text != "" {
+		entry.TextFile = manifestFilePath(assets.text)
+	}
+	if assets.images != "" {
+		entry.ImagesDir = manifestFilePath(assets.images)
+	}
+}
+
+// manifestFilePath returns the path of an output relative to the output directory,
+// or as it is if the output is outside of it.
+func manifestFilePath(path string) string {
+	if rel, err := filepath.Rel(outputDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// absolutePath returns the absolute path of an output, or the path as it is if it cannot be resolved.
+func absolutePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// encodeManifest writes the manifest in the format of the --manifest extension.
+// JSON is an array of chapter objects, CSV has a header row with the same field names.
+func encodeManifest(w io.Writer) error {
+	if strings.ToLower(filepath.Ext(manifestFile)) == manifestJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(manifestEntries)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "order", "title", "start_page", "end_page", "pages", "padded_pages", "file", "estimated", "logical_start_page", "logical_end_page", "toc_source", "toc_source_id", "text_file", "images_dir", "confidence", "verified", "version", "run_id", "path", "unsupported_features", "duration_ms", "page_order", "links", "status", "error", "validation", "pages_written", "warnings", "bytes_per_page", "bloat_ratio"})
+	for _, e := range manifestEntries {
+		cw.Write([]string{e.ID, strconv.Itoa(int(e.Order)), e.Title, strconv.Itoa(int(e.StartPage)),
+			strconv.Itoa(int(e.EndPage)), strconv.Itoa(e.Pages), strconv.Itoa(e.PaddedPages), e.File, strconv.FormatBool(e.Estimated), e.LogicalStart, e.LogicalEnd, e.TOCSource, e.TOCSourceID, e.TextFile, e.ImagesDir, strconv.FormatFloat(e.Confidence, 'f', 2, 64), strconv.FormatBool(e.Verified), e.Version, e.RunID, e.Path, strings.Join(e.Unsupported, ","), strconv.FormatInt(e.DurationMS, 10), strconv.Itoa(int(e.PageOrder)), strings.Join(e.Links, "|"), e.Status, e.Error, strings.Join(e.Validation, "\n"), strconv.Itoa(e.PagesWritten), strings.Join(e.Warnings, ","), strconv.FormatInt(e.BytesPerPage, 10), strconv.FormatFloat(e.BloatRatio, 'f', 2, 64)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeManifest writes the manifest after a successful export. It is kept or refused like the chapter
+// files if it already exists; that is checked by checkManifest before the export starts.
+func writeManifest() error {
+	path := manifestPath()
+	if manifestSkipped {
+		printMsg("skipped_existing", manifestFile, path)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("fail to create output directory: %w", err)
+	}
+	outputFile, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed to create manifest '%s': %w", path, err)
+	}
+	if err = encodeManifest(outputFile); err != nil {
+		outputFile.discard()
+		return fmt.Errorf("failed to write manifest '%s': %w", path, err)
+	}
+	if err = outputFile.commit(); err != nil {
+		return fmt.Errorf("failed to write manifest '%s': %w", path, err)
+	}
+	printMsg("manifest_written", len(manifestEntries), path)
+	return nil
+}
+
+// mergesManifests reports whether a batch writes --manifest itself from the manifests of its runs,
+// which it does for an absolute path that every run would otherwise overwrite. A relative path is
+// taken within the output directory of each document, and --dry-run prints the manifests instead.
+func mergesManifests() bool {
+	return manifestFile != "" && filepath.IsAbs(manifestFile) && dryRun == ""
+}
+
+// batchManifestPath returns the path the run of the ith document of a batch writes its manifest to.
+func batchManifestPath(dir string, i int) string {
+	return filepath.Join(dir, fmt.Sprintf("%d%s", i+1, manifestJSON))
+}
+
+// writeBatchManifest writes --manifest for a batch from the manifests its runs wrote into dir, in
+// the order of the inputs and of their chapters, with the paths of the outputs taken relative to
+// the output directory of the batch. A document that failed before its manifest was written has
+// no records.
+// Parameters:
+//   - dir: directory of the manifests of the runs
+//   - inputs: the documents of the batch
+//
+// Returns:
+//   - error: if a manifest of a run cannot be read or the manifest cannot be written
+func writeBatchManifest(dir string, inputs []batchInput) error {
+	for i, input := range inputs {
+		data, err := os.ReadFile(batchManifestPath(dir, i))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		var entries []manifestEntry
+		if err == nil {
+			err = json.Unmarshal(data, &entries)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read manifest of '%s': %w", input.path, err)
+		}
+		for _, e := range entries {
+			e.File = batchManifestFile(input.dir, e.File)
+			e.TextFile = batchManifestFile(input.dir, e.TextFile)
+			e.ImagesDir = batchManifestFile(input.dir, e.ImagesDir)
+			manifestEntries = append(manifestEntries, e)
+		}
+	}
+	return writeManifest()
+}
+
+// batchManifestFile returns a path of the manifest of a run, relative to the output directory of
+// its document, relative to the output directory of the batch.
+func batchManifestFile(documentDir, file string) string {
+	if file == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return manifestFilePath(filepath.Join(documentDir, filepath.FromSlash(file)))
+}
+
+// checkManifest keeps or refuses an existing manifest before anything is written,
+// so that a refused manifest does not end the run after all chapters were exported.
+func checkManifest() error {
+	skip, err := checkExistingOutputs([]string{manifestPath()})
+	if err != nil {
+		return err
+	}
+	manifestSkipped = skip[0]
+	return nil
+}
+
+// printManifest prints the manifest of a --dry-run to stdout in place of the plan, built from the
+// planned files. Problems of the plan are listed on the message output and end the run with exitPlanProblems.
+func printManifest() error {
+	for _, f := range plan.Files {
+		manifestEntries = append(manifestEntries, manifestEntry{
+			ID:           f.ID,
+			Order:        f.Order,
+			PageOrder:    f.PageOrder,
+			Title:        f.Title,
+			StartPage:    f.StartPage,
+			EndPage:      f.EndPage,
+			Pages:        f.Pages,
+			PaddedPages:  f.PaddedPages,
+			File:         manifestFilePath(f.Target),
+			Path:         absolutePath(f.Target),
+			Estimated:    f.Estimated,
+			LogicalStart: f.LogicalStart,
+			LogicalEnd:   f.LogicalEnd,
+			TOCSource:    tocSource(),
+			TOCSourceID:  tocSourceID,
+			Unsupported:  sourceUnsupported,
+			Confidence:   plan.Confidence,
+			Status:       statusPlanned,
+			Version:      versionString(),
+			RunID:        runID,
+		})
+	}
+	if err := encodeManifest(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	for _, problem := range plan.Problems {
+		errorMsg("plan_problem", problem)
+	}
+	if len(plan.Problems) > 0 {
+		return exitWith(exitPlanProblems, nil)
+	}
+	return nil
+}
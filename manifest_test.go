@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// readManifest returns the records of a JSON or CSV manifest as maps of field name to value.
+func readManifest(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []map[string]any
+	if filepath.Ext(path) == manifestJSON {
+		if err = json.Unmarshal(data, &records); err != nil {
+			t.Fatal(err)
+		}
+		return records
+	}
+	rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows[1:] {
+		record := make(map[string]any, len(row))
+		for i, field := range rows[0] {
+			record[field] = row[i]
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// TestManifestVersion splits the book fixture with a JSON and a CSV manifest, whose records must
+// all name the build and run that wrote them and the time each chapter took, and with --provenance, whose outputs name the build
+// as Producer.
+func TestManifestVersion(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := versionString()
+	for _, manifest := range []string{"toc.json", "toc.csv"} {
+		out := filepath.Join(dir, strings.TrimPrefix(filepath.Ext(manifest), "."))
+		if output, err := runCommand(t, dir, "-i", source, "-o", out, "--manifest", manifest,
+			"--provenance", "--run-id", "pipeline-7", "--sidecar-suffix=", "--bloat-factor=0"); err != nil {
+			t.Fatalf("--manifest %s: %v\n%s", manifest, err, output)
+		}
+		records := readManifest(t, filepath.Join(out, manifest))
+		if len(records) != 2 {
+			t.Fatalf("%s: got %d records, want 2", manifest, len(records))
+		}
+		for _, record := range records {
+			if record["version"] != want {
+				t.Errorf("%s: %v has version %v, want %q", manifest, record["file"], record["version"], want)
+			}
+			if record["run_id"] != "pipeline-7" {
+				t.Errorf("%s: %v has run_id %v, want pipeline-7", manifest, record["file"], record["run_id"])
+			}
+			if ms, err := strconv.ParseFloat(fmt.Sprint(record["duration_ms"]), 64); err != nil || ms < 0 {
+				t.Errorf("%s: %v has duration_ms %v, want a number of milliseconds", manifest, record["file"], record["duration_ms"])
+			}
+		}
+	}
+
+	ctx, err := api.ReadContextFile(filepath.Join(dir, "json", "01_Part One.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if producer := ctx.Producer; !strings.HasSuffix(producer, "; pdf-split "+want) {
+		t.Errorf("got Producer %q, want it to name pdf-split %s", producer, want)
+	}
+}
+
+// TestManifestUnsupported splits a tagged copy of the book fixture with a JSON and a CSV manifest,
+// whose records must all list the tagged structure as a feature the file does not preserve.
+func TestManifestUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	source := writeTaggedCopy(t, filepath.Join("testdata", "book.pdf"), filepath.Join(dir, "tagged.pdf"))
+	for manifest, want := range map[string]any{"toc.json": []any{featureTagged}, "toc.csv": featureTagged} {
+		out := filepath.Join(dir, strings.TrimPrefix(filepath.Ext(manifest), "."))
+		if output, err := runCommand(t, dir, "-i", source, "-o", out, "--manifest", manifest,
+			"--allow-untagged-output", "--sidecar-suffix=", "--bloat-factor=0"); err != nil {
+			t.Fatalf("--manifest %s: %v\n%s", manifest, err, output)
+		}
+		for _, record := range readManifest(t, filepath.Join(out, manifest)) {
+			if !reflect.DeepEqual(record["unsupported_features"], want) {
+				t.Errorf("%s: %v has unsupported_features %v, want %v", manifest, record["file"], record["unsupported_features"], want)
+			}
+		}
+	}
+}
+
+// TestManifestPageOrder splits the layers fixture in title order with a JSON and a CSV manifest,
+// whose records must be in export order and keep the page order of every chapter.
+func TestManifestPageOrder(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "layers.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]string{{"First Floor", "2"}, {"Ground Floor", "1"}, {"Notes", "3"}}
+	for _, manifest := range []string{"toc.json", "toc.csv"} {
+		out := filepath.Join(dir, strings.TrimPrefix(filepath.Ext(manifest), "."))
+		if output, err := runCommand(t, dir, "-i", source, "-o", out, "--manifest", manifest,
+			"--order-by", "title", "--sidecar-suffix=", "--bloat-factor=0"); err != nil {
+			t.Fatalf("--manifest %s: %v\n%s", manifest, err, output)
+		}
+		var got [][2]string
+		for _, record := range readManifest(t, filepath.Join(out, manifest)) {
+			got = append(got, [2]string{record["title"].(string), fmt.Sprint(record["page_order"])})
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got titles and page orders %q, want %q", manifest, got, want)
+		}
+	}
+}
+
+// TestManifestTimeout splits the book fixture with a timeout no chapter can meet: the run must
+// fail with the exit code of timeouts and still write a manifest recording both chapters as
+// timed out.
+func TestManifestTimeout(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := runCommand(t, dir, "-i", source, "-o", "out", "--manifest", "toc.json",
+		"--chapter-timeout", "1ns", "--sidecar-suffix=", "--bloat-factor=0")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitChapterTimeout {
+		t.Fatalf("got %v, want exit code %d\n%s", err, exitChapterTimeout, output)
+	}
+	records := readManifest(t, filepath.Join(dir, "out", "toc.json"))
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	for _, record := range records {
+		if record["status"] != statusTimeout || record["error"] != "chapter export timed out after 1ns" {
+			t.Errorf("%v: got status %v with error %v, want a timeout", record["file"], record["status"], record["error"])
+		}
+	}
+}
+
+// TestManifestWarnings splits a document whose chapters share a title with a JSON and a CSV
+// manifest: only the records of the renamed chapters must list the code of the name collision.
+func TestManifestWarnings(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "repeated.pdf")
+	if err := writeSampleDocument(source, 6, []pdfcpu.Bookmark{
+		{Title: "Notes", PageFrom: 1},
+		{Title: "Notes", PageFrom: 3},
+		{Title: "Notes", PageFrom: 5},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	code := warningCodes["name_collision"]
+	for manifest, want := range map[string][]any{
+		"toc.json": {nil, []any{code}, []any{code}},
+		"toc.csv":  {"", code, code},
+	} {
+		out := filepath.Join(dir, strings.TrimPrefix(filepath.Ext(manifest), "."))
+		if output, err := runCommand(t, dir, "-i", source, "-o", out, "--manifest", manifest,
+			"--name-template", "{title}", "--sidecar-suffix=", "--bloat-factor=0"); err != nil {
+			t.Fatalf("--manifest %s: %v\n%s", manifest, err, output)
+		}
+		var got []any
+		for _, record := range readManifest(t, filepath.Join(out, manifest)) {
+			got = append(got, record["warnings"])
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got warnings %v, want %v", manifest, got, want)
+		}
+	}
+}
+
+// TestBatchManifest splits two documents into one absolute manifest, which the batch must write
+// once with the records of both in input order and their files relative to its output directory,
+// and into an --also-link view, which must get a subdirectory per document and be listed as the
+// links of every record.
+func TestBatchManifest(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := filepath.Join(dir, "inputs")
+	if err = os.Mkdir(inputs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"b.pdf", "a.pdf"} {
+		if err = os.WriteFile(filepath.Join(inputs, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := filepath.Join(dir, "toc.json")
+	if output, err := runCommand(t, dir, "-i", inputs, "-o", "out", "--manifest", manifest,
+		"--also-link", "view", "--sidecar-suffix=", "--bloat-factor=0"); err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+
+	var files []string
+	for _, record := range readManifest(t, manifest) {
+		files = append(files, record["file"].(string))
+	}
+	want := []string{"a/01_Part One.pdf", "a/02_Part Two.pdf", "b/01_Part One.pdf", "b/02_Part Two.pdf"}
+	if strings.Join(files, "|") != strings.Join(want, "|") {
+		t.Errorf("got files %q, want %q", files, want)
+	}
+	for _, document := range []string{"a", "b"} {
+		if _, err := os.Stat(filepath.Join(dir, "out", document, "toc.json")); !os.IsNotExist(err) {
+			t.Errorf("document %s wrote a manifest of its own: %v", document, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "view", document, "Part One.pdf")); err != nil {
+			t.Errorf("document %s: %v", document, err)
+		}
+	}
+	for _, record := range readManifest(t, manifest) {
+		document, name, _ := strings.Cut(record["file"].(string), "/")
+		link := filepath.Join(dir, "view", document, strings.TrimLeft(name, "0123456789_"))
+		if want := []any{link}; !reflect.DeepEqual(record["links"], want) {
+			t.Errorf("%s: got links %v, want %v", record["file"], record["links"], want)
+		}
+	}
+}
+
+// TestComparePrevious plans the book fixture against hand-written manifests of an earlier run:
+// one that lacks Part One, has Part Two at half its size and an appendix that is gone, which
+// must be reported in the JSON report and fail with --fail-on-structure-change, and one within
+// --resize-threshold, which must pass.
+func TestComparePrevious(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePrevious := func(name string, entries []manifestEntry) string {
+		data, err := json.Marshal(entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	changed := writePrevious("changed.json", []manifestEntry{{Title: "Part Two", Pages: 4}, {Title: "Appendix", Pages: 3}})
+	report := filepath.Join(dir, "report.json")
+	output, err := runCommand(t, dir, "-i", source, "--dry-run", "--compare-previous", changed,
+		"--compare-report", report, "--fail-on-structure-change", "--sidecar-suffix=")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitStructureChanged {
+		t.Fatalf("got %v, want exit code %d\n%s", err, exitStructureChanged, output)
+	}
+	if !strings.Contains(output, "resized since the previous run: Part Two, 4 pages before, 8 now") {
+		t.Errorf("the resized chapter is not listed\n%s", output)
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got structureComparison
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := structureComparison{
+		Previous: changed,
+		Added:    []chapterChange{{Title: "Part One", Pages: 8}},
+		Removed:  []chapterChange{{Title: "Appendix", PreviousPages: 3}},
+		Resized:  []chapterChange{{Title: "Part Two", PreviousPages: 4, Pages: 8}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got report %+v, want %+v", got, want)
+	}
+
+	similar := writePrevious("similar.json", []manifestEntry{{Title: "Part One", Pages: 8}, {Title: "Part Two", Pages: 7}})
+	output, err = runCommand(t, dir, "-i", source, "--dry-run", "--compare-previous", similar,
+		"--fail-on-structure-change", "--sidecar-suffix=")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	if !strings.Contains(output, "0 added, 0 removed, 0 resized, 2 unchanged") {
+		t.Errorf("the unchanged structure is not reported\n%s", output)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// defaultLang is the language used when no translation is selected or a message is missing.
+const defaultLang = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// messages maps message keys to format strings of the selected language.
+// It starts out as the English catalog so that output works before setLanguage is called.
+var messages = loadCatalog(defaultLang)
+
+// messageOutput receives all progress messages and warnings. They go to stderr,
+// so that stdout carries only the results: the --dry-run plan or the printed manifest.
+var messageOutput io.Writer = clearingWriter{os.Stderr}
+
+// fallbackMessages is the English catalog used for keys missing in a translation.
+var fallbackMessages = messages
+
+// loadCatalog reads the embedded catalog of a language.
+// Returns nil if there is no catalog for the language.
+func loadCatalog(lang string) map[string]string {
+	data, err := localeFiles.ReadFile("locales/" + lang + ".json")
+	if err != nil {
+		return nil
+	}
+	var catalog map[string]string
+	if err = json.Unmarshal(data, &catalog); err != nil {
+		panic(fmt.Sprintf("invalid message catalog '%s': %v", lang, err))
+	}
+	return catalog
+}
+
+// setLanguage selects the catalog for user-facing messages.
+// The language is taken from --lang or else from the LC_ALL, LC_MESSAGES and LANG environment
+// variables, in that order. Unknown languages fall back to English.
+// Parameters:
+//   - lang: value of the --lang flag, may be empty
+//
+// Returns:
+//   - error: if lang was given explicitly but no translation exists for it
+func setLanguage(lang string) error {
+	explicit := lang != ""
+	if !explicit {
+		for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+			if lang = os.Getenv(name); lang != "" {
+				break
+			}
+		}
+	}
+	for _, candidate := range languageCandidates(lang) {
+		if catalog := loadCatalog(candidate); catalog != nil {
+			messages = catalog
+			return nil
+		}
+	}
+	messages = fallbackMessages
+	if explicit {
+		return fmt.Errorf("unsupported language '%s'", lang)
+	}
+	return nil
+}
+
+// languageCandidates converts a locale such as "zh_CN.UTF-8" or "de-AT" into catalog names
+// to try, most specific first: "zh-CN", "zh".
+func languageCandidates(locale string) []string {
+	// Strip the encoding and modifier, e.g. ".UTF-8" or "@euro"
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return nil
+	}
+	lang, region, _ := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-")
+	lang = strings.ToLower(lang)
+	if region == "" {
+		return []string{lang}
+	}
+	return []string{lang + "-" + strings.ToUpper(region), lang}
+}
+
+// msg returns the user-facing message for key in the selected language, formatted with args.
+// Missing translations fall back to English, and unknown keys are returned as is.
+func msg(key string, args ...any) string {
+	format, ok := messages[key]
+	if !ok {
+		if format, ok = fallbackMessages[key]; !ok {
+			format = key
+		}
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// printMsg prints the user-facing message for key on its own line, unless --quiet is set.
+func printMsg(key string, args ...any) {
+	if quiet {
+		return
+	}
+	fmt.Fprintln(messageOutput, msg(key, args...))
+}
+
+// errorMsg prints a message reporting a failure on its own line; unlike printMsg it is shown with --quiet.
+func errorMsg(key string, args ...any) {
+	fmt.Fprintln(messageOutput, msg(key, args...))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/souhup/pdf-spliter/ranges"
+)
+
+// Positions of the --stamp-header line.
+const (
+	stampHeaderTop    = "top"
+	stampHeaderBottom = "bottom"
+)
+
+// pageHeaderText is the line --stamp-header prints: the chapter title and the page number in the source.
+const pageHeaderText = "%s · p. %d"
+
+// pageHeaderStamp positions the header line centered at the top or bottom edge of the page.
+const pageHeaderStamp = "font:Helvetica, points:%d, pos:%s, off:0 %d, scale:1 abs, rot:0, fillc:#404040"
+
+// retitleSeparator joins the title of the source and of the chapter in the Title of an output.
+const retitleSeparator = " — "
+
+// documentInfo is the descriptive part of the document information dictionary of the source,
+// copied into every output; pdfcpu starts the outputs with a dictionary of its own.
+type documentInfo struct {
+	title    string
+	author   string
+	subject  string
+	keywords string
+}
+
+// readDocumentInfo returns the descriptive entries of the source's document information
+// dictionary. A source without one, or with unreadable entries, has empty entries.
+func readDocumentInfo(inputFile *os.File) documentInfo {
+	var di documentInfo
+	_ = inspectSource(inputFile, func(ctx *model.Context) error {
+		if ctx.Info == nil {
+			return nil
+		}
+		info, err := ctx.DereferenceDict(*ctx.Info)
+		if err != nil || info == nil {
+			return nil
+		}
+		text := func(key string) string {
+			obj, err := ctx.Dereference(info[key])
+			if err != nil || obj == nil {
+				return ""
+			}
+			s, err := model.Text(obj)
+			if err != nil {
+				return ""
+			}
+			return strings.TrimSpace(s)
+		}
+		di = documentInfo{title: text("Title"), author: text("Author"), subject: text("Subject"), keywords: text("Keywords")}
+		return nil
+	})
+	return di
+}
+
+// properties returns the entries to set in an output, leaving out the empty ones.
+// The Title names the chapter after the title of the source, unless title is empty or
+// --no-retitle keeps the title of the source.
+func (d documentInfo) properties(title string) map[string]string {
+	if title != "" && !noRetitle {
+		if d.title != "" {
+			title = d.title + retitleSeparator + title
+		}
+	} else {
+		title = d.title
+	}
+	props := map[string]string{}
+	for key, value := range map[string]string{"Title": title, "Author": d.author, "Subject": d.subject, "Keywords": d.keywords} {
+		if value != "" {
+			props[key] = value
+		}
+	}
+	return props
+}
+
+// setDocumentInfo sets entries of the document information dictionary of a trimmed chapter.
+func setDocumentInfo(ctx *model.Context, props map[string]string) error {
+	if len(props) == 0 {
+		return nil
+	}
+	return pdfcpu.PropertiesAdd(ctx, props)
+}
+
+// pageHeader is the line --stamp-header prints on every page of a chapter.
+type pageHeader struct {
+	title    string
+	position string
+	size     int
+}
+
+// stampPageHeaders prints the header line on every page of a trimmed chapter, each with the
+// number of the page in the source. Pages added after trimming, such as padding, get none.
+// Parameters:
+//   - ctx: the trimmed chapter
+//   - h: title, position and font size of the line
+//   - pageRange: pdfcpu page selection of the chapter, ranges like "4-9,15-20" in page order
+//
+// Returns:
+//   - error: if the page range cannot be read or the pages cannot be stamped
+func stampPageHeaders(ctx *model.Context, h *pageHeader, pageRange string) error {
+	spans, err := ranges.Parse(pageRange)
+	if err != nil {
+		return fmt.Errorf("page range '%s': %w", pageRange, err)
+	}
+	var sourcePages []int
+	for _, span := range spans {
+		for page := span.From; page <= span.End(span.From); page += span.Step {
+			sourcePages = append(sourcePages, int(page))
+		}
+	}
+
+	pos, off := "tc", -10
+	if h.position == stampHeaderBottom {
+		pos, off = "bc", 10
+	}
+	description := fmt.Sprintf(pageHeaderStamp, h.size, pos, off)
+	watermarks := map[int]*model.Watermark{}
+	for i, page := range sourcePages {
+		if i >= ctx.PageCount {
+			break
+		}
+		wm, err := api.TextWatermark(fmt.Sprintf(pageHeaderText, h.title, page), description, true, false, types.POINTS)
+		if err != nil {
+			return err
+		}
+		watermarks[i+1] = wm
+	}
+	return pdfcpu.AddWatermarksMap(ctx, watermarks)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// defaultNameTemplate reproduces the classic "01_Title.pdf" chapter filenames.
+const defaultNameTemplate = splitter.DefaultNameTemplate
+
+// nameTemplateParsed is the parsed --name-template used by chapterFileStem.
+var nameTemplateParsed, _ = splitter.ParseNameTemplate(defaultNameTemplate)
+
+// barcodeNames is set with --split-on-barcode and no --name-template: the documents started at
+// barcode separator pages are named by the barcode value alone, with titleNameTemplate. Chapters
+// of the outline, used when no separator page is found, keep the default names.
+var barcodeNames bool
+
+// titleTemplateParsed is the parsed titleNameTemplate.
+var titleTemplateParsed, _ = splitter.ParseNameTemplate(titleNameTemplate)
+
+// parseNameTemplate parses a --name-template with the placeholders of splitter.ParseNameTemplate.
+func parseNameTemplate(template string) (splitter.NameTemplate, error) {
+	parsed, err := splitter.ParseNameTemplate(template)
+	if err != nil {
+		return parsed, fmt.Errorf("invalid --name-template: %w", err)
+	}
+	return parsed, nil
+}
+
+// chapterFileStem returns the filename of a chapter without the .pdf extension,
+// rendered from --name-template and sanitized.
+// Parameters:
+//   - cpt: the chapter
+//   - inputName: path of the source document, used for {source}
+func chapterFileStem(cpt chapter, inputName string) string {
+	template := nameTemplateParsed
+	if barcodeNames && cpt.detectedBy == detectionBarcode {
+		template = titleTemplateParsed
+	}
+	return sanitizeFilename(template.Render(splitter.NameFields{
+		Order:        int(cpt.order),
+		Title:        cpt.title,
+		StartPage:    int(cpt.startPage),
+		EndPage:      int(cpt.endPage),
+		Source:       strings.TrimSuffix(filepath.Base(inputName), filepath.Ext(inputName)),
+		LogicalStart: logicalPage(cpt.startPage),
+		LogicalEnd:   logicalPage(cpt.endPage),
+	}))
+}
+
+// assignFileNames sets the file name of every chapter of an export, rendered by chapterFileStem.
+// A name already taken by an earlier chapter in the same directory, also in a different case,
+// gets the suffix _2, _3 and so on, shortened to fit like any other name. The directories are
+// compared as resolved against --output, so the output_dir of a --plan output is checked against
+// all other destinations, however it is spelled. The names are assigned to all planned chapters
+// before --chapters, --match and --sample select some, so a chapter keeps its file name whatever
+// is selected.
+// Parameters:
+//   - chapters: list of chapter information in export order, updated in place
+//   - inputName: path of the source document
+//
+// Returns:
+//   - error: if --name-template renders a chapter to an empty file name
+func assignFileNames(chapters []chapter, inputName string) error {
+	used := make(map[string]map[string]bool)
+	for i := range chapters {
+		cpt := &chapters[i]
+		stem := chapterFileStem(*cpt, inputName)
+		if strings.TrimSpace(stem) == "" {
+			return fmt.Errorf("--name-template renders chapter '%s' to an empty file name", cpt.title)
+		}
+		dir := strings.ToLower(filepath.Clean(chapterDir(*cpt, outputDir)))
+		if used[dir] == nil {
+			used[dir] = make(map[string]bool)
+		}
+		name := outputFS.Unique(stem, used[dir])
+		if name != stem {
+			cpt.warn("name_collision", cpt.title, stem+".pdf", name+".pdf")
+		}
+		cpt.file = name
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+)
+
+// lossyNameThreshold is the fraction of changed characters above which
+// sanitizing a title into a filename is considered lossy.
+const lossyNameThreshold = 0.3
+
+// checkLossyNames warns about chapters whose title lost much of its content during sanitization.
+// With --fail-on-lossy-names such chapters are fatal, before any file has been written.
+// Parameters:
+//   - chapters: list of chapter information
+//
+// Returns:
+//   - error: with --fail-on-lossy-names, if any title is stored lossily
+func checkLossyNames(chapters []chapter) error {
+	var lossy int
+	for i := range chapters {
+		cpt := &chapters[i]
+		sanitized := sanitizeFilename(cpt.title)
+		ratio := changedRatio(cpt.title, sanitized)
+		if ratio <= lossyNameThreshold {
+			continue
+		}
+		lossy++
+		cpt.warn("lossy_name", ratio*100, cpt.title, sanitized)
+	}
+	if lossy > 0 && failOnLossyNames {
+		return fmt.Errorf("%d chapter title(s) would be stored with lossy filenames", lossy)
+	}
+	return nil
+}
+
+// changedRatio returns the edit distance between two strings relative to the longer one,
+// counted in runes: 0 means identical, 1 means nothing in common.
+func changedRatio(original, sanitized string) float64 {
+	a, b := []rune(original), []rune(sanitized)
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 0
+	}
+	return float64(editDistance(a, b)) / float64(longest)
+}
+
+// editDistance computes the Levenshtein distance between two rune slices.
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// namingCase is a document of one-page chapters with the given titles, and the file names of
+// its chapters when split with the options of the case. The cases in testdata/naming.json are
+// shared with the tests of the splitter package; every name in them is a file name users get.
+type namingCase struct {
+	Name          string   `json:"name"`
+	Titles        []string `json:"titles"`
+	NameTemplate  string   `json:"name_template,omitempty"`
+	TargetFS      string   `json:"target_fs,omitempty"`
+	MaxNameLength int      `json:"max_name_length,omitempty"`
+	Files         []string `json:"files"`
+}
+
+// loadNamingCases reads the naming cases of testdata/naming.json.
+func loadNamingCases(t *testing.T) []namingCase {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "naming.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Cases []namingCase `json:"cases"`
+	}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc.Cases
+}
+
+// TestNamingCases splits the document of every naming case with the command in a temporary
+// directory, as a user would, and compares the written files with the expected names.
+func TestNamingCases(t *testing.T) {
+	for _, tc := range loadNamingCases(t) {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			outline := make([]pdfcpu.Bookmark, len(tc.Titles))
+			for i, title := range tc.Titles {
+				outline[i] = pdfcpu.Bookmark{Title: title, PageFrom: i + 1}
+			}
+			if err := writeSampleDocument(filepath.Join(dir, "naming.pdf"), len(tc.Titles), outline); err != nil {
+				t.Fatal(err)
+			}
+
+			targetFS := tc.TargetFS
+			if targetFS == "" {
+				targetFS = targetFSPOSIX
+			}
+			args := []string{"-i", "naming.pdf", "-o", "out", "--sidecar-suffix=", "--bloat-factor=0", "--no-verify",
+				"--target-fs", targetFS, "--max-name-length", strconv.Itoa(tc.MaxNameLength)}
+			if tc.NameTemplate != "" {
+				args = append(args, "--name-template", tc.NameTemplate)
+			}
+			if output, err := runCommand(t, dir, args...); err != nil {
+				t.Fatalf("%v\n%s", err, output)
+			}
+
+			entries, err := os.ReadDir(filepath.Join(dir, "out"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+			if want := slices.Sorted(slices.Values(tc.Files)); !slices.Equal(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Supported values of the --order-by flag.
+const (
+	orderByPage    = "page"
+	orderByOutline = "outline"
+	orderByTitle   = "title"
+)
+
+// orderChapters sorts chapters into export order and renumbers them from 1.
+// The number drives the filename prefix and the position in combined output.
+// Front matter found by --detect-headings always comes first as number 0, so the chapters keep their numbers.
+// Every chapter also keeps its position in page order, so either order can be reconstructed.
+// Parameters:
+//   - chapters: chapters in outline order
+//   - by: one of orderByPage, orderByOutline or orderByTitle
+//
+// Returns:
+//   - []chapter: the chapters in export order
+func orderChapters(chapters []chapter, by string) []chapter {
+	first := 1
+	if len(chapters) > 0 && chapters[0].frontMatter {
+		first = 0
+	}
+
+	// Record the reading sequence first
+	byPage := make([]int, len(chapters))
+	for i := range byPage {
+		byPage[i] = i
+	}
+	sort.SliceStable(byPage, func(a, b int) bool {
+		return chapters[byPage[a]].startPage < chapters[byPage[b]].startPage
+	})
+	for position, i := range byPage {
+		chapters[i].pageOrder = uint32(position + first)
+	}
+
+	// Sort into export order; ties keep the reading sequence
+	ordered := append([]chapter(nil), chapters...)
+	switch by {
+	case orderByPage:
+		sort.SliceStable(ordered, func(a, b int) bool { return ordered[a].pageOrder < ordered[b].pageOrder })
+	case orderByTitle:
+		sort.SliceStable(ordered, func(a, b int) bool {
+			if ordered[a].frontMatter != ordered[b].frontMatter {
+				return ordered[a].frontMatter
+			}
+			ta, tb := strings.ToLower(ordered[a].title), strings.ToLower(ordered[b].title)
+			if ta != tb {
+				return ta < tb
+			}
+			return ordered[a].pageOrder < ordered[b].pageOrder
+		})
+	}
+
+	for i := range ordered {
+		ordered[i].order = uint32(i + first)
+		if ordered[i].order != ordered[i].pageOrder {
+			ordered[i].explain("explain_order", ordered[i].order, by, ordered[i].pageOrder)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Policies of --orphan-pages for the pages no planned chapter covers.
+const (
+	orphanIgnore         = "ignore"
+	orphanCollect        = "collect"
+	orphanAttachPrevious = "attach-previous"
+)
+
+// orphanTitle is the title of the chapter --orphan-pages collect gathers the orphan pages in.
+const orphanTitle = "orphan_pages"
+
+// orphanRuns returns the runs of consecutive pages of the source, up to --truncate-at-page, that
+// none of the chapters covers.
+func orphanRuns(inputFile *os.File, chapters []chapter) ([]pageRange, error) {
+	pageCount, err := sourcePageCount(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	if truncateAtPage > 0 && truncateAtPage < pageCount {
+		pageCount = truncateAtPage
+	}
+	var spans []pageRange
+	for _, cpt := range chapters {
+		spans = append(spans, cpt.pageSpans()...)
+	}
+	return subtractRanges([]pageRange{{1, uint32(pageCount)}}, unionRanges(spans)), nil
+}
+
+// applyOrphanPages handles the pages no planned chapter covers as --orphan-pages asks and
+// reports every run of them and what became of it.
+// With collect, the runs form one more chapter numbered after the last one. With
+// attach-previous, every run joins the chapter ending right before it; a run at the start of
+// the document joins the chapter following it.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//   - chapters: the planned chapters, numbered
+//
+// Returns:
+//   - []chapter: the chapters with the orphan pages attached or collected
+//   - error: if the page count of the source cannot be read
+func applyOrphanPages(inputFile *os.File, chapters []chapter) ([]chapter, error) {
+	runs, err := orphanRuns(inputFile, chapters)
+	if err != nil || len(runs) == 0 {
+		return chapters, err
+	}
+	switch orphanPages {
+	case orphanCollect:
+		orphans := chapter{title: orphanTitle, startPage: runs[0].start, endPage: runs[len(runs)-1].end}
+		if len(runs) > 1 {
+			orphans.ranges = runs
+		}
+		for _, cpt := range chapters {
+			orphans.order = max(orphans.order, cpt.order)
+			orphans.pageOrder = max(orphans.pageOrder, cpt.pageOrder)
+		}
+		orphans.order++
+		orphans.pageOrder++
+		orphans.explain("explain_orphans_collected", formatPageRuns(runs))
+		printMsg("orphans_collected", formatPageRuns(runs), orphans.order, orphans.title)
+		return append(chapters, orphans), nil
+
+	case orphanAttachPrevious:
+		for _, run := range runs {
+			i := neighbourChapter(chapters, run)
+			if i < 0 {
+				printMsg("orphans_not_attached", formatPageRuns([]pageRange{run}))
+				continue
+			}
+			cpt := &chapters[i]
+			spans := unionRanges(append(cpt.pageSpans(), run))
+			cpt.startPage, cpt.endPage, cpt.ranges = spans[0].start, spans[len(spans)-1].end, nil
+			if len(spans) > 1 {
+				cpt.ranges = spans
+			}
+			cpt.explain("explain_orphans_attached", formatPageRuns([]pageRange{run}))
+			printMsg("orphans_attached", formatPageRuns([]pageRange{run}), cpt.order, cpt.title)
+		}
+		return chapters, nil
+	}
+
+	printMsg("orphans_ignored", formatPageRuns(runs))
+	return chapters, nil
+}
+
+// neighbourChapter returns the index of the last chapter covering the page before an orphan
+// run, or for a run at the start of the document the page after it, or -1 if there is none.
+func neighbourChapter(chapters []chapter, run pageRange) int {
+	page := run.start - 1
+	if run.start == 1 {
+		page = run.end + 1
+	}
+	found := -1
+	for i, cpt := range chapters {
+		for _, span := range cpt.pageSpans() {
+			if span.start <= page && page <= span.end {
+				found = i
+			}
+		}
+	}
+	return found
+}
+
+// formatPageRuns returns page ranges as text like "3, 7-9".
+func formatPageRuns(runs []pageRange) string {
+	parts := make([]string, len(runs))
+	for i, run := range runs {
+		if run.start == run.end {
+			parts[i] = strconv.Itoa(int(run.start))
+		} else {
+			parts[i] = fmt.Sprintf("%d-%d", run.start, run.end)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
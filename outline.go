@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// nearTopTolerance is the fraction of the page height, measured from the top edge,
+// within which a destination is considered to point at the top of the page.
+const nearTopTolerance = 0.15
+
+// destination describes the target of an outline item.
+// top is the vertical coordinate the destination scrolls to, valid only if hasTop is set.
+type destination struct {
+	page       int
+	top        float64
+	hasTop     bool
+	pageHeight float64
+}
+
+// nearTop reports whether the destination points at the top of its page.
+// Destinations without a vertical coordinate (e.g. /Fit) always show the whole page
+// and are therefore treated as pointing at the top.
+func (d destination) nearTop() bool {
+	if !d.hasTop || d.pageHeight <= 0 {
+		return true
+	}
+	return d.top >= d.pageHeight*(1-nearTopTolerance)
+}
+
+// destinationNode is a node of the outline tree holding the destination of one outline item.
+type destinationNode struct {
+	destination
+	kids []destinationNode
+}
+
+// outlineDestinations resolves the destinations of all outline items.
+// Items are skipped using the same rules as pdfcpu, so the result lines up index by index,
+// level by level, with the bookmark tree returned by api.Bookmarks.
+// Parameters:
+//   - ctx: pdfcpu context of the source document with the Dests name tree located
+//
+// Returns:
+//   - []destinationNode: destination tree of the top-level bookmarks
+//   - error: if the outline cannot be read
+func outlineDestinations(ctx *model.Context) ([]destinationNode, error) {
+	if ctx.Outlines == nil {
+		return nil, nil
+	}
+	dims, err := ctx.PageDims()
+	if err != nil {
+		return nil, err
+	}
+	return outlineItemDestinations(ctx, ctx.Outlines.IndirectRefEntry("First"), dims)
+}
+
+// outlineItemDestinations resolves the destinations of an outline item, its siblings and their kids.
+func outlineItemDestinations(ctx *model.Context, first *types.IndirectRef, dims []types.Dim) ([]destinationNode, error) {
+	var (
+		nodes []destinationNode
+		d     types.Dict
+		err   error
+	)
+	for ir := first; ir != nil; ir = d.IndirectRefEntry("Next") {
+		if d, err = ctx.DereferenceDict(*ir); err != nil {
+			return nil, err
+		}
+		arr, err := destinationArray(ctx, d)
+		if err != nil {
+			return nil, err
+		}
+		if len(arr) == 0 {
+			continue
+		}
+		pageRef, ok := arr[0].(types.IndirectRef)
+		if !ok {
+			continue
+		}
+		page, err := ctx.PageNumber(pageRef.ObjectNumber.Value())
+		if err != nil {
+			return nil, err
+		}
+
+		node := destinationNode{destination: destination{page: page}}
+		if page >= 1 && page <= len(dims) {
+			node.pageHeight = dims[page-1].Height
+		}
+		node.top, node.hasTop = destinationTop(ctx, arr)
+		if kids := d.IndirectRefEntry("First"); kids != nil {
+			if node.kids, err = outlineItemDestinations(ctx, kids, dims); err != nil {
+				return nil, err
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// destinationArray returns the explicit destination array of an outline item,
+// resolving named destinations and GoTo actions.
+// It returns nil if the item has no destination pdfcpu would recognize.
+func destinationArray(ctx *model.Context, item types.Dict) (types.Array, error) {
+	// The destination is given either directly or by a GoTo action
+	dest, found := item["Dest"]
+	if !found {
+		act, err := ctx.DereferenceDict(item["A"])
+		if err != nil || act == nil {
+			return nil, err
+		}
+		if s := act.NameEntry("S"); s == nil || *s != "GoTo" {
+			return nil, nil
+		}
+		dest = act["D"]
+	}
+
+	obj, err := ctx.Dereference(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Named destinations are looked up in the Dests name tree
+	switch obj := obj.(type) {
+	case types.Array:
+		return obj, nil
+	case types.Name:
+		return namedDestination(ctx, obj.Value())
+	case types.StringLiteral:
+		s, err := types.StringLiteralToString(obj)
+		if err != nil {
+			return nil, err
+		}
+		return namedDestination(ctx, s)
+	case types.HexLiteral:
+		s, err := types.HexLiteralToString(obj)
+		if err != nil {
+			return nil, err
+		}
+		return namedDestination(ctx, s)
+	}
+	return nil, nil
+}
+
+// namedDestination looks up a named destination in the Dests name tree, which must be located.
+// Documents without the name tree fail like names missing from it, instead of making pdfcpu panic.
+func namedDestination(ctx *model.Context, name string) (types.Array, error) {
+	if ctx.Names["Dests"] == nil {
+		return nil, fmt.Errorf("named destination '%s' not found: the document has no named destinations", name)
+	}
+	return ctx.DereferenceDestArray(name)
+}
+
+// destinationTop extracts the vertical coordinate from an explicit destination array.
+// Only /XYZ, /FitH, /FitBH and /FitR destinations carry one; a null value means "unchanged".
+func destinationTop(ctx *model.Context, arr types.Array) (float64, bool) {
+	if len(arr) < 2 {
+		return 0, false
+	}
+	fit, ok := arr[1].(types.Name)
+	if !ok {
+		return 0, false
+	}
+
+	// Position of the top coordinate within the array depends on the fit type
+	var idx int
+	switch fit.Value() {
+	case "XYZ":
+		idx = 3
+	case "FitH", "FitBH":
+		idx = 2
+	case "FitR":
+		idx = 5
+	default:
+		return 0, false
+	}
+	if idx >= len(arr) || arr[idx] == nil {
+		return 0, false
+	}
+	top, err := ctx.DereferenceNumber(arr[idx])
+	if err != nil {
+		return 0, false
+	}
+	return top, true
+}
+
+// writeOutline replaces the outline of a document with the given bookmark tree.
+// Unlike api.AddBookmarks, destinations are written as explicit page references:
+// pdfcpu registers named destinations keyed by title, which collide with names already
+// present in trimmed chapters and between bookmarks sharing the same title.
+// Parameters:
+//   - ctx: pdfcpu context of the document to modify
+//   - bookmarks: bookmark tree with page numbers relative to the document
+//
+// Returns:
+//   - error: if a destination page does not exist
+func writeOutline(ctx *model.Context, bookmarks []pdfcpu.Bookmark) error {
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	rootDict.Delete("Outlines")
+	if len(bookmarks) == 0 {
+		return nil
+	}
+
+	outlinesDict := types.Dict(map[string]types.Object{"Type": types.Name("Outlines")})
+	outlinesRef, err := ctx.IndRefForNewObject(outlinesDict)
+	if err != nil {
+		return err
+	}
+	first, last, count, err := outlineItems(ctx, bookmarks, *outlinesRef)
+	if err != nil {
+		return err
+	}
+	outlinesDict["First"] = *first
+	outlinesDict["Last"] = *last
+	outlinesDict["Count"] = types.Integer(count)
+	rootDict["Outlines"] = *outlinesRef
+	return nil
+}
+
+// outlineItems creates the outline item dictionaries for a list of sibling bookmarks and their kids.
+// All items are created open, so the returned count includes every descendant.
+func outlineItems(ctx *model.Context, bookmarks []pdfcpu.Bookmark, parent types.IndirectRef) (first, last *types.IndirectRef, count int, err error) {
+	var prevDict types.Dict
+	for _, bm := range bookmarks {
+		_, pageRef, _, err := ctx.PageDict(bm.PageFrom, false)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if pageRef == nil {
+			return nil, nil, 0, fmt.Errorf("bookmark '%s' points to missing page %d", bm.Title, bm.PageFrom)
+		}
+		title, err := types.EscapedUTF16String(bm.Title)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		d := types.Dict(map[string]types.Object{
+			"Title":  types.StringLiteral(*title),
+			"Parent": parent,
+			"Dest":   types.Array{*pageRef, types.Name("Fit")},
+		})
+		ref, err := ctx.IndRefForNewObject(d)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		count++
+
+		// Nested bookmarks become kids of this item
+		if len(bm.Kids) > 0 {
+			kidFirst, kidLast, kidCount, err := outlineItems(ctx, bm.Kids, *ref)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			d["First"] = *kidFirst
+			d["Last"] = *kidLast
+			d["Count"] = types.Integer(kidCount)
+			count += kidCount
+		}
+
+		// Link siblings
+		if first == nil {
+			first = ref
+		}
+		if prevDict != nil {
+			prevDict["Next"] = *ref
+			d["Prev"] = *last
+		}
+		prevDict = d
+		last = ref
+	}
+	return first, last, count, nil
+}
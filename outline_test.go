@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// TestCyclicOutline splits and lists the cyclic fixture, which must fail naming the item the
+// cycle leads back to instead of hanging.
+func TestCyclicOutline(t *testing.T) {
+	source, err := filepath.Abs(filepath.Join("testdata", "cyclic.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "outline appears to be malformed (cycle detected at 'Part One')"
+	for _, args := range [][]string{{"-i", source, "-o", "out"}, {"list", "-i", source}} {
+		output, err := runCommand(t, t.TempDir(), args...)
+		if err == nil || !strings.Contains(output, want) {
+			t.Errorf("%s: got %v, want %q\n%s", args[0], err, want, output)
+		}
+	}
+}
+
+// TestMinPages splits the book fixture by chapters with --min-pages 3 and --keep-bookmarks: the
+// one-page part title must be merged into the first chapter, which keeps its sections, and the
+// export must report the merge rather than a packed chapter.
+func TestMinPages(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := runCommand(t, dir, "-i", source, "-o", "out", "-d", "2", "--min-pages", "3", "--keep-bookmarks", "--sidecar-suffix=", "--bloat-factor=0")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	want := map[string]int{"01_Chapter 1.pdf": 5, "02_Chapter 2.pdf": 3, "03_Chapter 3.pdf": 5, "04_Chapter 4.pdf": 3}
+	if got := outputPageCounts(t, filepath.Join(dir, "out")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v\n%s", got, want, output)
+	}
+	if !strings.Contains(output, "merges 2 chapters (--min-pages): 'Part One (intro)', 'Chapter 1'") || strings.Contains(output, "combines") {
+		t.Errorf("the merge is not reported as such\n%s", output)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "out", "01_Chapter 1.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	bookmarks, err := api.Bookmarks(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, bm := range bookmarks {
+		got = append(got, bm.Title)
+	}
+	if want := []string{"Section 1.1", "Section 1.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got bookmarks %q, want %q", got, want)
+	}
+}
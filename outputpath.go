@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// normalizeOutputDir cleans and absolutizes the --output path before any work is done,
+// so that mistakes surface as one clear error instead of failures while writing chapters.
+// Quotes and whitespace left around the path by shells such as PowerShell are removed.
+// Parameters:
+//   - dir: output directory as given on the command line
+//
+// Returns:
+//   - string: the absolute, cleaned output directory
+//   - error: if the path is empty, uses a reserved device name, or cannot be a directory
+func normalizeOutputDir(dir string) (string, error) {
+	cleaned := strings.TrimSpace(dir)
+	cleaned = strings.Trim(cleaned, `"'`)
+	cleaned = strings.TrimSpace(cleaned)
+	if cleaned == "" {
+		return "", fmt.Errorf("invalid --output '%s': path is empty", dir)
+	}
+	abs, err := filepath.Abs(filepath.Clean(cleaned))
+	if err != nil {
+		return "", fmt.Errorf("invalid --output '%s': %v", dir, err)
+	}
+
+	// Device names such as CON or NUL cannot be used as directories on Windows
+	for _, part := range strings.Split(abs, string(filepath.Separator)) {
+		if isReservedName(part) {
+			return "", fmt.Errorf("invalid --output '%s': '%s' is a reserved device name", dir, part)
+		}
+	}
+
+	// The directory must exist or the nearest existing ancestor must be a directory
+	for path := abs; ; path = filepath.Dir(path) {
+		info, err := os.Stat(path)
+		if err == nil {
+			if !info.IsDir() {
+				return "", fmt.Errorf("invalid --output '%s': '%s' is not a directory", dir, path)
+			}
+			break
+		}
+		// Components below a file fail with "not a directory", which the walk reports at the file
+		if filepath.Dir(path) == path {
+			break
+		}
+	}
+	return abs, nil
+}
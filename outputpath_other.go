@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// isReservedName reports whether a path component is a reserved device name.
+// Only Windows reserves names; elsewhere every component is allowed.
+func isReservedName(string) bool {
+	return false
+}
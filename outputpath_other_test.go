@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeOutputDirDeviceNames(t *testing.T) {
+	// Only Windows reserves device names, elsewhere they are ordinary directories
+	dir := t.TempDir()
+	for _, name := range []string{"con", "NUL", "com1"} {
+		path := filepath.Join(dir, name)
+		if got, err := normalizeOutputDir(path); err != nil || got != path {
+			t.Errorf("%q: got %q, %v, want it unchanged", name, got, err)
+		}
+	}
+}
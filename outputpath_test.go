@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeOutputDir(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		{"out", filepath.Join(dir, "out")},
+		{"out" + string(filepath.Separator), filepath.Join(dir, "out")},
+		{`  "out` + string(filepath.Separator) + `"  `, filepath.Join(dir, "out")},
+		{"'a b'", filepath.Join(dir, "a b")},
+		{filepath.Join("a", "..", "b", ".", "c"), filepath.Join(dir, "b", "c")},
+		{filepath.Join("..", "sibling"), filepath.Join(filepath.Dir(dir), "sibling")},
+		{dir, dir},
+	}
+	for _, tt := range tests {
+		got, err := normalizeOutputDir(tt.dir)
+		if err != nil || got != tt.want {
+			t.Errorf("%q: got %q, %v, want %q", tt.dir, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeOutputDirErrors(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.pdf")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		{"", "path is empty"},
+		{` "" `, "path is empty"},
+		{file, "is not a directory"},
+		{filepath.Join(file, "chapters"), "'" + file + "' is not a directory"},
+	}
+	for _, tt := range tests {
+		_, err := normalizeOutputDir(tt.dir)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: got %v, want an error with %q", tt.dir, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "github.com/souhup/pdf-spliter/splitter"
+
+// isReservedName reports whether a path component is a reserved device name.
+func isReservedName(name string) bool {
+	return splitter.IsDeviceName(name)
+}
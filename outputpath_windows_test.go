@@ -0,0 +1,28 @@
+//go:build windows
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeOutputDirWindows(t *testing.T) {
+	dir := t.TempDir()
+	for _, reserved := range []string{"con", "NUL", "com1", "lpt9.txt", filepath.Join("out", "AUX", "chapters")} {
+		_, err := normalizeOutputDir(reserved)
+		if err == nil || !strings.Contains(err.Error(), "is a reserved device name") {
+			t.Errorf("%q: got %v, want a reserved device name", reserved, err)
+		}
+	}
+
+	// PowerShell passes a quoted path with a trailing backslash as it is
+	got, err := normalizeOutputDir(`"` + dir + `\out\"`)
+	if want := filepath.Join(dir, "out"); err != nil || got != want {
+		t.Errorf("got %q, %v, want %q", got, err, want)
+	}
+	if got, err = normalizeOutputDir(dir + `/mixed\separators/`); err != nil || got != filepath.Join(dir, "mixed", "separators") {
+		t.Errorf("mixed separators: got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// pageLabelPattern finds the label writeSampleDocument prints on every page.
+var pageLabelPattern = regexp.MustCompile(`\(Page (\d+)\) Tj`)
+
+// sourcePages returns the pages of the sample document a written chapter was trimmed from, read
+// from the labels printed on them.
+func sourcePages(t *testing.T, path string) []int {
+	t.Helper()
+	var pages []int
+	for _, content := range pageContents(t, path) {
+		match := pageLabelPattern.FindSubmatch(content)
+		if match == nil {
+			t.Fatalf("%s: a page has no page label", path)
+		}
+		page, _ := strconv.Atoi(string(match[1]))
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// pageSpan returns the pages from first to last.
+func pageSpan(first, last int) []int {
+	var pages []int
+	for page := first; page <= last; page++ {
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// TestChapterBoundaries splits a page-aligned outline and one whose bookmarks share pages, and
+// checks which pages of the source every chapter was trimmed to.
+func TestChapterBoundaries(t *testing.T) {
+	dir := t.TempDir()
+	aligned := filepath.Join(dir, "aligned.pdf")
+	if err := writeSampleDocument(aligned, 10, []pdfcpu.Bookmark{
+		{Title: "Cover", PageFrom: 1},
+		{Title: "Intro", PageFrom: 2},
+		{Title: "Body", PageFrom: 5},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	shared := filepath.Join(dir, "shared.pdf")
+	if err := writeSampleDocument(shared, 8, []pdfcpu.Bookmark{
+		{Title: "One", PageFrom: 1},
+		{Title: "Two", PageFrom: 4},
+		{Title: "Two A", PageFrom: 4},
+		{Title: "Three", PageFrom: 6},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		args   []string
+		want   map[string][]int
+	}{
+		{"aligned", aligned, nil, map[string][]int{
+			"01_Cover.pdf": {1},
+			"02_Intro.pdf": pageSpan(2, 4),
+			"03_Body.pdf":  pageSpan(5, 10),
+		}},
+		{"aligned-overlap", aligned, []string{"--no-overlap=false"}, map[string][]int{
+			"01_Cover.pdf": pageSpan(1, 2),
+			"02_Intro.pdf": pageSpan(2, 5),
+			"03_Body.pdf":  pageSpan(5, 10),
+		}},
+		{"shared", shared, nil, map[string][]int{
+			"01_One.pdf":   pageSpan(1, 3),
+			"02_Two.pdf":   pageSpan(4, 5),
+			"03_Three.pdf": pageSpan(6, 8),
+		}},
+		{"shared-overlap", shared, []string{"--no-overlap=false"}, map[string][]int{
+			"01_One.pdf":   pageSpan(1, 4),
+			"02_Two.pdf":   pageSpan(4, 6),
+			"03_Three.pdf": pageSpan(6, 8),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-i", tt.source, "-o", tt.name, "--sidecar-suffix=", "--bloat-factor=0"}, tt.args...)
+			if output, err := runCommand(t, dir, args...); err != nil {
+				t.Fatalf("%v\n%s", err, output)
+			}
+			got := make(map[string][]int)
+			for name := range outputPageCounts(t, filepath.Join(dir, tt.name)) {
+				got[name] = sourcePages(t, filepath.Join(dir, tt.name, name))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// defaultPackJoiner separates the titles of chapters packed into one output.
+const defaultPackJoiner = "+"
+
+// packChapters combines consecutive chapters into outputs of at most target pages, without
+// splitting any chapter. A chapter longer than target is kept on its own.
+// Packed chapters remember their constituents so their starts can be bookmarked.
+// Parameters:
+//   - chapters: chapters with their final titles
+//   - target: page budget of an output
+//   - joiner: separator of the constituent titles in the packed title
+//
+// Returns:
+//   - []chapter: the packed chapters in page order
+func packChapters(chapters []chapter, target int, joiner string) []chapter {
+	// Pack in reading sequence; only neighbors on the page are combined
+	sorted := append([]chapter(nil), chapters...)
+	sort.SliceStable(sorted, func(a, b int) bool { return sorted[a].startPage < sorted[b].startPage })
+
+	var groups [][]chapter
+	for _, cpt := range sorted {
+		if n := len(groups); n > 0 {
+			first := groups[n-1][0]
+			if int(cpt.endPage-first.startPage)+1 <= target {
+				groups[n-1] = append(groups[n-1], cpt)
+				continue
+			}
+		}
+		groups = append(groups, []chapter{cpt})
+	}
+
+	packed := make([]chapter, 0, len(groups))
+	for _, group := range groups {
+		if len(group) == 1 {
+			packed = append(packed, group[0])
+			continue
+		}
+		first, last := group[0], group[len(group)-1]
+		titles := make([]string, len(group))
+		for i, cpt := range group {
+			titles[i] = cpt.title
+		}
+		merged := chapter{
+			title:         strings.Join(titles, joiner),
+			startPage:     first.startPage,
+			endPage:       last.endPage,
+			startsMidPage: first.startsMidPage,
+			estimated:     first.estimated,
+			parts:         group,
+		}
+		for _, cpt := range group {
+			merged.trace = append(merged.trace, cpt.trace...)
+		}
+		merged.explain("explain_packed", len(group), target)
+		packed = append(packed, merged)
+	}
+	return packed
+}
+
+// mergeSmallChapters merges every chapter shorter than minPages into the following chapter,
+// or into the previous one at the end of the document, until all outputs have at least
+// minPages pages. A merged chapter takes the title of its longest constituent and the
+// sub-bookmarks of all of them. If the whole document is shorter than minPages, a single
+// chapter remains.
+// Parameters:
+//   - chapters: chapters with their final titles
+//   - minPages: minimum page count of an output
+//
+// Returns:
+//   - []chapter: the merged chapters in page order
+func mergeSmallChapters(chapters []chapter, minPages int) []chapter {
+	sorted := append([]chapter(nil), chapters...)
+	sort.SliceStable(sorted, func(a, b int) bool { return sorted[a].startPage < sorted[b].startPage })
+
+	// Collect chapters until the group is long enough
+	var groups [][]chapter
+	var open []chapter
+	for _, cpt := range sorted {
+		open = append(open, cpt)
+		if int(cpt.endPage-open[0].startPage)+1 >= minPages {
+			groups = append(groups, open)
+			open = nil
+		}
+	}
+	if len(open) > 0 {
+		if n := len(groups); n > 0 {
+			groups[n-1] = append(groups[n-1], open...)
+		} else {
+			groups = append(groups, open)
+		}
+	}
+
+	merged := make([]chapter, 0, len(groups))
+	for _, group := range groups {
+		if len(group) == 1 {
+			merged = append(merged, group[0])
+			continue
+		}
+		longest := group[0]
+		for _, cpt := range group[1:] {
+			if plannedPages(cpt) > plannedPages(longest) {
+				longest = cpt
+			}
+		}
+		cpt := longest
+		cpt.startPage = group[0].startPage
+		cpt.endPage = group[len(group)-1].endPage
+		cpt.startsMidPage = group[0].startsMidPage
+		cpt.estimated = group[0].estimated
+		cpt.kids = nil
+		cpt.trace = nil
+		cpt.merged = nil
+		for _, part := range group {
+			cpt.kids = append(cpt.kids, part.kids...)
+			cpt.trace = append(cpt.trace, part.trace...)
+			cpt.merged = append(cpt.merged, part.title)
+		}
+		cpt.explain("explain_min_pages", len(group), minPages, longest.title)
+		merged = append(merged, cpt)
+	}
+	return merged
+}
+
+// partBookmarks returns one bookmark per constituent of a packed chapter,
+// pointing at its first page within the chapter's output.
+// With --keep-bookmarks, the sub-bookmarks of each constituent are nested below it.
+func partBookmarks(cpt chapter) []pdfcpu.Bookmark {
+	var bookmarks []pdfcpu.Bookmark
+	for _, part := range cpt.parts {
+		bm := pdfcpu.Bookmark{Title: part.title, PageFrom: int(part.startPage-cpt.startPage) + 1}
+		if keepBookmarks {
+			bm.Kids = remapBookmarks(part.kids, part.startPage, part.endPage, int(cpt.startPage)-1)
+		}
+		bookmarks = append(bookmarks, bm)
+	}
+	return bookmarks
+}
+
+// partTitles lists the constituents of a packed chapter, for the export summary.
+func partTitles(cpt chapter) string {
+	titles := make([]string, len(cpt.parts))
+	for i, part := range cpt.parts {
+		titles[i] = part.title
+	}
+	return quoteTitles(titles)
+}
+
+// quoteTitles joins titles in quotes, for the export summary.
+func quoteTitles(titles []string) string {
+	quoted := make([]string, len(titles))
+	for i, title := range titles {
+		quoted[i] = "'" + title + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// paddedPages returns the number of blank pages --pad-to-even or --signature-size adds to a
+// chapter. A --continuation-page counts towards the page count that is padded.
+func paddedPages(cpt chapter) int {
+	pages := plannedPages(cpt) + continuationPages(cpt)
+	switch {
+	case signatureSize > 0:
+		return (signatureSize - pages%signatureSize) % signatureSize
+	case padToEven && pages%2 == 1:
+		return 1
+	}
+	return 0
+}
+
+// appendBlankPage appends one blank page after the last page of a document.
+// The blank page gets the media box of the last page and copies its crop and trim boxes,
+// so that it is cut to the same size by the printer.
+// Parameters:
+//   - ctx: pdfcpu context of the validated chapter
+//
+// Returns:
+//   - error: if the page tree cannot be updated
+func appendBlankPage(ctx *model.Context) error {
+	last := ctx.PageCount
+	if err := ctx.InsertBlankPages(types.IntSet{last: true}, nil, false); err != nil {
+		return err
+	}
+	ctx.PageCount++
+
+	// pdfcpu only sizes the blank page by the media box
+	lastDict, _, _, err := ctx.PageDict(last, false)
+	if err != nil {
+		return err
+	}
+	blankDict, _, _, err := ctx.PageDict(last+1, false)
+	if err != nil {
+		return err
+	}
+	for _, key := range []string{"CropBox", "TrimBox"} {
+		if box, found := lastDict.Find(key); found {
+			blankDict[key] = box
+		}
+	}
+	return nil
+}
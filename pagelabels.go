@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/souhup/pdf-spliter/ranges"
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// The --pages-as values: page selections name physical pages, or page labels of the input.
+const (
+	pagesAsPhysical = "physical"
+	pagesAsLabels   = "labels"
+)
+
+// pagesAs is the --pages-as value.
+var pagesAs string
+
+// pageSelection returns the pages of a selection flag for an input. Physical selections were
+// parsed with the flags; with --pages-as labels the selection names page labels, such as iv or
+// A-3, which are resolved through the label ranges of the input.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//   - flag: name of the flag, for errors
+//   - text: the selection as given
+//   - physical: the selection parsed as physical pages, nil with --pages-as labels
+//
+// Returns:
+//   - ranges.List: the physical pages of the selection, nil for an empty selection
+//   - error: if the input has no page labels, a label is unknown or ambiguous, or the selection
+//     mixes labels and physical page numbers
+func pageSelection(inputFile *os.File, flag, text string, physical ranges.List) (ranges.List, error) {
+	if pagesAs != pagesAsLabels || text == "" {
+		return physical, nil
+	}
+	doc, err := sourceDocument(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := doc.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshot.PageLabels) == 0 {
+		return nil, fmt.Errorf("invalid --%s '%s': --pages-as %s, but the input has no page labels", flag, text, pagesAsLabels)
+	}
+	list, err := resolvePageLabels(text, documentPageLabels(snapshot.PageLabels, snapshot.PageCount))
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s '%s': %v", flag, text, err)
+	}
+	return list, nil
+}
+
+// resolvePageLabels resolves a selection of page labels and label ranges, such as "iv,ix" or
+// "i-iv", to physical pages.
+// Parameters:
+//   - text: the selection
+//   - labels: the label of every page, from documentPageLabels
+//
+// Returns:
+//   - ranges.List: one range per item, of physical pages
+//   - error: for an unknown or ambiguous label, a range that ends before it starts, or a selection
+//     that mixes labels and physical page numbers
+func resolvePageLabels(text string, labels []string) (ranges.List, error) {
+	var (
+		list     ranges.List
+		numbers  []string
+		unknowns []string
+	)
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, fmt.Errorf("empty item")
+		}
+		r, found, err := resolveLabelItem(item, labels)
+		switch {
+		case err != nil:
+			return nil, err
+		case found:
+			list = append(list, r)
+		case isPhysicalSelection(item):
+			numbers = append(numbers, item)
+		default:
+			unknowns = append(unknowns, item)
+		}
+	}
+	switch {
+	case len(unknowns) > 0:
+		return nil, fmt.Errorf("no page is labeled '%s'", unknowns[0])
+	case len(numbers) > 0 && len(list) > 0:
+		return nil, fmt.Errorf("mixes page labels and physical page numbers: '%s' is not a page label; give either labels with --pages-as %s or physical pages with --pages-as %s",
+			numbers[0], pagesAsLabels, pagesAsPhysical)
+	case len(numbers) > 0:
+		return nil, fmt.Errorf("no page is labeled '%s'; physical pages need --pages-as %s", numbers[0], pagesAsPhysical)
+	}
+	return list, nil
+}
+
+// resolveLabelItem resolves one item of a label selection: a label, or two labels joined by a
+// dash. As labels may contain dashes themselves, such as A-3, the whole item is looked up first
+// and then every split at a dash.
+// Parameters:
+//   - item: the trimmed item
+//   - labels: the label of every page
+//
+// Returns:
+//   - ranges.Range: the physical pages of the item
+//   - bool: false if the item names no label
+//   - error: if a label of the item is on several pages or the range ends before it starts
+func resolveLabelItem(item string, labels []string) (ranges.Range, bool, error) {
+	page, found, err := labeledPage(item, labels)
+	if found || err != nil {
+		return ranges.Range{From: page, To: page, Step: 1}, found, err
+	}
+	for i := range len(item) {
+		if item[i] != '-' {
+			continue
+		}
+		from, fromFound, err := labeledPage(strings.TrimSpace(item[:i]), labels)
+		if err != nil {
+			return ranges.Range{}, false, err
+		}
+		to, toFound, err := labeledPage(strings.TrimSpace(item[i+1:]), labels)
+		if err != nil {
+			return ranges.Range{}, false, err
+		}
+		if !fromFound || !toFound {
+			continue
+		}
+		if to < from {
+			return ranges.Range{}, false, fmt.Errorf("'%s': range ends before it starts, on physical pages %d-%d", item, from, to)
+		}
+		return ranges.Range{From: from, To: to, Step: 1}, true, nil
+	}
+	return ranges.Range{}, false, nil
+}
+
+// labeledPage returns the physical page with a label, matched exactly.
+// Returns:
+//   - uint32: the page
+//   - bool: false if no page has the label
+//   - error: if several pages have the label, listing them
+func labeledPage(label string, labels []string) (uint32, bool, error) {
+	var pages []string
+	var page uint32
+	for i, l := range labels {
+		if l != "" && l == label {
+			page = uint32(i + 1)
+			pages = append(pages, strconv.Itoa(i+1))
+		}
+	}
+	if len(pages) > 1 {
+		return 0, false, fmt.Errorf("the label '%s' is ambiguous: it is on physical pages %s", label, strings.Join(pages, ", "))
+	}
+	return page, len(pages) == 1, nil
+}
+
+// isPhysicalSelection reports whether an item of a selection is a physical page selection, e.g.
+// 12, 3-5 or odd.
+func isPhysicalSelection(item string) bool {
+	_, err := ranges.Parse(item)
+	return err == nil
+}
+
+// documentPageLabels returns the label of every page of a document, "" for pages before the
+// first label range.
+// Parameters:
+//   - labelRanges: the page label ranges in page order
+//   - pageCount: the number of pages
+//
+// Returns:
+//   - []string: the label of page p at index p-1
+func documentPageLabels(labelRanges []splitter.PageLabel, pageCount int) []string {
+	labels := make([]string, pageCount)
+	for i, label := range labelRanges {
+		end := pageCount
+		if i+1 < len(labelRanges) {
+			end = min(labelRanges[i+1].Page-1, pageCount)
+		}
+		for page := label.Page; page <= end; page++ {
+			labels[page-1] = pageLabelText(label, page)
+		}
+	}
+	return labels
+}
+
+// pageLabelText returns the label of a page in a label range: the prefix followed by the number
+// of the page in the numbering style of the range, if it has one.
+func pageLabelText(label splitter.PageLabel, page int) string {
+	n := max(label.Start, 1) + page - label.Page
+	switch label.Style {
+	case "D":
+		return label.Prefix + strconv.Itoa(n)
+	case "r":
+		return label.Prefix + romanNumeral(n)
+	case "R":
+		return label.Prefix + strings.ToUpper(romanNumeral(n))
+	case "a":
+		return label.Prefix + strings.Repeat(string(rune('a'+(n-1)%26)), (n-1)/26+1)
+	case "A":
+		return label.Prefix + strings.Repeat(string(rune('A'+(n-1)%26)), (n-1)/26+1)
+	}
+	return label.Prefix
+}
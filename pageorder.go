@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// warnOutlineOrder warns about every bookmark that points to an earlier page than the bookmark
+// before it in the outline; splitter.PlanChapters takes them in page order.
+// Parameters:
+//   - bookmarks: bookmarks in outline order
+//
+// Returns:
+//   - bool: whether the outline was out of page order
+func warnOutlineOrder(bookmarks []pdfcpu.Bookmark) bool {
+	_, reordered := splitter.PageOrder(bookmarks)
+	for _, i := range reordered {
+		warnMsg("outline_reordered", bookmarks[i].Title, bookmarks[i].PageFrom, bookmarks[i-1].Title, bookmarks[i-1].PageFrom)
+	}
+	return len(reordered) > 0
+}
+
+// checkRanges fails if a chapter does not cover a page range that can be trimmed,
+// listing every such chapter, instead of letting pdfcpu fail or export the wrong pages.
+// Parameters:
+//   - chapters: list of chapter information
+//
+// Returns:
+//   - error: if any chapter starts before page 1 or ends before it starts
+func checkRanges(chapters []chapter) error {
+	spans := make([]splitter.Chapter, len(chapters))
+	for i, cpt := range chapters {
+		spans[i] = splitter.Chapter{Title: cpt.title, StartPage: int(cpt.startPage), EndPage: int(cpt.endPage)}
+	}
+	return splitter.CheckRanges(spans)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+)
+
+// exitPlanProblems is the exit code used when --dry-run found problems in the plan, when
+// --strict-plan found problems in the --plan file, and when validate-plan rejects a plan.
+const exitPlanProblems = 7
+
+// Supported values of the --dry-run flag.
+const (
+	dryRunTable = "table"
+	dryRunJSON  = "json"
+)
+
+// plannedFile is one row of the --dry-run plan.
+// BookmarkTitle is only set when --title-from replaced the bookmark title.
+// Estimated is set when the start page was estimated by --infer-missing-destinations.
+// StartPage and EndPage are physical pages; LogicalStart and LogicalEnd are the printed page
+// numbers of --logical-offset. Ranges lists the page ranges of a --plan output made of several
+// places, which StartPage and EndPage span. PaddedPages is the number of blank pages
+// --pad-to-even or --signature-size would add. Target is the path of the file and File the
+// same path relative to the output directory, as in the manifest.
+type plannedFile struct {
+	ID            string `json:"id"`
+	Order         uint32 `json:"order"`
+	PageOrder     uint32 `json:"page_order"`
+	Title         string `json:"title"`
+	BookmarkTitle string `json:"bookmark_title,omitempty"`
+	StartPage     uint32 `json:"start_page"`
+	EndPage       uint32 `json:"end_page"`
+	Pages         int    `json:"pages"`
+	PaddedPages   int    `json:"padded_pages,omitempty"`
+	Target        string `json:"target"`
+	File          string `json:"file"`
+	Estimated     bool   `json:"estimated,omitempty"`
+	LogicalStart  string `json:"logical_start_page,omitempty"`
+	LogicalEnd    string `json:"logical_end_page,omitempty"`
+	Ranges        string `json:"ranges,omitempty"`
+}
+
+// splitPlan collects the planned files and problems of all processed documents and subtrees.
+// RunID is the --run-id of the run that made the plan.
+// Warnings are the warnings printed while planning, with their codes.
+// Confidence is the confidence score of the chapter detection of the input document.
+// OptionsFingerprint is the optionsFingerprint of the run, which a split with the plan as --plan
+// compares with its own.
+type splitPlan struct {
+	SchemaVersion      int           `json:"schema_version"`
+	RunID              string        `json:"run_id"`
+	OptionsFingerprint string        `json:"options_fingerprint"`
+	Confidence         float64       `json:"confidence"`
+	Files              []plannedFile `json:"files"`
+	Problems           []string      `json:"problems"`
+	Warnings           []warning     `json:"warnings"`
+}
+
+// plan is filled by processChapters in --dry-run mode and printed at the end of the run.
+var plan = splitPlan{SchemaVersion: schemaVersion, Files: []plannedFile{}, Problems: []string{}}
+
+// planChapters adds the chapters of one export to the plan and records problems:
+// chapters without pages and output files that would overwrite each other.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//   - chapters: list of chapter information in export order
+//   - dir: directory the chapters would be written to
+func planChapters(inputFile *os.File, chapters []chapter, dir string) {
+	seen := make(map[string]string)
+	for _, f := range plan.Files {
+		seen[strings.ToLower(f.Target)] = f.Target
+	}
+	for _, cpt := range chapters {
+		target := singleOutput
+		if target == "" {
+			target = filepath.Join(chapterDir(cpt, dir), cpt.file+".pdf")
+		}
+		pages := int(cpt.endPage) - int(cpt.startPage) + 1
+		var ranges string
+		if len(cpt.ranges) > 0 {
+			pages, ranges = plannedPages(cpt), chapterPageRange(cpt)
+		}
+		plan.Files = append(plan.Files, plannedFile{
+			ID:            cpt.id,
+			Order:         cpt.order,
+			PageOrder:     cpt.pageOrder,
+			Title:         cpt.title,
+			BookmarkTitle: recoveredFrom(cpt),
+			StartPage:     cpt.startPage,
+			EndPage:       cpt.endPage,
+			Pages:         pages,
+			PaddedPages:   paddedPages(cpt),
+			Target:        target,
+			File:          manifestFilePath(target),
+			Estimated:     cpt.estimated,
+			LogicalStart:  logicalStart(cpt),
+			LogicalEnd:    logicalEnd(cpt),
+			Ranges:        ranges,
+		})
+
+		if pages <= 0 {
+			plan.Problems = append(plan.Problems, msg("plan_no_pages", cpt.title, cpt.startPage, cpt.endPage))
+		}
+		if singleOutput != "" {
+			continue
+		}
+		// Case-insensitive file systems would also merge names that differ in case only
+		if other, ok := seen[strings.ToLower(target)]; ok {
+			plan.Problems = append(plan.Problems, msg("plan_duplicate_target", target, other))
+		}
+		seen[strings.ToLower(target)] = target
+	}
+}
+
+// recoveredFrom returns the bookmark title of a chapter whose title was replaced, or "".
+func recoveredFrom(cpt chapter) string {
+	if cpt.bookmarkTitle == cpt.title {
+		return ""
+	}
+	return cpt.bookmarkTitle
+}
+
+// printPlan prints the plan in the --dry-run format and ends the run with exitPlanProblems if it has problems.
+func printPlan(format string) error {
+	if format == dryRunJSON {
+		plan.RunID = runID
+		plan.OptionsFingerprint = runOptionsFingerprint
+		plan.Warnings = warnings
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plan); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if logicalNumbering {
+			fmt.Fprintln(w, msg("plan_columns_logical"))
+		} else {
+			fmt.Fprintln(w, msg("plan_columns"))
+		}
+		var estimated bool
+		for _, f := range plan.Files {
+			if logicalNumbering {
+				fmt.Fprintf(w, "%02d\t%s\t%s\t%d\t%s-%s\t%d\t%s\n", f.Order, f.Title, estimatedStart(f), f.EndPage, f.LogicalStart, f.LogicalEnd, f.Pages, f.Target)
+			} else {
+				fmt.Fprintf(w, "%02d\t%s\t%s\t%d\t%d\t%s\n", f.Order, f.Title, estimatedStart(f), f.EndPage, f.Pages, f.Target)
+			}
+			estimated = estimated || f.Estimated
+		}
+		w.Flush()
+		if estimated {
+			fmt.Println(msg("plan_estimated"))
+		}
+		for _, problem := range plan.Problems {
+			fmt.Println(msg("plan_problem", problem))
+		}
+	}
+	if len(plan.Problems) > 0 {
+		return exitWith(exitPlanProblems, nil)
+	}
+	return nil
+}
+
+// outputOptionFlags are the flags that change the content of the written files rather than which
+// pages they hold, which a --dry-run=json plan records as its optionsFingerprint.
+var outputOptionFlags = []string{"continuation-page", "extract", "image-quality", "keep-bookmarks", "keep-encryption",
+	"pad-to-even", "provenance", "signature-size", "stamp-header", "stamp-header-size", "stamp-id", "subset-resources"}
+
+// runOptionsFingerprint is the optionsFingerprint of the flags of the run.
+var runOptionsFingerprint string
+
+// optionsFingerprint returns a hash of the values of outputOptionFlags, so that a split with a
+// --dry-run=json plan can tell whether the plan was made with other output options.
+func optionsFingerprint(flags *pflag.FlagSet) string {
+	h := sha256.New()
+	for _, name := range outputOptionFlags {
+		fmt.Fprintf(h, "%s=%s\n", name, flags.Lookup(name).Value.String())
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))[:16]
+}
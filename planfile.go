@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/souhup/pdf-spliter/ranges"
+	"gopkg.in/yaml.v2"
+)
+
+// titleNameTemplate replaces the default --name-template when the outputs are named by the user,
+// with --plan and for the documents found by --split-on-barcode, so that files carry that name only.
+const titleNameTemplate = "{title}"
+
+// pageRange is a range of physical pages, both ends included.
+type pageRange struct {
+	start uint32
+	end   uint32
+}
+
+// pageSpans returns the page ranges of a chapter in page order: the ranges of a --plan output
+// assembled from several places, or else its single range.
+func (c chapter) pageSpans() []pageRange {
+	if len(c.ranges) > 0 {
+		return c.ranges
+	}
+	return []pageRange{{c.startPage, c.endPage}}
+}
+
+// chapterPageRange returns the pdfcpu page selection of a chapter, e.g. "4-9" or "1-8,15-20".
+func chapterPageRange(cpt chapter) string {
+	spans := cpt.pageSpans()
+	parts := make([]string, len(spans))
+	for i, span := range spans {
+		parts[i] = fmt.Sprintf("%d-%d", span.start, span.end)
+	}
+	return strings.Join(parts, ",")
+}
+
+// planEntry is one output of a --plan file: its name and either the numbers of the chapters or
+// the pages it is made of, as a selection of the ranges package like "1-8, 10", "16-" or "odd",
+// where an open range runs through the last chapter or page. OutputDir, if set, is the directory
+// the output is written to, relative to --output or, with --allow-absolute-output-dirs, absolute.
+type planEntry struct {
+	Name      string `yaml:"name"`
+	Chapters  string `yaml:"chapters"`
+	Pages     string `yaml:"pages"`
+	OutputDir string `yaml:"output_dir,omitempty"`
+}
+
+// planDocument is the content of a --plan file. SchemaVersion is 0 for files written before
+// the format had a version, which are version 1. OptionsFingerprint is the optionsFingerprint of
+// the run that wrote the plan, empty for a plan written by hand.
+type planDocument struct {
+	SchemaVersion      int         `yaml:"schema_version,omitempty"`
+	OptionsFingerprint string      `yaml:"options_fingerprint,omitempty"`
+	Outputs            []planEntry `yaml:"outputs"`
+}
+
+// planIssue is a problem of a --plan file, printed as a warning or, with --strict-plan, as an error.
+type planIssue struct {
+	key  string
+	args []any
+}
+
+// readPlanFile reads and checks the syntax of a --plan file: a YAML or JSON plan, or the plan
+// printed by --dry-run=json, which is read with planFromDryRun.
+// Parameters:
+//   - path: path of the file
+//
+// Returns:
+//   - planDocument: the plan, its outputs in the order they are declared
+//   - error: naming the offending entry if the file is malformed
+func readPlanFile(path string) (planDocument, error) {
+	var doc planDocument
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return doc, err
+	}
+	var probe struct {
+		Files json.RawMessage `json:"files"`
+	}
+	if json.Unmarshal(data, &probe) == nil && probe.Files != nil {
+		doc, err = planFromDryRun(data)
+	} else {
+		err = yaml.UnmarshalStrict(data, &doc)
+	}
+	if err != nil {
+		return doc, fmt.Errorf("plan '%s': %v", path, err)
+	}
+	if doc.SchemaVersion > schemaVersion {
+		return doc, fmt.Errorf("plan '%s' has schema_version %d, newer than the supported %d", path, doc.SchemaVersion, schemaVersion)
+	}
+	if len(doc.Outputs) == 0 {
+		return doc, fmt.Errorf("plan '%s' declares no outputs", path)
+	}
+	if err = checkChapterLimit(len(doc.Outputs)); err != nil {
+		return doc, err
+	}
+	for i, entry := range doc.Outputs {
+		if strings.TrimSpace(entry.Name) == "" {
+			return doc, fmt.Errorf("plan '%s': output %d has no name", path, i+1)
+		}
+		if (entry.Chapters == "") == (entry.Pages == "") {
+			return doc, fmt.Errorf("plan '%s': output '%s' must have either chapters or pages", path, entry.Name)
+		}
+		if _, err = ranges.Parse(entry.Chapters + entry.Pages); err != nil {
+			return doc, fmt.Errorf("plan '%s': output '%s': %v", path, entry.Name, err)
+		}
+		if err = checkPlanOutputDir(entry.OutputDir); err != nil {
+			return doc, fmt.Errorf("plan '%s': output '%s': %v", path, entry.Name, err)
+		}
+	}
+	return doc, nil
+}
+
+// planFromDryRun reads the plan printed by --dry-run=json as a --plan file: every planned file
+// becomes an output of its pages, named like the file and written into the same directory
+// relative to --output, so that the split writes the files the dry run showed.
+// Parameters:
+//   - data: the JSON plan
+//
+// Returns:
+//   - planDocument: the outputs in the order of the files
+//   - error: if the JSON is malformed or has unknown fields
+func planFromDryRun(data []byte) (planDocument, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var p splitPlan
+	if err := dec.Decode(&p); err != nil {
+		return planDocument{}, err
+	}
+	doc := planDocument{SchemaVersion: p.SchemaVersion, OptionsFingerprint: p.OptionsFingerprint, Outputs: []planEntry{}}
+	for _, f := range p.Files {
+		pages := f.Ranges
+		if pages == "" {
+			pages = fmt.Sprintf("%d-%d", f.StartPage, f.EndPage)
+		}
+		dir, file := path.Split(f.File)
+		doc.Outputs = append(doc.Outputs, planEntry{
+			Name:      strings.TrimSuffix(file, ".pdf"),
+			Pages:     pages,
+			OutputDir: strings.TrimSuffix(dir, "/"),
+		})
+	}
+	return doc, nil
+}
+
+// checkPlanOutputDir checks the output_dir of a --plan output: a relative directory must stay
+// within --output, and an absolute one needs --allow-absolute-output-dirs.
+func checkPlanOutputDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	native := filepath.FromSlash(dir)
+	if filepath.IsAbs(native) {
+		if !allowAbsoluteDirs {
+			return fmt.Errorf("output_dir '%s' is absolute, which needs --allow-absolute-output-dirs", dir)
+		}
+	} else if filepath.VolumeName(native) != "" || strings.HasPrefix(native, string(filepath.Separator)) {
+		return fmt.Errorf("output_dir '%s' must be relative to --output or absolute", dir)
+	} else if cleaned := filepath.Clean(native); cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("output_dir '%s' leaves the output directory", dir)
+	}
+
+	// Device names such as CON or NUL cannot be used as directories on Windows
+	for _, part := range strings.Split(filepath.Clean(native), string(filepath.Separator)) {
+		if isReservedName(part) {
+			return fmt.Errorf("output_dir '%s': '%s' is a reserved device name", dir, part)
+		}
+	}
+	return nil
+}
+
+// chapterDir returns the directory a chapter is written to: dir, or the output_dir of its --plan
+// output, taken within dir unless it is absolute.
+func chapterDir(cpt chapter, dir string) string {
+	if cpt.outputDir == "" {
+		return dir
+	}
+	if filepath.IsAbs(cpt.outputDir) {
+		return cpt.outputDir
+	}
+	return filepath.Join(dir, cpt.outputDir)
+}
+
+// applySplitPlan replaces the chapters by the outputs of the --plan file, each made of the union
+// of its chapters or pages and numbered in the order of the file. Chapter numbers are those of the
+// chapters in export order. Unknown chapters and pages, pages shared by several outputs and pages
+// of the chapters left out by all outputs are reported as warnings, or fail the run with
+// --strict-plan. An output left without any page always fails the run.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//   - chapters: chapters in export order, numbered
+//
+// Returns:
+//   - []chapter: the outputs of the plan
+//   - []chapter: the chapters no output takes any page of
+//   - error: if the --plan file cannot be read or does not fit the chapters
+func applySplitPlan(inputFile *os.File, chapters []chapter) ([]chapter, []chapter, error) {
+	doc, err := readPlanFile(planFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read --plan: %w", err)
+	}
+	entries := doc.Outputs
+	if doc.OptionsFingerprint != "" && doc.OptionsFingerprint != runOptionsFingerprint {
+		warnMsg("plan_options_differ", planFile)
+	}
+	pageCount, err := sourcePageCount(inputFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	byOrder := make(map[uint32]chapter, len(chapters))
+	var lastOrder uint32
+	for _, cpt := range chapters {
+		byOrder[cpt.order] = cpt
+		lastOrder = max(lastOrder, cpt.order)
+	}
+
+	// Collect the pages of every output; the syntax was checked when the file was read
+	var issues []planIssue
+	var empty []string
+	outputs := make([]chapter, 0, len(entries))
+	for i, entry := range entries {
+		var spans []pageRange
+		var trace []string
+		estimated := false
+		if entry.Chapters != "" {
+			numbers, _ := ranges.Parse(entry.Chapters)
+			for _, r := range numbers {
+				for order := r.From; order <= r.End(lastOrder); order += r.Step {
+					cpt, ok := byOrder[order]
+					if !ok {
+						issues = append(issues, planIssue{"plan_unknown_chapter", []any{entry.Name, order}})
+						continue
+					}
+					spans = append(spans, cpt.pageSpans()...)
+					trace = append(trace, cpt.trace...)
+					estimated = estimated || cpt.estimated
+				}
+			}
+		} else {
+			numbers, _ := ranges.Parse(entry.Pages)
+			for _, r := range numbers {
+				from, to := r.From, r.End(uint32(pageCount))
+
+				// Ranges are cut at the end of the document
+				if int(to) > pageCount {
+					issues = append(issues, planIssue{"plan_unknown_pages", []any{entry.Name, from, to, pageCount}})
+					if int(from) > pageCount {
+						continue
+					}
+					to = uint32(pageCount)
+				}
+				if r.Step == 1 {
+					spans = append(spans, pageRange{from, to})
+					continue
+				}
+				for page := from; page <= to; page += r.Step {
+					spans = append(spans, pageRange{page, page})
+				}
+			}
+		}
+		spans = unionRanges(spans)
+		if len(spans) == 0 {
+			empty = append(empty, entry.Name)
+			continue
+		}
+
+		out := chapter{
+			title:         entry.Name,
+			bookmarkTitle: entry.Name,
+			order:         uint32(i + 1),
+			pageOrder:     uint32(i + 1),
+			startPage:     spans[0].start,
+			endPage:       spans[len(spans)-1].end,
+			estimated:     estimated,
+			trace:         trace,
+		}
+		if entry.OutputDir != "" {
+			out.outputDir = filepath.Clean(filepath.FromSlash(entry.OutputDir))
+		}
+		if len(spans) > 1 {
+			out.ranges = spans
+		}
+		out.explain("explain_plan_output", entry.Name, chapterPageRange(out))
+		outputs = append(outputs, out)
+	}
+
+	// Pages shared by two outputs are written twice
+	for a := range outputs {
+		for b := a + 1; b < len(outputs); b++ {
+			for _, shared := range intersectRanges(outputs[a].pageSpans(), outputs[b].pageSpans()) {
+				issues = append(issues, planIssue{"plan_overlap", []any{outputs[a].title, outputs[b].title, shared.start, shared.end}})
+			}
+		}
+	}
+
+	// Pages of the chapters that no output takes are left out
+	var planned, unplanned []pageRange
+	for _, out := range outputs {
+		planned = append(planned, out.pageSpans()...)
+	}
+	planned = unionRanges(planned)
+	var unreferenced []chapter
+	for _, cpt := range chapters {
+		unplanned = append(unplanned, subtractRanges(cpt.pageSpans(), planned)...)
+		if len(intersectRanges(cpt.pageSpans(), planned)) == 0 {
+			unreferenced = append(unreferenced, cpt)
+		}
+	}
+	for _, gap := range unionRanges(unplanned) {
+		issues = append(issues, planIssue{"plan_gap", []any{gap.start, gap.end}})
+	}
+
+	if len(issues) > 0 && strictPlan {
+		for _, issue := range issues {
+			errorMsg(issue.key, issue.args...)
+		}
+		errorMsg("strict_plan_failed", len(issues), planFile)
+		return nil, nil, exitWith(exitPlanProblems, nil)
+	}
+	for _, issue := range issues {
+		warnMsg(issue.key, issue.args...)
+	}
+	if len(empty) > 0 {
+		return nil, nil, errors.New(msg("plan_empty_output", empty[0]))
+	}
+	printMsg("using_plan", len(outputs), planFile)
+	return outputs, unreferenced, nil
+}
+
+// printUnreferencedChapters reports the chapters that no output of the --plan file takes any
+// page of, at the end of the export.
+func printUnreferencedChapters(chapters []chapter) {
+	if len(chapters) == 0 {
+		return
+	}
+	titles := make([]string, len(chapters))
+	for i, cpt := range chapters {
+		titles[i] = fmt.Sprintf("%02d '%s'", cpt.order, cpt.title)
+	}
+	printMsg("plan_unreferenced", len(chapters), strings.Join(titles, ", "))
+}
+
+// unionRanges sorts page ranges and merges the ones that overlap or touch.
+func unionRanges(ranges []pageRange) []pageRange {
+	sorted := append([]pageRange(nil), ranges...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].start < sorted[b].start })
+	var union []pageRange
+	for _, r := range sorted {
+		if n := len(union); n > 0 && r.start <= union[n-1].end+1 {
+			union[n-1].end = max(union[n-1].end, r.end)
+			continue
+		}
+		union = append(union, r)
+	}
+	return union
+}
+
+// intersectRanges returns the pages two sets of page ranges have in common, as merged ranges.
+func intersectRanges(a, b []pageRange) []pageRange {
+	var shared []pageRange
+	for _, ra := range a {
+		for _, rb := range b {
+			if start, end := max(ra.start, rb.start), min(ra.end, rb.end); start <= end {
+				shared = append(shared, pageRange{start, end})
+			}
+		}
+	}
+	return unionRanges(shared)
+}
+
+// subtractRanges returns the pages of ranges that are not in the merged ranges of taken.
+func subtractRanges(ranges, taken []pageRange) []pageRange {
+	var rest []pageRange
+	for _, r := range ranges {
+		start := r.start
+		for _, t := range taken {
+			if t.end < start || t.start > r.end {
+				continue
+			}
+			if t.start > start {
+				rest = append(rest, pageRange{start, t.start - 1})
+			}
+			start = t.end + 1
+		}
+		if start <= r.end {
+			rest = append(rest, pageRange{start, r.end})
+		}
+	}
+	return rest
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestPlanOutputDir splits the book fixture with a --plan whose outputs are routed to their own
+// directories: the files must be written there, named uniquely per directory, and recorded in
+// the manifest with their absolute path. Directories outside --output must be refused.
+func TestPlanOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := filepath.Join(dir, "plan.yaml")
+	writePlan := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(plan, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writePlan(`outputs:
+  - name: Part One
+    chapters: 1
+    output_dir: legal
+  - name: Part Two
+    chapters: 2
+    output_dir: finance/2024
+  - name: Part One
+    pages: 1-2
+    output_dir: ./legal/
+`)
+	if output, err := runCommand(t, dir, "-i", source, "-o", "out", "--plan", plan, "--manifest", "toc.json",
+		"--sidecar-suffix=", "--bloat-factor=0"); err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	want := []string{
+		filepath.Join(dir, "out", "legal", "Part One.pdf"),
+		filepath.Join(dir, "out", "finance", "2024", "Part Two.pdf"),
+		filepath.Join(dir, "out", "legal", "Part One_2.pdf"),
+	}
+	records := readManifest(t, filepath.Join(dir, "out", "toc.json"))
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i, record := range records {
+		if record["path"] != want[i] {
+			t.Errorf("record %d has path %v, want %s", i+1, record["path"], want[i])
+		}
+		if _, err := os.Stat(want[i]); err != nil {
+			t.Error(err)
+		}
+	}
+
+	tests := []struct {
+		outputDir string
+		want      string
+	}{
+		{"../shared", "leaves the output directory"},
+		{"legal/../../shared", "leaves the output directory"},
+		{filepath.Join(dir, "shared"), "--allow-absolute-output-dirs"},
+	}
+	for _, tt := range tests {
+		writePlan("outputs:\n  - name: Part One\n    chapters: 1\n    output_dir: '" + tt.outputDir + "'\n")
+		output, err := runCommand(t, dir, "-i", source, "-o", "refused", "--plan", plan)
+		if err == nil || !strings.Contains(output, tt.want) {
+			t.Errorf("output_dir %s: got %v, want %q\n%s", tt.outputDir, err, tt.want, output)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "shared")); !os.IsNotExist(err) {
+		t.Errorf("a refused output_dir was created: %v", err)
+	}
+}
+
+// TestPlanFromDryRun splits the sections of the book fixture with the --dry-run=json plan of the
+// same split, with pages left out so that a file is made of two places: the split must write the
+// files of the dry run with their page counts, and warn only if its output options differ.
+func TestPlanFromDryRun(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := runCommand(t, dir, "-i", source, "-o", "planned", "--depth", "2", "--exclude-pages", "3-4",
+		"--dry-run=json", "-q", "--sidecar-suffix=", "--bloat-factor=0")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	var dryRun splitPlan
+	if err := json.Unmarshal([]byte(output), &dryRun); err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	plan := filepath.Join(dir, "plan.json")
+	if err := os.WriteFile(plan, []byte(output), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := make(map[string]int)
+	for _, f := range dryRun.Files {
+		want[f.File] = f.Pages
+	}
+
+	for _, tt := range []struct {
+		out   string
+		args  []string
+		warns bool
+	}{
+		{"same", nil, false},
+		{"stamped", []string{"--stamp-id", stampIDText}, true},
+	} {
+		args := append([]string{"-i", source, "-o", tt.out, "--plan", plan, "--sidecar-suffix=", "--bloat-factor=0"}, tt.args...)
+		output, err := runCommand(t, dir, args...)
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", tt.out, err, output)
+		}
+		if got := outputPageCounts(t, filepath.Join(dir, tt.out)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want the files of the dry run %v", tt.out, got, want)
+		}
+		if warned := strings.Contains(output, "[W031]"); warned != tt.warns {
+			t.Errorf("%s: warned about other output options: %v, want %v\n%s", tt.out, warned, tt.warns, output)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// exitProfilesFailed is the exit code used when at least one --profile failed.
+const exitProfilesFailed = 18
+
+// splitProfile is one output set of --profile: the outline level to split at, the name template
+// and the subdirectory of --output the files are written to.
+type splitProfile struct {
+	name         string
+	depth        int
+	template     splitter.NameTemplate
+	barcodeNames bool
+	output       string
+}
+
+// parseProfiles parses the --profile values, given as name:level=N,template=T,output=DIR. level
+// and template default to --depth and --name-template, and output to the name. A template cannot
+// contain a comma.
+// Parameters:
+//   - values: the --profile values in the order given
+//
+// Returns:
+//   - []splitProfile: the profiles in the same order
+//   - error: naming the offending profile if a value is malformed or two profiles share a name or output
+func parseProfiles(values []string) ([]splitProfile, error) {
+	profiles := make([]splitProfile, 0, len(values))
+	names := make(map[string]bool)
+	outputs := make(map[string]string)
+	for _, value := range values {
+		name, settings, _ := strings.Cut(value, ":")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("invalid --profile '%s': the profile has no name", value)
+		}
+		p := splitProfile{name: name, depth: splitDepth, template: nameTemplateParsed, barcodeNames: barcodeNames, output: name}
+		for _, setting := range strings.Split(settings, ",") {
+			if strings.TrimSpace(setting) == "" {
+				continue
+			}
+			key, val, ok := strings.Cut(setting, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid --profile '%s': '%s' is not key=value", name, setting)
+			}
+			var err error
+			switch strings.TrimSpace(key) {
+			case "level":
+				if p.depth, err = strconv.Atoi(strings.TrimSpace(val)); err != nil || p.depth < 1 {
+					return nil, fmt.Errorf("invalid --profile '%s': level '%s' must be at least 1", name, val)
+				}
+			case "template":
+				if p.template, err = parseNameTemplate(val); err != nil {
+					return nil, fmt.Errorf("invalid --profile '%s': %w", name, err)
+				}
+				p.barcodeNames = false
+			case "output":
+				p.output = strings.TrimSpace(val)
+			default:
+				return nil, fmt.Errorf("invalid --profile '%s': unknown setting '%s', want level, template or output", name, key)
+			}
+		}
+
+		// Every profile writes into a directory of its own within --output
+		native := filepath.FromSlash(p.output)
+		cleaned := filepath.Clean(native)
+		if p.output == "" || filepath.IsAbs(native) || filepath.VolumeName(native) != "" || cleaned == "." || cleaned == ".." ||
+			strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("invalid --profile '%s': output '%s' must be a subdirectory of --output", name, p.output)
+		}
+		p.output = cleaned
+		if names[strings.ToLower(name)] {
+			return nil, fmt.Errorf("invalid --profile '%s': the name is used by another profile", name)
+		}
+		if other, ok := outputs[strings.ToLower(cleaned)]; ok {
+			return nil, fmt.Errorf("invalid --profile '%s': output '%s' is used by profile '%s'", name, p.output, other)
+		}
+		names[strings.ToLower(name)] = true
+		outputs[strings.ToLower(cleaned)] = name
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// resetRunState clears what a split of the document collects for its summary, manifest and
+// checks, so that the next --profile starts from scratch. The source read by sourceDocument is kept.
+func resetRunState() {
+	manifestEntries = []manifestEntry{}
+	manifestSkipped = false
+	plan = splitPlan{SchemaVersion: schemaVersion, Files: []plannedFile{}, Problems: []string{}}
+	warnings = []warning{}
+	timedOutChapters = nil
+	unreadableChapters = nil
+	invalidOutputs = nil
+	failedDeliveries = nil
+}
+
+// splitProfiles splits an opened source once per --profile, each with its own outline level,
+// name template and subdirectory of --output, all from the source read once. Every profile writes
+// its own manifest and prints its own summary. A failing profile is reported and the others are
+// still split, unless --fail-fast stops at the first failure; the failed profiles are listed at
+// the end and the run ends with exitProfilesFailed.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//   - baseDir: directory the subdirectories of the profiles are created in
+//
+// Returns:
+//   - error: the exit code of failed profiles, or nil if all were split
+func splitProfiles(inputFile *os.File, baseDir string) error {
+	root, depth, template, byBarcode := outputDir, splitDepth, nameTemplateParsed, barcodeNames
+	defer func() { outputDir, splitDepth, nameTemplateParsed, barcodeNames = root, depth, template, byBarcode }()
+
+	var failed []string
+	for i, p := range profiles {
+		outputDir, splitDepth, nameTemplateParsed, barcodeNames = filepath.Join(root, p.output), p.depth, p.template, p.barcodeNames
+		printMsg("profile_start", i+1, len(profiles), p.name, p.depth, outputDir)
+		resetRunState()
+		var err error
+		if manifestFile != "" {
+			err = checkManifest()
+		}
+		if err == nil {
+			err = splitDocument(inputFile, filepath.Join(baseDir, p.output))
+		}
+		if err == nil {
+			continue
+		}
+		errorMsg("profile_failed", p.name, err)
+		failed = append(failed, p.name)
+		if failFast {
+			break
+		}
+	}
+
+	if len(failed) == 0 {
+		printMsg("profiles_done", len(profiles))
+		return nil
+	}
+	errorMsg("profiles_failed", len(failed), len(profiles), strings.Join(failed, ", "))
+	return exitWith(exitProfilesFailed, nil)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestProfiles splits the book fixture with three profiles, the second of which cannot create its
+// directory: the others must still be written, each with its own level, template and manifest,
+// and the run must end with exitProfilesFailed. With --fail-fast the third is not split.
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-i", source, "--manifest", "toc.json", "--sidecar-suffix=", "--bloat-factor=0",
+		"--profile", "print:level=1,output=parts", "--profile", "blocked", "--profile", "web:level=3,template={title}"}
+	for _, out := range []string{"out", "fast"} {
+		if err := os.MkdirAll(filepath.Join(dir, out), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, out, "blocked"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	output, err := runCommand(t, dir, append(args, "-o", "out")...)
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitProfilesFailed {
+		t.Fatalf("got %v, want exit code %d\n%s", err, exitProfilesFailed, output)
+	}
+	if !strings.Contains(output, "1 of 3 profiles failed: blocked") {
+		t.Errorf("output does not list the failed profile\n%s", output)
+	}
+	for _, tt := range []struct {
+		output string
+		files  []string
+	}{
+		{"parts", []string{"01_Part One.pdf", "02_Part Two.pdf"}},
+		{"web", []string{"Part One (intro).pdf", "Section 1.1.pdf", "Section 1.2.pdf", "Section 2.1.pdf",
+			"Section 2.2.pdf", "Section 3.1.pdf", "Section 3.2.pdf", "Section 4.1.pdf", "Section 4.2.pdf"}},
+	} {
+		var files []string
+		for _, record := range readManifest(t, filepath.Join(dir, "out", tt.output, "toc.json")) {
+			files = append(files, record["file"].(string))
+			if _, err := os.Stat(record["path"].(string)); err != nil {
+				t.Error(err)
+			}
+		}
+		if strings.Join(files, "|") != strings.Join(tt.files, "|") {
+			t.Errorf("profile into %s: got files %q, want %q", tt.output, files, tt.files)
+		}
+	}
+
+	output, err = runCommand(t, dir, append(args, "-o", "fast", "--fail-fast")...)
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitProfilesFailed {
+		t.Fatalf("--fail-fast: got %v, want exit code %d\n%s", err, exitProfilesFailed, output)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fast", "web")); !os.IsNotExist(err) {
+		t.Errorf("--fail-fast split the profile after the failing one: %v", err)
+	}
+}
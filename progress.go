@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// progressWidth is the widest progress line drawn on a terminal; longer lines are shortened
+// so that they never wrap, which would break updating them in place.
+const progressWidth = 79
+
+var (
+	// progressMu guards progressShown; retry messages are printed by the export workers.
+	progressMu sync.Mutex
+	// progressShown is the width of the progress line currently drawn on the terminal, 0 if none.
+	progressShown int
+)
+
+// clearingWriter writes messages to a stream that may show a progress line, removing the line
+// first so that a message never runs into it.
+type clearingWriter struct {
+	w io.Writer
+}
+
+// Write clears the progress line and writes p.
+func (c clearingWriter) Write(p []byte) (int, error) {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	clearProgressLocked(c.w)
+	return c.w.Write(p)
+}
+
+// clearProgressLocked removes the progress line from the terminal; progressMu must be held.
+func clearProgressLocked(w io.Writer) {
+	if progressShown > 0 {
+		fmt.Fprint(w, "\r"+strings.Repeat(" ", progressShown)+"\r")
+		progressShown = 0
+	}
+}
+
+// isTerminal reports whether w is a terminal rather than a file or pipe.
+// The null device is a character device as well, but output to it is discarded.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// showProgress reports the chapter an export is working on, as "[3/40] exporting: Title (pages 9-12)".
+// On a terminal the line replaces the previous one in place and is removed by the next message;
+// otherwise every chapter gets a line of its own. Nothing is shown with --quiet.
+// Parameters:
+//   - i: index of the chapter being exported
+//   - total: number of chapters of the export
+//   - cpt: the chapter
+func showProgress(i, total int, cpt chapter) {
+	if quiet {
+		return
+	}
+	line := msg("progress_chapter", i+1, total, cpt.title, pageRangeText(cpt))
+	if !isTerminal(os.Stderr) {
+		fmt.Fprintln(messageOutput, line)
+		return
+	}
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	clearProgressLocked(os.Stderr)
+	line = runewidth.Truncate(line, progressWidth, "…")
+	fmt.Fprint(os.Stderr, line)
+	progressShown = runewidth.StringWidth(line)
+}
+
+// endProgress removes the progress line once an export is done.
+func endProgress() {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	clearProgressLocked(os.Stderr)
+}
+
+// printChapterDuration prints how long the export of a chapter took, with --verbose.
+func printChapterDuration(title string, d time.Duration) {
+	if verbose {
+		printMsg("chapter_duration", title, d.Round(time.Millisecond))
+	}
+}
+
+// printConfiguration prints the pdfcpu version and the settings the source is read with, for --verbose.
+func printConfiguration() {
+	conf := sourceConfiguration()
+	validation := "relaxed"
+	if conf.ValidationMode == model.ValidationStrict {
+		validation = "strict"
+	}
+	printMsg("pdfcpu_configuration", model.VersionStr, validation, conf.Optimize, conf.WriteObjectStream, conf.WriteXRefStream)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// exitResplitRefused is the exit code used when the input is a chapter written by an earlier run.
+const exitResplitRefused = 13
+
+// Keys of the document information dictionary that mark a chapter file written by pdf-split.
+const (
+	provenanceSourceKey = "PdfSplitSource"
+	provenanceHashKey   = "PdfSplitSourceSHA256"
+	provenanceDateKey   = "PdfSplitDate"
+)
+
+// stampProvenance records the source in every chapter, for --provenance.
+var stampProvenance bool
+
+// producerOnce names this build in the Producer of the outputs once per run, see setProducer.
+var producerOnce sync.Once
+
+// provenance records which source a chapter file was split from, and when.
+// source is the file name of the input, or stdioPath for a source read from stdin; chapters of
+// earlier versions hold its absolute path. hash is the hex-encoded SHA-256 of the source, empty
+// for chapters of earlier versions.
+type provenance struct {
+	source string
+	hash   string
+	date   string
+}
+
+// runProvenance returns the provenance written into the chapters of this run with --provenance.
+// Only the file name of the source is recorded, so that chapters shared with others do not reveal
+// where the source was stored.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//
+// Returns:
+//   - *provenance: the provenance of the chapters, nil without --provenance
+//   - error: if the source cannot be hashed
+func runProvenance(inputFile *os.File) (*provenance, error) {
+	if !stampProvenance {
+		return nil, nil
+	}
+	hash, err := fileSHA256(inputFile.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash source: %w", err)
+	}
+	source := stdioPath
+	if !readsStdin() {
+		source = filepath.Base(inputFilePath)
+	}
+	producerOnce.Do(setProducer)
+	return &provenance{source: source, hash: hash, date: types.DateString(runClock.Now())}, nil
+}
+
+// setProducer adds the version of pdf-split to the Producer of every document written from now on.
+// pdfcpu replaces the Producer with "pdfcpu " and model.VersionStr whenever it writes a document,
+// so the version is appended to that string, before the first chapter is written and not while
+// workers write chapters; it keeps the pdfcpu version in front, which pdfcpu compares its
+// configuration with.
+func setProducer() {
+	model.VersionStr += "; pdf-split " + versionString()
+}
+
+// addProvenance records p in the document information dictionary of a trimmed chapter. Its
+// Producer names this build of pdf-split, see setProducer.
+func addProvenance(ctx *model.Context, p *provenance) error {
+	return pdfcpu.PropertiesAdd(ctx, map[string]string{
+		provenanceSourceKey: p.source,
+		provenanceHashKey:   p.hash,
+		provenanceDateKey:   p.date,
+	})
+}
+
+// readProvenance returns the provenance of a document, or nil if it was not written by pdf-split.
+func readProvenance(inputFile *os.File) *provenance {
+	var p provenance
+	_ = inspectSource(inputFile, func(ctx *model.Context) error {
+		if ctx.Info == nil {
+			return nil
+		}
+		info, err := ctx.DereferenceDict(*ctx.Info)
+		if err != nil || info == nil {
+			return nil
+		}
+		text := func(key string) string {
+			obj, err := ctx.Dereference(info[key])
+			if err != nil || obj == nil {
+				return ""
+			}
+			s, err := model.Text(obj)
+			if err != nil {
+				return ""
+			}
+			return strings.TrimSpace(s)
+		}
+		p = provenance{source: text(provenanceSourceKey), hash: text(provenanceHashKey), date: text(provenanceDateKey)}
+		return nil
+	})
+	if p.source == "" {
+		return nil
+	}
+	return &p
+}
+
+// displayDate returns the day the chapter was written, or the raw date if it cannot be parsed.
+func (p *provenance) displayDate() string {
+	if date, ok := types.DateTime(p.date, true); ok {
+		return date.Format("2006-01-02")
+	}
+	if p.date == "" {
+		return "?"
+	}
+	return p.date
+}
+
+// checkResplit refuses an input that is a chapter written by an earlier run with --provenance, which
+// would be split into its sub-headings. --allow-resplit splits it anyway, and --resplit splits its
+// source instead: the file of the recorded name in the directory of the input, if it still has the
+// recorded SHA-256.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//
+// Returns:
+//   - string: path of the source to split instead, empty to split the input
+//   - error: ending the run with exitResplitRefused if the input is refused
+func checkResplit(inputFile *os.File) (string, error) {
+	p := readProvenance(inputFile)
+	if p == nil || allowResplit {
+		return "", nil
+	}
+	name := filepath.Base(inputFile.Name())
+	if !resplit {
+		return "", exitWith(exitResplitRefused, errors.New(msg("resplit_refused", p.displayDate(), p.source)))
+	}
+	if p.source == stdioPath {
+		return "", exitWith(exitResplitRefused, errors.New(msg("resplit_stdin", name)))
+	}
+	source := p.source
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(filepath.Dir(inputFile.Name()), source)
+	}
+	if info, err := os.Stat(source); err != nil || info.IsDir() {
+		return "", exitWith(exitResplitRefused, errors.New(msg("resplit_missing", name, source)))
+	}
+	if p.hash != "" {
+		if hash, err := fileSHA256(source); err != nil || hash != p.hash {
+			return "", exitWith(exitResplitRefused, errors.New(msg("resplit_changed", name, source)))
+		}
+	}
+	printMsg("resplit_source", name, p.displayDate(), source)
+	return source, nil
+}
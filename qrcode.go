@@ -0,0 +1,1242 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// Finder pattern matching tolerances, relative to the module width: the deviation of a single
+// run along a row or column, and along the diagonal.
+const (
+	qrFinderVariance         = 0.5
+	qrFinderDiagonalVariance = 0.75
+)
+
+// qrMaxFinders is the number of finder pattern candidates, the most often seen first, that are
+// combined into the corners of QR codes.
+const qrMaxFinders = 12
+
+// qrMaxCorners is the number of corner combinations tried per image, the most regular first.
+const qrMaxCorners = 5
+
+// qrAlignmentMinMatch is the number of the 25 modules of an alignment pattern that must be read
+// as expected for it to be used.
+const qrAlignmentMinMatch = 22
+
+// qrAlphanumeric are the characters of the alphanumeric mode, by value.
+const qrAlphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// Modes of the segments of a QR code.
+const (
+	qrModeTerminator   = 0x0
+	qrModeNumeric      = 0x1
+	qrModeAlphanumeric = 0x2
+	qrModeStructured   = 0x3
+	qrModeByte         = 0x4
+	qrModeFNC1First    = 0x5
+	qrModeECI          = 0x7
+	qrModeKanji        = 0x8
+	qrModeFNC1Second   = 0x9
+)
+
+// Character sets of ECI segments that change how bytes are decoded.
+const (
+	qrECIShiftJIS = 20
+	qrECIUTF8     = 26
+)
+
+// Sizes of QR codes in modules: versions 1 and 40, and version 7, the first with version
+// information.
+const (
+	qrMinDimension       = 21
+	qrMaxDimension       = 177
+	qrVersionInfoMinSize = 45
+)
+
+// qrLevels maps the error correction bits of the format information to the rows of the block
+// tables: L, M, Q and H.
+var qrLevels = [4]int{1, 0, 3, 2}
+
+// qrECCodewords is the number of error correction codewords per block, by level and version.
+var qrECCodewords = [4][40]int{
+	{7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// qrBlocks is the number of error correction blocks, by level and version. The codewords are
+// shared out so that the blocks differ by at most one data codeword, the shorter ones first.
+var qrBlocks = [4][40]int{
+	{1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// gfExp and gfLog are the powers of the generator 2 of GF(256) with the QR code polynomial
+// x^8+x^4+x^3+x^2+1, twice over so that sums of logarithms need no modulo, and their logarithms.
+var gfExp, gfLog = galoisTables()
+
+// galoisTables computes gfExp and gfLog.
+func galoisTables() ([510]byte, [256]int) {
+	var exp [510]byte
+	var log [256]int
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i], exp[i+255] = byte(x), byte(x)
+		log[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	return exp, log
+}
+
+// gfMul multiplies in GF(256).
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+// gfDiv divides a by the non-zero b in GF(256).
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+255-gfLog[b]]
+}
+
+// gfPoly evaluates a polynomial over GF(256), lowest degree first, at x.
+func gfPoly(poly []byte, x byte) byte {
+	var y byte
+	for i := len(poly) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ poly[i]
+	}
+	return y
+}
+
+// qrDecoded is the content of a QR code, and how many codewords its error correction repaired
+// out of the number it could have.
+type qrDecoded struct {
+	value       string
+	corrected   int
+	correctable int
+}
+
+// readQRCode looks for QR codes in an image and decodes the first one that can be read. Its
+// confidence is the share of its error correction left unused: lines is the number of codewords
+// the error correction could repair and votes the number of them not needed for the codewords
+// read wrong. A code whose finder patterns were found but that could not be decoded has one
+// line and no value.
+func readQRCode(img image.Image) barcodeRead {
+	m := binarize(img)
+	if m == nil {
+		return barcodeRead{}
+	}
+	candidates := qrCornerCandidates(m.findFinders())
+	for _, corners := range candidates {
+		if decoded, ok := m.decodeQR(corners); ok {
+			return barcodeRead{value: decoded.value, votes: decoded.correctable - decoded.corrected, lines: decoded.correctable, qr: true}
+		}
+	}
+	if len(candidates) > 0 {
+		return barcodeRead{lines: 1, qr: true}
+	}
+	return barcodeRead{}
+}
+
+// bitMatrix is a binarized image, or the modules of a QR code, true for dark.
+type bitMatrix struct {
+	width, height int
+	bits          []bool
+}
+
+// dark reports whether the pixel at x, y is dark; pixels outside the matrix are not.
+func (m *bitMatrix) dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < m.width && y < m.height && m.bits[y*m.width+x]
+}
+
+// light reports whether the pixel at x, y is light; pixels outside the matrix are not.
+func (m *bitMatrix) light(x, y int) bool {
+	return x >= 0 && y >= 0 && x < m.width && y < m.height && !m.bits[y*m.width+x]
+}
+
+// binarize converts an image to gray and splits its pixels into dark and light at the threshold
+// that separates its gray levels best (Otsu's method). An image without the contrast of
+// barcodeMinContrast gives nil.
+func binarize(img image.Image) *bitMatrix {
+	gray := grayImage(img)
+	b := gray.Bounds()
+	var histogram [256]int
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for _, v := range gray.Pix[gray.PixOffset(b.Min.X, y):][:b.Dx()] {
+			histogram[v]++
+		}
+	}
+	lo, hi := 0, 255
+	for lo < 255 && histogram[lo] == 0 {
+		lo++
+	}
+	for hi > 0 && histogram[hi] == 0 {
+		hi--
+	}
+	if hi-lo < barcodeMinContrast {
+		return nil
+	}
+
+	threshold := otsuThreshold(histogram)
+	m := &bitMatrix{width: b.Dx(), height: b.Dy(), bits: make([]bool, b.Dx()*b.Dy())}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := m.bits[(y-b.Min.Y)*m.width:][:m.width]
+		for x, v := range gray.Pix[gray.PixOffset(b.Min.X, y):][:b.Dx()] {
+			row[x] = v <= threshold
+		}
+	}
+	return m
+}
+
+// grayImage returns the gray levels of an image, taking the luma of JPEG images as it is.
+func grayImage(img image.Image) *image.Gray {
+	switch src := img.(type) {
+	case *image.Gray:
+		return src
+	case *image.YCbCr:
+		b := src.Bounds()
+		gray := image.NewGray(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			copy(gray.Pix[gray.PixOffset(b.Min.X, y):][:b.Dx()], src.Y[src.YOffset(b.Min.X, y):])
+		}
+		return gray
+	}
+	gray := image.NewGray(img.Bounds())
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+	return gray
+}
+
+// otsuThreshold returns the gray level that splits a histogram into the two classes with the
+// largest variance between them; levels up to it are dark.
+func otsuThreshold(histogram [256]int) uint8 {
+	var total, sum float64
+	for v, n := range histogram {
+		total += float64(n)
+		sum += float64(v * n)
+	}
+	var below, sumBelow, best float64
+	var threshold int
+	for v, n := range histogram {
+		below += float64(n)
+		if below == 0 {
+			continue
+		}
+		above := total - below
+		if above == 0 {
+			break
+		}
+		sumBelow += float64(v * n)
+		diff := sumBelow/below - (sum-sumBelow)/above
+		if between := below * above * diff * diff; between > best {
+			best, threshold = between, v
+		}
+	}
+	return uint8(threshold)
+}
+
+// qrPoint is a position in an image, in pixels: pixel x, y covers [x, x+1) × [y, y+1).
+type qrPoint struct {
+	x, y float64
+}
+
+// qrFinder is a candidate finder pattern: its center, its module width in pixels, and the number
+// of rows it was found on.
+type qrFinder struct {
+	qrPoint
+	module float64
+	count  int
+}
+
+// finderRatio reports whether five runs have the widths 1, 1, 3, 1, 1 of a finder pattern, each
+// within variance module widths.
+func finderRatio(counts [5]int, variance float64) bool {
+	var total int
+	for _, c := range counts {
+		if c == 0 {
+			return false
+		}
+		total += c
+	}
+	if total < 7 {
+		return false
+	}
+	module := float64(total) / 7
+	for i, c := range counts {
+		want, limit := module, module*variance
+		if i == 2 {
+			want, limit = 3*module, 3*limit
+		}
+		if math.Abs(float64(c)-want) >= limit {
+			return false
+		}
+	}
+	return true
+}
+
+// finderRuns counts the five runs of a finder pattern on the line through the dark pixel x, y in
+// direction dx, dy, the middle run to both sides. The outer runs may end at the image border.
+// Parameters:
+//   - x, y: a pixel of the middle run
+//   - dx, dy: one step along the line
+//   - maxCount: the longest run accepted
+//
+// Returns:
+//   - [5]int: the lengths of the runs, in steps
+//   - float64: the center of the middle run, in steps from x, y
+//   - bool: whether the line crosses five runs around x, y
+func (m *bitMatrix) finderRuns(x, y, dx, dy, maxCount int) ([5]int, float64, bool) {
+	var counts [5]int
+	back := 0
+	for m.dark(x-back*dx, y-back*dy) {
+		back++
+	}
+	if back == 0 {
+		return counts, 0, false
+	}
+	i := back
+	for ; m.light(x-i*dx, y-i*dy) && counts[1] <= maxCount; i++ {
+		counts[1]++
+	}
+	if !m.dark(x-i*dx, y-i*dy) || counts[1] > maxCount {
+		return counts, 0, false
+	}
+	for ; m.dark(x-i*dx, y-i*dy) && counts[0] <= maxCount; i++ {
+		counts[0]++
+	}
+	if counts[0] > maxCount {
+		return counts, 0, false
+	}
+
+	forward := 0
+	for m.dark(x+(forward+1)*dx, y+(forward+1)*dy) {
+		forward++
+	}
+	i = forward + 1
+	for ; m.light(x+i*dx, y+i*dy) && counts[3] <= maxCount; i++ {
+		counts[3]++
+	}
+	if !m.dark(x+i*dx, y+i*dy) || counts[3] > maxCount {
+		return counts, 0, false
+	}
+	for ; m.dark(x+i*dx, y+i*dy) && counts[4] <= maxCount; i++ {
+		counts[4]++
+	}
+	if counts[4] > maxCount {
+		return counts, 0, false
+	}
+	counts[2] = back + forward
+	// The middle run covers the steps -(back-1) to forward
+	return counts, float64(forward-back+2) / 2, true
+}
+
+// findFinders scans every row of the image for the 1:1:3:1:1 runs of finder patterns and
+// confirms each along its column, its row again and its diagonal. Hits on the same pattern in
+// several rows are merged.
+func (m *bitMatrix) findFinders() []qrFinder {
+	var finders []qrFinder
+	runs := make([]int, 0, 64)
+	for y := 0; y < m.height; y++ {
+		// The runs of the row, starting with a possibly empty light run
+		runs = append(runs[:0], 0)
+		dark := false
+		for _, d := range m.bits[y*m.width:][:m.width] {
+			if d != dark {
+				dark = !dark
+				runs = append(runs, 0)
+			}
+			runs[len(runs)-1]++
+		}
+		start := runs[0]
+		for i := 1; i+4 < len(runs); i += 2 {
+			counts := [5]int(runs[i : i+5])
+			if finderRatio(counts, qrFinderVariance) {
+				x := float64(start+counts[0]+counts[1]) + float64(counts[2])/2
+				if f, ok := m.confirmFinder(x, y, counts); ok {
+					finders = mergeFinder(finders, f)
+				}
+			}
+			start += runs[i] + runs[i+1]
+		}
+	}
+	return finders
+}
+
+// confirmFinder checks a finder pattern found on row y around x along its column, then along
+// its row and its diagonal through the center found on the column.
+func (m *bitMatrix) confirmFinder(x float64, y int, row [5]int) (qrFinder, bool) {
+	var total int
+	for _, c := range row {
+		total += c
+	}
+	vertical, offset, ok := m.finderRuns(int(x), y, 0, 1, total)
+	if !ok || !finderRatio(vertical, qrFinderVariance) {
+		return qrFinder{}, false
+	}
+	var verticalTotal int
+	for _, c := range vertical {
+		verticalTotal += c
+	}
+	// A square pattern has about the same size both ways
+	if 5*abs(verticalTotal-total) >= 2*total {
+		return qrFinder{}, false
+	}
+	cy := float64(y) + offset
+	horizontal, offset, ok := m.finderRuns(int(x), int(cy), 1, 0, total)
+	if !ok || !finderRatio(horizontal, qrFinderVariance) {
+		return qrFinder{}, false
+	}
+	cx := float64(int(x)) + offset
+	diagonal, _, ok := m.finderRuns(int(cx), int(cy), 1, 1, total)
+	if !ok || !finderRatio(diagonal, qrFinderDiagonalVariance) {
+		return qrFinder{}, false
+	}
+	var horizontalTotal int
+	for _, c := range horizontal {
+		horizontalTotal += c
+	}
+	return qrFinder{qrPoint{cx, cy}, float64(horizontalTotal+verticalTotal) / 14, 1}, true
+}
+
+// mergeFinder adds a finder pattern to those found, averaging it into one at the same place
+// and of about the same size.
+func mergeFinder(finders []qrFinder, f qrFinder) []qrFinder {
+	for i, g := range finders {
+		if math.Abs(g.x-f.x) <= g.module && math.Abs(g.y-f.y) <= g.module && math.Abs(g.module-f.module) <= max(1, g.module) {
+			n := float64(g.count)
+			finders[i] = qrFinder{
+				qrPoint{(g.x*n + f.x) / (n + 1), (g.y*n + f.y) / (n + 1)},
+				(g.module*n + f.module) / (n + 1),
+				g.count + 1,
+			}
+			return finders
+		}
+	}
+	return append(finders, f)
+}
+
+// qrCorners are the centers of the three finder patterns of a QR code and its module width.
+type qrCorners struct {
+	topLeft, topRight, bottomLeft qrPoint
+	module                        float64
+}
+
+// qrCornerCandidates combines the finder patterns seen most often into the corners of QR codes,
+// those closest to a right isosceles triangle first. Patterns seen on a single row only are
+// left out if there are enough others.
+func qrCornerCandidates(finders []qrFinder) []qrCorners {
+	sort.SliceStable(finders, func(i, j int) bool { return finders[i].count > finders[j].count })
+	if len(finders) > 3 && finders[2].count > 1 {
+		for i, f := range finders {
+			if f.count == 1 {
+				finders = finders[:i]
+				break
+			}
+		}
+	}
+	finders = finders[:min(len(finders), qrMaxFinders)]
+
+	type candidate struct {
+		corners qrCorners
+		score   float64
+	}
+	var candidates []candidate
+	for i := range finders {
+		for j := i + 1; j < len(finders); j++ {
+			for k := j + 1; k < len(finders); k++ {
+				if corners, score, ok := arrangeCorners(finders[i], finders[j], finders[k]); ok {
+					candidates = append(candidates, candidate{corners, score})
+				}
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score < candidates[j].score })
+	var corners []qrCorners
+	for _, c := range candidates[:min(len(candidates), qrMaxCorners)] {
+		corners = append(corners, c.corners)
+	}
+	return corners
+}
+
+// arrangeCorners takes three finder patterns as the corners of a QR code: the top left one is
+// opposite the longest side, and the top right one follows it clockwise. The score is lower the
+// closer they are to a right isosceles triangle.
+// Returns:
+//   - qrCorners: the corners of the code
+//   - float64: the score
+//   - bool: whether the patterns can be the corners of one code
+func arrangeCorners(a, b, c qrFinder) (qrCorners, float64, bool) {
+	smallest, largest := min(a.module, b.module, c.module), max(a.module, b.module, c.module)
+	if largest > 1.5*smallest {
+		return qrCorners{}, 0, false
+	}
+	ab, ac, bc := distance(a.qrPoint, b.qrPoint), distance(a.qrPoint, c.qrPoint), distance(b.qrPoint, c.qrPoint)
+	topLeft, p, q := a, b, c
+	switch {
+	case ac >= ab && ac >= bc:
+		topLeft, p, q = b, a, c
+	case ab >= ac && ab >= bc:
+		topLeft, p, q = c, a, b
+	}
+	module := (a.module + b.module + c.module) / 3
+	px, py := p.x-topLeft.x, p.y-topLeft.y
+	qx, qy := q.x-topLeft.x, q.y-topLeft.y
+	legP, legQ := math.Hypot(px, py), math.Hypot(qx, qy)
+	// The centers of the finder patterns are at least 14 modules apart
+	if min(legP, legQ) < 10*module {
+		return qrCorners{}, 0, false
+	}
+	legs := max(legP, legQ) / min(legP, legQ)
+	cos := (px*qx + py*qy) / (legP * legQ)
+	if legs > 1.5 || math.Abs(cos) > 0.35 {
+		return qrCorners{}, 0, false
+	}
+	// With y pointing down, the turn from top right to bottom left is positive
+	if px*qy-py*qx < 0 {
+		p, q = q, p
+	}
+	return qrCorners{topLeft.qrPoint, p.qrPoint, q.qrPoint, module}, legs - 1 + math.Abs(cos), true
+}
+
+// distance returns the distance between two points.
+func distance(a, b qrPoint) float64 {
+	return math.Hypot(a.x-b.x, a.y-b.y)
+}
+
+// dimension estimates the number of modules on a side of the code from the distances between
+// its finder patterns, rounded to a valid size of 4 × version + 17.
+func (c qrCorners) dimension() int {
+	modules := (distance(c.topLeft, c.topRight) + distance(c.topLeft, c.bottomLeft)) / 2 / c.module
+	d := int(math.Round(modules)) + 7
+	switch d % 4 {
+	case 0:
+		d++
+	case 2:
+		d--
+	case 3:
+		d -= 2
+	}
+	return d
+}
+
+// decodeQR samples the modules of the QR code at the given corners and decodes them. As the size
+// estimated from the corners can be off by a version, the versions around it are tried as well;
+// a version read from the version information of the code is tried at once. The modules are
+// located with the alignment pattern where there is one, and from the finder patterns alone if
+// that fails.
+func (m *bitMatrix) decodeQR(c qrCorners) (qrDecoded, bool) {
+	estimate := c.dimension()
+	for _, dimension := range []int{estimate, estimate + 4, estimate - 4} {
+		if dimension < qrMinDimension || dimension > qrMaxDimension {
+			continue
+		}
+		for _, alignment := range []bool{true, false} {
+			grid := m.sampleQR(c, dimension, alignment)
+			if grid == nil {
+				continue
+			}
+			if version, ok := qrVersionInfo(grid); ok && 4*version+17 != dimension {
+				if grid = m.sampleQR(c, 4*version+17, alignment); grid == nil {
+					continue
+				}
+			}
+			if decoded, ok := decodeQRGrid(grid); ok {
+				return decoded, true
+			}
+			if dimension == qrMinDimension {
+				// Version 1 has no alignment pattern
+				break
+			}
+		}
+	}
+	return qrDecoded{}, false
+}
+
+// qrTransform is a perspective transform as the 3×3 matrix of homogeneous coordinates, by rows.
+type qrTransform [9]float64
+
+// apply transforms the point x, y.
+func (t qrTransform) apply(x, y float64) qrPoint {
+	w := t[6]*x + t[7]*y + t[8]
+	return qrPoint{(t[0]*x + t[1]*y + t[2]) / w, (t[3]*x + t[4]*y + t[5]) / w}
+}
+
+// times returns the transform that applies u, then t.
+func (t qrTransform) times(u qrTransform) qrTransform {
+	var p qrTransform
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			p[3*r+c] = t[3*r]*u[c] + t[3*r+1]*u[3+c] + t[3*r+2]*u[6+c]
+		}
+	}
+	return p
+}
+
+// adjugate returns the adjugate of t, which is its inverse up to a factor that homogeneous
+// coordinates ignore.
+func (t qrTransform) adjugate() qrTransform {
+	a, b, c, d, e, f, g, h, i := t[0], t[1], t[2], t[3], t[4], t[5], t[6], t[7], t[8]
+	return qrTransform{
+		e*i - f*h, c*h - b*i, b*f - c*e,
+		f*g - d*i, a*i - c*g, c*d - a*f,
+		d*h - e*g, b*g - a*h, a*e - b*d,
+	}
+}
+
+// squareToQuad returns the transform of the unit square onto a quadrilateral: the corners
+// (0, 0), (1, 0), (1, 1) and (0, 1) onto p0 to p3.
+func squareToQuad(p0, p1, p2, p3 qrPoint) (qrTransform, bool) {
+	dx1, dx2, dx3 := p1.x-p2.x, p3.x-p2.x, p0.x-p1.x+p2.x-p3.x
+	dy1, dy2, dy3 := p1.y-p2.y, p3.y-p2.y, p0.y-p1.y+p2.y-p3.y
+	denominator := dx1*dy2 - dx2*dy1
+	if denominator == 0 {
+		return qrTransform{}, false
+	}
+	g := (dx3*dy2 - dx2*dy3) / denominator
+	h := (dx1*dy3 - dx3*dy1) / denominator
+	return qrTransform{
+		p1.x - p0.x + g*p1.x, p3.x - p0.x + h*p3.x, p0.x,
+		p1.y - p0.y + g*p1.y, p3.y - p0.y + h*p3.y, p0.y,
+		g, h, 1,
+	}, true
+}
+
+// sampleQR reads the modules of a QR code of dimension modules a side at the center of each.
+// The finder patterns fix three corners of the code; the fourth is the alignment pattern in the
+// bottom right corner if alignment is set and it is found, or else completes a parallelogram.
+// Returns nil if the corners do not span a code.
+func (m *bitMatrix) sampleQR(c qrCorners, dimension int, alignment bool) *bitMatrix {
+	d := float64(dimension)
+	corner := qrPoint{c.topRight.x - c.topLeft.x + c.bottomLeft.x, c.topRight.y - c.topLeft.y + c.bottomLeft.y}
+	cornerModule := d - 3.5
+	if alignment && dimension > qrMinDimension {
+		// The alignment pattern is 3 modules closer to the top left than the fourth corner
+		u := (d - 10) / (d - 7)
+		estimate := qrPoint{c.topLeft.x + u*(corner.x-c.topLeft.x), c.topLeft.y + u*(corner.y-c.topLeft.y)}
+		right := qrPoint{(c.topRight.x - c.topLeft.x) / (d - 7), (c.topRight.y - c.topLeft.y) / (d - 7)}
+		down := qrPoint{(c.bottomLeft.x - c.topLeft.x) / (d - 7), (c.bottomLeft.y - c.topLeft.y) / (d - 7)}
+		if p, ok := m.findAlignment(estimate, right, down, c.module); ok {
+			corner, cornerModule = p, d-6.5
+		}
+	}
+	toImage, ok := squareToQuad(c.topLeft, c.topRight, corner, c.bottomLeft)
+	if !ok {
+		return nil
+	}
+	fromModules, ok := squareToQuad(qrPoint{3.5, 3.5}, qrPoint{d - 3.5, 3.5}, qrPoint{cornerModule, cornerModule}, qrPoint{3.5, d - 3.5})
+	if !ok {
+		return nil
+	}
+	t := toImage.times(fromModules.adjugate())
+
+	grid := &bitMatrix{width: dimension, height: dimension, bits: make([]bool, dimension*dimension)}
+	for y := 0; y < dimension; y++ {
+		for x := 0; x < dimension; x++ {
+			p := t.apply(float64(x)+0.5, float64(y)+0.5)
+			if math.IsNaN(p.x) || math.IsNaN(p.y) || math.IsInf(p.x, 0) || math.IsInf(p.y, 0) {
+				return nil
+			}
+			grid.bits[y*dimension+x] = m.dark(int(math.Floor(p.x)), int(math.Floor(p.y)))
+		}
+	}
+	return grid
+}
+
+// findAlignment looks for an alignment pattern around where the finder patterns place it, by
+// matching its 5×5 modules, a dark module in a light ring in a dark ring, at every pixel within
+// four modules of the estimate. right and down are the steps of one module along the rows and
+// the columns of the code. The center of the pixels that match best is taken.
+func (m *bitMatrix) findAlignment(estimate, right, down qrPoint, module float64) (qrPoint, bool) {
+	radius := int(math.Ceil(4 * module))
+	best := 0
+	var sumX, sumY, n float64
+	for y := int(estimate.y) - radius; y <= int(estimate.y)+radius; y++ {
+		for x := int(estimate.x) - radius; x <= int(estimate.x)+radius; x++ {
+			var score int
+			for j := -2; j <= 2; j++ {
+				for i := -2; i <= 2; i++ {
+					px := float64(x) + 0.5 + float64(i)*right.x + float64(j)*down.x
+					py := float64(y) + 0.5 + float64(i)*right.y + float64(j)*down.y
+					if m.dark(int(math.Floor(px)), int(math.Floor(py))) == (max(abs(i), abs(j)) != 1) {
+						score++
+					}
+				}
+			}
+			switch {
+			case score > best:
+				best, sumX, sumY, n = score, float64(x)+0.5, float64(y)+0.5, 1
+			case score == best:
+				sumX, sumY, n = sumX+float64(x)+0.5, sumY+float64(y)+0.5, n+1
+			}
+		}
+	}
+	if best < qrAlignmentMinMatch {
+		return qrPoint{}, false
+	}
+	return qrPoint{sumX / n, sumY / n}, true
+}
+
+// abs returns the absolute value of an integer.
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// decodeQRGrid decodes the modules of a QR code: it reads the format information, unmasks and
+// reads the codewords, repairs them block by block and decodes the segments of the data.
+func decodeQRGrid(grid *bitMatrix) (qrDecoded, bool) {
+	version := (grid.width - 17) / 4
+	format, ok := qrFormatInfo(grid)
+	if !ok {
+		return qrDecoded{}, false
+	}
+	codewords := readQRCodewords(grid, qrFunctionModules(version), format&7)
+	data, corrected, correctable, ok := correctQRBlocks(codewords, version, qrLevels[format>>3])
+	if !ok {
+		return qrDecoded{}, false
+	}
+	value, ok := decodeQRSegments(data, version)
+	return qrDecoded{value, corrected, correctable}, ok
+}
+
+// qrFormatCode returns the 15 bits of format information for its 5 data bits, the error
+// correction level and the mask: BCH coded and masked.
+func qrFormatCode(data int) int {
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// qrVersionCode returns the 18 bits of version information of a version: BCH coded.
+func qrVersionCode(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1f25
+	}
+	return version<<12 | rem
+}
+
+// qrFormatInfo reads both copies of the format information and returns the data of the code
+// closest to either, if it differs in at most 3 bits.
+func qrFormatInfo(grid *bitMatrix) (int, bool) {
+	d := grid.width
+	var first, second int
+	for i := 0; i <= 5; i++ {
+		first |= bit(grid.dark(8, i)) << i
+	}
+	first |= bit(grid.dark(8, 7))<<6 | bit(grid.dark(8, 8))<<7 | bit(grid.dark(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		first |= bit(grid.dark(14-i, 8)) << i
+	}
+	for i := 0; i < 8; i++ {
+		second |= bit(grid.dark(d-1-i, 8)) << i
+	}
+	for i := 8; i < 15; i++ {
+		second |= bit(grid.dark(8, d-15+i)) << i
+	}
+	return closestCode(first, second, 0, 31, qrFormatCode)
+}
+
+// qrVersionInfo reads both copies of the version information of a code of version 7 or later
+// and returns the version of the code closest to either, if it differs in at most 3 bits.
+func qrVersionInfo(grid *bitMatrix) (int, bool) {
+	d := grid.width
+	if d < qrVersionInfoMinSize {
+		return 0, false
+	}
+	var first, second int
+	for i := 0; i < 18; i++ {
+		a, b := d-11+i%3, i/3
+		first |= bit(grid.dark(a, b)) << i
+		second |= bit(grid.dark(b, a)) << i
+	}
+	return closestCode(first, second, 7, 40, qrVersionCode)
+}
+
+// closestCode returns the value among from to to whose code differs in the fewest bits from
+// either of two reads, if in at most 3.
+func closestCode(first, second, from, to int, code func(int) int) (int, bool) {
+	best, bestDistance := -1, 4
+	for value := from; value <= to; value++ {
+		c := code(value)
+		for _, read := range []int{first, second} {
+			if distance := bits.OnesCount(uint(c ^ read)); distance < bestDistance {
+				best, bestDistance = value, distance
+			}
+		}
+	}
+	return best, best >= 0
+}
+
+// bit returns 1 for true.
+func bit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// qrAlignmentPositions returns the rows, and columns, of the centers of the alignment patterns
+// of a version.
+func qrAlignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := 26
+	if version != 32 {
+		step = (version*4 + n*2 + 1) / (n*2 - 2) * 2
+	}
+	positions := make([]int, n)
+	positions[0] = 6
+	for i, pos := n-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// qrFunctionModules marks the modules of a version that hold no data: the finder patterns with
+// their separators and the format information, the timing patterns, the alignment patterns and
+// the version information.
+func qrFunctionModules(version int) []bool {
+	d := version*4 + 17
+	function := make([]bool, d*d)
+	mark := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				function[y*d+x] = true
+			}
+		}
+	}
+	mark(0, 0, 9, 9)
+	mark(d-8, 0, 8, 9)
+	mark(0, d-8, 9, 8)
+	mark(6, 0, 1, d)
+	mark(0, 6, d, 1)
+	positions := qrAlignmentPositions(version)
+	last := len(positions) - 1
+	for i, cy := range positions {
+		for j, cx := range positions {
+			// The corners with finder patterns have none
+			if i == 0 && (j == 0 || j == last) || i == last && j == 0 {
+				continue
+			}
+			mark(cx-2, cy-2, 5, 5)
+		}
+	}
+	if version >= 7 {
+		mark(d-11, 0, 3, 6)
+		mark(0, d-11, 6, 3)
+	}
+	return function
+}
+
+// qrMasked reports whether a mask pattern inverts the module at x, y.
+func qrMasked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// readQRCodewords unmasks the data modules and reads them as codewords, in columns of two from
+// the bottom right, upwards and downwards in turn, skipping the vertical timing pattern. The
+// remainder bits that do not fill a codeword are dropped.
+func readQRCodewords(grid *bitMatrix, function []bool, mask int) []byte {
+	d := grid.width
+	var codewords []byte
+	var current byte
+	var n int
+	for right := d - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < d; vert++ {
+			y := vert
+			if upward {
+				y = d - 1 - vert
+			}
+			for x := right; x >= right-1; x-- {
+				if function[y*d+x] {
+					continue
+				}
+				current = current<<1 | byte(bit(grid.dark(x, y) != qrMasked(mask, x, y)))
+				if n++; n == 8 {
+					codewords = append(codewords, current)
+					current, n = 0, 0
+				}
+			}
+		}
+	}
+	return codewords
+}
+
+// qrRawCodewords returns the number of codewords of a version, data and error correction.
+func qrRawCodewords(version int) int {
+	modules := (16*version+128)*version + 64
+	if version >= 2 {
+		n := version/7 + 2
+		modules -= (25*n-10)*n - 55
+		if version >= 7 {
+			modules -= 36
+		}
+	}
+	return modules / 8
+}
+
+// correctQRBlocks splits the interleaved codewords of a code into their blocks and repairs each
+// with its error correction codewords.
+// Parameters:
+//   - codewords: the codewords as read
+//   - version: the version of the code
+//   - level: the error correction level, the row of the block tables
+//
+// Returns:
+//   - []byte: the data codewords of all blocks
+//   - int: the number of codewords repaired
+//   - int: the number of codewords that could have been repaired
+//   - bool: whether every block could be repaired
+func correctQRBlocks(codewords []byte, version, level int) ([]byte, int, int, bool) {
+	blocks, ec := qrBlocks[level][version-1], qrECCodewords[level][version-1]
+	raw := qrRawCodewords(version)
+	if len(codewords) < raw {
+		return nil, 0, 0, false
+	}
+	short := blocks - raw%blocks
+	shortData := raw/blocks - ec
+
+	// The data codewords are dealt out to the blocks in turn, the long blocks taking one more,
+	// and then the error correction codewords
+	blockCodewords := make([][]byte, blocks)
+	var i int
+	for k := 0; k <= shortData; k++ {
+		for b := range blockCodewords {
+			if k == shortData && b < short {
+				continue
+			}
+			blockCodewords[b] = append(blockCodewords[b], codewords[i])
+			i++
+		}
+	}
+	for k := 0; k < ec; k++ {
+		for b := range blockCodewords {
+			blockCodewords[b] = append(blockCodewords[b], codewords[i])
+			i++
+		}
+	}
+
+	var data []byte
+	var corrected, correctable int
+	for _, block := range blockCodewords {
+		n, ok := correctReedSolomon(block, ec)
+		if !ok {
+			return nil, 0, 0, false
+		}
+		corrected += n
+		correctable += ec / 2
+		data = append(data, block[:len(block)-ec]...)
+	}
+	return data, corrected, correctable, true
+}
+
+// correctReedSolomon repairs a block of codewords in place with its ec error correction
+// codewords: it finds the error locator with the Berlekamp-Massey algorithm, the positions of
+// the errors as its roots and their values with Forney's formula.
+// Returns:
+//   - int: the number of codewords repaired
+//   - bool: whether the block could be repaired
+func correctReedSolomon(block []byte, ec int) (int, bool) {
+	syndromes := make([]byte, ec)
+	clean := true
+	for j := range syndromes {
+		var s byte
+		for _, c := range block {
+			s = gfMul(s, gfExp[j]) ^ c
+		}
+		syndromes[j] = s
+		clean = clean && s == 0
+	}
+	if clean {
+		return 0, true
+	}
+
+	// The error locator, lowest degree first
+	locator, previous := []byte{1}, []byte{1}
+	errors, shift, previousDiscrepancy := 0, 1, byte(1)
+	for k := 0; k < ec; k++ {
+		discrepancy := syndromes[k]
+		for i := 1; i <= errors && i < len(locator); i++ {
+			discrepancy ^= gfMul(locator[i], syndromes[k-i])
+		}
+		if discrepancy == 0 {
+			shift++
+			continue
+		}
+		next := append([]byte(nil), locator...)
+		for len(next) < len(previous)+shift {
+			next = append(next, 0)
+		}
+		factor := gfDiv(discrepancy, previousDiscrepancy)
+		for i, c := range previous {
+			next[i+shift] ^= gfMul(factor, c)
+		}
+		if 2*errors <= k {
+			errors, previous, previousDiscrepancy, shift = k+1-errors, locator, discrepancy, 1
+		} else {
+			shift++
+		}
+		locator = next
+	}
+	if 2*errors > ec {
+		return 0, false
+	}
+
+	// An error in the codeword of power p, counted from the last one, is a root 2^-p
+	var powers []int
+	for p := 0; p < len(block); p++ {
+		if gfPoly(locator, gfExp[(255-p)%255]) == 0 {
+			powers = append(powers, p)
+		}
+	}
+	if len(powers) != errors {
+		return 0, false
+	}
+	evaluator := make([]byte, ec)
+	for i := range evaluator {
+		for j := 0; j <= i && j < len(locator); j++ {
+			evaluator[i] ^= gfMul(locator[j], syndromes[i-j])
+		}
+	}
+	for _, p := range powers {
+		inverse := gfExp[(255-p)%255]
+		var derivative byte
+		for i := 1; i < len(locator); i += 2 {
+			derivative ^= gfMul(locator[i], gfExp[gfLog[inverse]*(i-1)%255])
+		}
+		if derivative == 0 {
+			return 0, false
+		}
+		block[len(block)-1-p] ^= gfMul(gfExp[p], gfDiv(gfPoly(evaluator, inverse), derivative))
+	}
+	return errors, true
+}
+
+// qrBits reads a bit stream, most significant bit first.
+type qrBits struct {
+	data []byte
+	pos  int
+}
+
+// available returns the number of bits left.
+func (b *qrBits) available() int {
+	return len(b.data)*8 - b.pos
+}
+
+// read returns the next n bits, which must be available.
+func (b *qrBits) read(n int) int {
+	var v int
+	for ; n > 0; n-- {
+		v = v<<1 | int(b.data[b.pos/8]>>(7-b.pos%8))&1
+		b.pos++
+	}
+	return v
+}
+
+// qrCountBits returns the length of the character count of a mode in a version.
+func qrCountBits(mode, version int) int {
+	class := 0
+	switch {
+	case version >= 27:
+		class = 2
+	case version >= 10:
+		class = 1
+	}
+	switch mode {
+	case qrModeNumeric:
+		return [3]int{10, 12, 14}[class]
+	case qrModeAlphanumeric:
+		return [3]int{9, 11, 13}[class]
+	case qrModeByte:
+		return [3]int{8, 16, 16}[class]
+	default:
+		return [3]int{8, 10, 12}[class]
+	}
+}
+
+// decodeQRSegments decodes the segments of the data of a QR code into its text. Bytes are taken
+// as UTF-8, or as ISO 8859-1 if they are not valid UTF-8, unless an ECI names Shift JIS or UTF-8.
+// Structured append headers and FNC1 markers are skipped; the text of one code is returned.
+func decodeQRSegments(data []byte, version int) (string, bool) {
+	b := &qrBits{data: data}
+	var sb strings.Builder
+	eci := -1
+	for b.available() >= 4 {
+		mode := b.read(4)
+		var count int
+		switch mode {
+		case qrModeTerminator:
+			return sb.String(), sb.Len() > 0
+		case qrModeNumeric, qrModeAlphanumeric, qrModeByte, qrModeKanji:
+			n := qrCountBits(mode, version)
+			if b.available() < n {
+				return "", false
+			}
+			count = b.read(n)
+		}
+
+		switch mode {
+		case qrModeNumeric:
+			for ; count > 0; count -= 3 {
+				digits := min(count, 3)
+				n := [4]int{0, 4, 7, 10}[digits]
+				if b.available() < n {
+					return "", false
+				}
+				v := b.read(n)
+				if v >= [4]int{1, 10, 100, 1000}[digits] {
+					return "", false
+				}
+				s := strconv.Itoa(v)
+				sb.WriteString(strings.Repeat("0", digits-len(s)) + s)
+			}
+		case qrModeAlphanumeric:
+			for ; count > 0; count -= 2 {
+				if count == 1 {
+					if b.available() < 6 {
+						return "", false
+					}
+					v := b.read(6)
+					if v >= len(qrAlphanumeric) {
+						return "", false
+					}
+					sb.WriteByte(qrAlphanumeric[v])
+					break
+				}
+				if b.available() < 11 {
+					return "", false
+				}
+				v := b.read(11)
+				if v >= len(qrAlphanumeric)*len(qrAlphanumeric) {
+					return "", false
+				}
+				sb.WriteByte(qrAlphanumeric[v/len(qrAlphanumeric)])
+				sb.WriteByte(qrAlphanumeric[v%len(qrAlphanumeric)])
+			}
+		case qrModeByte:
+			if b.available() < 8*count {
+				return "", false
+			}
+			raw := make([]byte, count)
+			for i := range raw {
+				raw[i] = byte(b.read(8))
+			}
+			text, ok := qrText(raw, eci)
+			if !ok {
+				return "", false
+			}
+			sb.WriteString(text)
+		case qrModeKanji:
+			if b.available() < 13*count {
+				return "", false
+			}
+			raw := make([]byte, 0, 2*count)
+			for i := 0; i < count; i++ {
+				v := b.read(13)
+				code := v/0xc0<<8 | v%0xc0
+				if code < 0x1f00 {
+					code += 0x8140
+				} else {
+					code += 0xc140
+				}
+				raw = append(raw, byte(code>>8), byte(code))
+			}
+			text, ok := qrText(raw, qrECIShiftJIS)
+			if !ok {
+				return "", false
+			}
+			sb.WriteString(text)
+		case qrModeECI:
+			if b.available() < 8 {
+				return "", false
+			}
+			switch v := b.read(8); {
+			case v&0x80 == 0:
+				eci = v
+			case v&0xc0 == 0x80 && b.available() >= 8:
+				eci = (v&0x3f)<<8 | b.read(8)
+			case v&0xe0 == 0xc0 && b.available() >= 16:
+				eci = (v&0x1f)<<16 | b.read(16)
+			default:
+				return "", false
+			}
+		case qrModeStructured:
+			if b.available() < 16 {
+				return "", false
+			}
+			b.read(16)
+		case qrModeFNC1First:
+		case qrModeFNC1Second:
+			if b.available() < 8 {
+				return "", false
+			}
+			b.read(8)
+		default:
+			return "", false
+		}
+	}
+	return sb.String(), sb.Len() > 0
+}
+
+// qrText decodes the bytes of a segment in the character set of an ECI, or of the data itself
+// if eci is -1 or names another character set.
+func qrText(raw []byte, eci int) (string, bool) {
+	switch {
+	case eci == qrECIShiftJIS:
+		text, err := japanese.ShiftJIS.NewDecoder().Bytes(raw)
+		return string(text), err == nil
+	case eci == qrECIUTF8, utf8.Valid(raw):
+		return string(raw), true
+	}
+	runes := make([]rune, len(raw))
+	for i, c := range raw {
+		runes[i] = rune(c)
+	}
+	return string(runes), true
+}
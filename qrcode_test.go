@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// qrBitWriter collects the bits of the data of a QR code.
+type qrBitWriter []bool
+
+// write appends the low n bits of v, most significant first.
+func (w *qrBitWriter) write(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*w = append(*w, v>>i&1 == 1)
+	}
+}
+
+// qrDataCodewords returns the number of data codewords of a version at a level.
+func qrDataCodewords(version, level int) int {
+	return qrRawCodewords(version) - qrBlocks[level][version-1]*qrECCodewords[level][version-1]
+}
+
+// reedSolomonRemainder returns the ec error correction codewords of data.
+func reedSolomonRemainder(data []byte, ec int) []byte {
+	// The generator polynomial (x-1)(x-2)...(x-2^(ec-1)), highest degree first without its
+	// leading 1
+	divisor := make([]byte, ec)
+	divisor[ec-1] = 1
+	root := byte(1)
+	for i := 0; i < ec; i++ {
+		for j := range divisor {
+			divisor[j] = gfMul(divisor[j], root)
+			if j+1 < ec {
+				divisor[j] ^= divisor[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	remainder := make([]byte, ec)
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[ec-1] = 0
+		for i := range remainder {
+			remainder[i] ^= gfMul(divisor[i], factor)
+		}
+	}
+	return remainder
+}
+
+// encodeQR builds the modules of a QR code holding text in one segment of the first of the
+// numeric, alphanumeric and byte modes that fits it, at the smallest version from minVersion
+// up that has room for it.
+// Parameters:
+//   - text: the content
+//   - level: the error correction level, the row of the block tables
+//   - minVersion: the smallest version to use
+//   - mask: the mask pattern
+//
+// Returns:
+//   - *bitMatrix: the modules, without a quiet zone
+func encodeQR(t *testing.T, text string, level, minVersion, mask int) *bitMatrix {
+	t.Helper()
+	mode := qrModeByte
+	switch {
+	case text != "" && strings.Trim(text, "0123456789") == "":
+		mode = qrModeNumeric
+	case strings.Trim(text, qrAlphanumeric) == "":
+		mode = qrModeAlphanumeric
+	}
+	var w qrBitWriter
+	version := minVersion
+	for ; version <= 40; version++ {
+		w = nil
+		w.write(mode, 4)
+		switch mode {
+		case qrModeNumeric:
+			w.write(len(text), qrCountBits(mode, version))
+			for i := 0; i < len(text); i += 3 {
+				chunk := text[i:min(i+3, len(text))]
+				v := 0
+				for _, c := range chunk {
+					v = v*10 + int(c-'0')
+				}
+				w.write(v, [4]int{0, 4, 7, 10}[len(chunk)])
+			}
+		case qrModeAlphanumeric:
+			w.write(len(text), qrCountBits(mode, version))
+			for i := 0; i < len(text); i += 2 {
+				v := strings.IndexByte(qrAlphanumeric, text[i])
+				if i+1 == len(text) {
+					w.write(v, 6)
+					break
+				}
+				w.write(v*45+strings.IndexByte(qrAlphanumeric, text[i+1]), 11)
+			}
+		default:
+			w.write(len(text), qrCountBits(mode, version))
+			for _, c := range []byte(text) {
+				w.write(int(c), 8)
+			}
+		}
+		if len(w) <= qrDataCodewords(version, level)*8 {
+			break
+		}
+	}
+	if version > 40 {
+		t.Fatalf("'%s' does not fit a QR code", text)
+	}
+
+	// Terminate and pad the data, and add the error correction of every block
+	capacity := qrDataCodewords(version, level)
+	w.write(0, min(4, capacity*8-len(w)))
+	w.write(0, (8-len(w)%8)%8)
+	data := make([]byte, 0, capacity)
+	for i := 0; i < len(w); i += 8 {
+		var b byte
+		for _, dark := range w[i : i+8] {
+			b = b<<1 | byte(bit(dark))
+		}
+		data = append(data, b)
+	}
+	for pad := byte(0xec); len(data) < capacity; pad ^= 0xec ^ 0x11 {
+		data = append(data, pad)
+	}
+	blocks, ec := qrBlocks[level][version-1], qrECCodewords[level][version-1]
+	raw := qrRawCodewords(version)
+	short, shortData := blocks-raw%blocks, raw/blocks-ec
+	var dataBlocks, ecBlocks [][]byte
+	for b := 0; b < blocks; b++ {
+		n := shortData
+		if b >= short {
+			n++
+		}
+		dataBlocks = append(dataBlocks, data[:n])
+		ecBlocks = append(ecBlocks, reedSolomonRemainder(data[:n], ec))
+		data = data[n:]
+	}
+	var codewords []byte
+	for i := 0; i <= shortData; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				codewords = append(codewords, block[i])
+			}
+		}
+	}
+	for i := 0; i < ec; i++ {
+		for _, block := range ecBlocks {
+			codewords = append(codewords, block[i])
+		}
+	}
+
+	// Draw the function patterns, then the masked data
+	d := version*4 + 17
+	grid := &bitMatrix{width: d, height: d, bits: make([]bool, d*d)}
+	set := func(x, y int, dark bool) {
+		if x >= 0 && y >= 0 && x < d && y < d {
+			grid.bits[y*d+x] = dark
+		}
+	}
+	for i := 0; i < d; i++ {
+		set(6, i, i%2 == 0)
+		set(i, 6, i%2 == 0)
+	}
+	for _, center := range [][2]int{{3, 3}, {d - 4, 3}, {3, d - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				ring := max(abs(dx), abs(dy))
+				set(center[0]+dx, center[1]+dy, ring != 2 && ring != 4)
+			}
+		}
+	}
+	positions := qrAlignmentPositions(version)
+	for i, cy := range positions {
+		for j, cx := range positions {
+			if i == 0 && (j == 0 || j == len(positions)-1) || i == len(positions)-1 && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	format := qrFormatCode(qrLevels[level]<<3 | mask)
+	for i := 0; i <= 5; i++ {
+		set(8, i, format>>i&1 == 1)
+	}
+	set(8, 7, format>>6&1 == 1)
+	set(8, 8, format>>7&1 == 1)
+	set(7, 8, format>>8&1 == 1)
+	for i := 9; i < 15; i++ {
+		set(14-i, 8, format>>i&1 == 1)
+	}
+	for i := 0; i < 8; i++ {
+		set(d-1-i, 8, format>>i&1 == 1)
+	}
+	for i := 8; i < 15; i++ {
+		set(8, d-15+i, format>>i&1 == 1)
+	}
+	set(8, d-8, true)
+	if version >= 7 {
+		info := qrVersionCode(version)
+		for i := 0; i < 18; i++ {
+			a, b := d-11+i%3, i/3
+			set(a, b, info>>i&1 == 1)
+			set(b, a, info>>i&1 == 1)
+		}
+	}
+	function := qrFunctionModules(version)
+	n := 0
+	for right := d - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < d; vert++ {
+			y := vert
+			if upward {
+				y = d - 1 - vert
+			}
+			for x := right; x >= right-1; x-- {
+				if function[y*d+x] {
+					continue
+				}
+				dark := n < len(codewords)*8 && codewords[n/8]>>(7-n%8)&1 == 1
+				set(x, y, dark != qrMasked(mask, x, y))
+				n++
+			}
+		}
+	}
+	return grid
+}
+
+// renderQR draws the modules of a QR code in black on white, scale pixels a module, turned
+// clockwise by angle degrees around the center of a square image with room for it at any angle.
+func renderQR(grid *bitMatrix, scale, angle float64) *image.Gray {
+	d := float64(grid.width)
+	size := int(math.Ceil((d + 8) * scale * math.Sqrt2))
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)+0.5-float64(size)/2, float64(y)+0.5-float64(size)/2
+			mx, my := (cos*dx+sin*dy)/scale+d/2, (cos*dy-sin*dx)/scale+d/2
+			img.Pix[y*img.Stride+x] = 255
+			if grid.dark(int(math.Floor(mx)), int(math.Floor(my))) {
+				img.Pix[y*img.Stride+x] = 0
+			}
+		}
+	}
+	return img
+}
+
+// TestQRBlockTables checks the block tables against the size of every version: the data modules
+// must hold its codewords with fewer than 8 remainder bits, and the data capacities published for
+// some versions must follow from them.
+func TestQRBlockTables(t *testing.T) {
+	for version := 1; version <= 40; version++ {
+		var modules int
+		for _, function := range qrFunctionModules(version) {
+			if !function {
+				modules++
+			}
+		}
+		if raw := qrRawCodewords(version); modules/8 != raw {
+			t.Errorf("version %d: %d data modules for %d codewords", version, modules, raw)
+		}
+		for level := range qrBlocks {
+			blocks, ec := qrBlocks[level][version-1], qrECCodewords[level][version-1]
+			if raw := qrRawCodewords(version); raw/blocks <= ec {
+				t.Errorf("version %d level %d: %d blocks of %d codewords leave no data", version, level, blocks, raw/blocks)
+			}
+		}
+	}
+	capacities := []struct{ version, level, data int }{
+		{1, 0, 19}, {1, 3, 9}, {5, 2, 62}, {7, 3, 66}, {10, 1, 216}, {40, 0, 2956}, {40, 1, 2334}, {40, 2, 1666}, {40, 3, 1276},
+	}
+	for _, c := range capacities {
+		if got := qrDataCodewords(c.version, c.level); got != c.data {
+			t.Errorf("version %d level %d: %d data codewords, want %d", c.version, c.level, got, c.data)
+		}
+	}
+}
+
+// TestQRErrorCorrection checks the error correction of the "HELLO WORLD" version 1-Q code of the
+// Thonky QR code tutorial: its error correction codewords, the repair of as many wrong codewords
+// as it can take and the refusal of one more.
+func TestQRErrorCorrection(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236}
+	ec := []byte{168, 72, 22, 82, 217, 54, 156, 0, 46, 15, 180, 122, 16}
+	if got := reedSolomonRemainder(data, len(ec)); !bytes.Equal(got, ec) {
+		t.Fatalf("got error correction %v, want %v", got, ec)
+	}
+	if value, ok := decodeQRSegments(data, 1); !ok || value != "HELLO WORLD" {
+		t.Errorf("got %q, %v, want HELLO WORLD", value, ok)
+	}
+
+	block := append(append([]byte(nil), data...), ec...)
+	for errors := 0; errors <= 7; errors++ {
+		damaged := append([]byte(nil), block...)
+		for i := 0; i < errors; i++ {
+			damaged[i*3] ^= byte(0x5a + i)
+		}
+		corrected, ok := correctReedSolomon(damaged, len(ec))
+		switch {
+		case errors <= len(ec)/2 && (!ok || corrected != errors || !bytes.Equal(damaged, block)):
+			t.Errorf("%d errors: got %d repaired, %v", errors, corrected, ok)
+		case errors > len(ec)/2 && ok && bytes.Equal(damaged, block):
+			t.Errorf("%d errors: repaired beyond the capacity of the code", errors)
+		}
+	}
+}
+
+// TestQRSegments decodes the segments of a numeric, a Kanji and a UTF-8 byte segment after an
+// ECI, one after the other.
+func TestQRSegments(t *testing.T) {
+	var w qrBitWriter
+	w.write(qrModeNumeric, 4)
+	w.write(4, 10)
+	w.write(12, 10)
+	w.write(3, 4)
+	// 点 and 茗 in Shift JIS, 0x935f and 0xe4aa
+	w.write(qrModeKanji, 4)
+	w.write(2, 8)
+	w.write(0xd9f, 13)
+	w.write(0x1aaa, 13)
+	w.write(qrModeECI, 4)
+	w.write(qrECIUTF8, 8)
+	w.write(qrModeByte, 4)
+	w.write(2, 8)
+	w.write(0xc3, 8)
+	w.write(0xa4, 8)
+	w.write(qrModeTerminator, 4)
+	w.write(0, (8-len(w)%8)%8)
+	data := make([]byte, len(w)/8)
+	for i, dark := range w {
+		if dark {
+			data[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	if value, ok := decodeQRSegments(data, 1); !ok || value != "0123点茗ä" {
+		t.Errorf("got %q, %v, want 0123点茗ä", value, ok)
+	}
+}
+
+// TestReadQRCode renders QR codes of several versions, modes, levels, masks and orientations,
+// which must read with full confidence, and a damaged one, which must read with less.
+func TestReadQRCode(t *testing.T) {
+	tests := []struct {
+		text                    string
+		level, minVersion, mask int
+		scale, angle            float64
+	}{
+		{"INV-2026-0001", 0, 1, 0, 4, 0},
+		{"https://example.com/batch/17?sheet=separator", 1, 1, 1, 3, 90},
+		{"00420017000000001234", 2, 1, 2, 5, 180},
+		{"Akte Müller, Ordner 3", 3, 1, 3, 4, 270},
+		{strings.Repeat("SEPARATOR SHEET ", 8), 0, 7, 4, 2, 0},
+		{strings.Repeat("Batch 2026/10 box 4. ", 12), 2, 12, 5, 3, 90},
+		{"Vorgang 7", 1, 2, 6, 1, 0},
+		{"Vorgang 8", 1, 3, 7, 6, 0},
+		{"Vorgang 9, leicht schief eingelegt", 1, 4, 0, 5, 8},
+		{strings.Repeat("Scanned askew. ", 10), 1, 8, 1, 4, -23},
+	}
+	for _, tt := range tests {
+		img := renderQR(encodeQR(t, tt.text, tt.level, tt.minVersion, tt.mask), tt.scale, tt.angle)
+		read := readQRCode(img)
+		if read.value != tt.text || !read.qr || read.confidence() != 1 {
+			t.Errorf("'%s': got %+v, want it with confidence 1", tt.text, read)
+		}
+	}
+
+	// Three modules of the data damaged in three codewords of a code that can repair 14
+	grid := encodeQR(t, "DAMAGED", 3, 2, 0)
+	for _, p := range [][2]int{{24, 24}, {24, 14}, {13, 20}} {
+		grid.bits[p[1]*grid.width+p[0]] = !grid.bits[p[1]*grid.width+p[0]]
+	}
+	read := readQRCode(renderQR(grid, 4, 0))
+	if read.value != "DAMAGED" || read.lines != 14 || read.votes != 11 {
+		t.Errorf("damaged code: got %+v, want DAMAGED with 11 of 14 votes", read)
+	}
+
+	// A page without a code, and one with a code too damaged to read
+	if read := readQRCode(image.NewGray(image.Rect(0, 0, 100, 100))); read.lines != 0 {
+		t.Errorf("blank image: got %+v", read)
+	}
+	grid = encodeQR(t, "UNREADABLE", 0, 1, 0)
+	for y := 9; y < grid.height; y++ {
+		for x := 9; x < grid.width; x++ {
+			grid.bits[y*grid.width+x] = (x*7+y*3)%5 < 2
+		}
+	}
+	if read := readQRCode(renderQR(grid, 4, 0)); read.value != "" || read.lines != 1 || !read.qr {
+		t.Errorf("unreadable code: got %+v, want a code without value", read)
+	}
+}
+
+// TestSplitOnQRCode splits a batch of the book fixture behind two QR code cover sheets, which
+// must give a document per sheet named after its value and without the sheet.
+func TestSplitOnQRCode(t *testing.T) {
+	dir := t.TempDir()
+	book, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inputs []string
+	for _, value := range []string{"INV-0001", "Akte 2"} {
+		var buf bytes.Buffer
+		if err = png.Encode(&buf, renderQR(encodeQR(t, value, 1, 1, 0), 8, 0)); err != nil {
+			t.Fatal(err)
+		}
+		sheet := filepath.Join(dir, value+".png")
+		if err = os.WriteFile(sheet, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		separator := filepath.Join(dir, value+".pdf")
+		if err = api.ImportImagesFile([]string{sheet}, separator, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, book, separator)
+	}
+	inputs = append(inputs, book)
+	batch := filepath.Join(dir, "batch.pdf")
+	if err = api.MergeCreateFile(inputs, batch, false, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := runCommand(t, dir, "-i", batch, "-o", "out", "--split-on-barcode", "-v", "--sidecar-suffix=", "--bloat-factor=0")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	want := map[string]int{"leading.pdf": 16, "INV-0001.pdf": 16, "Akte 2.pdf": 16}
+	if got := outputPageCounts(t, filepath.Join(dir, "out")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v\n%s", got, want, output)
+	}
+	if !strings.Contains(output, "page 17: separator barcode 'INV-0001', confidence 1.00") {
+		t.Errorf("the separator page is not reported\n%s", output)
+	}
+}
+
+// TestSplitOnBarcodeFallback splits the book fixture, which has no separator page, with
+// --split-on-barcode: the outline is used with the default file names.
+func TestSplitOnBarcodeFallback(t *testing.T) {
+	dir := t.TempDir()
+	book, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := runCommand(t, dir, "-i", book, "-o", "out", "--split-on-barcode", "--sidecar-suffix=", "--bloat-factor=0")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	want := map[string]int{"01_Part One.pdf": 8, "02_Part Two.pdf": 8}
+	if got := outputPageCounts(t, filepath.Join(dir, "out")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v\n%s", got, want, output)
+	}
+	if !strings.Contains(output, "no page has a readable separator barcode") {
+		t.Errorf("the missing separator pages are not reported\n%s", output)
+	}
+}
@@ -0,0 +1,172 @@
+// Package ranges parses the selections of chapter and page numbers pdf-split takes on the command
+// line and in plan files, so that --chapters, --exclude-pages, --barcode-pages and the entries of a
+// --plan file all accept the same grammar:
+//
+//	selection = item { "," item }
+//	item      = number | number "-" number | number "-" | "odd" | "even"
+//
+// Numbers start at 1. An open range such as "16-" runs through the last number, which is only
+// known once the selection is applied to a document; so do "odd" and "even". Spaces around
+// items and around the dash are ignored.
+package ranges
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Range is one item of a selection: every Step-th number from From to To. An open range has no
+// To and runs through the last number of what it is applied to.
+type Range struct {
+	From uint32
+	To   uint32
+	Open bool
+	Step uint32
+}
+
+// List is a parsed selection, its items in the order they were written.
+type List []Range
+
+// SyntaxError is a malformed selection. It points at the offending token, e.g. the part of a
+// range that is not a number.
+type SyntaxError struct {
+	// Token is the offending text, empty for a missing item
+	Token string
+	// Column is the 1-based position of Token in the selection, in bytes
+	Column int
+	// Reason says what is wrong with Token
+	Reason string
+}
+
+// Error names the token, its column and the reason.
+func (e *SyntaxError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("column %d: %s", e.Column, e.Reason)
+	}
+	return fmt.Sprintf("'%s' at column %d: %s", e.Token, e.Column, e.Reason)
+}
+
+// Parse parses a selection such as "1-8, 10, 16-" or "odd".
+// Parameters:
+//   - text: the selection
+//
+// Returns:
+//   - List: the items of the selection
+//   - error: a *SyntaxError for the first malformed item
+func Parse(text string) (List, error) {
+	var list List
+	offset := 0
+	for _, item := range strings.Split(text, ",") {
+		r, err := parseItem(item, offset)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+		offset += len(item) + 1
+	}
+	return list, nil
+}
+
+// parseItem parses one item of a selection that starts at offset.
+func parseItem(item string, offset int) (Range, error) {
+	token, column := trim(item, offset)
+	switch strings.ToLower(token) {
+	case "":
+		return Range{}, &SyntaxError{Column: column, Reason: "empty item"}
+	case "odd":
+		return Range{From: 1, Open: true, Step: 2}, nil
+	case "even":
+		return Range{From: 2, Open: true, Step: 2}, nil
+	}
+
+	dash := strings.IndexByte(token, '-')
+	if dash < 0 {
+		n, err := parseNumber(token, column)
+		return Range{From: n, To: n, Step: 1}, err
+	}
+	fromToken, fromColumn := trim(token[:dash], column-1)
+	from, err := parseNumber(fromToken, fromColumn)
+	if err != nil {
+		return Range{}, err
+	}
+	toToken, toColumn := trim(token[dash+1:], column+dash)
+	if toToken == "" {
+		return Range{From: from, Open: true, Step: 1}, nil
+	}
+	to, err := parseNumber(toToken, toColumn)
+	if err != nil {
+		return Range{}, err
+	}
+	if to < from {
+		return Range{}, &SyntaxError{Token: token, Column: column, Reason: "range ends before it starts"}
+	}
+	return Range{From: from, To: to, Step: 1}, nil
+}
+
+// parseNumber parses a number of a selection at column.
+func parseNumber(token string, column int) (uint32, error) {
+	if token == "" {
+		return 0, &SyntaxError{Column: column, Reason: "missing number"}
+	}
+	n, err := strconv.ParseUint(token, 10, 32)
+	switch {
+	case err != nil && strings.Trim(token, "0123456789") == "":
+		return 0, &SyntaxError{Token: token, Column: column, Reason: "number too large"}
+	case err != nil:
+		return 0, &SyntaxError{Token: token, Column: column, Reason: "not a number"}
+	case n == 0:
+		return 0, &SyntaxError{Token: token, Column: column, Reason: "numbers start at 1"}
+	}
+	return uint32(n), nil
+}
+
+// trim removes the spaces around a part of a selection that starts at offset and returns the
+// part with its 1-based column.
+func trim(part string, offset int) (string, int) {
+	trimmed := strings.TrimLeft(part, " \t")
+	column := offset + len(part) - len(trimmed) + 1
+	return strings.TrimRight(trimmed, " \t"), column
+}
+
+// End returns the last number of the range, last for an open range that starts before it and
+// From for one that starts after it.
+func (r Range) End(last uint32) uint32 {
+	if r.Open {
+		return max(r.From, last)
+	}
+	return r.To
+}
+
+// Contains reports whether a number is in the range, open ranges having no end.
+func (r Range) Contains(n int) bool {
+	if n < int(r.From) || (!r.Open && n > int(r.To)) {
+		return false
+	}
+	return (uint32(n)-r.From)%max(r.Step, 1) == 0
+}
+
+// Contains reports whether a number is in any item of the list.
+func (l List) Contains(n int) bool {
+	if n < 1 || uint64(n) > math.MaxUint32 {
+		return false
+	}
+	for _, r := range l {
+		if r.Contains(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// Numbers returns the numbers of the list from 1 to last, in ascending order and each once.
+func (l List) Numbers(last int) []int {
+	var numbers []int
+	for n := 1; n <= last; n++ {
+		if l.Contains(n) {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
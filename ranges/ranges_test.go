@@ -0,0 +1,106 @@
+package ranges
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want List
+	}{
+		{"3", List{{From: 3, To: 3, Step: 1}}},
+		{"3,5,7-9", List{{From: 3, To: 3, Step: 1}, {From: 5, To: 5, Step: 1}, {From: 7, To: 9, Step: 1}}},
+		{" 1 - 8 , 10, 16- ", List{{From: 1, To: 8, Step: 1}, {From: 10, To: 10, Step: 1}, {From: 16, Open: true, Step: 1}}},
+		{"odd,EVEN", List{{From: 1, Open: true, Step: 2}, {From: 2, Open: true, Step: 2}}},
+		{"4-4", List{{From: 4, To: 4, Step: 1}}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.text)
+		if err != nil {
+			t.Errorf("%q: %v", tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", "column 1: empty item"},
+		{"1,,3", "column 3: empty item"},
+		{"3, x", "'x' at column 4: not a number"},
+		{"7-x", "'x' at column 3: not a number"},
+		{"1-8, 10-y", "'y' at column 9: not a number"},
+		{"-5", "column 1: missing number"},
+		{"0-3", "'0' at column 1: numbers start at 1"},
+		{"9-3", "'9-3' at column 1: range ends before it starts"},
+		{"2, 99999999999", "'99999999999' at column 4: number too large"},
+		{"1-2-3", "'2-3' at column 3: not a number"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.text)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || err.Error() != tt.want {
+			t.Errorf("%q: got %v, want %s", tt.text, err, tt.want)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	list, err := Parse("2-4, 10-, odd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := list.Numbers(12), []int{1, 2, 3, 4, 5, 7, 9, 10, 11, 12}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if list.Contains(0) || !list.Contains(1_000_001) {
+		t.Error("0 must not be selected and every number from 10 on must be")
+	}
+	if got := (Range{From: 16, Open: true, Step: 1}).End(12); got != 16 {
+		t.Errorf("open range starting after the last number: got end %d, want 16", got)
+	}
+}
+
+// FuzzParse checks that every selection either parses into well-formed ranges or fails with a
+// *SyntaxError that points into the selection.
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{"3,5,7-9", "16-", "odd", " 1 - 8 ,10", "9-3", ",", "-", "1-2-3", "0"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, text string) {
+		list, err := Parse(text)
+		if err != nil {
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("%q: got %T, want a *SyntaxError", text, err)
+			}
+			if syntaxErr.Column < 1 || syntaxErr.Column+len(syntaxErr.Token)-1 > len(text)+1 {
+				t.Fatalf("%q: column %d is outside the selection", text, syntaxErr.Column)
+			}
+			if token := syntaxErr.Token; token != "" && text[syntaxErr.Column-1:syntaxErr.Column-1+len(token)] != token {
+				t.Fatalf("%q: %q is not at column %d", text, token, syntaxErr.Column)
+			}
+			return
+		}
+		if len(list) == 0 {
+			t.Fatalf("%q: parsed without any item", text)
+		}
+		for _, r := range list {
+			if r.From < 1 || r.Step < 1 || (!r.Open && r.To < r.From) {
+				t.Fatalf("%q: malformed range %+v", text, r)
+			}
+			if !list.Contains(int(r.From)) {
+				t.Fatalf("%q: %d is not selected by %+v", text, r.From, r)
+			}
+		}
+	})
+}
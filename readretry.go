@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// exitSourceUnreadable is the exit code used when chapters failed because the source could no
+// longer be read, as opposed to invalid source content, which fails with the generic exit code.
+const exitSourceUnreadable = 9
+
+// initialRetryBackoff is the pause before the first retry of a failed source read; it doubles for every further retry.
+const initialRetryBackoff = 500 * time.Millisecond
+
+// errSourceUnreadable is returned when reading the source kept failing through all --read-retries.
+var errSourceUnreadable = errors.New("source became unreadable")
+
+var (
+	// readRetryCount counts the retried source reads of the run, for the summary.
+	// Chapters are exported concurrently, so it is updated atomically.
+	readRetryCount atomic.Int64
+	// unreadableChapters lists the chapters that failed because the source became unreadable.
+	unreadableChapters []string
+)
+
+// isTransientReadError reports whether err is a read error that may go away on its own,
+// such as those of a network share that disconnected for a moment.
+func isTransientReadError(err error) bool {
+	for _, errno := range transientErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// withReadRetries runs a chapter export and retries it up to --read-retries times while it fails
+// with a transient read error, pausing with a doubling backoff in between. A handle whose share
+// went away usually stays broken, so every retry reads from a freshly opened handle of the source.
+// Parameters:
+//   - inputFile: the source PDF file, used for the first attempt
+//   - title: chapter title for the retry messages
+//   - export: the export, reading the source from the handle it is given
+//
+// Returns:
+//   - error: the error of the last attempt, wrapped in errSourceUnreadable if it was transient
+func withReadRetries(inputFile *os.File, title string, export func(source *os.File) error) error {
+	backoff := initialRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := exportAttempt(inputFile, attempt, export)
+		if err == nil || !isTransientReadError(err) {
+			return err
+		}
+		if attempt >= readRetries {
+			return fmt.Errorf("%w: %v", errSourceUnreadable, err)
+		}
+		readRetryCount.Add(1)
+		if verbose {
+			printMsg("read_retry", title, attempt+1, readRetries, backoff, err)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// exportAttempt runs one attempt of an export, reopening the source for retries.
+func exportAttempt(inputFile *os.File, attempt int, export func(source *os.File) error) error {
+	if attempt == 0 {
+		return export(inputFile)
+	}
+	source, err := os.Open(inputFile.Name())
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	return export(source)
+}
+
+// exitOnUnreadable prints how many source reads were retried and ends the run with
+// exitSourceUnreadable if any chapter failed because the source became unreadable.
+func exitOnUnreadable() error {
+	if n := readRetryCount.Load(); n > 0 {
+		printMsg("read_retries_summary", n)
+	}
+	if len(unreadableChapters) == 0 {
+		return nil
+	}
+	errorMsg("unreadable_failed", len(unreadableChapters))
+	for _, line := range unreadableChapters {
+		fmt.Fprintln(messageOutput, "  - "+line)
+	}
+	return exitWith(exitSourceUnreadable, nil)
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// transientErrnos are the read errors worth retrying: I/O errors and stale NFS handles,
+// and dropped or timed out connections of network file systems.
+var transientErrnos = []error{syscall.EIO, syscall.ESTALE, syscall.ECONNRESET, syscall.ECONNABORTED, syscall.ETIMEDOUT, syscall.ENOTCONN}
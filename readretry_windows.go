@@ -0,0 +1,18 @@
+//go:build windows
+
+package main
+
+import "syscall"
+
+// Windows error codes of SMB shares that disconnected or timed out.
+const (
+	errorBadNetpath     = syscall.Errno(53)
+	errorUnexpNetErr    = syscall.Errno(59)
+	errorNetnameDeleted = syscall.Errno(64)
+	errorSemTimeout     = syscall.Errno(121)
+)
+
+// transientErrnos are the read errors worth retrying: I/O errors, reset connections and
+// the errors of SMB shares that went away for a moment.
+var transientErrnos = []error{syscall.EIO, syscall.ECONNRESET, syscall.ECONNABORTED,
+	errorBadNetpath, errorUnexpNetErr, errorNetnameDeleted, errorSemTimeout}
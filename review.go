@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/souhup/pdf-spliter/splitter"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// exitReviewAborted is the exit code used when a review ends without applying the edited chapters.
+const exitReviewAborted = 14
+
+// reviewHeartbeat is how often the review page reports that it is still open.
+const reviewHeartbeat = 3 * time.Second
+
+// reviewHeartbeatTimeout is how long the review page may stay silent before the review is
+// aborted as if the browser had been closed.
+const reviewHeartbeatTimeout = 15 * time.Second
+
+// reviewSnippetLength is the number of characters of first-page text shown per chapter.
+const reviewSnippetLength = 200
+
+// reviewReservedFlags are the split flags review sets itself or that cannot be combined with
+// the --plan it applies.
+var reviewReservedFlags = []string{"input", "i", "output", "o", "dry-run", "plan", "min-pages", "target-pages"}
+
+//go:embed review.html
+var reviewPageSource string
+
+var reviewPage = template.Must(template.New("review").Parse(reviewPageSource))
+
+var (
+	reviewOutput string
+	reviewPort   int
+)
+
+var reviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Edit the planned chapters in a local web page, then split with the edited plan",
+	Args:  cobra.ArbitraryArgs,
+	RunE:  runReview,
+	Example: `./pdf-split review -i book.pdf -o chapters
+./pdf-split review -i book.pdf -- --depth 2 --lookback 1`,
+}
+
+// reviewChapter is a row of the review page: a planned chapter and the text its first page starts with.
+type reviewChapter struct {
+	Order     uint32
+	Title     string
+	StartPage uint32
+	EndPage   uint32
+	Snippet   string
+}
+
+// reviewEdit is a chapter as edited on the review page.
+type reviewEdit struct {
+	Title     string `json:"title"`
+	StartPage int    `json:"start_page"`
+}
+
+// initReviewFlags registers the flags of the review subcommand. Flags of the split itself
+// follow after --, and apply to both the plan shown and the split that is run.
+func initReviewFlags() {
+	flags := reviewCmd.Flags()
+	flags.StringVarP(&inputFilePath, "input", "i", "", "input PDF file path")
+	flags.StringVarP(&reviewOutput, "output", "o", "output", "output directory path")
+	flags.IntVar(&reviewPort, "port", 0, "port of the review page on 127.0.0.1 (default a free port)")
+	flags.StringVar(&language, "lang", "", "language of messages, e.g. de or zh-CN (default from LANG)")
+	if err := reviewCmd.MarkFlagRequired("input"); err != nil {
+		log.Fatalf("failed to parse param: %v", err)
+	}
+}
+
+// runReview plans the split of the input with this program, serves the planned chapters as an
+// editable table on 127.0.0.1 and, once the page applies the edits, splits the input with a
+// --plan file holding the edited titles and page ranges. Nothing is written before that: Ctrl-C,
+// the Cancel button or closing the page aborts the review. The page and its requests carry a
+// random token, so that other local pages cannot drive the review.
+// Parameter cmd is the running command, args are the split flags given after --.
+func runReview(cmd *cobra.Command, args []string) error {
+	if err := setLanguage(language); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if strings.HasPrefix(arg, "-") && slices.Contains(reviewReservedFlags, name) {
+			return fmt.Errorf("--%s cannot be passed to review, which sets the input, output and plan of the split itself", name)
+		}
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate executable: %w", err)
+	}
+	if language != "" {
+		args = append([]string{"--lang=" + language}, args...)
+	}
+
+	// Plan the split as a user would, with the flags to apply
+	planned, err := reviewPlan(executable, args)
+	if err != nil {
+		return err
+	}
+	chapters, doc, err := reviewChapters(planned.Files)
+	if err != nil {
+		return err
+	}
+
+	// Serve the review page on the loopback interface only
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(reviewPort)))
+	if err != nil {
+		return fmt.Errorf("start review server: %w", err)
+	}
+	tokenBytes := make([]byte, 16)
+	if _, err = rand.Read(tokenBytes); err != nil {
+		return fmt.Errorf("create review token: %w", err)
+	}
+	session := &reviewSession{
+		token:     hex.EncodeToString(tokenBytes),
+		port:      listener.Addr().(*net.TCPAddr).Port,
+		files:     planned.Files,
+		chapters:  chapters,
+		problems:  planned.Problems,
+		pageCount: doc.PageCount(),
+		source:    doc,
+		applied:   make(chan []planEntry, 1),
+		cancelled: make(chan struct{}, 1),
+	}
+	server := &http.Server{Handler: session.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	printMsg("review_url", fmt.Sprintf("http://127.0.0.1:%d/?token=%s", session.port, session.token))
+
+	// Wait for the page, Ctrl-C or the page going away
+	interrupted, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ticker := time.NewTicker(reviewHeartbeat)
+	defer ticker.Stop()
+	var entries []planEntry
+	for entries == nil {
+		select {
+		case entries = <-session.applied:
+		case <-session.cancelled:
+			return failed(cmd, abortReview(server, "review_cancelled"))
+		case <-interrupted.Done():
+			return failed(cmd, abortReview(server, "review_interrupted"))
+		case <-ticker.C:
+			if session.silentFor() > reviewHeartbeatTimeout {
+				return failed(cmd, abortReview(server, "review_page_closed"))
+			}
+		}
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), time.Second)
+	server.Shutdown(shutdown)
+	cancel()
+	stop()
+
+	return failed(cmd, applyReview(executable, args, entries))
+}
+
+// abortReview stops the review server and returns the error ending the run with exitReviewAborted,
+// naming the reason by its message key.
+func abortReview(server *http.Server, reason string) error {
+	server.Close()
+	errorMsg("review_aborted", msg(reason))
+	return exitWith(exitReviewAborted, nil)
+}
+
+// reviewPlan runs this program with --dry-run=json and returns the planned files.
+func reviewPlan(executable string, args []string) (splitPlan, error) {
+	var planned splitPlan
+	run := exec.Command(executable, append([]string{"-i", inputFilePath, "-o", reviewOutput, "--dry-run=json"}, args...)...)
+	var stdout, stderr bytes.Buffer
+	run.Stdout, run.Stderr = &stdout, &stderr
+	// A plan with problems is still printed, and the review is the place to fix them
+	if err := run.Run(); err != nil && stdout.Len() == 0 {
+		return planned, fmt.Errorf("plan the split: %v\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &planned); err != nil {
+		return planned, fmt.Errorf("read the planned split: %w", err)
+	}
+	if len(planned.Files) == 0 {
+		return planned, errors.New("the split plans no chapters to review")
+	}
+	return planned, nil
+}
+
+// reviewChapters reads the source once for the first-page text of every planned chapter.
+// Returns:
+//   - []reviewChapter: the rows of the review page
+//   - *splitter.Document: the source, for previews of edited start pages
+//   - error: if the source cannot be read
+func reviewChapters(files []plannedFile) ([]reviewChapter, *splitter.Document, error) {
+	inputFile, err := os.Open(inputFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer inputFile.Close()
+	doc, err := sourceDocument(inputFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read '%s': %w", inputFilePath, err)
+	}
+	releaseSourceDocument(inputFile)
+	chapters := make([]reviewChapter, len(files))
+	for i, f := range files {
+		chapters[i] = reviewChapter{
+			Order:     f.Order,
+			Title:     f.Title,
+			StartPage: f.StartPage,
+			EndPage:   f.EndPage,
+			Snippet:   pageSnippet(doc, int(f.StartPage)),
+		}
+	}
+	return chapters, doc, nil
+}
+
+// pageSnippet returns the start of the text of a page on a single line.
+func pageSnippet(doc *splitter.Document, page int) string {
+	var text []rune
+	_ = doc.Inspect(func(ctx *model.Context) error {
+		text = []rune(strings.Join(strings.Fields(pageText(ctx, page)), " "))
+		return nil
+	})
+	if len(text) > reviewSnippetLength {
+		return string(text[:reviewSnippetLength]) + "…"
+	}
+	return string(text)
+}
+
+// reviewSession is the state of the review server.
+type reviewSession struct {
+	token     string
+	port      int
+	files     []plannedFile
+	chapters  []reviewChapter
+	problems  []string
+	pageCount int
+	// applied receives the edited plan, cancelled the Cancel button
+	applied   chan []planEntry
+	cancelled chan struct{}
+
+	// source is the document previews are read from, one at a time
+	source *splitter.Document
+
+	// mu guards the last heartbeat
+	mu       sync.Mutex
+	lastSeen time.Time
+}
+
+// handler returns the routes of the review page. Every request must name the server by its
+// loopback address, which keeps out pages of other sites resolving their name to 127.0.0.1, and
+// must carry the token.
+func (s *reviewSession) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.servePage)
+	mux.HandleFunc("GET /preview", s.servePreview)
+	mux.HandleFunc("POST /alive", func(w http.ResponseWriter, _ *http.Request) {
+		s.mu.Lock()
+		s.lastSeen = time.Now()
+		s.mu.Unlock()
+	})
+	mux.HandleFunc("POST /apply", s.serveApply)
+	mux.HandleFunc("POST /cancel", func(w http.ResponseWriter, _ *http.Request) {
+		select {
+		case s.cancelled <- struct{}{}:
+		default:
+		}
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Review-Token")
+		if r.URL.Path == "/" {
+			token = r.URL.Query().Get("token")
+		}
+		if host := r.Host; host != fmt.Sprintf("127.0.0.1:%d", s.port) && host != fmt.Sprintf("localhost:%d", s.port) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if token != s.token {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// silentFor returns how long the page has not reported, or 0 before it was opened.
+func (s *reviewSession) silentFor() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastSeen.IsZero() {
+		return 0
+	}
+	return time.Since(s.lastSeen)
+}
+
+// servePage renders the table of planned chapters.
+func (s *reviewSession) servePage(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err := reviewPage.Execute(w, map[string]any{
+		"Source":          filepath.Base(inputFilePath),
+		"Output":          reviewOutput,
+		"Token":           s.token,
+		"Chapters":        s.chapters,
+		"Problems":        s.problems,
+		"PageCount":       s.pageCount,
+		"HeartbeatMillis": reviewHeartbeat.Milliseconds(),
+	})
+	if err != nil {
+		log.Printf("failed to render the review page: %v", err)
+	}
+}
+
+// servePreview returns the first-page text of an edited start page.
+func (s *reviewSession) servePreview(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 || page > s.pageCount {
+		http.Error(w, msg("review_invalid_page", r.URL.Query().Get("page"), s.pageCount), http.StatusBadRequest)
+		return
+	}
+	text := pageSnippet(s.source, page)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"text": text})
+}
+
+// serveApply checks the edited chapters and hands the plan made of them to runReview.
+func (s *reviewSession) serveApply(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Chapters []reviewEdit `json:"chapters"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := reviewPlanEntries(s.files, body.Chapters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	select {
+	case s.applied <- entries:
+	default:
+		http.Error(w, msg("review_already_applied"), http.StatusConflict)
+	}
+}
+
+// reviewPlanEntries turns the edited chapters into the outputs of a --plan file. A chapter that
+// ended right before the next one in the plan still does, wherever the next one starts now;
+// other chapters keep their end unless the next one now starts on or before it.
+// Parameters:
+//   - files: the planned chapters
+//   - edits: the chapters as edited, in the same order
+//
+// Returns:
+//   - []planEntry: one output per chapter, named by its title
+//   - error: naming the chapter whose title or start page is not usable
+func reviewPlanEntries(files []plannedFile, edits []reviewEdit) ([]planEntry, error) {
+	if len(edits) != len(files) {
+		return nil, errors.New(msg("review_chapter_count", len(edits), len(files)))
+	}
+	for i, edit := range edits {
+		if strings.TrimSpace(edit.Title) == "" {
+			return nil, errors.New(msg("review_no_title", i+1))
+		}
+		if i > 0 && edit.StartPage <= edits[i-1].StartPage {
+			return nil, errors.New(msg("review_start_order", strings.TrimSpace(edit.Title), edit.StartPage, edits[i-1].StartPage))
+		}
+	}
+	entries := make([]planEntry, len(edits))
+	for i, edit := range edits {
+		title, start := strings.TrimSpace(edit.Title), edit.StartPage
+		end := int(files[i].EndPage)
+		if i+1 < len(edits) {
+			next := edits[i+1].StartPage
+			if int(files[i].EndPage)+1 == int(files[i+1].StartPage) || end >= next {
+				end = next - 1
+			}
+		}
+		if start < 1 || start > end {
+			return nil, errors.New(msg("review_start_range", title, start, end))
+		}
+		entries[i] = planEntry{Name: title, Pages: fmt.Sprintf("%d-%d", start, end)}
+	}
+	return entries, nil
+}
+
+// applyReview splits the input with a --plan file of the edited chapters, passing on the split
+// flags, and ends with the exit code of that split. Files keep the default names, numbered in
+// plan order, unless a --name-template is among the flags.
+func applyReview(executable string, args []string, entries []planEntry) error {
+	dir, err := os.MkdirTemp("", "pdf-split-review-")
+	if err != nil {
+		return fmt.Errorf("create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	data, err := yaml.Marshal(planDocument{SchemaVersion: schemaVersion, Outputs: entries})
+	if err != nil {
+		return err
+	}
+	planPath := filepath.Join(dir, "plan.yaml")
+	if err = os.WriteFile(planPath, data, 0644); err != nil {
+		return err
+	}
+
+	splitArgs := []string{"-i", inputFilePath, "-o", reviewOutput, "--plan", planPath}
+	if !slices.ContainsFunc(args, func(arg string) bool { return strings.HasPrefix(arg, "--name-template") }) {
+		splitArgs = append(splitArgs, "--name-template", defaultNameTemplate)
+	}
+	printMsg("review_applying", len(entries), reviewOutput)
+	run := exec.Command(executable, append(splitArgs, args...)...)
+	run.Stdout, run.Stderr = os.Stdout, os.Stderr
+	err = run.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitWith(exitErr.ExitCode(), nil)
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// defaultSampleDir is the subdirectory of the output directory that --sample writes into.
+const defaultSampleDir = "_sample"
+
+// sampleIndexes picks the items kept by --sample out of n items.
+// Without a seed every nth item is kept, starting with the first. With a seed the same number
+// of items is drawn at random, which is reproducible for the same seed and the same items.
+// Parameters:
+//   - n: number of items
+//   - every: keep one item out of this many
+//   - random: draw the items at random instead of taking every nth one
+//   - seed: seed of the random draw
+//
+// Returns:
+//   - []int: indexes of the kept items in ascending order
+func sampleIndexes(n, every int, random bool, seed int64) []int {
+	count := (n + every - 1) / every
+	var indexes []int
+	if random {
+		indexes = rand.New(rand.NewSource(seed)).Perm(n)[:count]
+		sort.Ints(indexes)
+		return indexes
+	}
+	for i := 0; i < n; i += every {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// sampleChapters keeps the chapters picked by --sample, with their numbers unchanged.
+func sampleChapters(chapters []chapter) []chapter {
+	var sampled []chapter
+	for _, i := range sampleIndexes(len(chapters), sampleEvery, sampleRandom, sampleSeed) {
+		sampled = append(sampled, chapters[i])
+	}
+	return sampled
+}
+
+// sampleInputs keeps the documents of a batch picked by --sample.
+func sampleInputs(inputs []batchInput) []batchInput {
+	var sampled []batchInput
+	for _, i := range sampleIndexes(len(inputs), sampleEvery, sampleRandom, sampleSeed) {
+		sampled = append(sampled, inputs[i])
+	}
+	return sampled
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// schemaVersion is the version of the --plan file and --dry-run=json formats. A file of a newer
+// version is refused instead of being read with parts of it missing; files without a version
+// are version 1.
+const schemaVersion = 1
+
+// schemaFiles holds the JSON Schemas of the formats pdf-split reads and writes, one per format
+// named as the argument of the schema subcommand.
+//
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema " + strings.Join(schemaNames(), "|"),
+	Short: "Print the JSON Schema of a file format, to validate files before a run",
+	Args:  cobra.ExactArgs(1),
+	RunE:  printSchema,
+	Example: `./pdf-split schema plan > plan.schema.json
+./pdf-split schema manifest`,
+	ValidArgs: schemaNames(),
+}
+
+var validatePlanCmd = &cobra.Command{
+	Use:     "validate-plan <file>",
+	Short:   "Check a --plan file against its format and rules without the source document",
+	Args:    cobra.ExactArgs(1),
+	RunE:    validatePlan,
+	Example: `./pdf-split validate-plan plan.yaml`,
+}
+
+// schemaNames returns the formats the schema subcommand prints, in alphabetical order.
+func schemaNames() []string {
+	entries, err := schemaFiles.ReadDir("schemas")
+	if err != nil {
+		log.Fatalf("failed to read the embedded schemas: %v", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	return names
+}
+
+// initValidatePlanFlags registers the flags of the validate-plan subcommand.
+func initValidatePlanFlags() {
+	flags := validatePlanCmd.Flags()
+	flags.BoolVar(&allowAbsoluteDirs, "allow-absolute-output-dirs", false, "accept absolute output_dir values")
+	flags.StringVar(&language, "lang", "", "language of messages, e.g. de or zh-CN (default from LANG)")
+}
+
+// printSchema writes the JSON Schema of the format named by the only argument to stdout.
+// Parameter args holds the name of the format.
+func printSchema(cmd *cobra.Command, args []string) error {
+	if !slices.Contains(schemaNames(), args[0]) {
+		return fmt.Errorf("unknown format '%s': must be one of %s", args[0], strings.Join(schemaNames(), ", "))
+	}
+	data, err := schemaFiles.ReadFile(path.Join("schemas", args[0]+".json"))
+	if err != nil {
+		return failed(cmd, err)
+	}
+	if _, err = os.Stdout.Write(data); err != nil {
+		return failed(cmd, fmt.Errorf("failed to write schema: %w", err))
+	}
+	return nil
+}
+
+// validatePlan checks the --plan file named by the only argument as a split would read it: its
+// fields and their types, its version, and that every output has a name and either chapters or
+// pages given as a valid selection. Problems end the run with exitPlanProblems.
+// Parameter args holds the path of the plan.
+func validatePlan(cmd *cobra.Command, args []string) error {
+	if err := setLanguage(language); err != nil {
+		return err
+	}
+	doc, err := readPlanFile(args[0])
+	if err != nil {
+		return failed(cmd, exitWith(exitPlanProblems, err))
+	}
+	fmt.Println(msg("plan_valid", args[0], len(doc.Outputs)))
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// TestSchemas compares the properties of every embedded schema with the fields of the type the
+// format is read or written with, so that a new field cannot be left out of its schema.
+func TestSchemas(t *testing.T) {
+	tests := []struct {
+		schema string
+		path   []string
+		value  any
+		tag    string
+	}{
+		{"plan", nil, planDocument{}, "yaml"},
+		{"plan", []string{"properties", "outputs", "items"}, planEntry{}, "yaml"},
+		{"manifest", []string{"items"}, manifestEntry{}, "json"},
+		{"dry-run", nil, splitPlan{}, "json"},
+		{"dry-run", []string{"properties", "files", "items"}, plannedFile{}, "json"},
+		{"dry-run", []string{"properties", "warnings", "items"}, warning{}, "json"},
+		{"snapshot", nil, splitter.Snapshot{}, "json"},
+		{"snapshot", []string{"properties", "page_labels", "items"}, splitter.PageLabel{}, "json"},
+		{"snapshot", []string{"$defs", "bookmark"}, pdfcpu.Bookmark{}, "json"},
+	}
+	for _, tt := range tests {
+		data, err := schemaFiles.ReadFile("schemas/" + tt.schema + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var node map[string]any
+		if err := json.Unmarshal(data, &node); err != nil {
+			t.Fatalf("%s: %v", tt.schema, err)
+		}
+		for _, key := range tt.path {
+			node, _ = node[key].(map[string]any)
+		}
+		properties, _ := node["properties"].(map[string]any)
+		var got []string
+		for name := range properties {
+			got = append(got, name)
+		}
+		slices.Sort(got)
+		if want := fieldNames(reflect.TypeOf(tt.value), tt.tag); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s %v: got properties %q, want the fields %q", tt.schema, tt.path, got, want)
+		}
+	}
+}
+
+// fieldNames returns the sorted names of the fields of a struct type under a tag key, without
+// the fields left out with "-".
+func fieldNames(typ reflect.Type, key string) []string {
+	var names []string
+	for i := range typ.NumField() {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get(key), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// TestValidatePlan checks plan files with validate-plan, which must accept a valid plan and
+// reject unknown fields, ranges that end before they start, a page 0 and a newer version with
+// the exit code of plan problems, and prints the schema a plan is checked against.
+func TestValidatePlan(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		plan string
+		want string
+	}{
+		{"schema_version: 1\noutputs:\n  - name: Front\n    pages: 1-2\n  - name: Rest\n    chapters: 2-\n", "valid plan with 2 outputs"},
+		{"outputs:\n  - name: Front\n    page: 1-2\n", "field page not found"},
+		{"outputs:\n  - name: Front\n    pages: 4-2\n", "range ends before it starts"},
+		{"outputs:\n  - name: Front\n    pages: 0-2\n", "numbers start at 1"},
+		{"outputs:\n  - name: Front\n    pages: 1-2\n    chapters: 1\n", "must have either chapters or pages"},
+		{"schema_version: 2\noutputs:\n  - name: Front\n    pages: 1-2\n", "newer than the supported 1"},
+	}
+	for i, tt := range tests {
+		plan := filepath.Join(dir, strings.Repeat("x", i+1)+".yaml")
+		if err := os.WriteFile(plan, []byte(tt.plan), 0644); err != nil {
+			t.Fatal(err)
+		}
+		output, err := runCommand(t, dir, "validate-plan", plan)
+		var exitErr *exec.ExitError
+		if i > 0 && (!errors.As(err, &exitErr) || exitErr.ExitCode() != exitPlanProblems) {
+			t.Errorf("%q: got %v, want exit code %d\n%s", tt.plan, err, exitPlanProblems, output)
+		}
+		if i == 0 && err != nil {
+			t.Errorf("%q: %v\n%s", tt.plan, err, output)
+		}
+		if !strings.Contains(output, tt.want) {
+			t.Errorf("%q: the output does not contain %q\n%s", tt.plan, tt.want, output)
+		}
+	}
+
+	output, err := runCommand(t, dir, "schema", "plan")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	want, err := schemaFiles.ReadFile("schemas/plan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != string(want) {
+		t.Errorf("schema plan printed\n%s\nwant\n%s", output, want)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/spf13/cobra"
+)
+
+// exitSelftestFailed is the exit code used when a split of the selftest document did not give the expected files.
+const exitSelftestFailed = 11
+
+// selftestPages is the page count of the generated selftest document.
+const selftestPages = 16
+
+// selftestOutline is the three-level outline of the generated selftest document.
+// Part One has an opener page before its first chapter; every chapter starts with its first section.
+var selftestOutline = []pdfcpu.Bookmark{
+	{Title: "Part One", PageFrom: 1, Kids: []pdfcpu.Bookmark{
+		{Title: "Chapter 1", PageFrom: 2, Kids: []pdfcpu.Bookmark{
+			{Title: "Section 1.1", PageFrom: 2},
+			{Title: "Section 1.2", PageFrom: 4},
+		}},
+		{Title: "Chapter 2", PageFrom: 6, Kids: []pdfcpu.Bookmark{
+			{Title: "Section 2.1", PageFrom: 6},
+			{Title: "Section 2.2", PageFrom: 7},
+		}},
+	}},
+	{Title: "Part Two", PageFrom: 9, Kids: []pdfcpu.Bookmark{
+		{Title: "Chapter 3", PageFrom: 9, Kids: []pdfcpu.Bookmark{
+			{Title: "Section 3.1", PageFrom: 9},
+			{Title: "Section 3.2", PageFrom: 12},
+		}},
+		{Title: "Chapter 4", PageFrom: 14, Kids: []pdfcpu.Bookmark{
+			{Title: "Section 4.1", PageFrom: 14},
+			{Title: "Section 4.2", PageFrom: 15},
+		}},
+	}},
+}
+
+// selftestFile is a chapter file a selftest split is expected to write.
+type selftestFile struct {
+	name      string
+	startPage int
+	endPage   int
+}
+
+// selftestCase is one split of the selftest document and the files it must produce.
+type selftestCase struct {
+	name  string
+	args  []string
+	files []selftestFile
+}
+
+// selftestCases are the splits made by selftest: the default split by parts and a split by sections.
+var selftestCases = []selftestCase{
+	{name: "parts", files: []selftestFile{
+		{"01_Part One.pdf", 1, 8},
+		{"02_Part Two.pdf", 9, 16},
+	}},
+	{name: "sections", args: []string{"--depth=3"}, files: []selftestFile{
+		{"01_Part One (intro).pdf", 1, 1},
+		{"02_Section 1.1.pdf", 2, 3},
+		{"03_Section 1.2.pdf", 4, 5},
+		{"04_Section 2.1.pdf", 6, 6},
+		{"05_Section 2.2.pdf", 7, 8},
+		{"06_Section 3.1.pdf", 9, 11},
+		{"07_Section 3.2.pdf", 12, 13},
+		{"08_Section 4.1.pdf", 14, 14},
+		{"09_Section 4.2.pdf", 15, 16},
+	}},
+}
+
+var selftestKeep string
+
+var selftestCmd = &cobra.Command{
+	Use:     "selftest",
+	Short:   "Split a generated sample PDF and check the results",
+	Args:    cobra.NoArgs,
+	RunE:    runSelftest,
+	Example: `./pdf-split selftest --keep sample.pdf`,
+}
+
+// initSelftestFlags registers the flags of the selftest subcommand.
+func initSelftestFlags() {
+	flags := selftestCmd.Flags()
+	flags.StringVar(&selftestKeep, "keep", "", "also save the generated sample PDF to this path, e.g. as a fixture for a bug report")
+	flags.StringVar(&language, "lang", "", "language of messages, e.g. de or zh-CN (default from LANG)")
+	flags.BoolVarP(&verbose, "verbose", "v", false, "print the messages of the splits")
+}
+
+// runSelftest generates a sample document with a known outline, splits it with this program
+// in a temporary directory and checks every written file: its name, its page count and the page
+// number printed on its first page.
+// It ends with exitSelftestFailed if any check failed.
+// Parameter cmd is the running command, _ is used to satisfy the cobra.Command RunE interface.
+func runSelftest(cmd *cobra.Command, _ []string) error {
+	if err := setLanguage(language); err != nil {
+		return err
+	}
+	return failed(cmd, selftest())
+}
+
+// selftest runs the checks of runSelftest.
+func selftest() error {
+	dir, err := os.MkdirTemp("", "pdf-split-selftest-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Generate the sample document, and keep a copy if requested
+	sample := filepath.Join(dir, "sample.pdf")
+	if err = writeSelftestDocument(sample); err != nil {
+		return fmt.Errorf("failed to generate the sample PDF: %w", err)
+	}
+	if selftestKeep != "" {
+		data, err := os.ReadFile(sample)
+		if err != nil {
+			return fmt.Errorf("failed to read the sample PDF: %w", err)
+		}
+		if err = os.WriteFile(selftestKeep, data, 0644); err != nil {
+			return fmt.Errorf("failed to save the sample PDF: %w", err)
+		}
+		printMsg("selftest_kept", selftestKeep)
+	}
+
+	// Split it in a run of its own per case, as a user would; pages this small would all look bloated
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate executable: %w", err)
+	}
+	var failed int
+	for _, tc := range selftestCases {
+		outDir := filepath.Join(dir, tc.name)
+		args := append([]string{"-i", sample, "-o", outDir, "--sidecar-suffix=", "--bloat-factor=0"}, tc.args...)
+		if language != "" {
+			args = append(args, "--lang="+language)
+		}
+		run := exec.Command(executable, args...)
+		var output bytes.Buffer
+		run.Stdout, run.Stderr = &output, &output
+		problems := []string{}
+		if err := run.Run(); err != nil {
+			problems = append(problems, msg("selftest_run_failed", err, strings.TrimSpace(output.String())))
+		} else {
+			problems = checkSelftestFiles(outDir, tc.files)
+		}
+		if verbose {
+			fmt.Fprint(messageOutput, output.String())
+		}
+		if len(problems) > 0 {
+			failed++
+			printMsg("selftest_case_failed", tc.name)
+			for _, problem := range problems {
+				fmt.Fprintln(messageOutput, "  - "+problem)
+			}
+			continue
+		}
+		printMsg("selftest_case_passed", tc.name, len(tc.files))
+	}
+
+	splits := len(selftestCases)
+	if failed > 0 {
+		printMsg("selftest_failed", failed, splits)
+		return exitWith(exitSelftestFailed, nil)
+	}
+	printMsg("selftest_passed", splits)
+	return nil
+}
+
+// writeSelftestDocument creates the sample document: selftestPages pages, each with its page
+// number printed in the middle, and the outline selftestOutline.
+func writeSelftestDocument(path string) error {
+	return writeSampleDocument(path, selftestPages, selftestOutline)
+}
+
+// writeSampleDocument creates a document of pageCount pages, each with its page number printed
+// in the middle, and the given outline.
+func writeSampleDocument(path string, pageCount int, outline []pdfcpu.Bookmark) error {
+	// Describe the pages for pdfcpu's JSON page creation
+	pages := make(map[string]any, pageCount)
+	for i := 1; i <= pageCount; i++ {
+		pages[strconv.Itoa(i)] = map[string]any{
+			"content": map[string]any{
+				"text": []any{map[string]any{
+					"value":  selftestPageLabel(i),
+					"anchor": "center",
+					"font":   map[string]any{"name": "Helvetica", "size": 24},
+				}},
+			},
+		}
+	}
+	layout, err := json.Marshal(map[string]any{"paper": "A5P", "origin": "LowerLeft", "pages": pages})
+	if err != nil {
+		return err
+	}
+	var created bytes.Buffer
+	if err = api.Create(nil, bytes.NewReader(layout), &created, nil); err != nil {
+		return fmt.Errorf("create pages: %w", err)
+	}
+
+	// Add the outline
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = api.AddBookmarks(bytes.NewReader(created.Bytes()), out, outline, true, nil); err != nil {
+		out.Close()
+		return fmt.Errorf("add outline: %w", err)
+	}
+	return out.Close()
+}
+
+// selftestPageLabel is the text printed on a page of the sample document.
+func selftestPageLabel(page int) string {
+	return fmt.Sprintf("Page %d", page)
+}
+
+// checkSelftestFiles compares the files of a split with the expected ones.
+// Parameters:
+//   - dir: output directory of the split
+//   - want: the files the split must have written, in order
+//
+// Returns:
+//   - []string: the problems found, empty if the split is as expected
+func checkSelftestFiles(dir string, want []selftestFile) []string {
+	var problems []string
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	expected := make(map[string]bool, len(want))
+	for _, f := range want {
+		expected[f.name] = true
+	}
+	for _, entry := range entries {
+		if !expected[entry.Name()] {
+			problems = append(problems, msg("selftest_unexpected", entry.Name()))
+		}
+	}
+
+	// Every file must hold the planned pages, starting with the planned first page
+	for _, f := range want {
+		path := filepath.Join(dir, f.name)
+		ctx, err := api.ReadContextFile(path)
+		if err != nil {
+			problems = append(problems, msg("selftest_missing", f.name, err))
+			continue
+		}
+		if err = ctx.EnsurePageCount(); err != nil {
+			problems = append(problems, msg("selftest_missing", f.name, err))
+			continue
+		}
+		if pages := f.endPage - f.startPage + 1; ctx.PageCount != pages {
+			problems = append(problems, msg("selftest_page_count", f.name, ctx.PageCount, pages))
+		}
+		if text := pageText(ctx, 1); !slices.Contains(strings.Split(text, "\n"), selftestPageLabel(f.startPage)) {
+			problems = append(problems, msg("selftest_first_page", f.name, f.startPage, text))
+		}
+	}
+	return problems
+}
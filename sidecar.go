@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// defaultSidecarSuffix is appended to the input path to find a chapter sidecar file.
+const defaultSidecarSuffix = ".chapters"
+
+// sidecarEntry is one chapter read from a sidecar file.
+type sidecarEntry struct {
+	title string
+	page  int
+	line  int
+}
+
+// sidecarKeyPattern matches the keys of the key=value format: CHAPTER01 holds the start page
+// and CHAPTER01NAME the title.
+var sidecarKeyPattern = regexp.MustCompile(`^(?i)CHAPTER(\d+)(NAME)?$`)
+
+// findSidecar returns the path of the chapter sidecar file next to the input, if there is one.
+// An empty suffix disables sidecar detection.
+func findSidecar(inputPath, suffix string) string {
+	if suffix == "" {
+		return ""
+	}
+	path := inputPath + suffix
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return ""
+	}
+	return path
+}
+
+// readSidecar parses a chapter sidecar file. Two formats are recognized from the first entry:
+// "page<TAB>title" lines, and CHAPTER01=page / CHAPTER01NAME=title pairs as written by
+// audiobook and video tools, with pages in place of timestamps.
+// Empty lines and lines starting with '#' are ignored.
+// Parameters:
+//   - path: path of the sidecar file
+//   - pageCount: number of pages of the input, which no chapter may start after
+//
+// Returns:
+//   - []sidecarEntry: the chapters in page order
+//   - error: naming the offending line if the file is malformed
+func readSidecar(path string, pageCount int) ([]sidecarEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Read all meaningful lines, remembering their line numbers
+	var lines []string
+	var numbers []int
+	scanner := bufio.NewScanner(f)
+	for nr := 1; scanner.Scan(); nr++ {
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		lines = append(lines, line)
+		numbers = append(numbers, nr)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("sidecar '%s' contains no chapters", path)
+	}
+	if err = checkChapterLimit(len(lines)); err != nil {
+		return nil, err
+	}
+
+	// Sniff the format from the first entry
+	var entries []sidecarEntry
+	switch {
+	case strings.Contains(lines[0], "\t"):
+		entries, err = parseTabSidecar(lines, numbers)
+	case strings.Contains(lines[0], "="):
+		entries, err = parseKeyValueSidecar(lines, numbers)
+	default:
+		err = fmt.Errorf("line %d: expected \"page<TAB>title\" or CHAPTER01=page", numbers[0])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sidecar '%s' %w", path, err)
+	}
+
+	// Chapters must start on existing pages in reading order
+	for i, e := range entries {
+		if e.page < 1 || e.page > pageCount {
+			return nil, fmt.Errorf("sidecar '%s' line %d: page %d is outside the document (1-%d)", path, e.line, e.page, pageCount)
+		}
+		if i > 0 && e.page < entries[i-1].page {
+			return nil, fmt.Errorf("sidecar '%s' line %d: page %d comes before the previous chapter's page %d", path, e.line, e.page, entries[i-1].page)
+		}
+	}
+	return entries, nil
+}
+
+// parseTabSidecar parses "page<TAB>title" lines.
+func parseTabSidecar(lines []string, numbers []int) ([]sidecarEntry, error) {
+	var entries []sidecarEntry
+	for i, line := range lines {
+		pageText, title, found := strings.Cut(line, "\t")
+		page, err := strconv.Atoi(strings.TrimSpace(pageText))
+		if !found || err != nil {
+			return nil, fmt.Errorf("line %d: expected \"page<TAB>title\", got '%s'", numbers[i], line)
+		}
+		title = strings.TrimSpace(title)
+		if title == "" {
+			return nil, fmt.Errorf("line %d: missing title", numbers[i])
+		}
+		entries = append(entries, sidecarEntry{title: title, page: page, line: numbers[i]})
+	}
+	return entries, nil
+}
+
+// parseKeyValueSidecar parses CHAPTERnn=page and CHAPTERnnNAME=title pairs.
+// Chapters are ordered by their number; a chapter without a name is named after its number.
+func parseKeyValueSidecar(lines []string, numbers []int) ([]sidecarEntry, error) {
+	byNumber := map[int]*sidecarEntry{}
+	nameLines := map[int]int{}
+	for i, line := range lines {
+		key, value, found := strings.Cut(line, "=")
+		m := sidecarKeyPattern.FindStringSubmatch(strings.TrimSpace(key))
+		if !found || m == nil {
+			return nil, fmt.Errorf("line %d: expected CHAPTERnn=page or CHAPTERnnNAME=title, got '%s'", numbers[i], line)
+		}
+		nr, _ := strconv.Atoi(m[1])
+		e := byNumber[nr]
+		if e == nil {
+			e = &sidecarEntry{title: fmt.Sprintf("Chapter %d", nr)}
+			byNumber[nr] = e
+		}
+		value = strings.TrimSpace(value)
+		if m[2] != "" {
+			e.title = value
+			nameLines[nr] = numbers[i]
+			continue
+		}
+		page, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: '%s' is not a page number", numbers[i], value)
+		}
+		e.page, e.line = page, numbers[i]
+	}
+
+	var keys []int
+	for nr := range byNumber {
+		keys = append(keys, nr)
+	}
+	sort.Ints(keys)
+	var entries []sidecarEntry
+	for _, nr := range keys {
+		if byNumber[nr].line == 0 {
+			return nil, fmt.Errorf("line %d: chapter %02d has a name but no CHAPTER%02d=page line", nameLines[nr], nr, nr)
+		}
+		entries = append(entries, *byNumber[nr])
+	}
+	return entries, nil
+}
+
+// sidecarBookmarks converts sidecar entries into a flat bookmark list.
+func sidecarBookmarks(entries []sidecarEntry) []pdfcpu.Bookmark {
+	bookmarks := make([]pdfcpu.Bookmark, len(entries))
+	for i, e := range entries {
+		bookmarks[i] = pdfcpu.Bookmark{Title: e.title, PageFrom: e.page}
+	}
+	return bookmarks
+}
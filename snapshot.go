@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/souhup/pdf-spliter/splitter"
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:     "snapshot",
+	Short:   "Print the page count, outline, page labels and metadata of a document as JSON, to report a planning problem without the document",
+	Args:    cobra.NoArgs,
+	RunE:    printSnapshot,
+	Example: `./pdf-split snapshot -i book.pdf > book.snapshot.json`,
+}
+
+// initSnapshotFlags registers the flags of the snapshot subcommand.
+func initSnapshotFlags() {
+	flags := snapshotCmd.Flags()
+	flags.StringVarP(&inputFilePath, "input", "i", "", "source PDF file path")
+	initPasswordFlags(flags)
+	flags.StringVar(&language, "lang", "", "language of messages, e.g. de or zh-CN (default from LANG)")
+	if err := snapshotCmd.MarkFlagRequired("input"); err != nil {
+		log.Fatalf("failed to parse param: %v", err)
+	}
+}
+
+// printSnapshot writes the splitter.Snapshot of --input to stdout.
+func printSnapshot(cmd *cobra.Command, _ []string) error {
+	if err := setLanguage(language); err != nil {
+		return err
+	}
+	inputFile, err := openInput(inputFilePath)
+	if err != nil {
+		return failed(cmd, err)
+	}
+	defer inputFile.Close()
+	doc, err := sourceDocument(inputFile)
+	if err != nil {
+		return failed(cmd, err)
+	}
+	snapshot, err := doc.Snapshot()
+	if err != nil {
+		return failed(cmd, err)
+	}
+	return failed(cmd, splitter.WriteSnapshot(os.Stdout, snapshot))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// exitInvalidInput is the exit code used when the input is not a PDF file at all.
+const exitInvalidInput = 12
+
+// headerWindow is how far into the file the %PDF header is searched. Readers accept up to
+// 1024 bytes of leading garbage, which some producers and mail gateways leave in front of it.
+const headerWindow = 1024
+
+// checkInputFormat ends the run with exitInvalidInput if the input cannot be a PDF file,
+// before pdfcpu fails on it with a parse error. Empty files, HTML pages saved by a failed
+// download and ZIP based documents such as DOCX get a message of their own.
+// Parameters:
+//   - inputFile: pointer to the source file
+//
+// Returns:
+//   - error: ending the run with exitInvalidInput, naming the likely kind of file, or the read error
+func checkInputFormat(inputFile *os.File) error {
+	head := make([]byte, headerWindow)
+	n, err := inputFile.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read input file %s: %w", inputFile.Name(), err)
+	}
+	head = head[:n]
+	if bytes.Contains(head, []byte("%PDF-")) {
+		return nil
+	}
+
+	// Name the most likely cause
+	name := filepath.Base(inputFile.Name())
+	start := bytes.ToLower(bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n"))
+	key := "input_not_pdf"
+	switch {
+	case n == 0:
+		key = "input_empty"
+	case bytes.HasPrefix(start, []byte("<!doctype html")) || bytes.Contains(start, []byte("<html")):
+		key = "input_html"
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		key = "input_zip"
+	}
+	return exitWith(exitInvalidInput, errors.New(msg(key, name)))
+}
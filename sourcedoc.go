@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// sourceDocuments are the sources read by sourceDocument, by input file. The outline, the
+// detection, the checks and the export of an input all read the same document instead of
+// parsing the file again.
+var sourceDocuments = map[*os.File]*splitter.Document{}
+
+// sourceDocument returns the source of an input, read and validated on first use. Malformed
+// outlines are rejected before pdfcpu walks them recursively.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//
+// Returns:
+//   - *splitter.Document: the document in memory, shared by all readers of the input
+//   - error: if the source cannot be read, ending the run with exitLimitsExceeded for an
+//     outline over the limits
+func sourceDocument(inputFile *os.File) (*splitter.Document, error) {
+	if doc, ok := sourceDocuments[inputFile]; ok {
+		return doc, nil
+	}
+	doc, err := splitter.ReadDocument(inputFile, sourceConfiguration(), sourceLimits())
+	if err != nil {
+		if errors.Is(err, splitter.ErrMalformedOutline) {
+			return nil, fmt.Errorf("failed to read PDF bookmarks: %w", err)
+		}
+		return nil, limitExceeded(fmt.Errorf("failed to read PDF file: %w", err))
+	}
+	sourceDocuments[inputFile] = doc
+	return doc, nil
+}
+
+// inspectSource calls f with the pdfcpu context of the source of an input, which f must not change.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//   - f: reads the context
+//
+// Returns:
+//   - error: the error of sourceDocument or of f
+func inspectSource(inputFile *os.File, f func(ctx *model.Context) error) error {
+	doc, err := sourceDocument(inputFile)
+	if err != nil {
+		return err
+	}
+	return doc.Inspect(f)
+}
+
+// sourcePageCount returns the page count of the source of an input.
+func sourcePageCount(inputFile *os.File) (int, error) {
+	doc, err := sourceDocument(inputFile)
+	if err != nil {
+		return 0, err
+	}
+	return doc.PageCount(), nil
+}
+
+// releaseSourceDocument drops the source of an input, which is read again if it is needed later.
+func releaseSourceDocument(inputFile *os.File) {
+	delete(sourceDocuments, inputFile)
+}
+
+// loadSourceDocument returns the source of an input for the export, unless --low-memory asks to
+// read it again for every chapter instead of holding it in memory. If it cannot be read, the
+// chapters fall back to reading it one by one, which reports the failing chapters on their own.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//
+// Returns:
+//   - *splitter.Document: the document in memory, or nil to read the source per chapter
+func loadSourceDocument(inputFile *os.File) *splitter.Document {
+	if lowMemory {
+		releaseSourceDocument(inputFile)
+		return nil
+	}
+	if doc, ok := sourceDocuments[inputFile]; ok {
+		return doc
+	}
+	var doc *splitter.Document
+	err := withReadRetries(inputFile, filepath.Base(inputFile.Name()), func(source *os.File) error {
+		var err error
+		doc, err = splitter.ReadDocument(source, sourceConfiguration(), sourceLimits())
+		return err
+	})
+	if err != nil {
+		if verbose {
+			printMsg("source_read_once_failed", err)
+		}
+		return nil
+	}
+	sourceDocuments[inputFile] = doc
+	return doc
+}
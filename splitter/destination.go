@@ -0,0 +1,208 @@
+package splitter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// partialNameReserve is the room a name needs for the temporary ".<name>.<random>.partial" form.
+const partialNameReserve = len("..") + len(".partial") + 10
+
+// NameEnv is the environment variable that holds the file name for the command of a
+// CommandDestination.
+const NameEnv = "PDF_SPLIT_NAME"
+
+// Destination receives the chapter files of a split, e.g. to store them in a document
+// management system instead of a directory. ExportChapters writes a chapter only once it is
+// complete and verified, so a Destination never sees a file that has to be taken back.
+type Destination interface {
+	// Create opens the file of one chapter, with a name as returned by FileNames; the chapter is
+	// delivered once the writer is closed without error. If the writer also implements Aborter,
+	// a chapter that cannot be written completely is aborted instead of closed.
+	Create(name string) (io.WriteCloser, error)
+	// Finalize is called once after all chapters were delivered, with the list of them.
+	Finalize(manifest Manifest) error
+}
+
+// Aborter is implemented by writers of a Destination that can drop a file they were given
+// only in part, leaving nothing behind under its name.
+type Aborter interface {
+	Abort() error
+}
+
+// Manifest lists the files delivered to a Destination, in the order they were delivered.
+type Manifest struct {
+	Files []ManifestFile
+}
+
+// ManifestFile is a delivered file and the chapter it holds.
+type ManifestFile struct {
+	Name    string
+	Chapter Chapter
+}
+
+// DirDestination writes the files into a directory, which is created if missing. A file is
+// written under a temporary name and renamed into place when it is closed, so an interrupted
+// split never leaves a file that looks complete; an aborted file is removed.
+type DirDestination struct {
+	Dir string
+	// Names shortens the temporary file names to the limits of the filesystem of Dir
+	Names NameRules
+}
+
+// Create opens a temporary file for the named file in Dir.
+func (d DirDestination) Create(name string) (io.WriteCloser, error) {
+	target := filepath.Join(d.Dir, name)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, fmt.Errorf("create output directory: %w", err)
+	}
+	base := d.Names.Truncate(filepath.Base(target), d.Names.maxLength()-partialNameReserve)
+	f, err := os.CreateTemp(filepath.Dir(target), "."+base+".*.partial")
+	if err != nil {
+		return nil, fmt.Errorf("create output file: %w", err)
+	}
+	return &pendingFile{File: f, target: target}, nil
+}
+
+// Finalize does nothing; the files are in place once they are closed.
+func (d DirDestination) Finalize(Manifest) error {
+	return nil
+}
+
+// pendingFile is a file of a DirDestination written under its temporary name.
+type pendingFile struct {
+	*os.File
+	target string
+}
+
+// Close closes the file and moves it to its target, replacing an existing file.
+func (p *pendingFile) Close() error {
+	if err := p.File.Close(); err != nil {
+		os.Remove(p.Name())
+		return err
+	}
+	if err := os.Chmod(p.Name(), 0644); err != nil {
+		os.Remove(p.Name())
+		return err
+	}
+	if err := os.Rename(p.Name(), p.target); err != nil {
+		os.Remove(p.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort closes and removes the temporary file, leaving the target untouched.
+func (p *pendingFile) Abort() error {
+	p.File.Close()
+	return os.Remove(p.Name())
+}
+
+// CommandDestination hands every file to an external command, such as the upload tool of a
+// document management system. The command is run once per file, when the file is closed,
+// with the file's bytes on its standard input and its name in NameEnv; "{name}" in an argument
+// is replaced by the name as well. The command is run directly, not by a shell. A command that
+// fails or exits with a non-zero status fails the file with a *DeliveryError.
+type CommandDestination struct {
+	// Args is the command and its arguments
+	Args []string
+	// Env is the environment of the command, nil for the environment of this process
+	Env []string
+	// Stdout and Stderr receive the output of the command, nil to discard it
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// ParseCommand splits a command line like "mytool put {name}" into its arguments at
+// whitespace, without shell quoting.
+func ParseCommand(command string) ([]string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("the command must not be empty")
+	}
+	return args, nil
+}
+
+// Create returns a writer that collects the file and runs the command when it is closed.
+func (d CommandDestination) Create(name string) (io.WriteCloser, error) {
+	if len(d.Args) == 0 {
+		return nil, errors.New("no destination command")
+	}
+	return &commandFile{dest: d, name: name}, nil
+}
+
+// Finalize does nothing; every file was handed over when it was closed.
+func (d CommandDestination) Finalize(Manifest) error {
+	return nil
+}
+
+// DeliveryError is returned when the command of a CommandDestination fails for the file Name.
+// ExitCode is the exit status of the command, or -1 if it could not be run.
+type DeliveryError struct {
+	Name     string
+	ExitCode int
+	Err      error
+}
+
+// Error describes the failed delivery.
+func (e *DeliveryError) Error() string {
+	if e.ExitCode >= 0 {
+		return fmt.Sprintf("destination command exited with status %d", e.ExitCode)
+	}
+	return fmt.Sprintf("destination command: %v", e.Err)
+}
+
+// Unwrap returns the error of the command.
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// commandFile collects a file of a CommandDestination until it is closed.
+type commandFile struct {
+	dest CommandDestination
+	name string
+	data bytes.Buffer
+}
+
+// Write adds to the collected file.
+func (c *commandFile) Write(p []byte) (int, error) {
+	return c.data.Write(p)
+}
+
+// Close runs the command with the collected file on its standard input.
+func (c *commandFile) Close() error {
+	args := make([]string, len(c.dest.Args))
+	for i, arg := range c.dest.Args {
+		args[i] = strings.ReplaceAll(arg, "{name}", c.name)
+	}
+	run := exec.Command(args[0], args[1:]...)
+	env := c.dest.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	run.Env = append(slices.Clip(env), NameEnv+"="+c.name)
+	run.Stdin = &c.data
+	run.Stdout, run.Stderr = c.dest.Stdout, c.dest.Stderr
+	if err := run.Run(); err != nil {
+		code := -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.ExitCode()
+		}
+		return &DeliveryError{Name: c.name, ExitCode: code, Err: err}
+	}
+	return nil
+}
+
+// Abort drops the collected file without running the command.
+func (c *commandFile) Abort() error {
+	c.data.Reset()
+	return nil
+}
@@ -0,0 +1,247 @@
+package splitter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// TestMain runs receiveFile instead of the tests when the test binary is started as the command
+// of a CommandDestination, which sets NameEnv.
+func TestMain(m *testing.M) {
+	if name := os.Getenv(NameEnv); name != "" {
+		os.Exit(receiveFile(name, os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// receiveFile is the command of the CommandDestination tests: it stores stdin in the directory
+// args[0] under name, and fails with exit code 3 if name is one of the rest of args.
+func receiveFile(name string, args []string) int {
+	if len(args) == 0 || slices.Contains(args[1:], name) {
+		return 3
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(args[0], name), data, 0644)
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// memDestination keeps the delivered files in memory and refuses to create the names in refuse.
+type memDestination struct {
+	refuse    []string
+	created   []*memFile
+	files     map[string][]byte
+	finalized *Manifest
+}
+
+func (d *memDestination) Create(name string) (io.WriteCloser, error) {
+	if slices.Contains(d.refuse, name) {
+		return nil, fmt.Errorf("refused '%s'", name)
+	}
+	f := &memFile{dest: d, name: name}
+	d.created = append(d.created, f)
+	return f, nil
+}
+
+func (d *memDestination) Finalize(manifest Manifest) error {
+	d.finalized = &manifest
+	return nil
+}
+
+// memFile is a file of a memDestination, delivered when it is closed.
+type memFile struct {
+	dest    *memDestination
+	name    string
+	data    bytes.Buffer
+	aborted bool
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	return f.data.Write(p)
+}
+
+func (f *memFile) Close() error {
+	if f.dest.files == nil {
+		f.dest.files = make(map[string][]byte)
+	}
+	f.dest.files[f.name] = f.data.Bytes()
+	return nil
+}
+
+func (f *memFile) Abort() error {
+	f.aborted = true
+	return nil
+}
+
+func TestExportChaptersDestination(t *testing.T) {
+	source := openFixture(t, "book.pdf")
+	chapters, err := ExtractChapters(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := &memDestination{}
+	if err = ExportChapters(source, chapters, ExportOptions{Destination: dest, VerifyPages: true}); err != nil {
+		t.Fatal(err)
+	}
+	if dest.finalized == nil {
+		t.Fatal("the destination was not finalized")
+	}
+	var names []string
+	for i, file := range dest.finalized.Files {
+		names = append(names, file.Name)
+		if !reflect.DeepEqual(file.Chapter, chapters[i]) {
+			t.Errorf("%s: got chapter %+v, want %+v", file.Name, file.Chapter, chapters[i])
+		}
+		if err := CheckPageCount(bytes.NewReader(dest.files[file.Name]), chapters[i].Pages(), nil); err != nil {
+			t.Errorf("%s: %v", file.Name, err)
+		}
+	}
+	if want := []string{"01_Part One.pdf", "02_Part Two.pdf"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got files %q, want %q", names, want)
+	}
+
+	// A refused chapter fails the export before the destination is finalized
+	dest = &memDestination{refuse: []string{"02_Part Two.pdf"}}
+	err = ExportChapters(source, chapters, ExportOptions{Destination: dest})
+	var chapterErr *ChapterError
+	if !errors.As(err, &chapterErr) || chapterErr.Chapter.Title != "Part Two" {
+		t.Errorf("got %v, want the error of chapter 'Part Two'", err)
+	}
+	if dest.finalized != nil || len(dest.files) != 1 {
+		t.Errorf("got files %v and manifest %v, want only the first file", dest.files, dest.finalized)
+	}
+}
+
+// returnedSource is a source that counts the reads and seeks made after returned was set.
+type returnedSource struct {
+	io.ReadSeeker
+	returned atomic.Bool
+	late     atomic.Int32
+}
+
+func (s *returnedSource) Read(p []byte) (int, error) {
+	if s.returned.Load() {
+		s.late.Add(1)
+	}
+	return s.ReadSeeker.Read(p)
+}
+
+func (s *returnedSource) Seek(offset int64, whence int) (int64, error) {
+	if s.returned.Load() {
+		s.late.Add(1)
+	}
+	return s.ReadSeeker.Seek(offset, whence)
+}
+
+func TestExportChaptersRefusedStopsReading(t *testing.T) {
+	// The first chapter is refused while the worker reads the source for the next ones
+	source := &returnedSource{ReadSeeker: openFixture(t, "book.pdf")}
+	var chapters []Chapter
+	for i := 0; i < 8; i++ {
+		chapters = append(chapters, Chapter{Title: fmt.Sprintf("Part %d", i+1), Order: i + 1, StartPage: 2*i + 1, EndPage: 2*i + 2})
+	}
+	dest := &memDestination{refuse: []string{"01_Part 1.pdf"}}
+	err := ExportChapters(source, chapters, ExportOptions{Destination: dest, LowMemory: true})
+	source.returned.Store(true)
+	var chapterErr *ChapterError
+	if !errors.As(err, &chapterErr) || chapterErr.Chapter.Title != "Part 1" {
+		t.Fatalf("got %v, want the error of chapter 'Part 1'", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if late := source.late.Load(); late > 0 {
+		t.Errorf("the source was read %d times after the export returned", late)
+	}
+}
+
+func TestDeliverAbort(t *testing.T) {
+	dest := &memDestination{}
+	err := Deliver(dest, "01.pdf", iotest.ErrReader(errors.New("broken")))
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("got %v, want the read error", err)
+	}
+	if len(dest.created) != 1 || !dest.created[0].aborted || len(dest.files) != 0 {
+		t.Errorf("the file was delivered instead of aborted")
+	}
+}
+
+func TestDirDestination(t *testing.T) {
+	dir := t.TempDir()
+	dest := DirDestination{Dir: dir}
+	target := filepath.Join(dir, "part", "01.pdf")
+
+	w, err := dest.Create("part/01.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "chapter")
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("the file is in place before it was closed: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(target); err != nil || string(data) != "chapter" {
+		t.Errorf("got %q, %v, want the written file", data, err)
+	}
+
+	// An aborted file leaves the file before it untouched and nothing else
+	w, err = dest.Create("part/01.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "partial")
+	if err = w.(Aborter).Abort(); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(target))
+	if data, _ := os.ReadFile(target); len(entries) != 1 || string(data) != "chapter" {
+		t.Errorf("got %d files and %q after the abort, want the first file alone", len(entries), data)
+	}
+}
+
+func TestCommandDestination(t *testing.T) {
+	dir := t.TempDir()
+	dest := CommandDestination{Args: []string{os.Args[0], dir, "02.pdf"}}
+	for _, name := range []string{"01.pdf", "02.pdf"} {
+		err := Deliver(dest, name, strings.NewReader("chapter "+name))
+		var deliveryErr *DeliveryError
+		switch name {
+		case "01.pdf":
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		case "02.pdf":
+			if !errors.As(err, &deliveryErr) || deliveryErr.Name != name || deliveryErr.ExitCode != 3 {
+				t.Errorf("%s: got %v, want a *DeliveryError with exit code 3", name, err)
+			}
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "01.pdf")); err != nil || string(data) != "chapter 01.pdf" {
+		t.Errorf("got %q, %v, want the delivered file", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "02.pdf")); !os.IsNotExist(err) {
+		t.Errorf("the refused file was stored: %v", err)
+	}
+
+	// {name} in an argument is replaced by the name of the file
+	dest = CommandDestination{Args: []string{os.Args[0], dir, "{name}"}}
+	var deliveryErr *DeliveryError
+	if err := Deliver(dest, "03.pdf", strings.NewReader("")); !errors.As(err, &deliveryErr) {
+		t.Errorf("got %v, want the file refused by its name", err)
+	}
+}
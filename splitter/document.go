@@ -0,0 +1,183 @@
+package splitter
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Document is a source PDF read and validated once, so that every chapter is taken from memory
+// instead of parsing the cross-reference table and object streams of the source again.
+// Its methods are safe for concurrent use: chapters are taken one at a time, writing them runs
+// concurrently.
+type Document struct {
+	mu  sync.Mutex
+	ctx *model.Context
+}
+
+// ReadDocument reads and validates a source document. The outline is checked with CheckOutline
+// before pdfcpu walks it, so a malformed outline fails here instead of hanging the validation.
+// Parameters:
+//   - rs: source document
+//   - conf: pdfcpu configuration for reading the source, nil for the default
+//   - limits: limits on the outline of the source
+//
+// Returns:
+//   - *Document: the document in memory
+//   - error: the wrapped read or validation error, or the error of CheckOutline
+func ReadDocument(rs io.ReadSeeker, conf *model.Configuration, limits Limits) (*Document, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("rewind source: %w", err)
+	}
+	c := configuration(conf)
+	c.Cmd = model.TRIM
+	ctx, err := api.ReadContext(rs, c)
+	if err != nil {
+		return nil, fmt.Errorf("read source: %w", err)
+	}
+	if err = CheckOutline(ctx, limits); err != nil {
+		return nil, err
+	}
+	if err = api.ValidateContext(ctx); err != nil {
+		return nil, fmt.Errorf("validate source: %w", err)
+	}
+	if c.Optimize {
+		if err = api.OptimizeContext(ctx); err != nil {
+			return nil, fmt.Errorf("optimize source: %w", err)
+		}
+	}
+	if err = pdfcpu.CacheFormFonts(ctx); err != nil {
+		return nil, fmt.Errorf("read form fonts: %w", err)
+	}
+	return &Document{ctx: ctx}, nil
+}
+
+// PageCount returns the number of pages of the document.
+func (d *Document) PageCount() int {
+	return d.ctx.PageCount
+}
+
+// Bookmarks returns the outline of the document as read by pdfcpu.
+// Returns:
+//   - []pdfcpu.Bookmark: the bookmark tree, nil if the document has no outline
+//   - error: if a bookmark cannot be resolved
+func (d *Document) Bookmarks() ([]pdfcpu.Bookmark, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bookmarks, err := pdfcpu.Bookmarks(d.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read bookmarks: %w", err)
+	}
+	return bookmarks, nil
+}
+
+// Inspect calls f with the pdfcpu context of the document, one call at a time. f must not
+// change the document; what it locates, like name trees, is shared with later calls.
+func (d *Document) Inspect(f func(ctx *model.Context) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return f(d.ctx)
+}
+
+// Trim writes the selected pages like api.Trim, taking them from the document in memory.
+// Parameters:
+//   - w: destination of the chapter
+//   - selection: pdfcpu page selection expressions
+//
+// Returns:
+//   - error: if the selection is invalid or the pages cannot be copied or written
+func (d *Document) Trim(w io.Writer, selection []string) error {
+	pages, err := api.PagesForPageSelection(d.ctx.PageCount, selection, false, true)
+	if err != nil {
+		return err
+	}
+	var pageNrs []int
+	for page, selected := range pages {
+		if selected {
+			pageNrs = append(pageNrs, page)
+		}
+	}
+	sort.Ints(pageNrs)
+
+	d.mu.Lock()
+	chapterCtx, err := pdfcpu.ExtractPages(d.chapterSource(), pageNrs, false)
+	postValidate := d.ctx.Configuration.PostProcessValidate
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if postValidate {
+		if err = api.ValidateContext(chapterCtx); err != nil {
+			return err
+		}
+	}
+	return api.WriteContext(chapterCtx, w)
+}
+
+// chapterSource returns a view of the source to take one chapter from. pdfcpu rewrites the page
+// references of the named destinations in the context it copies pages from, which would point
+// those of the next chapter to the wrong objects, so the view has its own copy of the Dests name
+// tree and of the objects it holds; all other objects are shared with the source.
+func (d *Document) chapterSource() *model.Context {
+	xRefTable := *d.ctx.XRefTable
+	xRefTable.Table = maps.Clone(d.ctx.Table)
+	xRefTable.Names = map[string]*model.Node{}
+	ctx := *d.ctx
+	ctx.XRefTable = &xRefTable
+	tree := d.ctx.Names["Dests"]
+	if tree == nil {
+		return &ctx
+	}
+
+	// A single leaf holds all destinations; it is bound like the original tree when the chapter is written
+	dests := &model.Node{D: types.NewDict()}
+	seen := map[int]bool{}
+	err := tree.Process(d.ctx.XRefTable, func(_ *model.XRefTable, k string, v *types.Object) error {
+		copyObjects(&xRefTable, *v, seen)
+		dests.AppendToNames(k, (*v).Clone())
+		return nil
+	})
+	// Taking the pages without the named destinations is still correct
+	if err == nil {
+		xRefTable.Names["Dests"] = dests
+	}
+	return &ctx
+}
+
+// copyObjects replaces the objects o refers to, directly or through other objects, by copies in
+// the table. Pages are only read while a chapter is taken, so references into the page tree are
+// not followed.
+func copyObjects(xRefTable *model.XRefTable, o types.Object, seen map[int]bool) {
+	switch o := o.(type) {
+	case types.IndirectRef:
+		objNr := o.ObjectNumber.Value()
+		entry, ok := xRefTable.Table[objNr]
+		if seen[objNr] || !ok || entry.Object == nil {
+			return
+		}
+		seen[objNr] = true
+		if d, ok := entry.Object.(types.Dict); ok && d.Type() != nil && (*d.Type() == "Page" || *d.Type() == "Pages") {
+			return
+		}
+		copied := *entry
+		copied.Object = entry.Object.Clone()
+		xRefTable.Table[objNr] = &copied
+		copyObjects(xRefTable, copied.Object, seen)
+	case types.Dict:
+		for _, v := range o {
+			copyObjects(xRefTable, v, seen)
+		}
+	case types.Array:
+		for _, v := range o {
+			copyObjects(xRefTable, v, seen)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package splitter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultNameTemplate reproduces the classic "01_Title.pdf" chapter file names.
+const DefaultNameTemplate = "{order}_{title}"
+
+// defaultOrderWidth is the zero-padded width of {order} without an explicit width.
+const defaultOrderWidth = 2
+
+// defaultTemplate is DefaultNameTemplate parsed, the naming of DefaultFileName.
+var defaultTemplate = mustParseNameTemplate(DefaultNameTemplate)
+
+// nameSegment is a piece of a parsed name template: literal text or a placeholder.
+type nameSegment struct {
+	literal string
+	field   string
+	width   int
+}
+
+// NameTemplate is a parsed file name template without extension, the --name-template of the
+// pdf-split command, e.g. "{order}_{title}".
+type NameTemplate struct {
+	segments []nameSegment
+}
+
+// NameFields are the values of the placeholders of a NameTemplate.
+// LogicalStart and LogicalEnd are the printed page numbers of the first and last page.
+type NameFields struct {
+	Order        int
+	Title        string
+	StartPage    int
+	EndPage      int
+	Source       string
+	LogicalStart string
+	LogicalEnd   string
+}
+
+// ParseNameTemplate parses a name template. Placeholders are {order}, {order:N} for a
+// zero-padded width of N digits, {title}, {start}, {end}, {source}, the source file name without
+// extension, and {logical_start} and {logical_end}, the printed page numbers. Braces that do not
+// close are kept as text.
+// Parameters:
+//   - template: the template without the .pdf extension
+//
+// Returns:
+//   - NameTemplate: the parsed template
+//   - error: if it contains an unknown placeholder or renders to nothing
+func ParseNameTemplate(template string) (NameTemplate, error) {
+	var segments []nameSegment
+	rest := template
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		end := -1
+		if open >= 0 {
+			end = strings.IndexByte(rest[open:], '}')
+		}
+		if open < 0 || end < 0 {
+			segments = append(segments, nameSegment{literal: rest})
+			break
+		}
+		if open > 0 {
+			segments = append(segments, nameSegment{literal: rest[:open]})
+		}
+		placeholder := rest[open+1 : open+end]
+		segment, err := parsePlaceholder(placeholder)
+		if err != nil {
+			return NameTemplate{}, err
+		}
+		segments = append(segments, segment)
+		rest = rest[open+end+1:]
+	}
+	if len(segments) == 0 {
+		return NameTemplate{}, errors.New("the name template must not be empty")
+	}
+	return NameTemplate{segments: segments}, nil
+}
+
+// parsePlaceholder parses the text between the braces of a template placeholder.
+func parsePlaceholder(placeholder string) (nameSegment, error) {
+	name, width, hasWidth := strings.Cut(placeholder, ":")
+	switch name {
+	case "order":
+		segment := nameSegment{field: name, width: defaultOrderWidth}
+		if hasWidth {
+			n, err := strconv.Atoi(width)
+			if err != nil || n < 1 || n > 9 {
+				return nameSegment{}, fmt.Errorf("invalid width in placeholder {%s}: must be 1 to 9", placeholder)
+			}
+			segment.width = n
+		}
+		return segment, nil
+	case "title", "start", "end", "source", "logical_start", "logical_end":
+		if !hasWidth {
+			return nameSegment{field: name}, nil
+		}
+	}
+	return nameSegment{}, fmt.Errorf("unknown placeholder {%s}: use {order}, {order:N}, {title}, {start}, {end}, {logical_start}, {logical_end} or {source}", placeholder)
+}
+
+// mustParseNameTemplate parses a built-in template.
+func mustParseNameTemplate(template string) NameTemplate {
+	t, err := ParseNameTemplate(template)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// UsesLogicalPages reports whether the template contains {logical_start} or {logical_end}.
+func (t NameTemplate) UsesLogicalPages() bool {
+	for _, segment := range t.segments {
+		if segment.field == "logical_start" || segment.field == "logical_end" {
+			return true
+		}
+	}
+	return false
+}
+
+// Render returns the file name stem of the fields, before SanitizeFilename and NameRules apply.
+func (t NameTemplate) Render(f NameFields) string {
+	var sb strings.Builder
+	for _, segment := range t.segments {
+		switch segment.field {
+		case "":
+			sb.WriteString(segment.literal)
+		case "order":
+			fmt.Fprintf(&sb, "%0*d", segment.width, f.Order)
+		case "title":
+			sb.WriteString(f.Title)
+		case "start":
+			fmt.Fprint(&sb, f.StartPage)
+		case "end":
+			fmt.Fprint(&sb, f.EndPage)
+		case "logical_start":
+			sb.WriteString(f.LogicalStart)
+		case "logical_end":
+			sb.WriteString(f.LogicalEnd)
+		case "source":
+			sb.WriteString(f.Source)
+		}
+	}
+	return sb.String()
+}
+
+// FileName returns an ExportOptions.FileName naming chapters with the template, sanitized and
+// with the .pdf extension. The chapters of the library have no printed page numbers, so
+// {logical_start} and {logical_end} render to the physical ones.
+// Parameters:
+//   - source: the source file name without extension, for {source}
+func (t NameTemplate) FileName(source string) func(Chapter) string {
+	return func(cpt Chapter) string {
+		return SanitizeFilename(t.Render(NameFields{
+			Order:        cpt.Order,
+			Title:        cpt.Title,
+			StartPage:    cpt.StartPage,
+			EndPage:      cpt.EndPage,
+			Source:       source,
+			LogicalStart: strconv.Itoa(cpt.StartPage),
+			LogicalEnd:   strconv.Itoa(cpt.EndPage),
+		})) + ".pdf"
+	}
+}
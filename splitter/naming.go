@@ -0,0 +1,163 @@
+package splitter
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// defaultMaxLength is the longest file name of the common filesystems, in bytes or UTF-16 units.
+const defaultMaxLength = 255
+
+// NameRules are the file name limits of the filesystem chapter files are written to.
+// The pdf-split command names its files with the same rules, so a library split and a command
+// line split of a document give the same file names.
+// Under all rules, device names such as CON are prefixed with an underscore and trailing dots and
+// spaces are removed, so that the files can still be copied to a Windows filesystem.
+type NameRules struct {
+	// WindowsNames also replaces control characters, as FAT, exFAT and NTFS require
+	WindowsNames bool
+	// MaxLength is the longest file name, in UTF-16 units if UTF16 is set and in bytes
+	// otherwise; 0 for 255
+	MaxLength int
+	UTF16     bool
+	// MaxBytes also limits file names to this many bytes, 0 for no limit of its own
+	MaxBytes int
+}
+
+// Name rules of the common filesystems. The zero NameRules are POSIXNames.
+var (
+	POSIXNames = NameRules{MaxLength: defaultMaxLength}
+	FATNames   = NameRules{WindowsNames: true, MaxLength: defaultMaxLength, UTF16: true}
+	NTFSNames  = NameRules{WindowsNames: true, MaxLength: defaultMaxLength, UTF16: true}
+)
+
+// deviceNames are the device names Windows does not allow as file or directory names,
+// with or without an extension.
+var deviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// IsDeviceName reports whether a file name is a device name reserved by Windows.
+func IsDeviceName(name string) bool {
+	base, _, _ := strings.Cut(name, ".")
+	return deviceNames[strings.ToUpper(strings.TrimRight(base, " "))]
+}
+
+// SanitizeFilename replaces the characters that are not allowed in file names on common
+// filesystems with underscores.
+func SanitizeFilename(filename string) string {
+	// Define characters that are not allowed in filenames
+	illegal := []string{"/", "\\", ":", "*", "?", "\"", "<", ">", "|"}
+	result := filename
+
+	// Replace each illegal character with an underscore
+	for _, char := range illegal {
+		result = strings.ReplaceAll(result, char, "_")
+	}
+	return result
+}
+
+// Clean applies the rules to a file name stem that is already free of the characters no common
+// filesystem allows, e.g. by SanitizeFilename. The stem is shortened so that it still fits with
+// a .pdf extension.
+func (r NameRules) Clean(stem string) string {
+	if r.WindowsNames {
+		stem = strings.Map(func(c rune) rune {
+			if c < 0x20 {
+				return '_'
+			}
+			return c
+		}, stem)
+	}
+	if IsDeviceName(stem) {
+		stem = "_" + stem
+	}
+	return r.Fit(stem, "")
+}
+
+// Fit shortens a cleaned stem so that it still fits with suffix and a .pdf extension, and
+// appends suffix, which is ASCII so that its length is the same in all units. Characters are
+// never split.
+func (r NameRules) Fit(stem, suffix string) string {
+	stem = r.Truncate(stem, r.maxLength()-len(".pdf")-len(suffix))
+	if r.MaxBytes > 0 {
+		stem = POSIXNames.Truncate(stem, r.MaxBytes-len(".pdf")-len(suffix))
+	}
+	return strings.TrimRight(stem, ". ") + suffix
+}
+
+// Unique returns stem, or stem with the suffix _2, _3 and so on, fitted by Fit, if it is in
+// used, also in a different case, so that no chapter overwrites an earlier one. It adds the
+// returned stem to used.
+func (r NameRules) Unique(stem string, used map[string]bool) string {
+	name := stem
+	for n := 2; used[strings.ToLower(name)]; n++ {
+		name = r.Fit(stem, fmt.Sprintf("_%d", n))
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+// Truncate shortens a name to at most limit units of the filesystem, without splitting a character.
+func (r NameRules) Truncate(name string, limit int) string {
+	length := 0
+	for i, c := range name {
+		if r.UTF16 {
+			length += utf16.RuneLen(c)
+		} else {
+			length += utf8.RuneLen(c)
+		}
+		if length > limit {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+// maxLength returns MaxLength, or the default for the zero NameRules.
+func (r NameRules) maxLength() int {
+	if r.MaxLength == 0 {
+		return defaultMaxLength
+	}
+	return r.MaxLength
+}
+
+// FileNames returns the file names ExportChapters writes the chapters to: the names of
+// opts.FileName, or DefaultFileName, cleaned by opts.Names and numbered _2, _3 and so on if
+// several chapters get the same name, also in case only.
+// Parameters:
+//   - chapters: chapters to write, in the order they are written
+//   - opts: file naming and name rules
+//
+// Returns:
+//   - []string: the file name of every chapter, without directory
+func FileNames(chapters []Chapter, opts ExportOptions) []string {
+	fileName := fileNamer(opts)
+	names := make([]string, len(chapters))
+	for i, cpt := range chapters {
+		names[i] = fileName(cpt)
+	}
+	return names
+}
+
+// fileNamer returns a function that names the chapters as FileNames does, one at a time in the
+// order they are written.
+func fileNamer(opts ExportOptions) func(Chapter) string {
+	fileName := opts.FileName
+	if fileName == nil {
+		fileName = DefaultFileName
+	}
+	used := make(map[string]bool)
+	return func(cpt Chapter) string {
+		name := fileName(cpt)
+		ext := filepath.Ext(name)
+		return opts.Names.Unique(opts.Names.Clean(strings.TrimSuffix(name, ext)), used) + ext
+	}
+}
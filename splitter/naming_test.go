@@ -0,0 +1,88 @@
+package splitter
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// namingCase is a case of ../testdata/naming.json: one-page chapters with the given titles and
+// the file names they get with the options of the case. The command line tool is checked
+// against the same cases.
+type namingCase struct {
+	Name          string   `json:"name"`
+	Titles        []string `json:"titles"`
+	NameTemplate  string   `json:"name_template,omitempty"`
+	TargetFS      string   `json:"target_fs,omitempty"`
+	MaxNameLength int      `json:"max_name_length,omitempty"`
+	Files         []string `json:"files"`
+}
+
+// namingChapters plans one-page chapters with the given titles on the first pages of the book
+// fixture, so that the titles are read back from an outline.
+func namingChapters(t *testing.T, titles []string) []Chapter {
+	t.Helper()
+	outline := make([]pdfcpu.Bookmark, len(titles))
+	for i, title := range titles {
+		outline[i] = pdfcpu.Bookmark{Title: title, PageFrom: i + 1}
+	}
+	var document bytes.Buffer
+	if err := api.AddBookmarks(openFixture(t, "book.pdf"), &document, outline, true, nil); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ReadDocument(bytes.NewReader(document.Bytes()), nil, DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookmarks, err := doc.Bookmarks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chapters, err := PlanChapters(bookmarks, PlanOptions{LastPage: len(titles)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chapters
+}
+
+func TestFileNamesCases(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "testdata", "naming.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Cases []namingCase `json:"cases"`
+	}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range doc.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			opts := ExportOptions{Names: POSIXNames}
+			switch tc.TargetFS {
+			case "fat":
+				opts.Names = FATNames
+			case "ntfs":
+				opts.Names = NTFSNames
+			}
+			opts.Names.MaxBytes = tc.MaxNameLength
+			if tc.NameTemplate != "" {
+				template, err := ParseNameTemplate(tc.NameTemplate)
+				if err != nil {
+					t.Fatal(err)
+				}
+				opts.FileName = template.FileName("naming")
+			}
+			if got := FileNames(namingChapters(t, tc.Titles), opts); !slices.Equal(got, tc.Files) {
+				t.Errorf("got %q, want %q", got, tc.Files)
+			}
+		})
+	}
+}
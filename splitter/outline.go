@@ -0,0 +1,148 @@
+package splitter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// MaxOutlineDepth is the deepest outline nesting accepted before the outline is considered malformed.
+const MaxOutlineDepth = 64
+
+// ErrMalformedOutline is returned for an outline that cannot be walked safely.
+var ErrMalformedOutline = errors.New("outline appears to be malformed")
+
+// Limits bound the work a document can cause. A zero field disables its limit.
+type Limits struct {
+	// OutlineEntries is the maximum number of outline items
+	OutlineEntries int
+	// TitleLength is the maximum length of a bookmark title in bytes
+	TitleLength int
+	// Chapters is the maximum number of planned chapters
+	Chapters int
+}
+
+// DefaultLimits are generous enough for any real book; a server processing untrusted uploads
+// should set tighter ones.
+var DefaultLimits = Limits{OutlineEntries: 1_000_000, TitleLength: 4096, Chapters: 100_000}
+
+// LimitError is returned when a document exceeds the Limits.
+type LimitError struct {
+	// What is the exceeded quantity, e.g. "document outline"
+	What string
+	// Limit is the value of the exceeded limit
+	Limit int
+	// Unit is the unit of Limit, e.g. "entries"
+	Unit string
+}
+
+// Error describes the exceeded limit.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s exceeds limits (%s %s)", e.What, FormatCount(e.Limit), e.Unit)
+}
+
+// CheckOutline walks the raw outline tree and rejects structures that would make a recursive
+// traversal hang or exhaust the stack: items reachable twice, e.g. a child pointing back to an
+// ancestor, and nesting deeper than MaxOutlineDepth. pdfcpu only detects loops among siblings,
+// so this must run before the document is validated, on a context from api.ReadContext.
+// The walk also enforces the outline entry and title length limits before any bookmark is materialized.
+// Parameters:
+//   - ctx: pdfcpu context of the source document, validated or not
+//   - limits: the outline entry and title length limits
+//
+// Returns:
+//   - error: wrapping ErrMalformedOutline, or a *LimitError
+func CheckOutline(ctx *model.Context, limits Limits) error {
+	root, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	outlines, err := ctx.DereferenceDict(root["Outlines"])
+	if err != nil || outlines == nil {
+		return err
+	}
+	return checkOutlineItems(ctx, limits, outlines.IndirectRefEntry("First"), 1, map[int]bool{})
+}
+
+// checkOutlineItems checks an outline item, its siblings and their kids.
+// visited holds the object numbers of all items seen so far in the whole tree.
+func checkOutlineItems(ctx *model.Context, limits Limits, first *types.IndirectRef, depth int, visited map[int]bool) error {
+	var d types.Dict
+	for ir := first; ir != nil; ir = d.IndirectRefEntry("Next") {
+		var err error
+		if d, err = ctx.DereferenceDict(*ir); err != nil || d == nil {
+			return err
+		}
+		nr := ir.ObjectNumber.Value()
+		if visited[nr] {
+			return fmt.Errorf("%w (cycle detected at '%s')", ErrMalformedOutline, OutlineItemTitle(ctx, d))
+		}
+		visited[nr] = true
+		if err = checkOutlineItemLimits(ctx, limits, d, len(visited)); err != nil {
+			return err
+		}
+		kids := d.IndirectRefEntry("First")
+		if kids == nil {
+			continue
+		}
+		if depth >= MaxOutlineDepth {
+			return fmt.Errorf("%w (nested deeper than %d levels at '%s')", ErrMalformedOutline, MaxOutlineDepth, OutlineItemTitle(ctx, d))
+		}
+		if err = checkOutlineItems(ctx, limits, kids, depth+1, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOutlineItemLimits enforces the entry count and title length limits on a raw outline item,
+// entries being the number of outline items seen so far, including this one.
+func checkOutlineItemLimits(ctx *model.Context, limits Limits, item types.Dict, entries int) error {
+	if limits.OutlineEntries > 0 && entries > limits.OutlineEntries {
+		return &LimitError{What: "document outline", Limit: limits.OutlineEntries, Unit: "entries"}
+	}
+	if limits.TitleLength <= 0 {
+		return nil
+	}
+	obj, err := ctx.Dereference(item["Title"])
+	if err != nil {
+		return nil
+	}
+	var length int
+	switch o := obj.(type) {
+	case types.StringLiteral:
+		length = len(o)
+	case types.HexLiteral:
+		length = len(o) / 2
+	}
+	if length > limits.TitleLength {
+		return &LimitError{What: "bookmark title", Limit: limits.TitleLength, Unit: "bytes"}
+	}
+	return nil
+}
+
+// OutlineItemTitle returns the title of a raw outline item for use in messages, or "" if it
+// cannot be read.
+func OutlineItemTitle(ctx *model.Context, item types.Dict) string {
+	obj, err := ctx.Dereference(item["Title"])
+	if err != nil {
+		return ""
+	}
+	title, err := model.Text(obj)
+	if err != nil {
+		return ""
+	}
+	return title
+}
+
+// FormatCount formats a number with thousands separators, e.g. 1,000,000.
+func FormatCount(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
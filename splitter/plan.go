@@ -0,0 +1,160 @@
+package splitter
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// ErrPastLastPage is returned when the first chapter starts after PlanOptions.LastPage.
+var ErrPastLastPage = errors.New("first chapter starts after the last page")
+
+// PlanOptions controls how PlanChapters turns bookmarks into chapters.
+type PlanOptions struct {
+	// LastPage is the page the last chapter ends on, usually the page count of the document;
+	// chapters starting after it are dropped
+	LastPage int
+	// Overlap ends every chapter on the start page of the next one instead of the page before,
+	// so that the page two chapters meet on belongs to both
+	Overlap bool
+	// Separate reports whether bookmark i starts a chapter of its own even though the chapter
+	// before starts on the same page, e.g. because it starts in the middle of the page; nil
+	// merges every such bookmark into the chapter before
+	Separate func(i int) bool
+	// Limits bounds the number of chapters; the zero value sets no limit
+	Limits Limits
+}
+
+// PageOrder returns the indexes of the bookmarks sorted by their start page, keeping the outline
+// order of bookmarks on the same page.
+// Parameters:
+//   - bookmarks: bookmarks in outline order
+//
+// Returns:
+//   - []int: indexes into bookmarks in page order
+//   - []int: indexes of the bookmarks that point to an earlier page than the bookmark before
+//     them in the outline
+func PageOrder(bookmarks []pdfcpu.Bookmark) ([]int, []int) {
+	indexes := make([]int, len(bookmarks))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return bookmarks[indexes[a]].PageFrom < bookmarks[indexes[b]].PageFrom
+	})
+
+	var reordered []int
+	for i := 1; i < len(bookmarks); i++ {
+		if bookmarks[i].PageFrom < bookmarks[i-1].PageFrom {
+			reordered = append(reordered, i)
+		}
+	}
+	return indexes, reordered
+}
+
+// PlanChapters plans one chapter per bookmark, in page order. A bookmark starting on the page
+// the chapter before starts on is merged into it, unless opts.Separate keeps it apart. Each
+// chapter ends on the page before the next chapter starts, or with opts.Overlap on that page;
+// chapters starting on the same page share it. The last chapter ends on opts.LastPage.
+// Parameters:
+//   - bookmarks: one level of the outline, in outline order
+//   - opts: last page, boundary and merge rules, and the chapter limit
+//
+// Returns:
+//   - []Chapter: the chapters in page order, with the bookmarks they were made from
+//   - error: ErrNoChapters without bookmarks, ErrPastLastPage, a *LimitError or the error of CheckRanges
+func PlanChapters(bookmarks []pdfcpu.Bookmark, opts PlanOptions) ([]Chapter, error) {
+	var chapters []Chapter
+	for cpt, err := range plannedChapters(bookmarks, opts) {
+		if err != nil {
+			return nil, err
+		}
+		chapters = append(chapters, cpt)
+	}
+
+	// Never hand a broken range to pdfcpu
+	if err := CheckRanges(chapters); err != nil {
+		return nil, err
+	}
+	return chapters, nil
+}
+
+// plannedChapters yields the chapters of PlanChapters in page order, each as soon as the start of
+// the next one ends its range, without checking the ranges. Chapters starting after opts.LastPage
+// end the plan.
+// Parameters:
+//   - bookmarks: one level of the outline, in outline order
+//   - opts: as for PlanChapters
+//
+// Returns:
+//   - iter.Seq2[Chapter, error]: the chapters, or one error that ends the plan: ErrNoChapters,
+//     ErrPastLastPage or a *LimitError
+func plannedChapters(bookmarks []pdfcpu.Bookmark, opts PlanOptions) iter.Seq2[Chapter, error] {
+	return func(yield func(Chapter, error) bool) {
+		byPage, _ := PageOrder(bookmarks)
+
+		// Convert bookmarks to chapters, merging bookmarks that start on the same page, and end
+		// every chapter where the next one starts, or on the page before
+		var pending *Chapter
+		for _, i := range byPage {
+			bm := bookmarks[i]
+			if pending != nil && bm.PageFrom == pending.StartPage && (opts.Separate == nil || !opts.Separate(i)) {
+				pending.Merged = append(pending.Merged, i)
+				continue
+			}
+			if bm.PageFrom > opts.LastPage {
+				if pending == nil {
+					yield(Chapter{}, fmt.Errorf("'%s' starts on page %d, after page %d: %w", bm.Title, bm.PageFrom, opts.LastPage, ErrPastLastPage))
+					return
+				}
+				break
+			}
+			next := Chapter{Title: bm.Title, Order: 1, StartPage: bm.PageFrom, Bookmark: i}
+			if pending != nil {
+				next.Order = pending.Order + 1
+				pending.EndPage = next.StartPage
+				if !opts.Overlap && next.StartPage > pending.StartPage {
+					pending.EndPage--
+				}
+				if !yield(*pending, nil) {
+					return
+				}
+			}
+			if opts.Limits.Chapters > 0 && next.Order > opts.Limits.Chapters {
+				yield(Chapter{}, &LimitError{What: "chapter plan", Limit: opts.Limits.Chapters, Unit: "chapters"})
+				return
+			}
+			pending = &next
+		}
+		if pending == nil {
+			yield(Chapter{}, ErrNoChapters)
+			return
+		}
+		pending.EndPage = opts.LastPage
+		yield(*pending, nil)
+	}
+}
+
+// CheckRanges fails if a chapter does not cover a page range that can be trimmed,
+// listing every such chapter, instead of letting pdfcpu fail or export the wrong pages.
+// Parameters:
+//   - chapters: list of chapters
+//
+// Returns:
+//   - error: if any chapter starts before page 1 or ends before it starts
+func CheckRanges(chapters []Chapter) error {
+	var broken []string
+	for _, cpt := range chapters {
+		if cpt.StartPage < 1 || cpt.EndPage < cpt.StartPage {
+			broken = append(broken, fmt.Sprintf("  '%s' (pages %d-%d)", cpt.Title, cpt.StartPage, cpt.EndPage))
+		}
+	}
+	if len(broken) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d chapter(s) have no valid page range:\n%s", len(broken), strings.Join(broken, "\n"))
+}
@@ -0,0 +1,199 @@
+package splitter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// infoKeys are the entries of the document information dictionary kept in a Snapshot.
+var infoKeys = []string{"Title", "Author", "Subject", "Keywords", "Creator", "Producer", "CreationDate", "ModDate"}
+
+// SnapshotVersion is the version of the snapshot format written by WriteSnapshot.
+const SnapshotVersion = 1
+
+// Snapshot is what chapters are planned from: the page count, outline, page labels and document
+// information of a document. It is written and read as JSON, so that a planning problem can be
+// reported and reproduced with the snapshot of a document instead of the document itself.
+// SchemaVersion is 0 for snapshots written before the format had a version, which are version 1.
+type Snapshot struct {
+	SchemaVersion int               `json:"schema_version,omitempty"`
+	PageCount     int               `json:"page_count"`
+	Outline       []pdfcpu.Bookmark `json:"outline,omitempty"`
+	PageLabels    []PageLabel       `json:"page_labels,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+// PageLabel is a range of the page labels of a document, running from Page to the page before
+// the next range. Style is the numbering style of the PDF: D, R, r, A or a, or empty for labels
+// made of the prefix only. Start is the number of its first page, 0 for the default of 1.
+type PageLabel struct {
+	Page   int    `json:"page"`
+	Style  string `json:"style,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Start  int    `json:"start,omitempty"`
+}
+
+// Snapshot returns the snapshot of the document.
+// Returns:
+//   - *Snapshot: the page count, outline, page labels and document information
+//   - error: if a bookmark cannot be resolved
+func (d *Document) Snapshot() (*Snapshot, error) {
+	bookmarks, err := d.Bookmarks()
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{SchemaVersion: SnapshotVersion, PageCount: d.PageCount(), Outline: bookmarks}
+	err = d.Inspect(func(ctx *model.Context) error {
+		s.PageLabels = pageLabels(ctx)
+		s.Metadata = documentInfo(ctx)
+		return nil
+	})
+	return s, err
+}
+
+// pageLabels returns the page label ranges of a document in page order, nil if it has none.
+func pageLabels(ctx *model.Context) []PageLabel {
+	root, err := ctx.Catalog()
+	if err != nil {
+		return nil
+	}
+	tree, err := ctx.DereferenceDict(root["PageLabels"])
+	if err != nil || tree == nil {
+		return nil
+	}
+	var labels []PageLabel
+	WalkNumberTree(ctx, tree, 0, func(index int, value types.Object) bool {
+		d, err := ctx.DereferenceDict(value)
+		if err != nil || d == nil {
+			return true
+		}
+		label := PageLabel{Page: index + 1}
+		if style := d.NameEntry("S"); style != nil {
+			label.Style = *style
+		}
+		if prefix, err := ctx.Dereference(d["P"]); err == nil && prefix != nil {
+			label.Prefix, _ = model.Text(prefix)
+		}
+		if start := d.IntEntry("St"); start != nil {
+			label.Start = *start
+		}
+		labels = append(labels, label)
+		return true
+	})
+	return labels
+}
+
+// documentInfo returns the non-empty text entries of infoKeys in the document information
+// dictionary, nil if there are none.
+func documentInfo(ctx *model.Context) map[string]string {
+	if ctx.Info == nil {
+		return nil
+	}
+	info, err := ctx.DereferenceDict(*ctx.Info)
+	if err != nil || info == nil {
+		return nil
+	}
+	var metadata map[string]string
+	for _, key := range infoKeys {
+		obj, err := ctx.Dereference(info[key])
+		if err != nil || obj == nil {
+			continue
+		}
+		if text, err := model.Text(obj); err == nil && strings.TrimSpace(text) != "" {
+			if metadata == nil {
+				metadata = make(map[string]string)
+			}
+			metadata[key] = strings.TrimSpace(text)
+		}
+	}
+	return metadata
+}
+
+// WalkNumberTree calls fn for the entries of a number tree in key order until it returns false.
+// depth guards against cyclic trees; pass 0 for the root.
+// Returns:
+//   - bool: false if fn stopped the walk
+func WalkNumberTree(ctx *model.Context, node types.Dict, depth int, fn func(key int, value types.Object) bool) bool {
+	if depth > MaxOutlineDepth {
+		return false
+	}
+	if nums, err := ctx.DereferenceArray(node["Nums"]); err == nil {
+		for i := 0; i+1 < len(nums); i += 2 {
+			key, err := ctx.DereferenceInteger(nums[i])
+			if err != nil || key == nil {
+				continue
+			}
+			if !fn(key.Value(), nums[i+1]) {
+				return false
+			}
+		}
+	}
+	kids, err := ctx.DereferenceArray(node["Kids"])
+	if err != nil {
+		return true
+	}
+	for _, kid := range kids {
+		d, err := ctx.DereferenceDict(kid)
+		if err != nil || d == nil {
+			continue
+		}
+		if !WalkNumberTree(ctx, d, depth+1, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteSnapshot writes a snapshot as indented JSON.
+func WriteSnapshot(w io.Writer, s *Snapshot) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// ReadSnapshot reads a snapshot written by WriteSnapshot. Unknown fields are rejected, so that a
+// snapshot of a newer version is not planned with parts of it silently missing.
+// Parameters:
+//   - r: the JSON snapshot
+//
+// Returns:
+//   - *Snapshot: the snapshot
+//   - error: if the JSON is malformed, of a newer version or the snapshot has no pages
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var s Snapshot
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+	if s.SchemaVersion > SnapshotVersion {
+		return nil, fmt.Errorf("read snapshot: schema_version %d is newer than the supported %d", s.SchemaVersion, SnapshotVersion)
+	}
+	if s.PageCount < 1 {
+		return nil, errors.New("read snapshot: page_count must be at least 1")
+	}
+	return &s, nil
+}
+
+// PlanSnapshot plans one chapter per top-level bookmark of a snapshot with PlanChapters. It reads
+// nothing but the snapshot, so a plan can be reproduced from a snapshot alone.
+// Parameters:
+//   - s: the snapshot of the document
+//   - opts: as for PlanChapters; a LastPage of 0 is the page count of the snapshot
+//
+// Returns:
+//   - []Chapter: the chapters in page order
+//   - error: the error of PlanChapters
+func PlanSnapshot(s *Snapshot, opts PlanOptions) ([]Chapter, error) {
+	if opts.LastPage == 0 {
+		opts.LastPage = s.PageCount
+	}
+	return PlanChapters(s.Outline, opts)
+}
@@ -0,0 +1,326 @@
+// Package splitter splits a PDF document into one file per top-level bookmark.
+// It is the library form of the pdf-split command's default split: errors are returned
+// instead of ending the process, so the splitting can be embedded in other programs.
+//
+// Every pdfcpu call of the package uses the configuration it is given, or pdfcpu's default
+// configuration if it is nil. The package never changes a caller's configuration: pdfcpu changes
+// the configuration it works with, so every call works on a copy of its own. One configuration
+// can therefore be set up once, e.g. with a validation mode and passwords, and shared by
+// concurrent splits, as long as the caller does not change it while they run.
+//
+// A source is any io.ReadSeeker whose offset 0 is the first byte of the document, such as an
+// open *os.File or a *bytes.Reader. A document stored inside a larger file or blob is passed as
+// an io.NewSectionReader over its bytes. The package seeks the source to its start before it
+// reads it, so the position it is given does not matter, and leaves the position unspecified
+// afterwards. It never closes a source: a file stays owned by the caller, who closes it once
+// the calls using it returned, and may reuse it for further calls before.
+package splitter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ErrNoChapters is returned when a document has no bookmark to split at.
+var ErrNoChapters = errors.New("no chapters found")
+
+// ErrPageCount is matched by a *PageCountError, returned when a written chapter does not have
+// the pages of its range.
+var ErrPageCount = errors.New("page count differs from the plan")
+
+// PageCountError is returned when a written chapter does not have the pages of its range.
+type PageCountError struct {
+	Got  int
+	Want int
+}
+
+// Error describes the difference.
+func (e *PageCountError) Error() string {
+	return fmt.Sprintf("has %d pages, want %d", e.Got, e.Want)
+}
+
+// Is reports whether target is ErrPageCount.
+func (e *PageCountError) Is(target error) bool {
+	return target == ErrPageCount
+}
+
+// Chapter is a page range of the source document that is written to its own file.
+// Order is the 1-based position in page order, StartPage and EndPage are inclusive.
+// Chapters planned by PlanChapters also name the bookmarks they were made from.
+type Chapter struct {
+	Title     string
+	Order     int
+	StartPage int
+	EndPage   int
+	// Bookmark is the index of the bookmark the chapter starts at
+	Bookmark int
+	// Merged are the indexes of the bookmarks on the start page that were merged into the chapter
+	Merged []int
+}
+
+// Pages returns the number of pages of the chapter.
+func (c Chapter) Pages() int {
+	return c.EndPage - c.StartPage + 1
+}
+
+// ExportOptions controls how ExportChapters writes the chapters.
+type ExportOptions struct {
+	// Dir is the directory the chapter files are written to, created if missing
+	Dir string
+	// Destination receives the chapter files instead of Dir, e.g. a CommandDestination
+	Destination Destination
+	// FileName returns the file name of a chapter; the default is "01_Title.pdf"
+	FileName func(Chapter) string
+	// Names are the file name rules of the filesystem of Dir; the zero value is POSIXNames
+	Names NameRules
+	// Conf is the pdfcpu configuration of every pdfcpu call: reading the source, e.g. with its
+	// passwords, writing the chapters and reading them back; nil for pdfcpu's default
+	Conf *model.Configuration
+	// VerifyPages reads back every written file and compares its page count with the chapter
+	VerifyPages bool
+	// Workers is the number of chapters trimmed concurrently; 0 trims one at a time
+	Workers int
+	// LowMemory reads the source again for every chapter instead of keeping it in memory;
+	// the chapters are then trimmed one at a time
+	LowMemory bool
+	// PostProcess changes every chapter after it was trimmed and verified and before it is
+	// delivered, e.g. to stamp or re-encrypt it with another library, by rewriting the file at
+	// tmpPath in place. It runs on the workers, concurrently for several chapters. An error fails
+	// the chapter like a failed trim, so nothing of it is delivered; nil delivers as trimmed
+	PostProcess func(ctx context.Context, c Chapter, tmpPath string) error
+}
+
+// ExtractChapters plans one chapter per top-level bookmark of a document from its Snapshot, with PlanSnapshot.
+// Each chapter ends on the page before the next chapter starts, or on the start page of the next
+// chapter if both start on the same page; the last chapter ends with the document.
+// Bookmarks are taken in page order, and bookmarks starting on the page the chapter before
+// starts on are merged into it.
+// Parameters:
+//   - rs: source document, read from its start and not closed
+//   - conf: pdfcpu configuration for reading the source, nil for the default
+//
+// Returns:
+//   - []Chapter: the chapters in page order
+//   - error: ErrNoChapters if the document has no bookmarks, or the wrapped read error
+func ExtractChapters(rs io.ReadSeeker, conf *model.Configuration) ([]Chapter, error) {
+	doc, err := ReadDocument(rs, conf, DefaultLimits)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := doc.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return PlanSnapshot(snapshot, PlanOptions{Limits: DefaultLimits})
+}
+
+// ExportChapters writes every chapter into a file of its own, named as by FileNames, and
+// finalizes the destination once all of them are delivered. Every chapter is trimmed,
+// verified and post-processed before it is handed to the destination, so a chapter that fails
+// leaves no file behind. With opts.Workers, chapters are trimmed concurrently, ahead of the delivery,
+// and still delivered in order.
+// Parameters:
+//   - rs: source document, read from its start and not closed; it must not be used elsewhere
+//     until ExportChapters returned, as the workers may still read it until then
+//   - chapters: chapters to write, e.g. from ExtractChapters
+//   - opts: output directory or destination, file naming, concurrency and source configuration
+//
+// Returns:
+//   - error: the first failure, a *ChapterError unless the source cannot be read
+func ExportChapters(rs io.ReadSeeker, chapters []Chapter, opts ExportOptions) error {
+	dest := opts.Destination
+	if dest == nil {
+		if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+			return fmt.Errorf("create output directory: %w", err)
+		}
+		dest = DirDestination{Dir: opts.Dir, Names: opts.Names}
+	}
+	names := FileNames(chapters, opts)
+
+	// Read the source once for all chapters, unless every chapter reads it on its own
+	var doc *Document
+	workers := opts.Workers
+	if opts.LowMemory {
+		workers = 1
+	} else {
+		var err error
+		if doc, err = ReadDocument(rs, opts.Conf, DefaultLimits); err != nil {
+			return err
+		}
+	}
+
+	// Trim the chapters on the workers and deliver them in order; a failure stops the rest
+	data := make([]bytes.Buffer, len(chapters))
+	ctx := context.Background()
+	exports := RunExports(ctx, len(chapters), workers, nil, func(i int) error {
+		if err := trimChapter(rs, doc, &data[i], chapters[i], names[i], opts); err != nil {
+			return err
+		}
+		return postProcess(ctx, &data[i], chapters[i], opts)
+	})
+	// No worker may read rs once the export returned, also after a failed delivery
+	defer exports.Close()
+	var manifest Manifest
+	for i, cpt := range chapters {
+		err := exports.Wait(i)
+		if errors.Is(err, context.Canceled) {
+			// A later chapter failed and stopped this one
+			if j, failure := exports.FirstFailure(i + 1); j >= 0 {
+				return &ChapterError{Chapter: chapters[j], Err: failure}
+			}
+		}
+		if err == nil {
+			err = Deliver(dest, names[i], &data[i])
+		}
+		if err != nil {
+			return &ChapterError{Chapter: cpt, Err: err}
+		}
+		data[i] = bytes.Buffer{}
+		manifest.Files = append(manifest.Files, ManifestFile{Name: names[i], Chapter: cpt})
+	}
+	if err := dest.Finalize(manifest); err != nil {
+		return fmt.Errorf("finalize destination: %w", err)
+	}
+	return nil
+}
+
+// trimChapter trims the pages of one chapter into w, from doc or, without it, from rs, and
+// optionally verifies them.
+func trimChapter(rs io.ReadSeeker, doc *Document, w *bytes.Buffer, cpt Chapter, name string, opts ExportOptions) error {
+	selection := []string{fmt.Sprintf("%d-%d", cpt.StartPage, cpt.EndPage)}
+	if doc != nil {
+		if err := doc.Trim(w, selection); err != nil {
+			return fmt.Errorf("trim: %w", err)
+		}
+	} else {
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("rewind source: %w", err)
+		}
+		if err := api.Trim(rs, w, selection, configuration(opts.Conf)); err != nil {
+			return fmt.Errorf("trim: %w", err)
+		}
+	}
+
+	// Read the pages back to catch pages lost in trimming
+	if opts.VerifyPages {
+		if err := CheckPageCount(bytes.NewReader(w.Bytes()), cpt.Pages(), opts.Conf); err != nil {
+			return fmt.Errorf("'%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// postProcess runs opts.PostProcess on a trimmed chapter in a temporary file and replaces the
+// chapter with the file it leaves behind.
+// Parameters:
+//   - ctx: passed on to opts.PostProcess
+//   - data: the trimmed chapter, replaced by the processed one
+//   - cpt: the chapter
+//   - opts: the export options, without PostProcess nothing is done
+//
+// Returns:
+//   - error: the error of opts.PostProcess, or of writing or reading the temporary file
+func postProcess(ctx context.Context, data *bytes.Buffer, cpt Chapter, opts ExportOptions) error {
+	if opts.PostProcess == nil {
+		return nil
+	}
+	f, err := os.CreateTemp("", "pdf-split-*.pdf")
+	if err != nil {
+		return fmt.Errorf("post-process: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data.Bytes())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("post-process: %w", err)
+	}
+	if err := opts.PostProcess(ctx, cpt, f.Name()); err != nil {
+		return fmt.Errorf("post-process: %w", err)
+	}
+	processed, err := os.ReadFile(f.Name())
+	if err != nil {
+		return fmt.Errorf("post-process: %w", err)
+	}
+	data.Reset()
+	data.Write(processed)
+	return nil
+}
+
+// CheckPageCount reads a written chapter back and compares its page count with the plan.
+// Parameters:
+//   - rs: the written chapter
+//   - want: number of pages the chapter should have
+//   - conf: pdfcpu configuration for reading it, e.g. with the passwords it was encrypted with
+//
+// Returns:
+//   - error: a *PageCountError on a mismatch, or the wrapped read error
+func CheckPageCount(rs io.ReadSeeker, want int, conf *model.Configuration) error {
+	got, err := api.PageCount(rs, configuration(conf))
+	if err != nil {
+		return fmt.Errorf("read back: %w", err)
+	}
+	if got != want {
+		return &PageCountError{Got: got, Want: want}
+	}
+	return nil
+}
+
+// Deliver copies a complete file to a destination. If the copy fails, the file is aborted if
+// the destination supports it, and closed otherwise.
+// Parameters:
+//   - dest: the destination
+//   - name: file name within the destination
+//   - r: content of the file
+//
+// Returns:
+//   - error: the error of creating, writing or closing the file
+func Deliver(dest Destination, name string, r io.Reader) error {
+	w, err := dest.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, r); err != nil {
+		if a, ok := w.(Aborter); ok {
+			a.Abort()
+		} else {
+			w.Close()
+		}
+		return fmt.Errorf("write '%s': %w", name, err)
+	}
+	return w.Close()
+}
+
+// DefaultFileName names a chapter file after its order and sanitized title, e.g. "01_Intro.pdf",
+// rendered from DefaultNameTemplate like the files of the pdf-split command.
+func DefaultFileName(cpt Chapter) string {
+	return defaultTemplate.FileName("")(cpt)
+}
+
+// configuration returns a copy of conf for one pdfcpu call, or a fresh default configuration if
+// conf is nil. pdfcpu changes the command mode of the configuration it is given and keeps it in
+// the context it reads, so a caller's configuration is never handed to pdfcpu itself. The new
+// passwords are pointers and are copied as well, so that no two calls share any part of it.
+func configuration(conf *model.Configuration) *model.Configuration {
+	if conf == nil {
+		return model.NewDefaultConfiguration()
+	}
+	c := *conf
+	if conf.UserPWNew != nil {
+		pw := *conf.UserPWNew
+		c.UserPWNew = &pw
+	}
+	if conf.OwnerPWNew != nil {
+		pw := *conf.OwnerPWNew
+		c.OwnerPWNew = &pw
+	}
+	return &c
+}
@@ -0,0 +1,529 @@
+package splitter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// openFixture opens a document of ../testdata, generated by the tests of the command with -update.
+func openFixture(t *testing.T, name string) *os.File {
+	t.Helper()
+	f, err := os.Open(filepath.Join("..", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// readSnapshotFixture reads a snapshot of ../testdata/snapshots.
+func readSnapshotFixture(t *testing.T, name string) *Snapshot {
+	t.Helper()
+	s, err := ReadSnapshot(openFixture(t, filepath.Join("snapshots", name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestExtractChapters(t *testing.T) {
+	chapters, err := ExtractChapters(openFixture(t, "book.pdf"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Chapter{
+		{Title: "Part One", Order: 1, StartPage: 1, EndPage: 8, Bookmark: 0},
+		{Title: "Part Two", Order: 2, StartPage: 9, EndPage: 16, Bookmark: 1},
+	}
+	if !reflect.DeepEqual(chapters, want) {
+		t.Errorf("got %+v, want %+v", chapters, want)
+	}
+}
+
+func TestExtractChaptersNotPDF(t *testing.T) {
+	_, err := ExtractChapters(strings.NewReader("not a PDF"), nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "read source: ") {
+		t.Errorf("got %v, want a read error", err)
+	}
+}
+
+func TestExportChapters(t *testing.T) {
+	source := openFixture(t, "book.pdf")
+	chapters, err := ExtractChapters(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err = ExportChapters(source, chapters, ExportOptions{Dir: dir, VerifyPages: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, cpt := range chapters {
+		f, err := os.Open(filepath.Join(dir, DefaultFileName(cpt)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = CheckPageCount(f, cpt.Pages(), nil)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %v", cpt.Title, err)
+		}
+	}
+}
+
+// TestSectionSource splits the book fixture stored between other bytes of a file, through an
+// io.SectionReader at its offset, with the file positioned elsewhere: the chapters must be those
+// of the fixture on its own, and the file must stay open and usable afterwards.
+func TestSectionSource(t *testing.T) {
+	book, err := os.ReadFile(filepath.Join("..", "testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := bytes.Repeat([]byte("blob header "), 100)
+	blob := filepath.Join(t.TempDir(), "blob")
+	if err = os.WriteFile(blob, slices.Concat(prefix, book, []byte("trailing data")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.Seek(7, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	source := io.NewSectionReader(f, int64(len(prefix)), int64(len(book)))
+	if _, err = source.Seek(100, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	chapters, err := ExtractChapters(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ExtractChapters(openFixture(t, "book.pdf"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(chapters, want) {
+		t.Errorf("got %+v, want %+v", chapters, want)
+	}
+	for _, lowMemory := range []bool{false, true} {
+		if err = ExportChapters(source, chapters, ExportOptions{Dir: t.TempDir(), VerifyPages: true, LowMemory: lowMemory}); err != nil {
+			t.Errorf("low memory %v: %v", lowMemory, err)
+		}
+	}
+
+	// The file is still open: the caller owns it
+	header := make([]byte, len(prefix))
+	if _, err = f.ReadAt(header, 0); err != nil {
+		t.Errorf("the file is not usable after the split: %v", err)
+	}
+}
+
+func TestExportChaptersFailure(t *testing.T) {
+	source := openFixture(t, "book.pdf")
+	chapters := []Chapter{
+		{Title: "Part One", Order: 1, StartPage: 1, EndPage: 8},
+		{Title: "Beyond", Order: 2, StartPage: 17, EndPage: 20},
+		{Title: "Part Two", Order: 3, StartPage: 9, EndPage: 12},
+		{Title: "Appendix", Order: 4, StartPage: 13, EndPage: 16},
+	}
+	for _, workers := range []int{1, 3} {
+		err := ExportChapters(source, chapters, ExportOptions{Dir: t.TempDir(), VerifyPages: true, Workers: workers})
+		var chapterErr *ChapterError
+		if !errors.As(err, &chapterErr) || chapterErr.Chapter.Title != "Beyond" {
+			t.Errorf("%d workers: got %v, want the error of chapter 'Beyond'", workers, err)
+		}
+	}
+}
+
+// TestExportChaptersPostProcess marks every chapter in its temporary file, which must be the file
+// delivered, and fails the second chapter, which must not be delivered.
+func TestExportChaptersPostProcess(t *testing.T) {
+	source := openFixture(t, "book.pdf")
+	chapters, err := ExtractChapters(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errRejected := errors.New("rejected")
+	marker := []byte("\n% processed\n")
+	for _, workers := range []int{1, 2} {
+		dir := t.TempDir()
+		err := ExportChapters(source, chapters, ExportOptions{Dir: dir, VerifyPages: true, Workers: workers,
+			PostProcess: func(ctx context.Context, c Chapter, tmpPath string) error {
+				if c.Order == 2 {
+					return errRejected
+				}
+				f, err := os.OpenFile(tmpPath, os.O_APPEND|os.O_WRONLY, 0)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				_, err = f.Write(marker)
+				return err
+			}})
+		var chapterErr *ChapterError
+		if !errors.As(err, &chapterErr) || chapterErr.Chapter.Order != 2 || !errors.Is(err, errRejected) {
+			t.Errorf("%d workers: got %v, want the post-processing error of chapter 2", workers, err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, DefaultFileName(chapters[0])))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasSuffix(data, marker) {
+			t.Errorf("%d workers: the first chapter was delivered without post-processing", workers)
+		}
+		if _, err := os.Stat(filepath.Join(dir, DefaultFileName(chapters[1]))); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%d workers: the rejected chapter was delivered: %v", workers, err)
+		}
+	}
+}
+
+func TestRunExportsCancel(t *testing.T) {
+	// Chapters 0 and 1 hold their workers until chapter 2 has failed on the third one
+	errFailed := errors.New("failed")
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var started []int
+	exports := RunExports(context.Background(), 6, 3, nil, func(i int) error {
+		mu.Lock()
+		started = append(started, i)
+		mu.Unlock()
+		switch i {
+		case 0, 1:
+			<-release
+		case 2:
+			return errFailed
+		}
+		return nil
+	})
+	if err := exports.Wait(2); !errors.Is(err, errFailed) {
+		t.Fatalf("chapter 2: got %v, want its error", err)
+	}
+	close(release)
+
+	// The running chapters are finished, the others are cancelled without being started
+	for i := 0; i < 2; i++ {
+		if err := exports.Wait(i); err != nil {
+			t.Errorf("chapter %d: got %v, want nil", i, err)
+		}
+	}
+	for i := 3; i < 6; i++ {
+		if err := exports.Wait(i); !errors.Is(err, context.Canceled) {
+			t.Errorf("chapter %d: got %v, want context.Canceled", i, err)
+		}
+	}
+	sort.Ints(started)
+	if !reflect.DeepEqual(started, []int{0, 1, 2}) {
+		t.Errorf("started chapters %v, want [0 1 2]", started)
+	}
+}
+
+func TestRunExportsClose(t *testing.T) {
+	// Close must wait for the running chapter 0 and keep the others from starting
+	running, release := make(chan struct{}), make(chan struct{})
+	var started, finished atomic.Int32
+	exports := RunExports(context.Background(), 4, 1, nil, func(i int) error {
+		started.Add(1)
+		if i == 0 {
+			close(running)
+			<-release
+		}
+		finished.Add(1)
+		return nil
+	})
+	<-running
+	closed := make(chan struct{})
+	go func() {
+		exports.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while chapter 0 was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-closed
+	if started.Load() != 1 || finished.Load() != 1 {
+		t.Errorf("%d chapters started and %d finished, want only chapter 0", started.Load(), finished.Load())
+	}
+	exports.Close()
+}
+
+func TestConcurrentSplitsShareConfiguration(t *testing.T) {
+	// Two splits on workers of their own share the caller's configuration, which go test -race
+	// checks for data races and which must be left as it was
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.SPLIT
+	userPW := "reader"
+	conf.UserPWNew = &userPW
+	want := *conf
+
+	var wg sync.WaitGroup
+	dirs := []string{t.TempDir(), t.TempDir()}
+	for _, dir := range dirs {
+		source := openFixture(t, "book.pdf")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chapters, err := ExtractChapters(source, conf)
+			if err == nil {
+				err = ExportChapters(source, chapters, ExportOptions{Dir: dir, Conf: conf, VerifyPages: true, Workers: 2})
+			}
+			if err != nil {
+				t.Errorf("%s: %v", dir, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !reflect.DeepEqual(*conf, want) || userPW != "reader" {
+		t.Errorf("the configuration changed: got Cmd %v and UserPWNew %q, want Cmd %v and UserPWNew %q", conf.Cmd, *conf.UserPWNew, want.Cmd, "reader")
+	}
+	for _, dir := range dirs {
+		for _, name := range []string{"01_Part One.pdf", "02_Part Two.pdf"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
+
+func TestFirstFailure(t *testing.T) {
+	// Chapter 1 is cancelled by the failure of chapter 3, which is reported instead
+	errFailed := errors.New("failed")
+	results := []error{nil, context.Canceled, nil, errFailed, context.Canceled}
+	exports := &Exports{results: make([]chan error, len(results)), fatal: func(err error) bool { return err != nil }}
+	for i, err := range results {
+		exports.results[i] = make(chan error, 1)
+		exports.results[i] <- err
+	}
+	if err := exports.Wait(1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("chapter 1: got %v, want context.Canceled", err)
+	}
+	if i, err := exports.FirstFailure(2); i != 3 || !errors.Is(err, errFailed) {
+		t.Errorf("got chapter %d with %v, want chapter 3 with its error", i, err)
+	}
+}
+
+func TestCheckPageCount(t *testing.T) {
+	source := openFixture(t, "book.pdf")
+	if err := CheckPageCount(source, 16, nil); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+	err := CheckPageCount(source, 15, nil)
+	var mismatch *PageCountError
+	if !errors.Is(err, ErrPageCount) || !errors.As(err, &mismatch) || mismatch.Got != 16 || mismatch.Want != 15 {
+		t.Errorf("got %v, want 16 pages instead of 15", err)
+	}
+}
+
+func TestReadDocumentTrim(t *testing.T) {
+	doc, err := ReadDocument(openFixture(t, "book.pdf"), nil, DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.PageCount() != 16 {
+		t.Errorf("got %d pages, want 16", doc.PageCount())
+	}
+	var chapter bytes.Buffer
+	if err = doc.Trim(&chapter, []string{"9-13"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = CheckPageCount(bytes.NewReader(chapter.Bytes()), 5, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPlanChapters(t *testing.T) {
+	snapshot := readSnapshotFixture(t, "shared-start.json")
+	tests := []struct {
+		name string
+		opts PlanOptions
+		want []Chapter
+	}{
+		{"merged", PlanOptions{}, []Chapter{
+			{Title: "Intro", Order: 1, StartPage: 1, EndPage: 2, Bookmark: 0},
+			{Title: "Part 1", Order: 2, StartPage: 3, EndPage: 6, Bookmark: 1, Merged: []int{2}},
+			{Title: "Chapter 2", Order: 3, StartPage: 7, EndPage: 10, Bookmark: 3},
+		}},
+		{"overlap", PlanOptions{LastPage: 10, Overlap: true}, []Chapter{
+			{Title: "Intro", Order: 1, StartPage: 1, EndPage: 3, Bookmark: 0},
+			{Title: "Part 1", Order: 2, StartPage: 3, EndPage: 7, Bookmark: 1, Merged: []int{2}},
+			{Title: "Chapter 2", Order: 3, StartPage: 7, EndPage: 10, Bookmark: 3},
+		}},
+		{"separate", PlanOptions{LastPage: 10, Separate: func(i int) bool { return i == 2 }}, []Chapter{
+			{Title: "Intro", Order: 1, StartPage: 1, EndPage: 2, Bookmark: 0},
+			{Title: "Part 1", Order: 2, StartPage: 3, EndPage: 3, Bookmark: 1},
+			{Title: "Chapter 1", Order: 3, StartPage: 3, EndPage: 6, Bookmark: 2},
+			{Title: "Chapter 2", Order: 4, StartPage: 7, EndPage: 10, Bookmark: 3},
+		}},
+		{"truncated", PlanOptions{LastPage: 5}, []Chapter{
+			{Title: "Intro", Order: 1, StartPage: 1, EndPage: 2, Bookmark: 0},
+			{Title: "Part 1", Order: 2, StartPage: 3, EndPage: 5, Bookmark: 1, Merged: []int{2}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PlanSnapshot(snapshot, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanChaptersPageAligned(t *testing.T) {
+	// Every chapter starts at the top of its own page, so no page is in two chapters
+	got, err := PlanSnapshot(readSnapshotFixture(t, "page-aligned.json"), PlanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Chapter{
+		{Title: "Cover", Order: 1, StartPage: 1, EndPage: 1, Bookmark: 0},
+		{Title: "Intro", Order: 2, StartPage: 2, EndPage: 4, Bookmark: 1},
+		{Title: "Body", Order: 3, StartPage: 5, EndPage: 10, Bookmark: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+// TestSnapshot writes and reads back the snapshot of the book fixture, which must plan the
+// chapters ExtractChapters plans from the document.
+func TestSnapshot(t *testing.T) {
+	source := openFixture(t, "book.pdf")
+	doc, err := ReadDocument(source, nil, DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := doc.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written bytes.Buffer
+	if err = WriteSnapshot(&written, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadSnapshot(bytes.NewReader(written.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rewritten bytes.Buffer
+	if err = WriteSnapshot(&rewritten, read); err != nil {
+		t.Fatal(err)
+	}
+	if written.String() != rewritten.String() {
+		t.Errorf("snapshot changed when read back:\n%s\nwant:\n%s", rewritten.String(), written.String())
+	}
+
+	got, err := PlanSnapshot(read, PlanOptions{Limits: DefaultLimits})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ExtractChapters(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if labels := readSnapshotFixture(t, "page-aligned.json").PageLabels; len(labels) != 2 || labels[1] != (PageLabel{Page: 5, Style: "D"}) {
+		t.Errorf("got page labels %+v", labels)
+	}
+
+	for _, data := range []string{`{"page_count": 0}`, `{"page_count": 3, "pages": 3}`, `not JSON`} {
+		if _, err := ReadSnapshot(strings.NewReader(data)); err == nil {
+			t.Errorf("%s: read without an error", data)
+		}
+	}
+}
+
+func TestPlanChaptersErrors(t *testing.T) {
+	bookmarks := []pdfcpu.Bookmark{{Title: "One", PageFrom: 4}, {Title: "Two", PageFrom: 6}}
+	if _, err := PlanChapters(nil, PlanOptions{LastPage: 10}); !errors.Is(err, ErrNoChapters) {
+		t.Errorf("without bookmarks: got %v, want ErrNoChapters", err)
+	}
+	if _, err := PlanChapters(bookmarks, PlanOptions{LastPage: 3}); !errors.Is(err, ErrPastLastPage) {
+		t.Errorf("past the last page: got %v, want ErrPastLastPage", err)
+	}
+	_, err := PlanChapters(bookmarks, PlanOptions{LastPage: 10, Limits: Limits{Chapters: 1}})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Unit != "chapters" {
+		t.Errorf("over the limit: got %v, want a *LimitError", err)
+	}
+}
+
+func TestCheckOutlineCycle(t *testing.T) {
+	source := openFixture(t, "cyclic.pdf")
+	ctx, err := api.ReadContext(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CheckOutline(ctx, DefaultLimits)
+	if !errors.Is(err, ErrMalformedOutline) || !strings.Contains(err.Error(), "cycle detected at 'Part One'") {
+		t.Errorf("got %v, want a cycle at 'Part One'", err)
+	}
+	if _, err = ReadDocument(source, nil, DefaultLimits); !errors.Is(err, ErrMalformedOutline) {
+		t.Errorf("ReadDocument: got %v, want ErrMalformedOutline", err)
+	}
+}
+
+func TestCheckOutlineDepth(t *testing.T) {
+	for _, levels := range []int{MaxOutlineDepth, MaxOutlineDepth + 1} {
+		ctx, err := api.ReadContext(openFixture(t, "book.pdf"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Replace the outline with a chain of items, each the only child of the one before
+		var first *types.IndirectRef
+		for level := levels; level >= 1; level-- {
+			item := types.Dict{"Title": types.StringLiteral(fmt.Sprintf("Level %d", level))}
+			if first != nil {
+				item["First"], item["Last"] = *first, *first
+			}
+			if first, err = ctx.IndRefForNewObject(item); err != nil {
+				t.Fatal(err)
+			}
+		}
+		outlines, err := ctx.IndRefForNewObject(types.Dict{"First": *first, "Last": *first})
+		if err != nil {
+			t.Fatal(err)
+		}
+		root, err := ctx.Catalog()
+		if err != nil {
+			t.Fatal(err)
+		}
+		root["Outlines"] = *outlines
+
+		err = CheckOutline(ctx, DefaultLimits)
+		if levels <= MaxOutlineDepth && err != nil {
+			t.Errorf("%d levels: got %v, want nil", levels, err)
+		}
+		if levels > MaxOutlineDepth && (!errors.Is(err, ErrMalformedOutline) || !strings.Contains(err.Error(), "at 'Level 64'")) {
+			t.Errorf("%d levels: got %v, want the outline nested too deep at 'Level 64'", levels, err)
+		}
+	}
+}
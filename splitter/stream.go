@@ -0,0 +1,119 @@
+package splitter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Chapters yields the chapters ExtractChapters returns one at a time, in page order, each as soon
+// as the start of the next one ends its range, so that a long plan can be exported while it is
+// still being made, e.g. with ExportSeq, without holding all of it. pdfcpu reads the outline as a
+// whole, so only the chapters are streamed, not the bookmarks. The source is read when the
+// iteration starts; a cancelled ctx ends it before the next chapter.
+// Parameters:
+//   - ctx: stops the iteration once cancelled
+//   - rs: source document
+//   - conf: pdfcpu configuration for reading the source, nil for the default
+//
+// Returns:
+//   - iter.Seq2[Chapter, error]: the chapters, or one error that ends them: the errors of
+//     ExtractChapters, an error for a chapter without a valid page range, or the error of ctx
+func Chapters(ctx context.Context, rs io.ReadSeeker, conf *model.Configuration) iter.Seq2[Chapter, error] {
+	return func(yield func(Chapter, error) bool) {
+		if err := ctx.Err(); err != nil {
+			yield(Chapter{}, err)
+			return
+		}
+		doc, err := ReadDocument(rs, conf, DefaultLimits)
+		if err != nil {
+			yield(Chapter{}, err)
+			return
+		}
+		bookmarks, err := doc.Bookmarks()
+		if err != nil {
+			yield(Chapter{}, err)
+			return
+		}
+		opts := PlanOptions{LastPage: doc.PageCount(), Limits: DefaultLimits}
+		for cpt, err := range plannedChapters(bookmarks, opts) {
+			if err == nil {
+				err = ctx.Err()
+			}
+			if err == nil {
+				err = CheckRanges([]Chapter{cpt})
+			}
+			if err != nil {
+				yield(Chapter{}, err)
+				return
+			}
+			if !yield(cpt, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ExportSeq writes the chapters of a sequence as ExportChapters does, each as soon as it is
+// yielded, so that the first files are written while later chapters are still planned. The
+// chapters are trimmed one at a time in the order they are yielded; opts.Workers is not used.
+// Files are named as by FileNames over the chapters written before. A chapter written before a
+// failure stays delivered, and the destination is only finalized once the sequence ended.
+// Parameters:
+//   - ctx: stops the export before the next chapter once cancelled
+//   - rs: source document
+//   - chapters: chapters to write, e.g. from Chapters
+//   - opts: output directory or destination, file naming and source configuration
+//
+// Returns:
+//   - error: the first failure: the error of the sequence or of ctx, a *ChapterError for a
+//     chapter that cannot be written, or the read error of the source
+func ExportSeq(ctx context.Context, rs io.ReadSeeker, chapters iter.Seq2[Chapter, error], opts ExportOptions) error {
+	dest := opts.Destination
+	if dest == nil {
+		if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+			return fmt.Errorf("create output directory: %w", err)
+		}
+		dest = DirDestination{Dir: opts.Dir, Names: opts.Names}
+	}
+	var doc *Document
+	if !opts.LowMemory {
+		var err error
+		if doc, err = ReadDocument(rs, opts.Conf, DefaultLimits); err != nil {
+			return err
+		}
+	}
+
+	fileName := fileNamer(opts)
+	var manifest Manifest
+	for cpt, err := range chapters {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		name := fileName(cpt)
+		var data bytes.Buffer
+		err = trimChapter(rs, doc, &data, cpt, name, opts)
+		if err == nil {
+			err = postProcess(ctx, &data, cpt, opts)
+		}
+		if err == nil {
+			err = Deliver(dest, name, &data)
+		}
+		if err != nil {
+			return &ChapterError{Chapter: cpt, Err: err}
+		}
+		manifest.Files = append(manifest.Files, ManifestFile{Name: name, Chapter: cpt})
+	}
+	if err := dest.Finalize(manifest); err != nil {
+		return fmt.Errorf("finalize destination: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package splitter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestChapters iterates the chapters of the book fixture, which must be those ExtractChapters
+// returns, and stops the iteration early by breaking and by cancelling.
+func TestChapters(t *testing.T) {
+	source := openFixture(t, "book.pdf")
+	want, err := ExtractChapters(source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Chapter
+	for cpt, err := range Chapters(context.Background(), source, nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, cpt)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	n := 0
+	for range Chapters(context.Background(), source, nil) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("got %d chapters after a break, want 1", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got = nil
+	for cpt, err := range Chapters(ctx, source, nil) {
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("got %v, want %v", err, context.Canceled)
+			}
+			break
+		}
+		got = append(got, cpt)
+		cancel()
+	}
+	if len(got) != 1 {
+		t.Errorf("got %d chapters before the cancellation, want 1", len(got))
+	}
+}
+
+// TestExportSeq writes the chapters of the book fixture as they are iterated.
+func TestExportSeq(t *testing.T) {
+	source := openFixture(t, "book.pdf")
+	dir := t.TempDir()
+	if err := ExportSeq(context.Background(), source, Chapters(context.Background(), source, nil), ExportOptions{Dir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"01_Part One.pdf", "02_Part Two.pdf"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := ExportSeq(ctx, source, Chapters(context.Background(), source, nil), ExportOptions{Dir: t.TempDir()})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,110 @@
+package splitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ChapterError is the failure of one chapter of an export.
+type ChapterError struct {
+	Chapter Chapter
+	Err     error
+}
+
+// Error names the chapter and its pages with the error.
+func (e *ChapterError) Error() string {
+	return fmt.Sprintf("chapter '%s' (pages %d-%d): %v", e.Chapter.Title, e.Chapter.StartPage, e.Chapter.EndPage, e.Err)
+}
+
+// Unwrap returns the error of the chapter.
+func (e *ChapterError) Unwrap() error {
+	return e.Err
+}
+
+// Exports are the chapters of an export running on worker goroutines, started by RunExports.
+type Exports struct {
+	results []chan error
+	fatal   func(error) bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// RunExports exports chapters 0 to n-1 on up to workers goroutines, handing them out in order.
+// The first error that stops the export cancels the chapters not yet started, which then fail
+// with context.Canceled; the chapters being exported are finished. A caller that stops waiting
+// for the chapters, e.g. on an error of its own, must call Close before it returns, so that no
+// chapter is exported after that.
+// Parameters:
+//   - ctx: context of the export; cancelling it stops the chapters not yet started
+//   - n: number of chapters
+//   - workers: maximum number of concurrent exports; less than 1 exports one at a time
+//   - fatal: whether an error stops the export, nil if every error does
+//   - export: exports chapter i
+//
+// Returns:
+//   - *Exports: the results of the chapters
+func RunExports(ctx context.Context, n, workers int, fatal func(error) bool, export func(i int) error) *Exports {
+	if fatal == nil {
+		fatal = func(err error) bool { return err != nil }
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	e := &Exports{results: make([]chan error, n), fatal: fatal, cancel: cancel, done: make(chan struct{})}
+	jobs := make(chan int, n)
+	for i := range e.results {
+		e.results[i] = make(chan error, 1)
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(max(workers, 1), n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					e.results[i] <- ctx.Err()
+					continue
+				}
+				err := export(i)
+				if fatal(err) {
+					cancel()
+				}
+				e.results[i] <- err
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(e.done)
+	}()
+	return e
+}
+
+// Close cancels the chapters not yet started and waits until the chapters being exported are
+// finished. The results of the chapters not waited for are discarded. Close may be called more
+// than once, and after all chapters were waited for.
+func (e *Exports) Close() {
+	e.cancel()
+	<-e.done
+}
+
+// Wait waits for chapter i and returns its error. Every chapter is waited for at most once,
+// by Wait or by FirstFailure.
+func (e *Exports) Wait(i int) error {
+	return <-e.results[i]
+}
+
+// FirstFailure waits for the chapters from index from on and returns the first one that stopped
+// the export for a reason other than the cancellation a failure caused, or -1 if there is none.
+func (e *Exports) FirstFailure(from int) (int, error) {
+	for i := from; i < len(e.results); i++ {
+		if err := <-e.results[i]; e.fatal(err) && !errors.Is(err, context.Canceled) {
+			return i, err
+		}
+	}
+	return -1, nil
+}
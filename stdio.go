@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// stdioPath is the -i and -o value that reads the source from stdin or writes the chapter to stdout.
+const stdioPath = "-"
+
+// stdinName is the file name stdin is spooled to; {source} renders as its stem.
+const stdinName = "stdin.pdf"
+
+// readsStdin reports whether the source is read from stdin.
+func readsStdin() bool {
+	for _, input := range inputPaths {
+		if input == stdioPath {
+			return true
+		}
+	}
+	return false
+}
+
+// writesStdout reports whether the chapter is written to stdout.
+func writesStdout() bool {
+	return outputDir == stdioPath
+}
+
+// spoolStdin copies stdin into a temporary file. pdfcpu needs to seek in its input, and every
+// export worker opens the source by name, so the source cannot stay in memory.
+// Returns:
+//   - string: path of the spooled source
+//   - func(): removes the temporary file
+func spoolStdin() (string, func(), error) {
+	dir, err := os.MkdirTemp("", "pdf-split-stdin-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	path := filepath.Join(dir, stdinName)
+	f, err := os.Create(path)
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to buffer stdin: %w", err)
+	}
+	n, err := io.Copy(f, os.Stdin)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to buffer stdin: %w", err)
+	}
+	if verbose {
+		printMsg("stdin_buffered", formatBytes(float64(n)), path)
+	}
+	return path, cleanup, nil
+}
+
+// exportToStdout writes a single chapter to stdout. The chapter is exported into a temporary
+// directory like any other, with all fixes and checks, and copied to stdout once it is complete.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//   - cpt: the chapter
+//
+// Returns:
+//   - error: if the chapter cannot be exported or written to stdout
+func exportToStdout(inputFile *os.File, cpt chapter) error {
+	dir, err := os.MkdirTemp("", "pdf-split-stdout-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = exportChapters(inputFile, []chapter{cpt}, dir); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, cpt.file+".pdf")
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read chapter '%s': %w", cpt.title, err)
+	}
+	defer f.Close()
+	if _, err = io.Copy(os.Stdout, f); err != nil {
+		return fmt.Errorf("failed to write chapter '%s' to stdout: %w", cpt.title, err)
+	}
+	return nil
+}
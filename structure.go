@@ -0,0 +1,226 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// structureHeading is a heading element of the structure tree.
+// level is 0 for Title, 1 to 6 for H1 to H6 and 7 for H, so a lower level is more prominent.
+type structureHeading struct {
+	page  int
+	level int
+	text  string
+}
+
+// headingLevels maps the standard structure types used for headings to their level.
+var headingLevels = map[string]int{
+	"Title": 0, "H1": 1, "H2": 2, "H3": 3, "H4": 4, "H5": 5, "H6": 6, "H": 7,
+}
+
+// structureReader walks the structure tree of a tagged document.
+type structureReader struct {
+	ctx      *model.Context
+	roleMap  types.Dict
+	pageNrs  map[int]int
+	marked   map[int]map[int]string
+	headings []structureHeading
+}
+
+// structureHeadings returns the heading elements of a tagged document in tree order.
+// Custom structure types are resolved through the role map. The text of a heading is its
+// ActualText, or else the text of the marked content it refers to, or else its Alt or T entry.
+// Parameters:
+//   - ctx: pdfcpu context of the source document
+//
+// Returns:
+//   - []structureHeading: the headings that have text, none for untagged documents
+func structureHeadings(ctx *model.Context) []structureHeading {
+	root, err := ctx.Catalog()
+	if err != nil {
+		return nil
+	}
+	treeRoot, err := ctx.DereferenceDict(root["StructTreeRoot"])
+	if err != nil || treeRoot == nil {
+		return nil
+	}
+	r := &structureReader{ctx: ctx, pageNrs: make(map[int]int), marked: make(map[int]map[int]string)}
+	r.roleMap, _ = ctx.DereferenceDict(treeRoot["RoleMap"])
+
+	// Structure elements refer to pages by object number
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		if _, indRef, _, err := ctx.PageDict(pageNr, false); err == nil && indRef != nil {
+			r.pageNrs[indRef.ObjectNumber.Value()] = pageNr
+		}
+	}
+	r.walk(treeRoot["K"], 0, 0)
+	return r.headings
+}
+
+// walk visits a structure element or an array of them. page is the page inherited from the parent.
+func (r *structureReader) walk(obj types.Object, page, depth int) {
+	if depth > splitter.MaxOutlineDepth {
+		return
+	}
+	obj, err := r.ctx.Dereference(obj)
+	if err != nil || obj == nil {
+		return
+	}
+	switch o := obj.(type) {
+	case types.Array:
+		for _, kid := range o {
+			r.walk(kid, page, depth+1)
+		}
+	case types.Dict:
+		if p := r.page(o); p > 0 {
+			page = p
+		}
+		if level, ok := headingLevels[r.standardType(o)]; ok && page > 0 {
+			if text := strings.Join(strings.Fields(r.elementText(o, page)), " "); isReadable(text) {
+				r.headings = append(r.headings, structureHeading{page: page, level: level, text: text})
+			}
+			return
+		}
+		r.walk(o["K"], page, depth+1)
+	}
+}
+
+// page returns the page number of an element's Pg entry, or 0.
+func (r *structureReader) page(d types.Dict) int {
+	if ref, ok := d["Pg"].(types.IndirectRef); ok {
+		return r.pageNrs[ref.ObjectNumber.Value()]
+	}
+	return 0
+}
+
+// standardType returns the structure type of an element, mapped through the role map.
+func (r *structureReader) standardType(d types.Dict) string {
+	name := d.NameEntry("S")
+	if name == nil {
+		return ""
+	}
+	s := *name
+	for i := 0; i < splitter.MaxOutlineDepth && r.roleMap != nil; i++ {
+		mapped := r.roleMap.NameEntry(s)
+		if mapped == nil || *mapped == s {
+			break
+		}
+		s = *mapped
+	}
+	return s
+}
+
+// elementText returns the text of a heading element.
+func (r *structureReader) elementText(d types.Dict, page int) string {
+	if text, err := r.ctx.DereferenceText(d["ActualText"]); err == nil && text != "" {
+		return text
+	}
+	var sb strings.Builder
+	r.collectMarked(d["K"], page, &sb, 0)
+	if strings.TrimSpace(sb.String()) != "" {
+		return sb.String()
+	}
+	for _, key := range []string{"Alt", "T"} {
+		if text, err := r.ctx.DereferenceText(d[key]); err == nil && text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// collectMarked appends the marked content referred to by the kids of an element, in order.
+// Kids are MCIDs on the element's page, marked-content references or nested elements.
+func (r *structureReader) collectMarked(obj types.Object, page int, sb *strings.Builder, depth int) {
+	if depth > splitter.MaxOutlineDepth {
+		return
+	}
+	obj, err := r.ctx.Dereference(obj)
+	if err != nil || obj == nil {
+		return
+	}
+	switch o := obj.(type) {
+	case types.Integer:
+		sb.WriteString(r.markedText(page, o.Value()))
+	case types.Array:
+		for _, kid := range o {
+			r.collectMarked(kid, page, sb, depth+1)
+		}
+	case types.Dict:
+		if p := r.page(o); p > 0 {
+			page = p
+		}
+		if mcid := o.IntEntry("MCID"); mcid != nil {
+			sb.WriteString(r.markedText(page, *mcid))
+			return
+		}
+		r.collectMarked(o["K"], page, sb, depth+1)
+	}
+}
+
+// markedText returns the text of a marked-content sequence, reading each page at most once.
+func (r *structureReader) markedText(page, mcid int) string {
+	if _, ok := r.marked[page]; !ok {
+		r.marked[page] = map[int]string{}
+		if rd, err := pdfcpu.ExtractPageContent(r.ctx, page); err == nil && rd != nil {
+			if content, err := io.ReadAll(rd); err == nil {
+				r.marked[page] = markedText(content)
+			}
+		}
+	}
+	return r.marked[page][mcid]
+}
+
+// applyStructureTitles replaces each chapter title with the most prominent heading of the
+// structure tree on its start page, the first one of that level in tree order. Pages without
+// a structure heading fall back to the first prominent text line, as with first-heading, and
+// chapters where neither yields text keep their bookmark title.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//   - chapters: list of chapter information, updated in place
+//
+// Returns:
+//   - error: if the pages cannot be read
+func applyStructureTitles(inputFile *os.File, chapters []chapter) error {
+	return inspectSource(inputFile, func(ctx *model.Context) error {
+		// Keep the most prominent heading of every page
+		best := make(map[int]structureHeading)
+		for _, h := range structureHeadings(ctx) {
+			if b, ok := best[h.page]; !ok || h.level < b.level {
+				best[h.page] = h
+			}
+		}
+
+		for i := range chapters {
+			cpt := &chapters[i]
+			cpt.bookmarkTitle = cpt.title
+			if h, ok := best[int(cpt.startPage)]; ok {
+				if h.text != cpt.title {
+					cpt.title = h.text
+					cpt.explain("explain_structure", structureTypeName(h.level), cpt.startPage)
+				}
+			} else if heading := firstHeading(ctx, int(cpt.startPage)); heading != "" && heading != cpt.title {
+				cpt.title = heading
+				cpt.explain("explain_heading", cpt.startPage)
+			}
+		}
+		return nil
+	})
+}
+
+// structureTypeName returns the standard structure type of a heading level.
+func structureTypeName(level int) string {
+	switch level {
+	case 0:
+		return "Title"
+	case 7:
+		return "H"
+	}
+	return "H" + strconv.Itoa(level)
+}
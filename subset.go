@@ -0,0 +1,88 @@
+package main
+
+import (
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// subsetResourceTypes are the resource categories reduced by --subset-resources.
+var subsetResourceTypes = []string{"Font", "XObject"}
+
+// subsetStats describes the outcome of reducing a chapter's resources.
+// skipped holds the reason if the chapter was left unchanged.
+type subsetStats struct {
+	savedBytes int64
+	skipped    error
+}
+
+// subsetResources gives every page of a trimmed chapter its own resource dictionary holding only
+// the fonts and XObjects its content stream uses, and removes the resources inherited from the
+// page tree. Resources no page refers to are then no longer written with the chapter.
+// The used names are determined by pdfcpu's resource consolidation; if it fails for any page,
+// the analysis is inconclusive and the chapter is left unchanged.
+// Parameters:
+//   - ctx: pdfcpu context of the trimmed chapter
+//
+// Returns:
+//   - error: why the chapter's resources could not be reduced
+func subsetResources(ctx *model.Context) error {
+	// Work out the reduced resources of all pages before changing anything
+	pages := make([]types.Dict, ctx.PageCount)
+	resources := make([]types.Dict, ctx.PageCount)
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		page, _, inherited, err := ctx.PageDict(pageNr, false)
+		if err != nil {
+			return err
+		}
+		_, _, used, err := ctx.PageDict(pageNr, true)
+		if err != nil {
+			return err
+		}
+		reduced := types.Dict{}
+		if inherited.Resources != nil {
+			reduced = inherited.Resources.Clone().(types.Dict)
+		}
+		for _, key := range subsetResourceTypes {
+			if sub, ok := used.Resources[key]; ok {
+				reduced[key] = sub
+			} else {
+				reduced.Delete(key)
+			}
+		}
+		pages[pageNr-1], resources[pageNr-1] = page, reduced
+	}
+
+	for i, page := range pages {
+		page["Resources"] = resources[i]
+	}
+	root, err := ctx.Pages()
+	if err != nil {
+		return err
+	}
+	return dropInheritedResources(ctx, *root, 0)
+}
+
+// dropInheritedResources removes the resources of the intermediate nodes of a page tree.
+func dropInheritedResources(ctx *model.Context, node types.IndirectRef, depth int) error {
+	if depth > splitter.MaxOutlineDepth {
+		return nil
+	}
+	d, err := ctx.DereferenceDict(node)
+	if err != nil || d == nil {
+		return err
+	}
+	kids := d.ArrayEntry("Kids")
+	if kids == nil {
+		return nil
+	}
+	d.Delete("Resources")
+	for _, kid := range kids {
+		if ref, ok := kid.(types.IndirectRef); ok {
+			if err = dropInheritedResources(ctx, ref, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
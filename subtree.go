@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// subtree is a bookmark located by --under, together with the page span it covers.
+// endPage is the start page of the bookmark following it in outline order,
+// or 0 if it extends to the end of the document.
+type subtree struct {
+	bookmark pdfcpu.Bookmark
+	dests    []destinationNode
+	endPage  int
+}
+
+// findSubtree locates the bookmark named by pattern anywhere in the outline.
+// An exact title match wins; otherwise pattern is used as a regular expression,
+// which must match exactly one bookmark.
+// Parameters:
+//   - bookmarks: top-level bookmarks of the document
+//   - dests: destination tree aligned with bookmarks, may be nil
+//   - pattern: exact bookmark title or regular expression
+//
+// Returns:
+//   - subtree: the located bookmark, the destinations of its kids and its end page
+//   - error: if no bookmark or more than one bookmark matches
+func findSubtree(bookmarks []pdfcpu.Bookmark, dests []destinationNode, pattern string) (subtree, error) {
+	// Try an exact title match first
+	exact := collectSubtrees(bookmarks, dests, 0, func(title string) bool { return title == pattern })
+	if len(exact) > 0 {
+		return exact[0], nil
+	}
+
+	// Fall back to a regular expression match
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return subtree{}, fmt.Errorf("no bookmark titled '%s' and not a valid regular expression: %v", pattern, err)
+	}
+	matches := collectSubtrees(bookmarks, dests, 0, re.MatchString)
+	switch len(matches) {
+	case 0:
+		return subtree{}, fmt.Errorf("no bookmark matches '%s'", pattern)
+	case 1:
+		return matches[0], nil
+	}
+	var titles []string
+	for _, m := range matches {
+		titles = append(titles, fmt.Sprintf("'%s'", m.bookmark.Title))
+	}
+	return subtree{}, fmt.Errorf("'%s' matches %d bookmarks: %s", pattern, len(matches), strings.Join(titles, ", "))
+}
+
+// collectSubtrees walks the bookmark tree depth-first and returns all bookmarks whose title matches.
+// followingPage is the start page of the bookmark following this list of siblings, or 0 for the document end.
+func collectSubtrees(bookmarks []pdfcpu.Bookmark, dests []destinationNode, followingPage int, match func(string) bool) []subtree {
+	var result []subtree
+	for i, bm := range bookmarks {
+		// The span ends where the next sibling starts, or where the parent's next sibling starts
+		endPage := followingPage
+		if i+1 < len(bookmarks) {
+			endPage = bookmarks[i+1].PageFrom
+		}
+		var kidDests []destinationNode
+		if len(dests) == len(bookmarks) {
+			kidDests = dests[i].kids
+		}
+
+		if match(bm.Title) {
+			result = append(result, subtree{bookmark: bm, dests: kidDests, endPage: endPage})
+		}
+		result = append(result, collectSubtrees(bm.Kids, kidDests, endPage, match)...)
+	}
+	return result
+}
+
+// descendSingleRoot detects the common outline shape of a single top-level bookmark (usually the
+// document title) whose children are the real chapters, and returns the children instead.
+// If the root bookmark starts before its first child, its own pages are kept as a leading entry.
+// Parameters:
+//   - bookmarks: top-level bookmarks of the document
+//   - dests: destination tree aligned with bookmarks, may be nil
+//
+// Returns:
+//   - []pdfcpu.Bookmark: the bookmarks to split on
+//   - []destinationNode: destinations aligned with the returned bookmarks
+//   - bool: whether the outline was descended
+func descendSingleRoot(bookmarks []pdfcpu.Bookmark, dests []destinationNode) ([]pdfcpu.Bookmark, []destinationNode, bool) {
+	if len(bookmarks) != 1 || len(bookmarks[0].Kids) == 0 {
+		return bookmarks, dests, false
+	}
+	root := bookmarks[0]
+	kids := root.Kids
+	var kidDests []destinationNode
+	if len(dests) == 1 {
+		kidDests = dests[0].kids
+	}
+
+	// Keep pages between the root destination and its first child
+	if root.PageFrom < kids[0].PageFrom {
+		kids = append([]pdfcpu.Bookmark{{Title: root.Title, PageFrom: root.PageFrom}}, kids...)
+		if len(dests) == 1 {
+			kidDests = append([]destinationNode{{destination: dests[0].destination}}, kidDests...)
+		}
+	}
+	return kids, kidDests, true
+}
+
+// introSuffix is appended to the title of the bookmark created by flattenToDepth for the
+// pages of a parent before its first kid.
+const introSuffix = " (intro)"
+
+// flattenToDepth replaces the bookmark tree by the bookmarks at the given outline level, in order.
+// A bookmark above that level with kids is replaced by its kids; the pages between its own
+// destination and its first kid are kept as an extra bookmark titled "<title> (intro)".
+// Branches ending above the level contribute their deepest bookmark.
+// Parameters:
+//   - bookmarks: bookmarks of the current level
+//   - dests: destination tree aligned with bookmarks, may be nil
+//   - depth: number of levels to descend, 1 for the bookmarks themselves
+//
+// Returns:
+//   - []pdfcpu.Bookmark: the bookmarks to split at, without kids
+//   - []destinationNode: destinations aligned with the result, nil if dests was not aligned
+func flattenToDepth(bookmarks []pdfcpu.Bookmark, dests []destinationNode, depth int) ([]pdfcpu.Bookmark, []destinationNode) {
+	aligned := len(dests) == len(bookmarks)
+	var (
+		flat      []pdfcpu.Bookmark
+		flatDests []destinationNode
+	)
+	for i, bm := range bookmarks {
+		var node destinationNode
+		if aligned {
+			node = dests[i]
+		}
+		if depth <= 1 || len(bm.Kids) == 0 {
+			flat = append(flat, pdfcpu.Bookmark{Title: bm.Title, PageFrom: bm.PageFrom, Kids: bm.Kids})
+			flatDests = append(flatDests, destinationNode{destination: node.destination})
+			continue
+		}
+
+		// Keep the parent's pages before its first kid
+		if bm.PageFrom < bm.Kids[0].PageFrom {
+			flat = append(flat, pdfcpu.Bookmark{Title: bm.Title + introSuffix, PageFrom: bm.PageFrom})
+			flatDests = append(flatDests, destinationNode{destination: node.destination})
+		}
+		kids, kidDests := flattenToDepth(bm.Kids, node.kids, depth-1)
+		flat = append(flat, kids...)
+		flatDests = append(flatDests, kidDests...)
+	}
+	if !aligned {
+		flatDests = nil
+	}
+	return flat, flatDests
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// isTagged reports whether a document is a tagged PDF, i.e. has a logical structure tree
+// or declares itself as marked.
+func isTagged(ctx *model.Context) bool {
+	root, err := ctx.Catalog()
+	if err != nil {
+		return false
+	}
+	if _, found := root.Find("StructTreeRoot"); found {
+		return true
+	}
+	markInfo, err := ctx.DereferenceDict(root["MarkInfo"])
+	if err != nil || markInfo == nil {
+		return false
+	}
+	marked := markInfo.BooleanEntry("Marked")
+	return marked != nil && *marked
+}
+
+// sourceIsTagged reports whether the source is a tagged PDF and warns that the outputs
+// will not be, unless --allow-untagged-output is set.
+func sourceIsTagged(inputFile *os.File) (bool, error) {
+	var tagged bool
+	err := inspectSource(inputFile, func(ctx *model.Context) error {
+		tagged = isTagged(ctx)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if tagged && !allowUntagged {
+		warnMsg("untagged_output")
+	}
+	return tagged, nil
+}
+
+// stripStructure removes what is left of the logical structure after trimming, so that a chapter
+// is consistently untagged instead of claiming to be tagged without a structure tree.
+// pdfcpu drops the structure tree when trimming but keeps MarkInfo and the pages' StructParents.
+// Parameters:
+//   - ctx: pdfcpu context of the trimmed chapter
+//
+// Returns:
+//   - error: if the document structure cannot be read
+func stripStructure(ctx *model.Context) error {
+	root, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	root.Delete("StructTreeRoot")
+	root.Delete("MarkInfo")
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		pageDict, _, _, err := ctx.PageDict(pageNr, false)
+		if err != nil {
+			return err
+		}
+		pageDict.Delete("StructParents")
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/souhup/pdf-spliter/splitter"
+	"github.com/spf13/pflag"
+)
+
+// Values of --target-fs.
+const (
+	targetFSAuto  = "auto"
+	targetFSFAT   = "fat"
+	targetFSNTFS  = "ntfs"
+	targetFSPOSIX = "posix"
+)
+
+// minNameLength is the smallest --max-name-length, which leaves room for a short title,
+// a collision suffix and the .pdf extension.
+const minNameLength = 16
+
+// partialNameReserve is the room a name needs for the temporary ".<name>.<random>.partial" form:
+// both dots, the suffix and up to 10 random digits.
+const partialNameReserve = len("..") + len(".partial") + 10
+
+// fsRules are the file name limits and metadata support of the filesystem the outputs are written to.
+// The name rules are those of the splitter package, so that the library names files the same way.
+type fsRules struct {
+	name string
+	splitter.NameRules
+	// noPermissions means setting file permissions may fail, which is then only a warning
+	noPermissions bool
+}
+
+// targetRules are the rules of each --target-fs value. FAT, exFAT and NTFS share the name rules
+// of Windows and count names in UTF-16 units.
+var targetRules = map[string]fsRules{
+	targetFSFAT:   {name: targetFSFAT, NameRules: splitter.FATNames, noPermissions: true},
+	targetFSNTFS:  {name: targetFSNTFS, NameRules: splitter.NTFSNames, noPermissions: true},
+	targetFSPOSIX: {name: targetFSPOSIX, NameRules: splitter.POSIXNames},
+}
+
+var (
+	// outputFS are the rules of the output filesystem, resolved from --target-fs before anything is named.
+	outputFS = targetRules[targetFSPOSIX]
+	// maxNameLength is --max-name-length, the longest file name in bytes, 0 for the limit of the
+	// filesystem; it is part of the rules of outputFS once they are resolved
+	maxNameLength int
+	// permissionsIgnored is set when the output filesystem refused the permissions of a file.
+	// Chapters are committed concurrently, so it is updated atomically.
+	permissionsIgnored atomic.Bool
+)
+
+// initTargetFSFlag registers --target-fs and --max-name-length on a command that writes outputs.
+func initTargetFSFlag(flags *pflag.FlagSet) {
+	flags.StringVar(&targetFS, "target-fs", targetFSAuto, "file name rules of the output filesystem: fat, ntfs, posix or auto to detect them")
+	flags.IntVar(&maxNameLength, "max-name-length", 0, "shorten file names to this many bytes, including the extension (0 for the limit of the filesystem)")
+}
+
+// resolveTargetFS sets the rules of the output filesystem from --target-fs.
+// Parameters:
+//   - dir: directory the outputs are written to; it may not exist yet
+//
+// Returns:
+//   - error: if --target-fs has an unknown value or --max-name-length is too small
+func resolveTargetFS(dir string) error {
+	if maxNameLength != 0 && maxNameLength < minNameLength {
+		return fmt.Errorf("invalid --max-name-length value %d: must be 0 or at least %d", maxNameLength, minNameLength)
+	}
+	if targetFS != targetFSAuto {
+		rules, ok := targetRules[targetFS]
+		if !ok {
+			return fmt.Errorf("invalid --target-fs value '%s': must be %s, %s, %s or %s",
+				targetFS, targetFSFAT, targetFSNTFS, targetFSPOSIX, targetFSAuto)
+		}
+		outputFS = rules
+		outputFS.MaxBytes = maxNameLength
+		return nil
+	}
+
+	// Detect the filesystem of the nearest directory that exists, keeping POSIX rules if it cannot be found
+	outputFS.MaxBytes = maxNameLength
+	path, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	for {
+		if _, err := os.Stat(path); err == nil || filepath.Dir(path) == path {
+			break
+		}
+		path = filepath.Dir(path)
+	}
+	outputFS = targetRules[detectFilesystem(path)]
+	outputFS.MaxBytes = maxNameLength
+	return nil
+}
+
+// reportIgnoredPermissions warns once if the output filesystem did not take the permissions of the outputs.
+func reportIgnoredPermissions() {
+	if permissionsIgnored.Load() {
+		warnMsg("permissions_ignored", outputFS.name)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import "syscall"
+
+// detectFilesystem returns the --target-fs rules for the filesystem of an existing path.
+func detectFilesystem(path string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return targetFSPOSIX
+	}
+	var name []byte
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	switch string(name) {
+	case "msdos", "exfat":
+		return targetFSFAT
+	case "ntfs":
+		return targetFSNTFS
+	}
+	return targetFSPOSIX
+}
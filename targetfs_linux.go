@@ -0,0 +1,27 @@
+package main
+
+import "syscall"
+
+// Filesystem magic numbers reported by statfs on Linux.
+const (
+	msdosMagic   = 0x4d44
+	exfatMagic   = 0x2011bab0
+	ntfsMagic    = 0x5346544e
+	fuseblkMagic = 0x65735546
+)
+
+// detectFilesystem returns the --target-fs rules for the filesystem of an existing path.
+// FUSE block devices are mostly NTFS or exFAT drives, which share the same rules.
+func detectFilesystem(path string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return targetFSPOSIX
+	}
+	switch uint32(st.Type) {
+	case msdosMagic, exfatMagic:
+		return targetFSFAT
+	case ntfsMagic, fuseblkMagic:
+		return targetFSNTFS
+	}
+	return targetFSPOSIX
+}
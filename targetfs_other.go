@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package main
+
+import "runtime"
+
+// detectFilesystem returns the --target-fs rules for the filesystem of an existing path.
+// The filesystem is not inspected here: Windows drives get the rules of NTFS, which FAT and
+// exFAT drives share, and other systems those of POSIX.
+func detectFilesystem(string) string {
+	if runtime.GOOS == "windows" {
+		return targetFSNTFS
+	}
+	return targetFSPOSIX
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// threadStats counts the article threads of a chapter.
+// preserved threads kept all their beads, truncated ones lost beads on pages outside the chapter.
+type threadStats struct {
+	preserved int
+	truncated int
+}
+
+// sourceHasThreads reports whether the source document defines article threads.
+func sourceHasThreads(inputFile *os.File) (bool, error) {
+	var found bool
+	err := inspectSource(inputFile, func(ctx *model.Context) error {
+		if root, err := ctx.Catalog(); err == nil {
+			_, found = root.Find("Threads")
+		}
+		return nil
+	})
+	return found, err
+}
+
+// rebuildThreads restores the article threads of a trimmed chapter.
+// pdfcpu drops the document's Threads array when trimming, but the pages keep their beads,
+// which still link to the thread and to beads on pages that were not exported. Every thread
+// with a bead on a remaining page is relinked in its original bead order, skipping beads on
+// other pages, and listed again in the catalog. Threads appear in the order of their first bead.
+// Parameters:
+//   - ctx: pdfcpu context of the trimmed chapter
+//
+// Returns:
+//   - threadStats: number of preserved and truncated threads
+//   - error: if the document structure cannot be read
+func rebuildThreads(ctx *model.Context) (threadStats, error) {
+	var stats threadStats
+
+	// Collect the beads of the remaining pages and the threads they belong to.
+	// Trimming copies the pages, so each kept bead is pointed at the copy listing it.
+	onPage := map[int]bool{}
+	var threadRefs []types.IndirectRef
+	seen := map[int]bool{}
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		pageDict, pageRef, _, err := ctx.PageDict(pageNr, false)
+		if err != nil {
+			return stats, err
+		}
+		beads, err := ctx.DereferenceArray(pageDict["B"])
+		if err != nil {
+			return stats, err
+		}
+		for _, b := range beads {
+			ir, ok := b.(types.IndirectRef)
+			if !ok {
+				continue
+			}
+			bead, err := ctx.DereferenceDict(ir)
+			if err != nil || bead == nil {
+				continue
+			}
+			onPage[ir.ObjectNumber.Value()] = true
+			bead["P"] = *pageRef
+			if t := bead.IndirectRefEntry("T"); t != nil && !seen[t.ObjectNumber.Value()] {
+				seen[t.ObjectNumber.Value()] = true
+				threadRefs = append(threadRefs, *t)
+			}
+		}
+	}
+
+	// Relink the beads of every thread that lie on remaining pages
+	var threads types.Array
+	for _, ref := range threadRefs {
+		thread, err := ctx.DereferenceDict(ref)
+		if err != nil || thread == nil {
+			continue
+		}
+		var kept []types.IndirectRef
+		var total int
+		first := thread.IndirectRefEntry("F")
+		visited := map[int]bool{}
+		for ir := first; ir != nil && !visited[ir.ObjectNumber.Value()]; {
+			visited[ir.ObjectNumber.Value()] = true
+			bead, err := ctx.DereferenceDict(*ir)
+			if err != nil || bead == nil {
+				break
+			}
+			total++
+			if onPage[ir.ObjectNumber.Value()] {
+				kept = append(kept, *ir)
+			}
+			ir = bead.IndirectRefEntry("N")
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		for i, ir := range kept {
+			bead, _ := ctx.DereferenceDict(ir)
+			bead["N"] = kept[(i+1)%len(kept)]
+			bead["V"] = kept[(i+len(kept)-1)%len(kept)]
+		}
+		thread["F"] = kept[0]
+		threads = append(threads, ref)
+		if len(kept) == total {
+			stats.preserved++
+		} else {
+			stats.truncated++
+		}
+	}
+	if len(threads) == 0 {
+		return stats, nil
+	}
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		return stats, err
+	}
+	root["Threads"] = threads
+	return stats, nil
+}
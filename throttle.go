@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxThrottledChunk bounds the size of a single throttled write so that
+// large writes are spread evenly over time instead of arriving in bursts.
+const maxThrottledChunk = 32 * 1024
+
+// rateLimiter is a token bucket shared by all output writers.
+// Tokens are bytes; the bucket refills at rate bytes per second and holds at most one second of tokens.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a limiter allowing bytesPerSecond bytes to be written per second.
+func newRateLimiter(bytesPerSecond float64) *rateLimiter {
+	return &rateLimiter{rate: bytesPerSecond, tokens: bytesPerSecond, last: time.Now()}
+}
+
+// wait blocks until n bytes may be written.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Refill the bucket for the time passed since the last call
+	now := time.Now()
+	l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	// Take the tokens, sleeping for any deficit while holding the lock so writers queue up fairly
+	l.tokens -= float64(n)
+	if l.tokens < 0 {
+		deficit := time.Duration(-l.tokens / l.rate * float64(time.Second))
+		time.Sleep(deficit)
+		l.last = l.last.Add(deficit)
+		l.tokens = 0
+	}
+}
+
+// throttledWriter is an io.Writer that waits for a shared rateLimiter before each write.
+type throttledWriter struct {
+	w       io.Writer
+	limiter *rateLimiter
+}
+
+// Write writes p in chunks, each admitted by the limiter.
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := p[:min(len(p), maxThrottledChunk)]
+		t.limiter.wait(len(chunk))
+		n, err := t.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// writeStats accumulates the number of bytes written to output files.
+type writeStats struct {
+	mu    sync.Mutex
+	bytes int64
+}
+
+// countingWriter is an io.Writer that adds the bytes written to a writeStats.
+type countingWriter struct {
+	w     io.Writer
+	stats *writeStats
+}
+
+// Write writes p and records its length.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.stats.mu.Lock()
+	c.stats.bytes += int64(n)
+	c.stats.mu.Unlock()
+	return n, err
+}
+
+// outputWriter wraps an output file with the --bandwidth limiter, if any, and byte counting.
+func outputWriter(w io.Writer, stats *writeStats) io.Writer {
+	if writeLimiter != nil {
+		w = &throttledWriter{w: w, limiter: writeLimiter}
+	}
+	return &countingWriter{w: w, stats: stats}
+}
+
+// printThroughput prints the average write throughput of an export.
+func printThroughput(stats *writeStats, elapsed time.Duration) {
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		return
+	}
+	printMsg("throughput", formatBytes(float64(stats.bytes)), seconds, formatBytes(float64(stats.bytes)/seconds))
+}
+
+// byteUnits maps size suffixes to their multiplier.
+var byteUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+}
+
+// parseBandwidth parses a bandwidth such as "10MB/s", "512KiB/s" or "1000000".
+// Decimal units (KB, MB, GB) are powers of 1000, binary units (KiB, MiB, GiB) powers of 1024.
+// Parameters:
+//   - s: bandwidth expression, the "/s" suffix is optional
+//
+// Returns:
+//   - float64: bytes per second
+//   - error: if the expression is malformed or not positive
+func parseBandwidth(s string) (float64, error) {
+	expr := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "/S")
+
+	// Split the number from the unit suffix
+	i := strings.IndexFunc(expr, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(expr)
+	}
+	value, err := strconv.ParseFloat(expr[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bandwidth '%s'", s)
+	}
+	unit, ok := byteUnits[strings.TrimSpace(expr[i:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid bandwidth unit in '%s': use B, KB, MB, GB, KiB, MiB or GiB", s)
+	}
+	if value <= 0 {
+		return 0, fmt.Errorf("bandwidth must be positive: '%s'", s)
+	}
+	return value * unit, nil
+}
+
+// formatBytes formats a byte count with a decimal unit.
+func formatBytes(n float64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1f GB", n/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1f MB", n/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1f KB", n/1e3)
+	}
+	return fmt.Sprintf("%.0f B", n)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// exitChapterTimeout is the exit code used when chapters were skipped because they timed out.
+const exitChapterTimeout = 6
+
+// errChapterTimeout is returned when exporting a chapter takes longer than --chapter-timeout.
+var errChapterTimeout = errors.New("chapter export timed out")
+
+// timedOutChapters lists the chapters skipped because of --chapter-timeout, for the summary.
+var timedOutChapters []string
+
+// trimWithTimeout trims a chapter like trimChapter, giving up after timeout.
+// pdfcpu cannot be interrupted, so the export runs on its own handle of the source, or on the
+// source read once, and into memory; on expiry it is abandoned and keeps running in the
+// background until it finishes or the process exits, without touching the output file.
+// Parameters:
+//   - sourcePath: path of the source PDF file
+//   - doc: the source read once, or nil to read it from sourcePath
+//   - pageRange: pdfcpu page selection of the chapter
+//   - fixes: repairs to apply
+//   - timeout: maximum duration of the export
+//
+// Returns:
+//   - []byte: the trimmed chapter
+//   - fixReport: what the fixes of the chapter did
+//   - error: errChapterTimeout on expiry, or the export error
+func trimWithTimeout(sourcePath string, doc *splitter.Document, pageRange string, fixes chapterFixes, timeout time.Duration) ([]byte, fixReport, error) {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, fixReport{}, err
+	}
+
+	type result struct {
+		data   []byte
+		report fixReport
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer source.Close()
+		var buf bytes.Buffer
+		report, err := trimChapter(source, doc, &buf, pageRange, fixes)
+		done <- result{data: buf.Bytes(), report: report, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.data, r.report, r.err
+	case <-time.After(timeout):
+		return nil, fixReport{}, errChapterTimeout
+	}
+}
+
+// exitOnTimeouts ends the run with exitChapterTimeout if any chapter timed out,
+// after listing the skipped chapters and their page ranges.
+func exitOnTimeouts() error {
+	if len(timedOutChapters) == 0 {
+		return nil
+	}
+	errorMsg("timeouts_failed", len(timedOutChapters))
+	for _, line := range timedOutChapters {
+		fmt.Fprintln(messageOutput, "  - "+line)
+	}
+	return exitWith(exitChapterTimeout, nil)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// tocPageTolerance is the largest difference in page count between the --toc-from-pdf document,
+// shifted by --page-offset, and the input, e.g. for blank or advertising pages at the end of a scan.
+const tocPageTolerance = 10
+
+var (
+	// pageOffsetSet is set when --page-offset was given, accepting that the page counts differ.
+	pageOffsetSet bool
+	// tocSourceID identifies the --toc-from-pdf document in the manifest, once it was read.
+	tocSourceID string
+)
+
+// sourceChapters plans the chapters of the input: from its own outline, or from the outline of
+// the --toc-from-pdf document, an edition with the same pagination, shifted by --page-offset.
+// Parameters:
+//   - inputFile: pointer to the opened PDF file
+//   - under: title or regular expression of the parent bookmark, or empty for the whole outline
+//
+// Returns:
+//   - []chapter: the chapters with page ranges of the input
+//   - string: title of the parent bookmark, empty if under is not set
+//   - error: if either document cannot be read or the plan does not fit the pages of the input
+func sourceChapters(inputFile *os.File, under string) ([]chapter, string, error) {
+	if tocFromPDF == "" {
+		return extractChapters(inputFile, under)
+	}
+	donor, err := os.Open(tocFromPDF)
+	if err != nil {
+		return nil, "", fmt.Errorf("open --toc-from-pdf file %s: %w", tocFromPDF, err)
+	}
+	defer donor.Close()
+	defer releaseSourceDocument(donor)
+	if err = checkInputFormat(donor); err != nil {
+		return nil, "", err
+	}
+	if err = checkPassword(donor); err != nil {
+		return nil, "", err
+	}
+
+	// Both editions must have the same pagination, apart from the offset
+	donorPages, err := sourcePageCount(donor)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", tocFromPDF, err)
+	}
+	inputPages, err := sourcePageCount(inputFile)
+	if err != nil {
+		return nil, "", err
+	}
+	if err = checkTocPages(donorPages, inputPages); err != nil {
+		return nil, "", err
+	}
+	if tocSourceID == "" {
+		if tocSourceID, err = sourceID(donor); err != nil {
+			return nil, "", err
+		}
+		printMsg("using_toc_from", tocFromPDF, donorPages, pageOffset)
+	}
+
+	chapters, parentTitle, err := extractChapters(donor, under)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range chapters {
+		if err = shiftChapter(&chapters[i], donorPages, inputPages); err != nil {
+			return nil, "", err
+		}
+	}
+	markDetection(chapters, detectionTOC)
+	if err = checkRanges(chapters); err != nil {
+		return nil, "", err
+	}
+	return chapters, parentTitle, nil
+}
+
+// checkTocPages verifies that the input can take a chapter plan made for the --toc-from-pdf
+// document. Unless --page-offset is given, both must have the same number of pages; with it,
+// the shifted page count may differ by up to tocPageTolerance pages.
+func checkTocPages(donorPages, inputPages int) error {
+	if !pageOffsetSet {
+		if donorPages != inputPages {
+			return fmt.Errorf("'%s' has %d pages, but --toc-from-pdf '%s' has %d; give --page-offset if the editions differ by pages at the start or end",
+				inputFilePath, inputPages, tocFromPDF, donorPages)
+		}
+		return nil
+	}
+	if diff := inputPages - (donorPages + pageOffset); diff > tocPageTolerance || diff < -tocPageTolerance {
+		return fmt.Errorf("'%s' has %d pages, but --toc-from-pdf '%s' has %d, which is %d with --page-offset %d; at most %d pages may differ",
+			inputFilePath, inputPages, tocFromPDF, donorPages, donorPages+pageOffset, pageOffset, tocPageTolerance)
+	}
+	return nil
+}
+
+// shiftChapter moves a chapter planned on the --toc-from-pdf document to the pages of the input.
+// A chapter that ends with the document ends with the input, and ranges are cut to the input.
+// Parameters:
+//   - cpt: chapter with page numbers of the --toc-from-pdf document, updated in place
+//   - donorPages: number of pages of the --toc-from-pdf document
+//   - inputPages: number of pages of the input
+//
+// Returns:
+//   - error: if the shifted chapter has no page of the input
+func shiftChapter(cpt *chapter, donorPages, inputPages int) error {
+	start, end := int(cpt.startPage)+pageOffset, int(cpt.endPage)+pageOffset
+	if int(cpt.endPage) == donorPages {
+		end = inputPages
+	}
+	start, end = max(start, 1), min(end, inputPages)
+	if start > end {
+		return fmt.Errorf("chapter '%s' (pages %d-%d of '%s') is outside the %d pages of '%s' with --page-offset %d",
+			cpt.title, cpt.startPage, cpt.endPage, tocFromPDF, inputPages, inputFilePath, pageOffset)
+	}
+	if start != int(cpt.startPage) || end != int(cpt.endPage) {
+		cpt.explain("explain_toc_shifted", cpt.startPage, cpt.endPage, start, end)
+	}
+	cpt.startPage, cpt.endPage = uint32(start), uint32(end)
+	cpt.kids = shiftBookmarks(cpt.kids, pageOffset)
+	return nil
+}
+
+// shiftBookmarks returns a copy of a bookmark tree with all pages moved by offset.
+func shiftBookmarks(bookmarks []pdfcpu.Bookmark, offset int) []pdfcpu.Bookmark {
+	if offset == 0 || len(bookmarks) == 0 {
+		return bookmarks
+	}
+	shifted := make([]pdfcpu.Bookmark, len(bookmarks))
+	for i, bm := range bookmarks {
+		shifted[i] = pdfcpu.Bookmark{Title: bm.Title, PageFrom: bm.PageFrom + offset, Kids: shiftBookmarks(bm.Kids, offset)}
+	}
+	return shifted
+}
+
+// tocSource returns the absolute path of the --toc-from-pdf document for the manifest, or "".
+func tocSource() string {
+	if tocFromPDF == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(tocFromPDF); err == nil {
+		return abs
+	}
+	return tocFromPDF
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// chapterFixes selects the repairs applied to a chapter after trimming.
+type chapterFixes struct {
+	// layers rebuilds the optional content properties for the remaining pages
+	layers bool
+	// untag removes the remains of the logical structure of a tagged source
+	untag bool
+	// continuation appends a page with this text, naming the next output
+	continuation string
+	// pad appends this many blank pages
+	pad int
+	// threads restores the article threads on the remaining pages
+	threads bool
+	// images downsamples and recompresses images above a resolution
+	images imageSettings
+	// subset removes fonts and XObjects no page uses
+	subset bool
+	// bookmarks replaces the outline, e.g. to mark the chapters packed into one output
+	bookmarks []pdfcpu.Bookmark
+	// stamp prints this chapter ID on the first page
+	stamp string
+	// header prints the chapter title and source page number on every page, for --stamp-header
+	header *pageHeader
+	// info sets these entries of the document information dictionary, e.g. the Title
+	info map[string]string
+	// protect encrypts the chapter like the source, for --keep-encryption
+	protect *protection
+	// provenance marks the chapter as written by pdf-split, so it is not split again by accident
+	provenance *provenance
+}
+
+// none reports whether no fix is selected.
+func (f chapterFixes) none() bool {
+	return !f.layers && !f.untag && f.continuation == "" && f.pad == 0 && !f.threads && !f.subset && f.images == (imageSettings{}) && len(f.bookmarks) == 0 && f.stamp == "" && f.header == nil && len(f.info) == 0 && f.protect == nil && f.provenance == nil
+}
+
+// fixReport collects what the fixes of a chapter did.
+type fixReport struct {
+	threads threadStats
+	images  int
+	subset  subsetStats
+}
+
+// trimChapter extracts a page range like api.Trim and applies the selected fixes to the result.
+// Without fixes the chapter is written directly; otherwise it is trimmed into memory and
+// rewritten once with all fixes applied.
+// Parameters:
+//   - rs: source document
+//   - doc: the source read once by loadSourceDocument, or nil to read rs for this chapter
+//   - w: destination of the chapter
+//   - pageRange: pdfcpu page selection of the chapter, several expressions separated by commas
+//   - fixes: repairs to apply
+//
+// Returns:
+//   - fixReport: preserved and truncated article threads, recompressed images and the bytes saved by subsetting
+//   - error: if trimming or rewriting fails
+func trimChapter(rs io.ReadSeeker, doc *splitter.Document, w io.Writer, pageRange string, fixes chapterFixes) (fixReport, error) {
+	var report fixReport
+	selection := strings.Split(pageRange, ",")
+	if fixes.layers && doc == nil {
+		// The layers of the chapter are rebuilt from those of the source
+		var err error
+		if doc, err = splitter.ReadDocument(rs, sourceConfiguration(), sourceLimits()); err != nil {
+			return report, err
+		}
+	}
+	trim := func(w io.Writer) error {
+		if doc != nil {
+			return doc.Trim(w, selection)
+		}
+		return api.Trim(rs, w, selection, sourceConfiguration())
+	}
+	if fixes.none() {
+		return report, trim(w)
+	}
+
+	var buf bytes.Buffer
+	if err := trim(&buf); err != nil {
+		return report, err
+	}
+	ctx, err := api.ReadAndValidate(bytes.NewReader(buf.Bytes()), model.NewDefaultConfiguration())
+	if err != nil {
+		return report, err
+	}
+	if fixes.layers {
+		pageNrs, err := selectedPages(doc.PageCount(), selection)
+		if err != nil {
+			return report, err
+		}
+		err = doc.Inspect(func(src *model.Context) error {
+			return copyOptionalContent(src, pageNrs, ctx)
+		})
+		if err == nil {
+			err = pruneOptionalContent(ctx)
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+	if fixes.untag {
+		if err = stripStructure(ctx); err != nil {
+			return report, err
+		}
+	}
+	if fixes.threads {
+		if report.threads, err = rebuildThreads(ctx); err != nil {
+			return report, err
+		}
+	}
+	if len(fixes.bookmarks) > 0 {
+		if err = writeOutline(ctx, fixes.bookmarks); err != nil {
+			return report, err
+		}
+	}
+	if fixes.header != nil {
+		if err = stampPageHeaders(ctx, fixes.header, pageRange); err != nil {
+			return report, err
+		}
+	}
+	if fixes.continuation != "" {
+		if err = appendContinuationPage(ctx, fixes.continuation); err != nil {
+			return report, err
+		}
+	}
+	for range fixes.pad {
+		if err = appendBlankPage(ctx); err != nil {
+			return report, err
+		}
+	}
+	if fixes.images != (imageSettings{}) {
+		if report.images, err = recompressImages(ctx, fixes.images); err != nil {
+			return report, err
+		}
+	}
+	if fixes.stamp != "" {
+		if err = stampChapterID(ctx, fixes.stamp); err != nil {
+			return report, err
+		}
+	}
+	if err = setDocumentInfo(ctx, fixes.info); err != nil {
+		return report, err
+	}
+	if fixes.provenance != nil {
+		if err = addProvenance(ctx, fixes.provenance); err != nil {
+			return report, err
+		}
+	}
+	if fixes.subset {
+		data, stats, err := subsetChapter(ctx)
+		if err != nil {
+			return report, err
+		}
+		report.subset = stats
+		if fixes.protect != nil {
+			// Encrypt only the final version, which needs another pass over the subset chapter
+			if ctx, err = api.ReadAndValidate(bytes.NewReader(data), model.NewDefaultConfiguration()); err != nil {
+				return report, err
+			}
+			encryptOnWrite(ctx, fixes.protect)
+			return report, api.WriteContext(ctx, w)
+		}
+		_, err = w.Write(data)
+		return report, err
+	}
+	if fixes.protect != nil {
+		encryptOnWrite(ctx, fixes.protect)
+	}
+	return report, api.WriteContext(ctx, w)
+}
+
+// subsetChapter reduces the resources of a chapter and returns it written, measuring the bytes saved.
+// The chapter is written once as is and read back, so the unchanged version is at hand if
+// the analysis is inconclusive, which is reported in the stats rather than as an error.
+func subsetChapter(ctx *model.Context) ([]byte, subsetStats, error) {
+	var stats subsetStats
+	var before bytes.Buffer
+	if err := api.WriteContext(ctx, &before); err != nil {
+		return nil, stats, err
+	}
+	reduced, err := api.ReadAndValidate(bytes.NewReader(before.Bytes()), model.NewDefaultConfiguration())
+	if err != nil {
+		return nil, stats, err
+	}
+	if stats.skipped = subsetResources(reduced); stats.skipped != nil {
+		return before.Bytes(), stats, nil
+	}
+	var after bytes.Buffer
+	if err = api.WriteContext(reduced, &after); err != nil {
+		return nil, stats, err
+	}
+	// Per-page resource dictionaries cost a few bytes, so keep the original if nothing was gained
+	if after.Len() >= before.Len() {
+		return before.Bytes(), stats, nil
+	}
+	stats.savedBytes = int64(before.Len() - after.Len())
+	return after.Bytes(), stats, nil
+}
+
+// selectedPages returns the pages of a pdfcpu page selection in ascending order, the order in
+// which trimming copies them.
+func selectedPages(pageCount int, selection []string) ([]int, error) {
+	pages, err := api.PagesForPageSelection(pageCount, selection, false, true)
+	if err != nil {
+		return nil, err
+	}
+	var pageNrs []int
+	for page, selected := range pages {
+		if selected {
+			pageNrs = append(pageNrs, page)
+		}
+	}
+	sort.Ints(pageNrs)
+	return pageNrs, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// exitInvalidOutputs is the exit code used when written files could not be read back or failed --validate-outputs.
+const exitInvalidOutputs = 10
+
+// invalidOutputs lists the written files that could not be read back or failed --validate-outputs, for the summary.
+var invalidOutputs []string
+
+// validateOutput checks a written file with pdfcpu's strict validation, which rejects quirks
+// that lenient viewers accept but strict consumers such as upload portals may not.
+// A failure is reported and recorded for the summary; the file is kept for inspection.
+// Parameters:
+//   - path: path of the written PDF file
+//   - title: chapter title used in messages
+//
+// Returns:
+//   - []string: the messages of the failed validation, one per line, for the manifest; nil if
+//     the file passed it
+func validateOutput(path, title string) []string {
+	f, err := os.Open(path)
+	if err == nil {
+		defer f.Close()
+
+		// Outputs encrypted by --keep-encryption open with the source's passwords
+		conf := sourceConfiguration()
+		conf.ValidationMode = model.ValidationStrict
+		err = api.Validate(f, conf)
+	}
+	if err == nil {
+		return nil
+	}
+	invalidOutputs = append(invalidOutputs, msg("invalid_entry", title, path, err))
+	errorMsg("output_invalid", title, path, err)
+	var messages []string
+	for _, line := range strings.Split(err.Error(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			messages = append(messages, line)
+		}
+	}
+	return messages
+}
+
+// exitOnInvalidOutputs ends the run with exitInvalidOutputs if any written file could not be read
+// back or failed --validate-outputs, after listing the files and their errors.
+func exitOnInvalidOutputs() error {
+	if len(invalidOutputs) == 0 {
+		return nil
+	}
+	errorMsg("validation_failed", len(invalidOutputs))
+	for _, line := range invalidOutputs {
+		fmt.Fprintln(messageOutput, "  - "+line)
+	}
+	return exitWith(exitInvalidOutputs, nil)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// verifyPageCount reads back a written file and compares its page count with the plan, unless
+// --no-verify is given. A file that cannot be read back is reported and recorded for the summary
+// like a file failing --validate-outputs, so the run ends with exitInvalidOutputs; the remaining
+// chapters are still written. A mismatch is reported loudly and, with --strict-pages, is fatal.
+// Parameters:
+//   - path: path of the written PDF file
+//   - title: chapter title used in messages
+//   - want: number of pages the plan expects
+//
+// Returns:
+//   - int: the page count read back, 0 if the file was not read back
+//   - bool: whether the file was read back with the planned page count
+//   - error: the page count mismatch with --strict-pages
+func verifyPageCount(path, title string, want int) (int, bool, error) {
+	if noVerify {
+		return 0, false, nil
+	}
+	// Outputs encrypted by --keep-encryption open with the source's passwords
+	f, err := os.Open(path)
+	if err == nil {
+		err = splitter.CheckPageCount(f, want, sourceConfiguration())
+		f.Close()
+	}
+	var mismatch *splitter.PageCountError
+	switch {
+	case err == nil:
+		return want, true, nil
+	case !errors.As(err, &mismatch):
+		invalidOutputs = append(invalidOutputs, msg("invalid_entry", title, path, err))
+		errorMsg("output_unreadable", title, path, err)
+		return 0, false, nil
+	case strictPages:
+		return mismatch.Got, false, fmt.Errorf("chapter '%s' has %d pages but %d were planned: '%s'", title, mismatch.Got, want, path)
+	default:
+		warnMsg("page_count_mismatch", title, mismatch.Got, want, path)
+	}
+	return mismatch.Got, false, nil
+}
+
+// printPageSummary reconciles the pages of an export with the source: the pages written, including
+// blank and continuation pages, how many source pages the chapters cover, and the source pages no
+// chapter covers, such as the pages before the first bookmark.
+// Parameters:
+//   - inputFile: pointer to the source PDF file
+//   - chapters: the chapters of the export
+//   - written: number of pages written
+//
+// Returns:
+//   - error: if the page count of the source cannot be read
+func printPageSummary(inputFile *os.File, chapters []chapter, written int) error {
+	pageCount, err := sourcePageCount(inputFile)
+	if err != nil {
+		return err
+	}
+	var spans []pageRange
+	for _, cpt := range chapters {
+		spans = append(spans, cpt.pageSpans()...)
+	}
+	covered := unionRanges(spans)
+	var coveredPages int
+	for _, span := range covered {
+		coveredPages += int(span.end-span.start) + 1
+	}
+	printMsg("pages_summary", len(chapters), written, coveredPages, pageCount)
+
+	gaps := subtractRanges([]pageRange{{1, uint32(pageCount)}}, covered)
+	if len(gaps) == 0 {
+		return nil
+	}
+	printMsg("pages_not_covered", formatPageRuns(gaps))
+	return nil
+}
+
+// plannedPages returns the number of pages a chapter is expected to contain.
+func plannedPages(cpt chapter) int {
+	var pages int
+	for _, span := range cpt.pageSpans() {
+		pages += int(span.end-span.start) + 1
+	}
+	return pages
+}
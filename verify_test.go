@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+// TestPageSummary splits a document whose first chapter starts on page 3, which must be reported
+// as written, covered and not covered pages, with every file verified in the manifest along with
+// the pages read back.
+func TestPageSummary(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "late.pdf")
+	if err := writeSampleDocument(source, 8, []pdfcpu.Bookmark{
+		{Title: "One", PageFrom: 3},
+		{Title: "Two", PageFrom: 6},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, verify := range []bool{true, false} {
+		out, args := "verified", []string{"--manifest", "toc.json", "--sidecar-suffix=", "--bloat-factor=0"}
+		if !verify {
+			out, args = "unverified", append(args, "--no-verify")
+		}
+		args = append(args, "-i", source, "-o", out)
+		output, err := runCommand(t, dir, args...)
+		if err != nil {
+			t.Fatalf("%v\n%s", err, output)
+		}
+		for _, want := range []string{
+			"2 chapters with 6 pages written, covering 6 of the source's 8 pages",
+			"source pages not in any chapter: 1-2",
+			"orphan pages 1-2 are in no chapter and left out",
+		} {
+			if !strings.Contains(output, want) {
+				t.Errorf("%s: output lacks %q\n%s", out, want, output)
+			}
+		}
+		for _, record := range readManifest(t, filepath.Join(dir, out, "toc.json")) {
+			if record["verified"] != verify {
+				t.Errorf("%s: %v has verified %v, want %v", out, record["file"], record["verified"], verify)
+			}
+			if written, ok := record["pages_written"]; ok != verify || verify && written != record["pages"] {
+				t.Errorf("%s: %v has pages_written %v, want the %v pages only if verified", out, record["file"], written, record["pages"])
+			}
+		}
+	}
+}
+
+// TestVerifyPageCount reads back a file with the planned pages, one with fewer and an empty file,
+// such as one left by a full disk, which must fail the run with exitInvalidOutputs.
+func TestVerifyPageCount(t *testing.T) {
+	messageOutput = io.Discard
+	defer func() { messageOutput = clearingWriter{os.Stderr} }()
+	defer func() { invalidOutputs, warnings, strictPages = nil, []warning{}, false }()
+
+	book := filepath.Join("testdata", "book.pdf")
+	if got, ok, err := verifyPageCount(book, "Book", 16); got != 16 || !ok || err != nil {
+		t.Errorf("planned pages: got %d, %v, %v, want 16 pages verified", got, ok, err)
+	}
+
+	if got, ok, err := verifyPageCount(book, "Book", 15); got != 16 || ok || err != nil || len(warnings) != 1 || warnings[0].Code != "W008" {
+		t.Errorf("other pages: got %d, %v, %v with warnings %v, want 16 pages and W008", got, ok, err, warnings)
+	}
+	strictPages = true
+	if got, ok, err := verifyPageCount(book, "Book", 15); got != 16 || ok || err == nil {
+		t.Errorf("other pages with --strict-pages: got %d, %v, %v, want 16 pages and an error", got, ok, err)
+	}
+	if err := exitOnInvalidOutputs(); err != nil {
+		t.Errorf("readable files: got %v, want nil", err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.pdf")
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, err := verifyPageCount(empty, "Empty", 3); got != 0 || ok || err != nil || len(invalidOutputs) != 1 {
+		t.Errorf("empty file: got %d, %v, %v with %v, want it recorded as invalid", got, ok, err, invalidOutputs)
+	}
+	var exit *exitError
+	if err := exitOnInvalidOutputs(); !errors.As(err, &exit) || exit.code != exitInvalidOutputs {
+		t.Errorf("empty file: got %v, want exit code %d", err, exitInvalidOutputs)
+	}
+}
+
+// TestValidateOutput validates the book fixture, whose standard fonts lack the FirstChar entry
+// strict validation requires: its message must be returned for the manifest and the file must be
+// recorded as invalid. A split with --validate-outputs must fail with exitInvalidOutputs and
+// still write the manifest with the messages of every chapter.
+func TestValidateOutput(t *testing.T) {
+	messageOutput = io.Discard
+	defer func() { messageOutput = clearingWriter{os.Stderr} }()
+	defer func() { invalidOutputs = nil }()
+
+	messages := validateOutput(filepath.Join("testdata", "book.pdf"), "Book")
+	if len(messages) != 1 || !strings.Contains(messages[0], "FirstChar") || len(invalidOutputs) != 1 {
+		t.Fatalf("got messages %q with %v, want the missing FirstChar recorded as invalid", messages, invalidOutputs)
+	}
+
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := runCommand(t, dir, "-i", source, "-o", "out", "--manifest", "toc.json",
+		"--validate-outputs", "--sidecar-suffix=", "--bloat-factor=0")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitInvalidOutputs {
+		t.Fatalf("got %v, want exit code %d\n%s", err, exitInvalidOutputs, output)
+	}
+	for _, record := range readManifest(t, filepath.Join(dir, "out", "toc.json")) {
+		validation, _ := record["validation"].([]any)
+		if record["verified"] != false || len(validation) == 0 || !strings.Contains(fmt.Sprint(validation[0]), "FirstChar") {
+			t.Errorf("%v: got verified %v with validation %v, want the missing FirstChar", record["file"], record["verified"], record["validation"])
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/spf13/cobra"
+)
+
+// Build information, set at link time, e.g.:
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=abc1234 -X main.buildDate=2024-01-01"
+//
+// Values left empty are filled from the module build information where possible.
+var (
+	version   string
+	commit    string
+	buildDate string
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print version information",
+	Args:  cobra.NoArgs,
+	Run: func(_ *cobra.Command, _ []string) {
+		fmt.Println(versionString())
+	},
+}
+
+// versionString returns a one-line description of this build, including the pdfcpu version.
+// Example: "v1.2.0 (commit abc1234, built 2024-01-01, pdfcpu v0.9.1)"
+func versionString() string {
+	v, c, d := version, commit, buildDate
+	pdfcpuVersion := model.VersionStr
+
+	// Fall back to the information embedded by the Go toolchain
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" {
+			v = info.Main.Version
+		}
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && c == "":
+				c = setting.Value
+			case setting.Key == "vcs.time" && d == "":
+				d = setting.Value
+			}
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/pdfcpu/pdfcpu" {
+				pdfcpuVersion = dep.Version
+			}
+		}
+	}
+
+	if v == "" {
+		v = "(devel)"
+	}
+	if c == "" {
+		c = "unknown"
+	}
+	if d == "" {
+		d = "unknown"
+	}
+	return fmt.Sprintf("%s (commit %s, built %s, pdfcpu %s)", v, c, d, pdfcpuVersion)
+}
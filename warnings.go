@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// exitWarnings is the exit code used when --strict fails a run because of warnings.
+const exitWarnings = 5
+
+// warningKind is a kind of warning with its stable code, for tooling that reacts to specific
+// warnings instead of matching their localized text.
+// key is the message key the warning is printed with.
+type warningKind struct {
+	Code        string `json:"code"`
+	Key         string `json:"key"`
+	Description string `json:"description"`
+}
+
+// warningKinds is the registry of all warnings, printed by the warnings subcommand. A code keeps
+// its meaning for good: a new kind of warning gets the next free code, and the code of a removed
+// kind is not reused. The tests compare the registry with testdata/warnings.json.
+var warningKinds = []warningKind{
+	{"W001", "outline_reordered", "a bookmark points to an earlier page than the one before it; chapters are taken in page order"},
+	{"W002", "lossy_name", "a chapter title changed significantly when it was made a file name"},
+	{"W003", "estimated_boundary", "a chapter starts on a page estimated by --infer-missing-destinations, accepted with --yes"},
+	{"W004", "outline_unresolved", "the outline has destinations that cannot be resolved; their start pages are estimated"},
+	{"W005", "outline_merged", "two bookmarks start at the top of the same page and are exported as one chapter"},
+	{"W006", "name_collision", "a chapter would take the file name of an earlier chapter and is written with a numeric suffix"},
+	{"W007", "uncovered_pages", "pages of the document are not part of any chapter"},
+	{"W008", "page_count_mismatch", "a written chapter has a different number of pages than planned"},
+	{"W009", "chapter_bloat", "a chapter is much larger per page than the source, e.g. because shared resources were copied"},
+	{"W010", "blank_chapter_skipped", "a chapter has only blank pages and is skipped by --strip-blank-pages"},
+	{"W011", "duplication_warning", "the input seems to contain the document twice"},
+	{"W012", "no_headings", "no page matches --heading-pattern; the outline is used"},
+	{"W013", "no_page_labels", "--logical-offset auto found no decimal page labels"},
+	{"W014", "untagged_output", "the input is a tagged PDF, whose chapters are written untagged"},
+	{"W015", "subset_skipped", "--subset-resources kept all resources of a chapter because the analysis was inconclusive"},
+	{"W016", "creation_date_missing", "the source has no creation date for --archive-layout; the run date is used"},
+	{"W017", "creation_date_invalid", "the creation date of the source cannot be parsed for --archive-layout; the run date is used"},
+	{"W018", "link_symlink", "--also-link could not hard link a chapter and created a symbolic link"},
+	{"W019", "link_copy", "--also-link could not link a chapter and copied it"},
+	{"W020", "link_failed", "--also-link could not publish a chapter"},
+	{"W021", "permissions_ignored", "the output filesystem does not support file permissions"},
+	{"W022", "assets_failed", "the --extract text or images of a chapter could not be written"},
+	{"W023", "barcode_empty", "a --split-on-barcode separator page is followed directly by another one or ends the document"},
+	{"W024", "no_barcodes", "--split-on-barcode found no separator page; the outline is used"},
+	{"W025", "plan_unknown_chapter", "an output of the --plan file refers to a chapter number that does not exist"},
+	{"W026", "plan_unknown_pages", "an output of the --plan file refers to pages beyond the end of the document"},
+	{"W027", "plan_overlap", "two outputs of the --plan file contain the same pages"},
+	{"W028", "plan_gap", "pages of the chapters are in no output of the --plan file"},
+	{"W029", "needs_review", "the chapter detection scores below --review-threshold; the document is written into _needs_review"},
+	{"W030", "batch_needs_review", "documents of a batch were written into _needs_review by --review-threshold"},
+	{"W031", "plan_options_differ", "the --plan file was written by a --dry-run=json run with other output options"},
+}
+
+// warningCodes maps the message key of every warning to its code.
+var warningCodes = func() map[string]string {
+	codes := make(map[string]string, len(warningKinds))
+	for _, kind := range warningKinds {
+		codes[kind.Key] = kind.Code
+	}
+	return codes
+}()
+
+// warning is a warning printed during the run.
+// code and key identify the kind of warning independently of the language of text.
+type warning struct {
+	Code string `json:"code"`
+	Key  string `json:"key"`
+	Text string `json:"message"`
+}
+
+// warnings collects all warnings printed so far.
+var warnings = []warning{}
+
+// warnMsg prints a localized warning with its code and records it.
+// The key must be registered in warningKinds.
+func warnMsg(key string, args ...any) {
+	code, ok := warningCodes[key]
+	if !ok {
+		panic(fmt.Sprintf("warning '%s' has no code in warningKinds", key))
+	}
+	w := warning{Code: code, Key: key, Text: msg(key, args...)}
+	warnings = append(warnings, w)
+	fmt.Fprintf(messageOutput, "[%s] %s\n", w.Code, w.Text)
+}
+
+// warningCodesSince returns the codes of the warnings printed after the first n, in order.
+func warningCodesSince(n int) []string {
+	var codes []string
+	for _, w := range warnings[n:] {
+		codes = append(codes, w.Code)
+	}
+	return codes
+}
+
+// failOnWarnings ends the run with exitWarnings if --strict is set and any warning was printed,
+// after listing the warnings that caused the failure.
+func failOnWarnings() error {
+	if !strict || len(warnings) == 0 {
+		return nil
+	}
+	errorMsg("strict_failed", len(warnings))
+	for _, w := range warnings {
+		errorMsg("strict_warning", w.Code, w.Text)
+	}
+	return exitWith(exitWarnings, nil)
+}
+
+// warningsFormat is the output format of the warnings subcommand: text or json.
+var warningsFormat string
+
+var warningsCmd = &cobra.Command{
+	Use:   "warnings",
+	Short: "List the codes of all warnings with their descriptions",
+	Args:  cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		switch warningsFormat {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(warningKinds)
+		case "text":
+			for _, kind := range warningKinds {
+				fmt.Printf("%s  %-22s %s\n", kind.Code, kind.Key, kind.Description)
+			}
+			return nil
+		}
+		return fmt.Errorf("invalid --format value '%s': must be text or json", warningsFormat)
+	},
+}
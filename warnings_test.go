@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// readWarningSnapshot returns the registry as recorded in testdata/warnings.json. The snapshot is
+// edited by hand together with warningKinds, so that a changed code shows up in review.
+func readWarningSnapshot(t *testing.T) []warningKind {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "warnings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []warningKind
+	if err = json.Unmarshal(data, &kinds); err != nil {
+		t.Fatal(err)
+	}
+	return kinds
+}
+
+// TestWarningRegistrySnapshot compares warningKinds with the snapshot. A code must keep its key
+// and meaning; a new kind of warning is appended with the next code, to both.
+func TestWarningRegistrySnapshot(t *testing.T) {
+	snapshot := readWarningSnapshot(t)
+	for i, want := range snapshot {
+		if i >= len(warningKinds) {
+			t.Errorf("%s '%s' was removed from warningKinds; codes are never removed", want.Code, want.Key)
+			continue
+		}
+		if got := warningKinds[i]; got != want {
+			t.Errorf("%s changed from %+v to %+v; give the new meaning a new code instead", want.Code, want, got)
+		}
+	}
+	for _, kind := range warningKinds[min(len(snapshot), len(warningKinds)):] {
+		t.Errorf("%s '%s' is not in testdata/warnings.json; add it there as well", kind.Code, kind.Key)
+	}
+}
+
+// TestWarningRegistry checks that the codes are numbered in order and that every warning has
+// a message in every language.
+func TestWarningRegistry(t *testing.T) {
+	entries, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]bool)
+	for i, kind := range warningKinds {
+		if want := fmt.Sprintf("W%03d", i+1); kind.Code != want {
+			t.Errorf("warning '%s' has code %s, want %s", kind.Key, kind.Code, want)
+		}
+		if keys[kind.Key] {
+			t.Errorf("warning '%s' has more than one code", kind.Key)
+		}
+		keys[kind.Key] = true
+		for _, entry := range entries {
+			lang := strings.TrimSuffix(entry.Name(), ".json")
+			if _, ok := loadCatalog(lang)[kind.Key]; !ok {
+				t.Errorf("%s '%s' has no message in %s", kind.Code, kind.Key, entry.Name())
+			}
+		}
+	}
+}
+
+// TestWarnMsgKeys checks that every warnMsg call of the program with a literal key uses a
+// registered key, which would otherwise panic at run time.
+func TestWarnMsgKeys(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	calls := 0
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			if ident, ok := call.Fun.(*ast.Ident); !ok || ident.Name != "warnMsg" {
+				return true
+			}
+			calls++
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			if key, _ := strconv.Unquote(lit.Value); warningCodes[key] == "" {
+				t.Errorf("%s: warning '%s' is not in warningKinds", fset.Position(call.Pos()), key)
+			}
+			return true
+		})
+	}
+	if calls == 0 {
+		t.Error("found no warnMsg call")
+	}
+}
+
+// TestWarningsCommand checks that the warnings subcommand prints the registry.
+func TestWarningsCommand(t *testing.T) {
+	output, err := runCommand(t, t.TempDir(), "warnings", "--format", "json")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	var kinds []warningKind
+	if err = json.Unmarshal([]byte(output), &kinds); err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	if len(kinds) != len(warningKinds) {
+		t.Fatalf("got %d warnings, want %d", len(kinds), len(warningKinds))
+	}
+	for i, kind := range kinds {
+		if kind != warningKinds[i] {
+			t.Errorf("got %+v, want %+v", kind, warningKinds[i])
+		}
+	}
+}
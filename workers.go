@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/souhup/pdf-spliter/splitter"
+)
+
+// isChapterFailure reports whether an export error ends the run. Timed-out and unreadable
+// chapters are skipped and listed at the end instead.
+func isChapterFailure(err error) bool {
+	return err != nil && !errors.Is(err, errChapterTimeout) && !errors.Is(err, errSourceUnreadable)
+}
+
+// writeChapterFile trims a chapter into a new file, retrying transient read errors.
+// The file only appears under its name once it is complete; a chapter that timed out or
+// could not be read leaves no file behind.
+// Parameters:
+//   - sourcePath: path of the source PDF file; pdfcpu reads through the file offset, so every
+//     chapter opens a handle of its own
+//   - doc: the source read once, or nil to read every attempt from the source file
+//   - path: path of the chapter file
+//   - title: chapter title for the retry messages
+//   - pageRange: pdfcpu page selection of the chapter
+//   - fixes: repairs to apply
+//   - stats: byte counter of the export
+//
+// Returns:
+//   - fixReport: what the fixes of the chapter did
+//   - error: errChapterTimeout, errSourceUnreadable or the export error
+func writeChapterFile(sourcePath string, doc *splitter.Document, path, title, pageRange string, fixes chapterFixes, stats *writeStats) (fixReport, error) {
+	var report fixReport
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return report, fmt.Errorf("open input file: %w", err)
+	}
+	defer source.Close()
+	outputFile, err := createOutput(path)
+	if err != nil {
+		return report, fmt.Errorf("create output file '%s': %w", path, err)
+	}
+	err = withReadRetries(source, title, func(source *os.File) error {
+		// Start every attempt with an empty output file
+		if err := outputFile.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := outputFile.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if chapterTimeout > 0 {
+			data, r, err := trimWithTimeout(source.Name(), doc, pageRange, fixes, chapterTimeout)
+			if err == nil {
+				_, err = outputWriter(outputFile, stats).Write(data)
+			}
+			report = r
+			return err
+		}
+		r, err := trimChapter(source, doc, outputWriter(outputFile, stats), pageRange, fixes)
+		report = r
+		return err
+	})
+	if err != nil {
+		// Remove the incomplete output
+		outputFile.discard()
+		return report, err
+	}
+	if err = outputFile.commit(); err != nil {
+		return report, fmt.Errorf("write output file '%s': %w", path, err)
+	}
+	return report, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// pageContents returns the content streams of every page of a written chapter, which do not
+// change with the time or order the chapter was written in, unlike its ID and dates.
+func pageContents(t *testing.T, path string) [][]byte {
+	t.Helper()
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make([][]byte, ctx.PageCount)
+	for page := 1; page <= ctx.PageCount; page++ {
+		d, _, _, err := ctx.PageDict(page, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contents[page-1], err = ctx.PageContent(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return contents
+}
+
+// manifestWithoutRun returns a JSON manifest without the fields that differ between runs of the
+// same split: the absolute path of the output directory and the time every chapter took.
+func manifestWithoutRun(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []map[string]any
+	if err = json.Unmarshal(data, &records); err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		delete(record, "path")
+		delete(record, "duration_ms")
+	}
+	data, err = json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestWorkersSameOutput splits the sections of the book fixture on one and on four workers,
+// which must write the same files with the same pages and the same manifest.
+func TestWorkersSameOutput(t *testing.T) {
+	dir := t.TempDir()
+	source, err := filepath.Abs(filepath.Join("testdata", "book.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []string{"1", "4"} {
+		if output, err := runCommand(t, dir, "-i", source, "-o", "workers-"+workers, "--depth=3",
+			"--workers", workers, "--manifest", filepath.Join(dir, "toc-"+workers+".json"), "--run-id", "workers",
+			"--sidecar-suffix=", "--bloat-factor=0"); err != nil {
+			t.Fatalf("--workers %s: %v\n%s", workers, err, output)
+		}
+	}
+
+	serial, err := os.ReadDir(filepath.Join(dir, "workers-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel, err := os.ReadDir(filepath.Join(dir, "workers-4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := manifestWithoutRun(t, filepath.Join(dir, "toc-4.json")), manifestWithoutRun(t, filepath.Join(dir, "toc-1.json")); !bytes.Equal(got, want) {
+		t.Errorf("got manifest\n%s\nwith four workers, want\n%s", got, want)
+	}
+	if len(serial) != 9 || len(parallel) != len(serial) {
+		t.Fatalf("got %d files with one worker and %d with four, want 9", len(serial), len(parallel))
+	}
+	for i, entry := range serial {
+		if parallel[i].Name() != entry.Name() {
+			t.Errorf("file %d: got %q with four workers, want %q", i+1, parallel[i].Name(), entry.Name())
+			continue
+		}
+		want := pageContents(t, filepath.Join(dir, "workers-1", entry.Name()))
+		got := pageContents(t, filepath.Join(dir, "workers-4", entry.Name()))
+		if len(got) != len(want) {
+			t.Errorf("%s: got %d pages with four workers, want %d", entry.Name(), len(got), len(want))
+			continue
+		}
+		for page := range want {
+			if !bytes.Equal(got[page], want[page]) {
+				t.Errorf("%s: page %d differs with four workers", entry.Name(), page+1)
+			}
+		}
+	}
+}